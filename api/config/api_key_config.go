@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+type APIKeyConfig struct {
+	// EncryptionKey encrypts the signing secrets of API keys at rest; CheckSecrets requires it
+	EncryptionKey string
+	// MaxClockSkew is how far the timestamp of a signed request may be from the server clock
+	MaxClockSkew time.Duration
+	// RotationGracePeriod is how long a rotated key keeps working next to its replacement
+	RotationGracePeriod time.Duration
+	// MaxKeysPerUser limits the active keys of a user
+	MaxKeysPerUser int
+	// MaxBodyBytes limits the body of a signed request, which is read whole to check the signature
+	MaxBodyBytes int64
+}
+
+func GetAPIKeyConfig() APIKeyConfig {
+	return APIKeyConfig{
+		EncryptionKey:       getEnvOrDefault("API_KEY_ENCRYPTION_KEY", ""),
+		MaxClockSkew:        time.Duration(getEnvInt("API_KEY_MAX_CLOCK_SKEW_SECONDS", 300)) * time.Second,
+		RotationGracePeriod: time.Duration(getEnvInt("API_KEY_ROTATION_GRACE_HOURS", 24)) * time.Hour,
+		MaxKeysPerUser:      getEnvInt("API_KEY_MAX_PER_USER", 5),
+		MaxBodyBytes:        int64(getEnvInt("API_KEY_MAX_BODY_BYTES", 1<<20)),
+	}
+}
@@ -0,0 +1,32 @@
+package config
+
+const (
+	CaptureModeOff    = "off"
+	CaptureModeMemory = "memory"
+	CaptureModeSpool  = "spool"
+)
+
+type CaptureConfig struct {
+	// Mode is "memory" or "spool" to store outgoing email and SMS instead of sending them
+	Mode string
+	// SpoolDir holds one JSON file per captured message in spool mode
+	SpoolDir string
+}
+
+// Enabled reports whether outgoing notifications are captured
+func (c CaptureConfig) Enabled() bool {
+	return c.Mode == CaptureModeMemory || c.Mode == CaptureModeSpool
+}
+
+func GetCaptureConfig() CaptureConfig {
+	mode := getEnvOrDefault("MAIL_CAPTURE", CaptureModeOff)
+	// Capturing in production would silently swallow every notification
+	if getEnvOrDefault("GIN_MODE", "debug") == "release" {
+		mode = CaptureModeOff
+	}
+
+	return CaptureConfig{
+		Mode:     mode,
+		SpoolDir: getEnvOrDefault("MAIL_CAPTURE_DIR", "tmp/mail"),
+	}
+}
@@ -41,6 +41,29 @@ var (
 	Redis *redis.Client
 )
 
+// retiredColumns lists columns whose model fields were removed; AutoMigrate never drops columns
+var retiredColumns = []struct {
+	model  interface{}
+	column string
+}{
+	// Per-user HMAC secrets were replaced by API keys
+	{&model.UserPassword{}, "hmac_secret"},
+}
+
+// DropRetiredColumns removes the retired columns that still exist
+func DropRetiredColumns(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, retired := range retiredColumns {
+		if !migrator.HasColumn(retired.model, retired.column) {
+			continue
+		}
+		if err := migrator.DropColumn(retired.model, retired.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func InitDB() {
 	// 檢查是否啟用自動遷移
 	autoMigrate := getEnvOrDefault("AUTO_MIGRATE", "true") == "true"
@@ -77,9 +100,29 @@ func InitDB() {
 		err = DB.AutoMigrate(
 			&model.Book{},
 			&model.Category{},
+			&model.Role{},
+			&model.Permission{},
 			&model.User{},
 			&model.UserPassword{},
+			&model.UserContact{},
+			&model.Session{},
+			&model.RefreshToken{},
+			&model.UserTOTP{},
+			&model.RecoveryCode{},
+			&model.PasswordResetToken{},
+			&model.OAuthClient{},
+			&model.OAuthAuthorizationCode{},
+			&model.OAuthRefreshToken{},
+			&model.OAuthConsent{},
+			&model.APIKey{},
 			&model.Account{},
+			&model.Transaction{},
+			&model.TransactionVerification{},
+			&model.OpenBankingConsent{},
+			&model.JournalEntry{},
+			&model.Posting{},
+			&model.IdempotencyRecord{},
+			&model.NotificationMessage{},
 			&model.Order{},
 			&model.OrderItem{},
 			&model.Cart{},
@@ -88,7 +131,14 @@ func InitDB() {
 		if err != nil {
 			log.Fatalf("Failed to migrate database: %v", err)
 		}
+		if err := DropRetiredColumns(DB); err != nil {
+			log.Fatalf("Failed to drop retired columns: %v", err)
+		}
 		log.Println("Database migration completed successfully")
+
+		if err := SeedRoles(DB); err != nil {
+			log.Fatalf("Failed to seed roles: %v", err)
+		}
 	} else {
 		log.Println("Skipping auto-migration (AUTO_MIGRATE=false)")
 	}
@@ -0,0 +1,26 @@
+package config
+
+import "time"
+
+type EmailVerificationConfig struct {
+	// TokenTTL is how long a verification link works
+	TokenTTL time.Duration
+	// URL is the page of the client that completes the verification; the token is appended as ?token=
+	URL string
+	// ResendInterval is the minimum time between two verification emails to the same user
+	ResendInterval time.Duration
+	// PurgeAfter is the age after which unverified registrations are deleted; zero keeps them
+	PurgeAfter time.Duration
+	// PurgeInterval is how often unverified registrations are looked for
+	PurgeInterval time.Duration
+}
+
+func GetEmailVerificationConfig() EmailVerificationConfig {
+	return EmailVerificationConfig{
+		TokenTTL:       time.Duration(getEnvInt("EMAIL_VERIFICATION_TOKEN_HOURS", 24)) * time.Hour,
+		URL:            getEnvOrDefault("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+		ResendInterval: time.Duration(getEnvInt("EMAIL_VERIFICATION_RESEND_SECONDS", 60)) * time.Second,
+		PurgeAfter:     time.Duration(getEnvInt("UNVERIFIED_USER_PURGE_DAYS", 7)) * 24 * time.Hour,
+		PurgeInterval:  time.Duration(getEnvInt("UNVERIFIED_USER_PURGE_INTERVAL_MINUTES", 60)) * time.Minute,
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+type JWTConfig struct {
+	// Current signs new tokens; Previous keys are only used to verify tokens issued before a rotation
+	Current  util.JWTKeyConfig
+	Previous []util.JWTKeyConfig
+	// AccessTokenTTL is short, since access tokens are only revoked through a Redis lookup
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// GetJWTConfig reads the signing keys. JWT_ALGORITHM selects HS256 (JWT_SECRET), RS256 or
+// EdDSA (JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_FILE). To rotate, move the old secret to
+// JWT_PREVIOUS_SECRETS or the old key file to JWT_PREVIOUS_KEY_FILES, and a JWT_KEY_ID it
+// had to JWT_PREVIOUS_KEY_IDS.
+func GetJWTConfig() JWTConfig {
+	accessMinutes, _ := strconv.Atoi(getEnvOrDefault("JWT_ACCESS_TOKEN_MINUTES", "15"))
+	if accessMinutes < 1 {
+		accessMinutes = 15
+	}
+	refreshHours, _ := strconv.Atoi(getEnvOrDefault("JWT_REFRESH_TOKEN_HOURS", "720"))
+	if refreshHours < 1 {
+		refreshHours = 720
+	}
+
+	jwtConfig := JWTConfig{
+		Current: util.JWTKeyConfig{
+			Algorithm: getEnvOrDefault("JWT_ALGORITHM", util.JWTAlgorithmHS256),
+			ID:        getEnvOrDefault("JWT_KEY_ID", ""),
+			Secret:    getEnvOrDefault("JWT_SECRET", ""),
+			// Inline keys usually have their newlines escaped in env files
+			PEM:     strings.ReplaceAll(getEnvOrDefault("JWT_PRIVATE_KEY", ""), `\n`, "\n"),
+			PEMFile: getEnvOrDefault("JWT_PRIVATE_KEY_FILE", ""),
+		},
+		AccessTokenTTL:  time.Duration(accessMinutes) * time.Minute,
+		RefreshTokenTTL: time.Duration(refreshHours) * time.Hour,
+	}
+
+	for _, secret := range splitList(getEnvOrDefault("JWT_PREVIOUS_SECRETS", "")) {
+		jwtConfig.Previous = append(jwtConfig.Previous, util.JWTKeyConfig{Algorithm: util.JWTAlgorithmHS256, Secret: secret})
+	}
+	for _, file := range splitList(getEnvOrDefault("JWT_PREVIOUS_KEY_FILES", "")) {
+		jwtConfig.Previous = append(jwtConfig.Previous, util.JWTKeyConfig{PEMFile: file})
+	}
+
+	// Tokens name their key by kid, so a previous key has to keep the kid it signed with.
+	// The IDs follow the order of the secrets, then the key files; an empty entry keeps
+	// the kid derived from the key.
+	keyIDs := splitPositionalList(getEnvOrDefault("JWT_PREVIOUS_KEY_IDS", ""))
+	if len(keyIDs) > len(jwtConfig.Previous) {
+		log.Printf("JWT_PREVIOUS_KEY_IDS lists %d key IDs for %d previous keys, ignoring the rest", len(keyIDs), len(jwtConfig.Previous))
+	}
+	for i := range jwtConfig.Previous {
+		if i < len(keyIDs) {
+			jwtConfig.Previous[i].ID = keyIDs[i]
+		}
+	}
+
+	return jwtConfig
+}
+
+// splitPositionalList splits a comma separated env value, keeping empty entries so each
+// item stays at the position it refers to
+func splitPositionalList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	items := strings.Split(value, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// splitList splits a comma separated env value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
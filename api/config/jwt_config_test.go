@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-gin-template/api/util"
+)
+
+// 輪替後舊金鑰依 JWT_PREVIOUS_KEY_IDS 保留原本的 kid，輪替前簽發的 token 仍可驗證
+func TestGetJWTConfigKeepsPreviousKeyIDs(t *testing.T) {
+	t.Setenv("JWT_ALGORITHM", util.JWTAlgorithmHS256)
+	t.Setenv("JWT_KEY_ID", "2025-01")
+	t.Setenv("JWT_SECRET", "old-secret")
+	t.Setenv("JWT_PREVIOUS_SECRETS", "")
+	t.Setenv("JWT_PREVIOUS_KEY_FILES", "")
+	t.Setenv("JWT_PREVIOUS_KEY_IDS", "")
+
+	before := GetJWTConfig()
+	oldManager, err := util.NewKeyManager(before.Current, before.Previous, time.Hour)
+	require.NoError(t, err)
+	oldToken, err := oldManager.Sign(&util.Claims{UserID: 1})
+	require.NoError(t, err)
+
+	t.Setenv("JWT_KEY_ID", "2025-02")
+	t.Setenv("JWT_SECRET", "new-secret")
+	t.Setenv("JWT_PREVIOUS_SECRETS", "older-secret, old-secret")
+	t.Setenv("JWT_PREVIOUS_KEY_IDS", ", 2025-01")
+
+	after := GetJWTConfig()
+	require.Len(t, after.Previous, 2)
+	assert.Empty(t, after.Previous[0].ID)
+	assert.Equal(t, "2025-01", after.Previous[1].ID)
+
+	manager, err := util.NewKeyManager(after.Current, after.Previous, time.Hour)
+	require.NoError(t, err)
+	assert.NoError(t, manager.Parse(oldToken, &util.Claims{}))
+}
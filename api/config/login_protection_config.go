@@ -0,0 +1,30 @@
+package config
+
+import "time"
+
+type LoginProtectionConfig struct {
+	// FailureWindow is how long failed attempts are remembered after the last one
+	FailureWindow time.Duration
+	// FreeAttempts is the number of failures per email before attempts are delayed
+	FreeAttempts int
+	// IPFreeAttempts is the number of failures per IP, over all emails, before attempts are delayed
+	IPFreeAttempts int
+	// BaseDelay is the first delay; it doubles with every further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold is the number of failures per email that locks the account
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+}
+
+func GetLoginProtectionConfig() LoginProtectionConfig {
+	return LoginProtectionConfig{
+		FailureWindow:    time.Duration(getEnvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15)) * time.Minute,
+		FreeAttempts:     getEnvInt("LOGIN_FREE_ATTEMPTS", 3),
+		IPFreeAttempts:   getEnvInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+		BaseDelay:        time.Duration(getEnvInt("LOGIN_BASE_DELAY_MS", 1000)) * time.Millisecond,
+		MaxDelay:         time.Duration(getEnvInt("LOGIN_MAX_DELAY_SECONDS", 30)) * time.Second,
+		LockoutThreshold: getEnvInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LockoutDuration:  time.Duration(getEnvInt("LOGIN_LOCKOUT_MINUTES", 15)) * time.Minute,
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+type NotificationConfig struct {
+	// Workers is the number of goroutines delivering outbox messages
+	Workers int
+	// PollInterval is how often the outbox is checked for due messages
+	PollInterval time.Duration
+	// LowBalanceThreshold triggers a low balance warning when a debit takes a balance below it
+	LowBalanceThreshold util.Money
+}
+
+func GetNotificationConfig() NotificationConfig {
+	workers, _ := strconv.Atoi(getEnvOrDefault("NOTIFICATION_WORKERS", "4"))
+	if workers < 1 {
+		workers = 1
+	}
+	pollMillis, _ := strconv.Atoi(getEnvOrDefault("NOTIFICATION_POLL_INTERVAL_MS", "1000"))
+	if pollMillis < 10 {
+		pollMillis = 1000
+	}
+
+	threshold, err := util.NewMoneyFromString(getEnvOrDefault("LOW_BALANCE_THRESHOLD", "100"))
+	if err != nil {
+		log.Printf("Invalid LOW_BALANCE_THRESHOLD, using 100: %v", err)
+		threshold = util.MustMoney("100")
+	}
+
+	return NotificationConfig{
+		Workers:             workers,
+		PollInterval:        time.Duration(pollMillis) * time.Millisecond,
+		LowBalanceThreshold: threshold,
+	}
+}
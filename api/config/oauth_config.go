@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+type OAuthConfig struct {
+	// CodeTTL is how long an authorization code can be exchanged
+	CodeTTL time.Duration
+	// RefreshTokenTTL is the lifetime of refresh tokens issued to third-party apps
+	RefreshTokenTTL time.Duration
+}
+
+func GetOAuthConfig() OAuthConfig {
+	return OAuthConfig{
+		CodeTTL:         time.Duration(getEnvInt("OAUTH_CODE_TTL_SECONDS", 60)) * time.Second,
+		RefreshTokenTTL: time.Duration(getEnvInt("OAUTH_REFRESH_TOKEN_HOURS", 720)) * time.Hour,
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+type OpenBankingConfig struct {
+	// AccountAccessMaxAge is the longest an account access consent can last
+	AccountAccessMaxAge time.Duration
+	// PaymentConsentTTL is how long a payment consent can be authorised and used
+	PaymentConsentTTL time.Duration
+}
+
+func GetOpenBankingConfig() OpenBankingConfig {
+	return OpenBankingConfig{
+		AccountAccessMaxAge: time.Duration(getEnvInt("OPEN_BANKING_ACCESS_MAX_DAYS", 90)) * 24 * time.Hour,
+		PaymentConsentTTL:   time.Duration(getEnvInt("OPEN_BANKING_PAYMENT_CONSENT_MINUTES", 30)) * time.Minute,
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strconv"
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+type PasswordConfig struct {
+	// Policy is the rule set for new passwords; its breached list is loaded from BreachedFile
+	Policy util.PasswordPolicy
+	// BreachedFile is an optional list of SHA-1 hashes of breached passwords, on top of the bundled one
+	BreachedFile string
+	// HistorySize is the number of recent passwords, the current one included, that cannot be reused
+	HistorySize int
+	// ResetTokenTTL is how long a password reset link works
+	ResetTokenTTL time.Duration
+	// ResetURL is the page of the client that completes a reset; the token is appended as ?token=
+	ResetURL string
+}
+
+func GetPasswordConfig() PasswordConfig {
+	historySize, err := strconv.Atoi(getEnvOrDefault("PASSWORD_HISTORY_SIZE", "5"))
+	if err != nil || historySize < 1 {
+		historySize = 5
+	}
+	resetMinutes, _ := strconv.Atoi(getEnvOrDefault("PASSWORD_RESET_TOKEN_MINUTES", "30"))
+	if resetMinutes < 1 {
+		resetMinutes = 30
+	}
+
+	return PasswordConfig{
+		Policy: util.PasswordPolicy{
+			MinLength:           getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			MinCharacterClasses: getEnvInt("PASSWORD_MIN_CHARACTER_CLASSES", 3),
+			RequireLowercase:    getEnvOrDefault("PASSWORD_REQUIRE_LOWERCASE", "false") == "true",
+			RequireUppercase:    getEnvOrDefault("PASSWORD_REQUIRE_UPPERCASE", "false") == "true",
+			RequireDigit:        getEnvOrDefault("PASSWORD_REQUIRE_DIGIT", "false") == "true",
+			RequireSymbol:       getEnvOrDefault("PASSWORD_REQUIRE_SYMBOL", "false") == "true",
+		},
+		BreachedFile:  getEnvOrDefault("PASSWORD_BREACHED_FILE", ""),
+		HistorySize:   historySize,
+		ResetTokenTTL: time.Duration(resetMinutes) * time.Minute,
+		ResetURL:      getEnvOrDefault("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnvOrDefault(key, strconv.Itoa(defaultValue)))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+)
+
+// defaultPermissions are created when missing, with their descriptions
+var defaultPermissions = []model.Permission{
+	{Name: model.PermissionBooksWrite, Description: "Create, update and delete books"},
+	{Name: model.PermissionLedgerReconcile, Description: "Reconcile account balances against the ledger"},
+	{Name: model.PermissionNotificationsManage, Description: "Inspect and replay outgoing notifications"},
+	{Name: model.PermissionUsersUnlock, Description: "Lift login lockouts"},
+	{Name: model.PermissionRolesManage, Description: "Manage roles and permissions and assign roles to users"},
+	{Name: model.PermissionUsersRead, Description: "View the profiles of other users"},
+	{Name: model.PermissionUsersWrite, Description: "Edit the profiles of other users"},
+	{Name: model.PermissionAccountsRead, Description: "View the accounts and transactions of other users"},
+}
+
+// defaultRolePermissions are granted to a role when it is created. Later changes made by
+// an admin are kept; only the admin role is topped up with every default permission.
+var defaultRolePermissions = map[string][]string{
+	model.RoleUser:   {},
+	model.RoleTeller: {model.PermissionUsersUnlock, model.PermissionLedgerReconcile, model.PermissionUsersRead, model.PermissionAccountsRead},
+}
+
+// SeedRoles creates the default permissions and the user, teller and admin roles, then
+// drops their cached permissions so what was granted applies right away
+func SeedRoles(db *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		permissions := make(map[string]model.Permission, len(defaultPermissions))
+		for _, defaultPermission := range defaultPermissions {
+			permission := model.Permission{Name: defaultPermission.Name}
+			err := tx.Where(&permission).
+				Attrs(model.Permission{Description: defaultPermission.Description}).
+				FirstOrCreate(&permission).Error
+			if err != nil {
+				return err
+			}
+			permissions[permission.Name] = permission
+		}
+
+		for name, granted := range defaultRolePermissions {
+			role := model.Role{Name: name}
+			result := tx.Where(&role).FirstOrCreate(&role)
+			if result.Error != nil {
+				return result.Error
+			}
+			// RowsAffected is zero when the role already existed
+			if result.RowsAffected == 0 || len(granted) == 0 {
+				continue
+			}
+
+			rolePermissions := make([]model.Permission, 0, len(granted))
+			for _, permissionName := range granted {
+				rolePermissions = append(rolePermissions, permissions[permissionName])
+			}
+			if err := tx.Model(&role).Association("Permissions").Append(&rolePermissions); err != nil {
+				return err
+			}
+		}
+
+		admin := model.Role{Name: model.RoleAdmin}
+		if err := tx.Where(&admin).FirstOrCreate(&admin).Error; err != nil {
+			return err
+		}
+		all := make([]model.Permission, 0, len(permissions))
+		for _, permission := range permissions {
+			all = append(all, permission)
+		}
+		return tx.Model(&admin).Association("Permissions").Append(&all)
+	})
+	if err != nil {
+		return err
+	}
+	return invalidateSeededRoles()
+}
+
+// invalidateSeededRoles drops the cached permissions of the seeded roles. Without it the
+// admin role would miss a newly added permission until the cache expires.
+func invalidateSeededRoles() error {
+	// Nothing can be cached by a process that has no Redis connection
+	if Redis == nil {
+		return nil
+	}
+
+	keys := []string{model.RolePermissionsCacheKey(model.RoleAdmin)}
+	for name := range defaultRolePermissions {
+		keys = append(keys, model.RolePermissionsCacheKey(name))
+	}
+	return Redis.Del(context.Background(), keys...).Err()
+}
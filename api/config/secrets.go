@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckSecrets fails when a key that protects stored codes or secrets is not set. Each key
+// needs a value of its own: falling back to JWT_SECRET would make a rotation of the signing
+// key lose everything hashed or sealed with it.
+func CheckSecrets() error {
+	type requiredSecret struct {
+		name  string
+		value string
+	}
+	secrets := []requiredSecret{
+		{"VERIFICATION_CODE_SECRET", GetVerificationConfig().CodeSecret},
+		{"TOTP_ENCRYPTION_KEY", GetTwoFactorConfig().EncryptionKey},
+		{"API_KEY_ENCRYPTION_KEY", GetAPIKeyConfig().EncryptionKey},
+	}
+	// The SMS auth token signs the delivery receipts of the provider
+	if smsConfig := GetSMSConfig(); smsConfig.ProviderURL != "" {
+		secrets = append(secrets, requiredSecret{"SMS_AUTH_TOKEN", smsConfig.AuthToken})
+	}
+
+	var missing []string
+	for _, secret := range secrets {
+		if secret.value == "" {
+			missing = append(missing, secret.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s must be set", strings.Join(missing, ", "))
+	}
+	return nil
+}
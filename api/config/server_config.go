@@ -0,0 +1,8 @@
+package config
+
+// GetTrustedProxies returns the proxies whose X-Forwarded-For header is believed when working
+// out the client IP. None are trusted by default, so the client IP is the address of the
+// connection and cannot be forged with a header.
+func GetTrustedProxies() []string {
+	return splitList(getEnvOrDefault("TRUSTED_PROXIES", ""))
+}
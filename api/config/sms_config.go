@@ -0,0 +1,46 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	SMSDialectTwilio  = "twilio"
+	SMSDialectWebhook = "webhook"
+)
+
+type SMSConfig struct {
+	// ProviderURL is the base URL of the provider API; SMS are only logged when it is empty
+	ProviderURL string
+	// Dialect selects the request format: "twilio" (form POST) or "webhook" (JSON)
+	Dialect string
+	// AccountSID and AuthToken authenticate against the provider. The webhook dialect
+	// sends AuthToken as a bearer token and uses it to verify delivery receipts.
+	AccountSID string
+	AuthToken  string
+	From       string
+	// DefaultCountryCode completes national numbers, e.g. 886 turns 0912345678 into +886912345678
+	DefaultCountryCode string
+	// StatusCallbackURL is the public URL of the delivery receipt endpoint
+	StatusCallbackURL string
+	Timeout           time.Duration
+}
+
+func GetSMSConfig() SMSConfig {
+	timeoutSeconds, _ := strconv.Atoi(getEnvOrDefault("SMS_TIMEOUT_SECONDS", "10"))
+	if timeoutSeconds < 1 {
+		timeoutSeconds = 10
+	}
+
+	return SMSConfig{
+		ProviderURL:        getEnvOrDefault("SMS_PROVIDER_URL", ""),
+		Dialect:            getEnvOrDefault("SMS_PROVIDER_DIALECT", SMSDialectTwilio),
+		AccountSID:         getEnvOrDefault("SMS_ACCOUNT_SID", ""),
+		AuthToken:          getEnvOrDefault("SMS_AUTH_TOKEN", ""),
+		From:               getEnvOrDefault("SMS_FROM", ""),
+		DefaultCountryCode: getEnvOrDefault("SMS_DEFAULT_COUNTRY_CODE", ""),
+		StatusCallbackURL:  getEnvOrDefault("SMS_STATUS_CALLBACK_URL", ""),
+		Timeout:            time.Duration(timeoutSeconds) * time.Second,
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+type TwoFactorConfig struct {
+	// Issuer is the account name shown in authenticator apps
+	Issuer string
+	// EncryptionKey encrypts TOTP secrets at rest; CheckSecrets requires it
+	EncryptionKey string
+	// ChallengeTTL is how long a password-verified login waits for the second factor
+	ChallengeTTL time.Duration
+}
+
+func GetTwoFactorConfig() TwoFactorConfig {
+	return TwoFactorConfig{
+		Issuer:        getEnvOrDefault("TOTP_ISSUER", "Go Gin Bank"),
+		EncryptionKey: getEnvOrDefault("TOTP_ENCRYPTION_KEY", ""),
+		ChallengeTTL:  5 * time.Minute,
+	}
+}
@@ -0,0 +1,12 @@
+package config
+
+type VerificationConfig struct {
+	// CodeSecret keys the hash of stored verification codes; CheckSecrets requires it
+	CodeSecret string
+}
+
+func GetVerificationConfig() VerificationConfig {
+	return VerificationConfig{
+		CodeSecret: getEnvOrDefault("VERIFICATION_CODE_SECRET", ""),
+	}
+}
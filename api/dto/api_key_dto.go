@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// APIKeyRequest represents the request body for issuing an API key
+// Used by: POST /users/me/api-keys
+type APIKeyRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"Payroll service"`
+}
+
+// APIKeyResponse represents an API key of the current user
+type APIKeyResponse struct {
+	ID    uint   `json:"id" example:"1"`
+	KeyID string `json:"key_id" example:"ak_Xw9bT3nLm5Rv7kq2"`
+	Name  string `json:"name" example:"Payroll service"`
+	// Secret is only returned when the key is issued or rotated
+	Secret     string     `json:"secret,omitempty" example:"Q2hlY2tzdW0gb2YgYSByYW5kb20gdG9rZW4"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// ExpiresAt is set on rotated keys, which keep working until then
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// APIKeyRotationResponse represents the key that replaces a rotated key
+// Used by: POST /users/me/api-keys/{id}/rotate
+type APIKeyRotationResponse struct {
+	APIKeyResponse
+	// PreviousKeyExpiresAt is when the rotated key stops working
+	PreviousKeyExpiresAt time.Time `json:"previous_key_expires_at"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+// ContactResponse describes one contact of the user; the value itself is masked
+type ContactResponse struct {
+	Channel    string     `json:"channel" example:"email"`
+	Hint       string     `json:"hint" example:"j***@example.com"`
+	Verified   bool       `json:"verified" example:"true"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+// ContactListResponse represents the response body for the user's contacts
+// Used by: GET /users/me/contacts, PUT /users/me/contacts/preferred
+type ContactListResponse struct {
+	PreferredChannel string            `json:"preferred_channel" example:"email"`
+	Contacts         []ContactResponse `json:"contacts"`
+}
+
+// PreferredChannelRequest represents the request body for choosing the preferred channel
+// Used by: PUT /users/me/contacts/preferred
+type PreferredChannelRequest struct {
+	Channel string `json:"channel" binding:"required,oneof=email sms" example:"sms"`
+}
+
+// ConfirmContactRequest represents the request body for confirming a phone number
+// Used by: POST /users/me/contacts/sms/confirm
+type ConfirmContactRequest struct {
+	Code string `json:"code" binding:"required,len=6" example:"123456"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "go-gin-template/api/util"
+
+// LedgerReconciliationResponse represents the result of checking an account balance against the ledger
+// Used by: GET /admin/accounts/{id}/reconcile
+type LedgerReconciliationResponse struct {
+	AccountID      uint       `json:"account_id" example:"1"`
+	CachedBalance  util.Money `json:"cached_balance" swaggertype:"string" example:"1000.50"`
+	LedgerBalance  util.Money `json:"ledger_balance" swaggertype:"string" example:"1000.50"`
+	RunningBalance util.Money `json:"running_balance" swaggertype:"string" example:"1000.50"`
+	PostingCount   int        `json:"posting_count" example:"12"`
+	Consistent     bool       `json:"consistent" example:"true"`
+}
@@ -0,0 +1,36 @@
+package dto
+
+import "time"
+
+// NotificationListQuery represents the query parameters for listing outbox messages
+// Used by: GET /admin/notifications
+type NotificationListQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending processing sent dead" example:"dead"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100" example:"20"`
+	Offset int    `form:"offset" binding:"omitempty,min=0" example:"0"`
+}
+
+// NotificationResponse represents the delivery status of one outbox message
+type NotificationResponse struct {
+	ID            uint       `json:"id" example:"1"`
+	Channel       string     `json:"channel" example:"email"`
+	Recipient     string     `json:"recipient" example:"j***@example.com"`
+	Status        string     `json:"status" example:"dead"`
+	Attempts      int        `json:"attempts" example:"8"`
+	MaxAttempts   int        `json:"max_attempts" example:"8"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty" example:"failed to send email: connection refused"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	// ProviderStatus is the delivery status reported by the SMS provider, if any
+	ProviderStatus string `json:"provider_status,omitempty" example:"delivered"`
+	ProviderError  string `json:"provider_error,omitempty" example:"30003"`
+	// Replayable is false for messages with one-time codes or links; they cannot be replayed
+	Replayable bool      `json:"replayable" example:"true"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NotificationListResponse represents a page of outbox messages
+type NotificationListResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	Total         int64                  `json:"total" example:"42"`
+}
@@ -0,0 +1,122 @@
+package dto
+
+import "time"
+
+// OAuthClientRequest represents the request body for registering a third-party app
+// Used by: POST /oauth/clients
+type OAuthClientRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"Budget Buddy"`
+	// Type is confidential for apps with a server that keeps the secret, public otherwise
+	Type string `json:"type" binding:"required,oneof=confidential public" example:"confidential"`
+	// RedirectURIs must match the redirect_uri of authorization requests exactly
+	RedirectURIs []string `json:"redirect_uris" binding:"dive,url" example:"https://budgetbuddy.example/callback"`
+	Scopes       []string `json:"scopes" binding:"required,min=1,dive,oneof=accounts:read transfers:write" example:"accounts:read"`
+}
+
+// OAuthClientResponse represents a registered third-party app
+type OAuthClientResponse struct {
+	ClientID string `json:"client_id" example:"7kq2Xw9bT3nLm5Rv"`
+	// ClientSecret is only returned when a confidential client is registered
+	ClientSecret string    `json:"client_secret,omitempty" example:"Q2hlY2tzdW0gb2YgYSByYW5kb20gdG9rZW4"`
+	Name         string    `json:"name" example:"Budget Buddy"`
+	Type         string    `json:"type" example:"confidential"`
+	RedirectURIs []string  `json:"redirect_uris" example:"https://budgetbuddy.example/callback"`
+	Scopes       []string  `json:"scopes" example:"accounts:read"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OAuthAuthorizeRequest represents an authorization request of a third-party app
+// Used by: GET /oauth/authorize (query)
+type OAuthAuthorizeRequest struct {
+	ResponseType string `form:"response_type" json:"response_type" binding:"required" example:"code"`
+	ClientID     string `form:"client_id" json:"client_id" binding:"required" example:"7kq2Xw9bT3nLm5Rv"`
+	// RedirectURI may be left out when the client registered a single one
+	RedirectURI string `form:"redirect_uri" json:"redirect_uri" example:"https://budgetbuddy.example/callback"`
+	// Scope is space separated; it defaults to every scope of the client
+	Scope               string `form:"scope" json:"scope" example:"accounts:read"`
+	State               string `form:"state" json:"state" example:"af0ifjsldkj"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge" binding:"required" example:"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method" binding:"required" example:"S256"`
+}
+
+// OAuthDecisionRequest represents the answer of the user on the consent screen
+// Used by: POST /oauth/authorize
+type OAuthDecisionRequest struct {
+	OAuthAuthorizeRequest
+	Approve bool `json:"approve" example:"true"`
+}
+
+// OAuthScopeResponse describes a scope on the consent screen
+type OAuthScopeResponse struct {
+	Name        string `json:"name" example:"accounts:read"`
+	Description string `json:"description" example:"View your accounts and their transactions"`
+	// Granted marks scopes the user already consented to
+	Granted bool `json:"granted" example:"false"`
+}
+
+// OAuthConsentResponse represents the consent screen of an authorization request
+type OAuthConsentResponse struct {
+	ClientID    string               `json:"client_id" example:"7kq2Xw9bT3nLm5Rv"`
+	ClientName  string               `json:"client_name" example:"Budget Buddy"`
+	RedirectURI string               `json:"redirect_uri" example:"https://budgetbuddy.example/callback"`
+	Scopes      []OAuthScopeResponse `json:"scopes"`
+	// ConsentRequired is false when every requested scope was granted before
+	ConsentRequired bool `json:"consent_required" example:"true"`
+}
+
+// OAuthDecisionResponse tells the user agent where to send the user next
+type OAuthDecisionResponse struct {
+	// RedirectURI carries the code and state, or the error when the user declined
+	RedirectURI string `json:"redirect_uri" example:"https://budgetbuddy.example/callback?code=SplxlOBeZQQYbYS6WxSbIA&state=af0ifjsldkj"`
+}
+
+// OAuthTokenRequest represents a token request (RFC 6749 section 4). Client credentials
+// may be sent with HTTP Basic authentication instead of the body.
+// Used by: POST /oauth/token
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type" binding:"required" example:"authorization_code"`
+	Code         string `form:"code" json:"code"`
+	RedirectURI  string `form:"redirect_uri" json:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier"`
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+	Scope        string `form:"scope" json:"scope"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+}
+
+// OAuthTokenResponse represents the tokens issued to a third-party app
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType   string `json:"token_type" example:"Bearer"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn    int    `json:"expires_in" example:"900"`
+	RefreshToken string `json:"refresh_token,omitempty" example:"Q2hlY2tzdW0gb2YgYSByYW5kb20gdG9rZW4"`
+	Scope        string `json:"scope" example:"accounts:read"`
+}
+
+// OAuthTokenActionRequest represents an introspection (RFC 7662) or revocation (RFC 7009) request
+// Used by: POST /oauth/introspect, POST /oauth/revoke
+type OAuthTokenActionRequest struct {
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint" example:"refresh_token"`
+	ClientID      string `form:"client_id" json:"client_id"`
+	ClientSecret  string `form:"client_secret" json:"client_secret"`
+}
+
+// OAuthIntrospectionResponse describes a token; only Active is set for unknown, expired
+// or revoked tokens
+type OAuthIntrospectionResponse struct {
+	Active    bool   `json:"active" example:"true"`
+	Scope     string `json:"scope,omitempty" example:"accounts:read"`
+	ClientID  string `json:"client_id,omitempty" example:"7kq2Xw9bT3nLm5Rv"`
+	Subject   string `json:"sub,omitempty" example:"42"`
+	TokenType string `json:"token_type,omitempty" example:"access_token"`
+	ExpiresAt int64  `json:"exp,omitempty" example:"1767225600"`
+	IssuedAt  int64  `json:"iat,omitempty" example:"1767224700"`
+}
+
+// OAuthErrorResponse represents an error of the OAuth endpoints (RFC 6749 section 5.2)
+type OAuthErrorResponse struct {
+	Error            string `json:"error" example:"invalid_grant"`
+	ErrorDescription string `json:"error_description,omitempty" example:"authorization code expired"`
+}
@@ -0,0 +1,81 @@
+package dto
+
+import (
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+// AccountAccessConsentRequest represents a request of a third-party app to read accounts
+// Used by: POST /open-banking/account-access-consents
+type AccountAccessConsentRequest struct {
+	Permissions []string `json:"permissions" binding:"required,min=1,dive,oneof=read_accounts read_balances read_transactions" example:"read_balances"`
+	// ExpiresAt defaults to the longest allowed age
+	ExpiresAt *time.Time `json:"expires_at" example:"2026-12-31T23:59:59Z"`
+}
+
+// PaymentConsentRequest represents a request of a third-party app to start one payment
+// Used by: POST /open-banking/payment-consents
+type PaymentConsentRequest struct {
+	DebtorAccountID   uint       `json:"debtor_account_id" binding:"required" example:"1"`
+	CreditorAccountID uint       `json:"creditor_account_id" binding:"required" example:"2"`
+	Amount            util.Money `json:"amount" binding:"money_positive,money_scale=Currency" swaggertype:"string" example:"25.00"`
+	Currency          string     `json:"currency" binding:"omitempty,currency" example:"USD"`
+	Reference         string     `json:"reference" binding:"max=140" example:"Invoice 2024-117"`
+}
+
+// AuthoriseConsentRequest represents the approval of a consent by the user
+// Used by: POST /open-banking/consents/{id}/authorise
+type AuthoriseConsentRequest struct {
+	// AccountIDs are the accounts shared under an account access consent; ignored for payments
+	AccountIDs []uint `json:"account_ids" example:"1"`
+}
+
+// ConsentResponse represents an open banking consent
+type ConsentResponse struct {
+	ID         uint   `json:"id" example:"1"`
+	Type       string `json:"type" example:"account_access"`
+	Status     string `json:"status" example:"authorised"`
+	ClientID   string `json:"client_id" example:"7kq2Xw9bT3nLm5Rv"`
+	ClientName string `json:"client_name" example:"Budget Buddy"`
+	// Permissions and AccountIDs are set on account access consents
+	Permissions []string `json:"permissions,omitempty" example:"read_balances"`
+	AccountIDs  []uint   `json:"account_ids,omitempty" example:"1"`
+	// The payment fields are set on payment consents
+	DebtorAccountID   *uint       `json:"debtor_account_id,omitempty" example:"1"`
+	CreditorAccountID *uint       `json:"creditor_account_id,omitempty" example:"2"`
+	Amount            *util.Money `json:"amount,omitempty" swaggertype:"string" example:"25.00"`
+	Currency          string      `json:"currency,omitempty" example:"USD"`
+	Reference         string      `json:"reference,omitempty" example:"Invoice 2024-117"`
+	TransactionID     *uint       `json:"transaction_id,omitempty" example:"42"`
+	// PaymentStatus is the status of the transfer once the payment was started
+	PaymentStatus string     `json:"payment_status,omitempty" example:"pending"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	AuthorisedAt  *time.Time `json:"authorised_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// OpenBankingAccountResponse represents an account shared under a consent
+type OpenBankingAccountResponse struct {
+	ID       uint   `json:"id" example:"1"`
+	Name     string `json:"name" example:"Savings Account"`
+	Currency string `json:"currency" example:"USD"`
+}
+
+// BalanceResponse represents the balance of an account shared under a consent
+type BalanceResponse struct {
+	AccountID uint       `json:"account_id" example:"1"`
+	Balance   util.Money `json:"balance" swaggertype:"string" example:"1000.50"`
+	Currency  string     `json:"currency" example:"USD"`
+}
+
+// PaymentResponse represents the transfer started under a payment consent
+type PaymentResponse struct {
+	ConsentID     uint       `json:"consent_id" example:"3"`
+	TransactionID uint       `json:"transaction_id" example:"42"`
+	Status        string     `json:"status" example:"pending"`
+	Amount        util.Money `json:"amount" swaggertype:"string" example:"25.00"`
+	Currency      string     `json:"currency" example:"USD"`
+	Message       string     `json:"message" example:"Payment initiated. The user must verify the transfer to complete it."`
+}
@@ -0,0 +1,42 @@
+package dto
+
+import "time"
+
+// RoleRequest represents the request body for creating or updating a role
+// Used by: POST /admin/roles, PUT /admin/roles/{id}
+type RoleRequest struct {
+	Name string `json:"name" binding:"required,max=50" example:"auditor"`
+	// Permissions replaces the permissions of the role; every name must exist
+	Permissions []string `json:"permissions" binding:"dive,permission_name" example:"ledger:reconcile"`
+}
+
+// RoleResponse represents a role and the permissions it grants
+type RoleResponse struct {
+	ID          uint      `json:"id" example:"2"`
+	Name        string    `json:"name" example:"teller"`
+	Permissions []string  `json:"permissions" example:"users:unlock"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PermissionRequest represents the request body for creating or updating a permission
+// Used by: POST /admin/permissions, PUT /admin/permissions/{id}
+type PermissionRequest struct {
+	Name        string `json:"name" binding:"required,max=100,permission_name" example:"books:write"`
+	Description string `json:"description" example:"Create, update and delete books"`
+}
+
+// PermissionResponse represents a permission
+type PermissionResponse struct {
+	ID          uint      `json:"id" example:"1"`
+	Name        string    `json:"name" example:"books:write"`
+	Description string    `json:"description" example:"Create, update and delete books"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AssignRoleRequest represents the request body for changing the role of a user
+// Used by: PUT /admin/users/{id}/role
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required" example:"teller"`
+}
@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+// SessionResponse represents one signed-in device
+type SessionResponse struct {
+	ID          string    `json:"id" example:"x4S0hV3k9Qm2T8bLr6Yp1w"`
+	DeviceLabel string    `json:"device_label" example:"Chrome on macOS"`
+	UserAgent   string    `json:"user_agent" example:"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) ..."`
+	IP          string    `json:"ip" example:"203.0.113.7"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	// Current marks the session of the token making the request
+	Current bool `json:"current" example:"true"`
+}
+
+// SessionListResponse represents the active sessions of a user
+// Used by: GET /users/me/sessions
+type SessionListResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
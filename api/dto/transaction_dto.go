@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+// TransactionHistoryQuery represents the query parameters for listing account transactions
+// Used by: GET /accounts/{id}/transactions
+type TransactionHistoryQuery struct {
+	Type      string     `form:"type" binding:"omitempty,oneof=transfer deposit withdraw" example:"transfer"`
+	Status    string     `form:"status" binding:"omitempty,oneof=pending verified completed failed canceled" example:"completed"`
+	From      *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" example:"2025-01-01T00:00:00Z"`
+	To        *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" example:"2025-12-31T23:59:59Z"`
+	MinAmount string     `form:"min_amount" example:"10.00"`
+	MaxAmount string     `form:"max_amount" example:"500.00"`
+	Cursor    string     `form:"cursor" example:"MTIz"`
+	Limit     int        `form:"limit" binding:"omitempty,min=1,max=100" example:"20"`
+}
+
+// CounterpartyResponse describes the other account involved in a transaction
+type CounterpartyResponse struct {
+	AccountID   uint   `json:"account_id" example:"2"`
+	AccountName string `json:"account_name" example:"Savings Account"`
+	OwnerName   string `json:"owner_name" example:"Jane Doe"`
+}
+
+// TransactionResponse represents a transaction from the point of view of one account
+type TransactionResponse struct {
+	ID          uint       `json:"id" example:"1"`
+	Type        string     `json:"type" example:"transfer"`
+	Status      string     `json:"status" example:"completed"`
+	Direction   string     `json:"direction" example:"outgoing"`
+	Amount      util.Money `json:"amount" swaggertype:"string" example:"100.50"`
+	Currency    string     `json:"currency" example:"USD"`
+	Description string     `json:"description" example:"Transfer"`
+	// Counterparty is empty for deposits and withdrawals
+	Counterparty *CounterpartyResponse `json:"counterparty,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+}
+
+// TransactionListResponse represents a page of transactions
+type TransactionListResponse struct {
+	Transactions []TransactionResponse `json:"transactions"`
+	// NextCursor is empty when there are no more results
+	NextCursor string `json:"next_cursor,omitempty" example:"MTIz"`
+}
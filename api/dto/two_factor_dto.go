@@ -0,0 +1,36 @@
+package dto
+
+// TwoFactorStatusResponse represents the two-factor settings of the current user
+// Used by: GET /users/me/2fa
+type TwoFactorStatusResponse struct {
+	Enabled bool `json:"enabled" example:"true"`
+	// RecoveryCodesRemaining is the number of unused recovery codes
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining" example:"10"`
+}
+
+// TOTPEnrollmentResponse represents a started authenticator enrollment
+// Used by: POST /users/me/2fa/totp
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	// OTPAuthURI is usually shown as a QR code for the authenticator app to scan
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/Go%20Gin%20Bank:user@example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=Go%20Gin%20Bank"`
+}
+
+// TwoFactorCodeRequest represents a code from the authenticator app, or a recovery code where accepted
+// Used by: POST /users/me/2fa/totp/confirm, POST /users/me/2fa/totp/disable, POST /users/me/2fa/recovery-codes
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required,max=20" example:"123456"`
+}
+
+// RecoveryCodesResponse represents newly issued recovery codes; they are not shown again
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k7p2m-x9c4d,q3w8e-r5t1y"`
+}
+
+// TwoFactorLoginRequest completes a login that returned a challenge token
+// Used by: POST /users/login/2fa
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// Code is a code from the authenticator app or an unused recovery code
+	Code string `json:"code" binding:"required,max=20" example:"123456"`
+}
@@ -1,18 +1,24 @@
 package dto
 
+import "go-gin-template/api/util"
+
 // RegisterRequest represents the request body for user registration
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email" example:"user@example.com"`
-	Password string `json:"password" binding:"required,min=6" example:"password123"`
+	// Password must satisfy the password policy, which is checked past binding
+	Password string `json:"password" binding:"required" example:"Correct-Horse-9"`
 	Name     string `json:"name" binding:"required" example:"John Doe"`
 	Phone    string `json:"phone" example:"1234567890"`
 	Address  string `json:"address" example:"123 Main St"`
+	Locale   string `json:"locale" binding:"omitempty,oneof=en zh-TW" example:"en"`
 }
 
 // LoginRequest represents the request body for user login
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email" example:"user@example.com"`
 	Password string `json:"password" binding:"required" example:"password123"`
+	// DeviceName labels the session in the session list; derived from the User-Agent when empty
+	DeviceName string `json:"device_name" binding:"omitempty,max=100" example:"Work laptop"`
 }
 
 // UpdateUserRequest represents the request body for updating user profile
@@ -20,6 +26,7 @@ type UpdateUserRequest struct {
 	Name    string `json:"name" example:"John Doe"`
 	Phone   string `json:"phone" example:"1234567890"`
 	Address string `json:"address" example:"123 Main St"`
+	Locale  string `json:"locale" binding:"omitempty,oneof=en zh-TW" example:"zh-TW"`
 }
 
 // UserResponse represents the response body for user information
@@ -30,52 +37,89 @@ type UserResponse struct {
 	Phone   string `json:"phone" example:"1234567890"`
 	Address string `json:"address" example:"123 Main St"`
 	Role    string `json:"role" example:"user"`
+	Locale  string `json:"locale" example:"en"`
+	// Status is pending_verification until the email address is verified, then active
+	Status string `json:"status" example:"active"`
 }
 
-// LoginResponse represents the response body for successful login
+// LoginResponse represents the response body for successful login. When the user has
+// two-factor authentication on, only MFARequired and ChallengeToken are set; the tokens
+// are returned by POST /users/login/2fa.
 type LoginResponse struct {
-	User  UserResponse `json:"user"`
-	Token string      `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	User *UserResponse `json:"user,omitempty"`
+	*TokenResponse
+	MFARequired bool `json:"mfa_required,omitempty" example:"false"`
+	// ChallengeToken is exchanged, together with a TOTP code, for the tokens
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+// TokenResponse represents an access token and the refresh token that renews it
+type TokenResponse struct {
+	Token        string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string `json:"refresh_token" example:"Q2hlY2tzdW0gb2YgYSByYW5kb20gdG9rZW4"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int `json:"expires_in" example:"900"`
+}
+
+// VerifyEmailRequest represents the request body for confirming an email address
+// Used by: POST /users/email/verify
+type VerifyEmailRequest struct {
+	// Token is the token query parameter of the link in the verification email
+	Token string `json:"token" binding:"required"`
+}
+
+// RefreshTokenRequest represents the request body for renewing an access token
+// Used by: POST /auth/refresh
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 // CreateAccountRequest represents the request body for creating a new account
 type CreateAccountRequest struct {
-	Name string `json:"name" binding:"required" example:"Savings Account"`
+	Name     string `json:"name" binding:"required" example:"Savings Account"`
+	Currency string `json:"currency" binding:"omitempty,currency" example:"USD"`
 }
 
 // AccountResponse represents the response body for account information
 type AccountResponse struct {
-	ID        uint    `json:"id" example:"1"`
-	UserID    uint    `json:"user_id" example:"1"`
-	Name      string  `json:"name" example:"Savings Account"`
-	Balance   float64 `json:"balance" example:"1000.50"`
-	IsDefault bool    `json:"is_default" example:"true"`
+	ID        uint       `json:"id" example:"1"`
+	UserID    uint       `json:"user_id" example:"1"`
+	Name      string     `json:"name" example:"Savings Account"`
+	Balance   util.Money `json:"balance" swaggertype:"string" example:"1000.50"`
+	Currency  string     `json:"currency" example:"USD"`
+	IsDefault bool       `json:"is_default" example:"true"`
 }
 
-// TransactionRequest represents the request body for deposit/withdrawal
+// TransactionRequest represents the request body for deposit/withdrawal.
+// Amounts are decimal strings; the number of decimals must fit the currency (USD when omitted).
 type TransactionRequest struct {
-	Amount float64 `json:"amount" binding:"required,gt=0" example:"100.50"`
+	Amount   util.Money `json:"amount" binding:"money_positive,money_scale=Currency" swaggertype:"string" example:"100.50"`
+	Currency string     `json:"currency" binding:"omitempty,currency" example:"USD"`
 }
 
 // TransferRequest represents the request body for transfer
 type TransferRequest struct {
-	Amount           float64 `json:"amount" binding:"required,gt=0" example:"100.50"`
-	TargetAccountID uint    `json:"target_account_id" binding:"required"`
+	Amount          util.Money `json:"amount" binding:"money_positive,money_scale=Currency" swaggertype:"string" example:"100.50"`
+	Currency        string     `json:"currency" binding:"omitempty,currency" example:"USD"`
+	TargetAccountID uint       `json:"target_account_id" binding:"required"`
 }
 
 // TransferInitRequest represents the request body for initiating transfer
 // Used by: POST /accounts/{id}/transfer/init
 type TransferInitRequest struct {
-	Amount           float64 `json:"amount" binding:"required,gt=0" example:"100.50"`
-	TargetAccountID uint    `json:"target_account_id" binding:"required"`
-	Description      string  `json:"description" example:"Payment for services"`
+	Amount          util.Money `json:"amount" binding:"money_positive,money_scale=Currency" swaggertype:"string" example:"100.50"`
+	Currency        string     `json:"currency" binding:"omitempty,currency" example:"USD"`
+	TargetAccountID uint       `json:"target_account_id" binding:"required"`
+	Description     string     `json:"description" example:"Payment for services"`
 }
 
-// VerificationRequest represents the request body for verification generation
+// VerificationRequest represents the request body for verification generation. The otp
+// type sends nothing; the code comes from the user's authenticator app.
 // Used by: POST /verifications
 type VerificationRequest struct {
 	TransactionID uint   `json:"transaction_id" binding:"required"`
-	Type          string `json:"type" binding:"required,oneof=email sms" example:"email"`
+	Type          string `json:"type" binding:"omitempty,oneof=email sms otp" example:"email"`
 }
 
 // VerificationVerifyRequest represents the request body for verification code verification
@@ -101,3 +145,23 @@ type VerificationResponse struct {
 	Type      string `json:"type"`
 	ExpiresAt string `json:"expires_at,omitempty"`
 }
+
+// ChangePasswordRequest represents the request body for changing the password
+// Used by: PUT /users/me/password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required" example:"password123"`
+	NewPassword     string `json:"new_password" binding:"required" example:"Correct-Horse-9"`
+}
+
+// ForgotPasswordRequest represents the request body for requesting a reset link
+// Used by: POST /users/password/forgot
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"user@example.com"`
+}
+
+// ResetPasswordRequest represents the request body for setting a new password with a reset token
+// Used by: POST /users/password/reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required" example:"Correct-Horse-9"`
+}
@@ -0,0 +1,81 @@
+package dto
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go-gin-template/api/util"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidations registers the custom binding tags used by the request DTOs:
+//   - currency:        the field is a supported ISO 4217 code
+//   - money_positive:  the util.Money field is greater than zero
+//   - money_scale=F:   the util.Money field has no more decimals than the currency in field F allows
+//   - permission_name: the field looks like "<resource>:<action>", e.g. books:write
+func RegisterValidations() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected binding validator engine")
+	}
+
+	// Report fields by their JSON or query name, which is what clients know them by
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" && name != "-" {
+				return name
+			}
+		}
+		return strings.ToLower(field.Name)
+	})
+
+	if err := v.RegisterValidation("currency", validateCurrency); err != nil {
+		return err
+	}
+	if err := v.RegisterValidation("money_positive", validateMoneyPositive); err != nil {
+		return err
+	}
+	if err := v.RegisterValidation("money_scale", validateMoneyScale); err != nil {
+		return err
+	}
+	return v.RegisterValidation("permission_name", validatePermissionName)
+}
+
+var permissionNamePattern = regexp.MustCompile(`^[a-z][a-z_]*:[a-z][a-z_]*$`)
+
+func validatePermissionName(fl validator.FieldLevel) bool {
+	return permissionNamePattern.MatchString(fl.Field().String())
+}
+
+func validateCurrency(fl validator.FieldLevel) bool {
+	return util.IsSupportedCurrency(fl.Field().String())
+}
+
+func validateMoneyPositive(fl validator.FieldLevel) bool {
+	amount, ok := fl.Field().Interface().(util.Money)
+	return ok && amount.IsPositive()
+}
+
+func validateMoneyScale(fl validator.FieldLevel) bool {
+	amount, ok := fl.Field().Interface().(util.Money)
+	if !ok {
+		return false
+	}
+
+	currency := util.DefaultCurrency
+	if name := fl.Param(); name != "" {
+		parent := fl.Parent()
+		if parent.Kind() == reflect.Ptr {
+			parent = parent.Elem()
+		}
+		if field := parent.FieldByName(name); field.IsValid() && field.String() != "" {
+			currency = field.String()
+		}
+	}
+
+	return amount.FitsCurrency(currency)
+}
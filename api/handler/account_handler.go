@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/policy"
 	"go-gin-template/api/service"
 	"net/http"
 	"strconv"
@@ -80,11 +83,14 @@ func (h *AccountHandler) GetAccounts(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer token"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Param id path int true "Account ID"
 // @Param request body dto.TransactionRequest true "Deposit request"
 // @Success 200 {object} dto.AccountResponse
 // @Failure 400 {object} dto.ErrorResponse
 // @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Request with the same Idempotency-Key still in progress"
+// @Failure 422 {object} dto.ErrorResponse "Idempotency-Key reused with a different payload"
 // @Router /accounts/{id}/deposit [post]
 func (h *AccountHandler) Deposit(c *gin.Context) {
 	userID := getUserIDFromContext(c)
@@ -100,7 +106,7 @@ func (h *AccountHandler) Deposit(c *gin.Context) {
 		return
 	}
 
-	account, err := h.accountService.Deposit(userID, uint(accountID), req.Amount)
+	account, err := h.accountService.Deposit(userID, uint(accountID), req.Amount, req.Currency)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -116,11 +122,14 @@ func (h *AccountHandler) Deposit(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer token"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Param id path int true "Account ID"
 // @Param request body dto.TransactionRequest true "Withdrawal request"
 // @Success 200 {object} dto.AccountResponse
 // @Failure 400 {object} dto.ErrorResponse
 // @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Request with the same Idempotency-Key still in progress"
+// @Failure 422 {object} dto.ErrorResponse "Idempotency-Key reused with a different payload"
 // @Router /accounts/{id}/withdraw [post]
 func (h *AccountHandler) Withdraw(c *gin.Context) {
 	userID := getUserIDFromContext(c)
@@ -136,7 +145,7 @@ func (h *AccountHandler) Withdraw(c *gin.Context) {
 		return
 	}
 
-	account, err := h.accountService.Withdraw(userID, uint(accountID), req.Amount)
+	account, err := h.accountService.Withdraw(userID, uint(accountID), req.Amount, req.Currency)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -151,11 +160,14 @@ func (h *AccountHandler) Withdraw(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param Authorization header string true "Bearer token"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Param id path int true "Source Account ID"
 // @Param request body dto.TransferRequest true "Transfer request"
 // @Success 200 {object} dto.AccountResponse
 // @Failure 400 {object} dto.ErrorResponse
 // @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Request with the same Idempotency-Key still in progress"
+// @Failure 422 {object} dto.ErrorResponse "Idempotency-Key reused with a different payload"
 // @Router /accounts/{id}/transfer [post]
 func (h *AccountHandler) Transfer(c *gin.Context) {
 	userID := getUserIDFromContext(c)
@@ -171,7 +183,7 @@ func (h *AccountHandler) Transfer(c *gin.Context) {
 		return
 	}
 
-	account, err := h.accountService.Transfer(userID, uint(sourceAccountID), req.TargetAccountID, req.Amount)
+	account, err := h.accountService.Transfer(userID, uint(sourceAccountID), req.TargetAccountID, req.Amount, req.Currency)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -206,7 +218,7 @@ func (h *AccountHandler) InitiateTransfer(c *gin.Context) {
 		return
 	}
 
-	transaction, err := h.accountService.InitiateTransfer(userID, uint(sourceAccountID), req.TargetAccountID, req.Amount)
+	transaction, err := h.accountService.InitiateTransfer(userID, uint(sourceAccountID), req.TargetAccountID, req.Amount, req.Currency, req.Description)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -216,6 +228,59 @@ func (h *AccountHandler) InitiateTransfer(c *gin.Context) {
 		"transaction_id": transaction.ID,
 		"status":         transaction.Status,
 		"amount":         transaction.Amount,
+		"currency":       transaction.Currency,
 		"message":        "Transfer initiated. Please generate verification code to complete the transfer.",
 	})
 }
+
+// GetTransactions godoc
+// @Summary List account transactions
+// @Description List the transactions of an account, newest first, with cursor pagination and filters
+// @Tags accounts
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Account ID"
+// @Param type query string false "Transaction type" Enums(transfer, deposit, withdraw)
+// @Param status query string false "Transaction status" Enums(pending, verified, completed, failed, canceled)
+// @Param from query string false "Created at or after (RFC 3339)"
+// @Param to query string false "Created at or before (RFC 3339)"
+// @Param min_amount query string false "Minimum amount"
+// @Param max_amount query string false "Maximum amount"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} dto.TransactionListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /accounts/{id}/transactions [get]
+func (h *AccountHandler) GetTransactions(c *gin.Context) {
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+		return
+	}
+
+	var query dto.TransactionHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subject, err := middleware.PolicySubject(c)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify permissions"})
+		return
+	}
+
+	transactions, err := h.accountService.GetTransactions(subject, uint(accountID), &query)
+	if errors.Is(err, policy.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account not found or access denied"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// ListKeys godoc
+// @Summary List my API keys
+// @Description List the API keys that are not revoked or expired. Secrets are not returned.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.APIKeyResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /users/me/api-keys [get]
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListKeys(getUserIDFromContext(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// IssueKey godoc
+// @Summary Issue an API key
+// @Description Issue a key for a service client. The secret is only returned once. The client signs each request with "Authorization: HMAC-SHA256 {key_id}:{signature}" and the X-Timestamp (Unix seconds) and X-Nonce headers, where the signature is the hex HMAC-SHA256, keyed with the secret, of the method, path with query string, timestamp, nonce and hex SHA-256 of the body, joined with newlines.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.APIKeyRequest true "Key name"
+// @Success 201 {object} dto.APIKeyResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /users/me/api-keys [post]
+func (h *APIKeyHandler) IssueKey(c *gin.Context) {
+	var req dto.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	key, err := h.apiKeyService.IssueKey(getUserIDFromContext(c), &req)
+	if err != nil {
+		c.Error(apiKeyError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// RotateKey godoc
+// @Summary Rotate an API key
+// @Description Issue a replacement key with a new secret. The rotated key keeps working until previous_key_expires_at.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 201 {object} dto.APIKeyRotationResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /users/me/api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid API key ID"))
+		return
+	}
+
+	key, err := h.apiKeyService.RotateKey(getUserIDFromContext(c), uint(id))
+	if err != nil {
+		c.Error(apiKeyError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// RevokeKey godoc
+// @Summary Revoke an API key
+// @Description Requests signed with the key are rejected from now on
+// @Tags users
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /users/me/api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid API key ID"))
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(getUserIDFromContext(c), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func apiKeyError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyLimit),
+		errors.Is(err, service.ErrAPIKeyNotRotatable):
+		return middleware.NewAppError(http.StatusConflict, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+	"go-gin-template/api/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	tokenService service.TokenService
+}
+
+func NewAuthHandler(tokenService service.TokenService) *AuthHandler {
+	return &AuthHandler{tokenService: tokenService}
+}
+
+// Refresh godoc
+// @Summary Refresh the access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body dto.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} dto.TokenResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req dto.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(req.RefreshToken)
+	if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+		c.Error(middleware.NewAppError(http.StatusUnauthorized, err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, service.ToTokenResponse(tokens))
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revoke the access token and the refresh tokens of the current session
+// @Tags auth
+// @Security BearerAuth
+// @Success 204
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims := c.MustGet("claims").(*util.Claims)
+	if err := h.tokenService.Logout(claims); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll godoc
+// @Summary Log out everywhere
+// @Description Revoke the tokens of every session of the current user
+// @Tags auth
+// @Security BearerAuth
+// @Success 204
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	if err := h.tokenService.LogoutAll(c.GetUint("userID")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListSessions godoc
+// @Summary List my sessions
+// @Description List the devices the current user is signed in on
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.SessionListResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /users/me/sessions [get]
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	claims := c.MustGet("claims").(*util.Claims)
+
+	sessions, err := h.tokenService.ListSessions(claims.UserID, claims.SessionID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Sign out a session
+// @Description Revoke the tokens of one of the current user's sessions
+// @Tags users
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 204
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /users/me/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	if err := h.tokenService.RevokeSession(c.GetUint("userID"), c.Param("id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/model"
+	"go-gin-template/api/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContactHandler struct {
+	contactService             service.ContactService
+	contactVerificationService service.ContactVerificationService
+}
+
+func NewContactHandler(contactService service.ContactService, contactVerificationService service.ContactVerificationService) *ContactHandler {
+	return &ContactHandler{
+		contactService:             contactService,
+		contactVerificationService: contactVerificationService,
+	}
+}
+
+// GetContacts godoc
+// @Summary Get my contacts
+// @Description List the channels verification codes can be sent to, with masked contact hints
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.ContactListResponse
+// @Failure 401 {object} object "Unauthorized"
+// @Router /users/me/contacts [get]
+func (h *ContactHandler) GetContacts(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+
+	contacts, err := h.contactService.GetContacts(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, contacts)
+}
+
+// SetPreferredChannel godoc
+// @Summary Set my preferred channel
+// @Description Choose whether verification codes are sent by email or SMS by default
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body dto.PreferredChannelRequest true "Preferred channel"
+// @Security BearerAuth
+// @Success 200 {object} dto.ContactListResponse
+// @Failure 400 {object} object "Invalid input, or no verified contact on file for the channel"
+// @Failure 401 {object} object "Unauthorized"
+// @Router /users/me/contacts/preferred [put]
+func (h *ContactHandler) SetPreferredChannel(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+
+	var req dto.PreferredChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	contacts, err := h.contactService.SetPreferredChannel(userID, model.ContactChannel(req.Channel))
+	if errors.Is(err, service.ErrContactUnavailable) || errors.Is(err, service.ErrContactUnverified) {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, contacts)
+}
+
+// SendSMSCode godoc
+// @Summary Send a code to my phone number
+// @Description Text a confirmation code to the phone number of the profile. Codes are only sent to a number once it is confirmed. A new code replaces the previous one and can be requested once per cooldown period.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse "No phone number on file"
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Phone number already confirmed"
+// @Failure 429 {object} dto.ErrorResponse
+// @Router /users/me/contacts/sms/verify [post]
+func (h *ContactHandler) SendSMSCode(c *gin.Context) {
+	contact, err := h.contactVerificationService.SendCode(getUserIDFromContext(c))
+	var cooldownErr *service.CooldownError
+	if errors.As(err, &cooldownErr) {
+		c.Header("Retry-After", strconv.Itoa(int(cooldownErr.RetryAfter.Seconds()+0.5)))
+		c.Error(middleware.NewAppError(http.StatusTooManyRequests, err.Error()))
+		return
+	}
+	if errors.Is(err, service.ErrContactUnavailable) {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+	if errors.Is(err, service.ErrContactAlreadyVerified) {
+		c.Error(middleware.NewAppError(http.StatusConflict, err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"sent_to": service.MaskContact(contact),
+		"message": "Confirmation code sent",
+	})
+}
+
+// ConfirmSMS godoc
+// @Summary Confirm my phone number
+// @Description Confirm the phone number of the profile with the code texted to it. Verification codes can then be sent by SMS.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body dto.ConfirmContactRequest true "Confirmation code"
+// @Security BearerAuth
+// @Success 200 {object} dto.ContactListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /users/me/contacts/sms/confirm [post]
+func (h *ContactHandler) ConfirmSMS(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+
+	var req dto.ConfirmContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err := h.contactVerificationService.Confirm(userID, req.Code)
+	if errors.Is(err, service.ErrInvalidContactCode) {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	contacts, err := h.contactService.GetContacts(userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, contacts)
+}
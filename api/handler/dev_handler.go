@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DevHandler serves development helpers. Its routes are only registered outside release mode.
+type DevHandler struct {
+	inbox service.Inbox
+}
+
+func NewDevHandler(inbox service.Inbox) *DevHandler {
+	return &DevHandler{inbox: inbox}
+}
+
+// ListInbox godoc
+// @Summary List captured notifications
+// @Description List email and SMS captured instead of sent, newest first. Only available when GIN_MODE is not release and MAIL_CAPTURE is set.
+// @Tags dev
+// @Produce json
+// @Param to query string false "Filter by recipient"
+// @Success 200 {object} map[string][]service.CapturedMessage
+// @Router /dev/inbox [get]
+func (h *DevHandler) ListInbox(c *gin.Context) {
+	messages, err := h.inbox.List(c.Query("to"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// GetInboxMessage godoc
+// @Summary Get a captured notification
+// @Tags dev
+// @Produce json
+// @Param id path string true "Captured message ID"
+// @Success 200 {object} service.CapturedMessage
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /dev/inbox/{id} [get]
+func (h *DevHandler) GetInboxMessage(c *gin.Context) {
+	message, err := h.inbox.Get(c.Param("id"))
+	if errors.Is(err, service.ErrCapturedMessageNotFound) {
+		c.Error(middleware.NotFoundError("Message"))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailVerificationHandler struct {
+	emailVerificationService service.EmailVerificationService
+}
+
+func NewEmailVerificationHandler(emailVerificationService service.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{emailVerificationService: emailVerificationService}
+}
+
+// VerifyEmail godoc
+// @Summary Confirm an email address
+// @Description Activate a new user with the token of the link in the verification email. The user's default account is opened; until then accounts cannot be opened and money cannot be moved.
+// @Tags users
+// @Accept json
+// @Param request body dto.VerifyEmailRequest true "Verification token"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /users/email/verify [post]
+func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err := h.emailVerificationService.Verify(req.Token)
+	if errors.Is(err, service.ErrInvalidEmailVerificationToken) {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResendVerification godoc
+// @Summary Resend the verification email
+// @Description Email a new verification link to the signed-in user. Earlier links keep working until they expire.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} map[string]string
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Router /users/me/email/verify/resend [post]
+func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
+	err := h.emailVerificationService.Resend(getUserIDFromContext(c))
+	if errors.Is(err, service.ErrEmailAlreadyVerified) {
+		c.Error(middleware.NewAppError(http.StatusConflict, err.Error()))
+		return
+	}
+	if errors.Is(err, service.ErrVerificationResendTooSoon) {
+		c.Error(middleware.NewAppError(http.StatusTooManyRequests, err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-gin-template/api/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	keyManager *util.KeyManager
+}
+
+func NewJWKSHandler(keyManager *util.KeyManager) *JWKSHandler {
+	return &JWKSHandler{keyManager: keyManager}
+}
+
+// GetJWKS godoc
+// @Summary Get the token signing keys
+// @Description Public keys other services use to verify access tokens, matched by the kid header. Empty when tokens are signed with HS256.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} util.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	// Verifiers may cache the keys briefly; rotated keys stay published while previous keys are accepted
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keyManager.JWKS())
+}
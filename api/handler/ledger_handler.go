@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	ledgerService service.LedgerService
+}
+
+func NewLedgerHandler(ledgerService service.LedgerService) *LedgerHandler {
+	return &LedgerHandler{ledgerService: ledgerService}
+}
+
+// ReconcileAccount godoc
+// @Summary Reconcile account balance
+// @Description Compare the cached account balance with the sum of its ledger postings
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Account ID"
+// @Success 200 {object} dto.LedgerReconciliationResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /admin/accounts/{id}/reconcile [get]
+func (h *LedgerHandler) ReconcileAccount(c *gin.Context) {
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("invalid account ID"))
+		return
+	}
+
+	result, err := h.ledgerService.Reconcile(uint(accountID))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.LedgerReconciliationResponse{
+		AccountID:      result.AccountID,
+		CachedBalance:  result.CachedBalance,
+		LedgerBalance:  result.LedgerBalance,
+		RunningBalance: result.RunningBalance,
+		PostingCount:   result.PostingCount,
+		Consistent:     result.Consistent,
+	})
+}
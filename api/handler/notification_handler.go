@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"errors"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/service"
+	"go-gin-template/api/util"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultNotificationPageSize = 20
+
+type NotificationHandler struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(notificationService service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// ListNotifications godoc
+// @Summary List outbox notifications
+// @Description List queued, sent and dead-lettered notifications with their delivery status
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (pending, processing, sent, dead)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of notifications to skip"
+// @Success 200 {object} dto.NotificationListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /admin/notifications [get]
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	var query dto.NotificationListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultNotificationPageSize
+	}
+
+	messages, total, err := h.notificationService.ListMessages(model.NotificationStatus(query.Status), query.Limit, query.Offset)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := dto.NotificationListResponse{
+		Notifications: make([]dto.NotificationResponse, 0, len(messages)),
+		Total:         total,
+	}
+	for _, message := range messages {
+		response.Notifications = append(response.Notifications, toNotificationResponse(message))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetNotification godoc
+// @Summary Get notification delivery status
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Notification ID"
+// @Success 200 {object} dto.NotificationResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /admin/notifications/{id} [get]
+func (h *NotificationHandler) GetNotification(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("invalid notification ID"))
+		return
+	}
+
+	message, err := h.notificationService.GetMessage(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toNotificationResponse(message))
+}
+
+// ReplayNotification godoc
+// @Summary Replay a dead notification
+// @Description Queue a dead-lettered notification for delivery again with a fresh set of attempts. Messages with one-time codes or links cannot be replayed.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Notification ID"
+// @Success 200 {object} dto.NotificationResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /admin/notifications/{id}/replay [post]
+func (h *NotificationHandler) ReplayNotification(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("invalid notification ID"))
+		return
+	}
+
+	message, err := h.notificationService.ReplayMessage(uint(id))
+	if errors.Is(err, repository.ErrNotificationNotDead) || errors.Is(err, repository.ErrNotificationExpired) {
+		c.Error(middleware.NewAppError(http.StatusConflict, err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toNotificationResponse(message))
+}
+
+// ReceiveSMSReceipt godoc
+// @Summary Receive an SMS delivery receipt
+// @Description Status callback of the SMS provider. The request must carry the provider signature.
+// @Tags notifications
+// @Accept x-www-form-urlencoded,json
+// @Produce json
+// @Param ref query int false "Notification ID (Twilio dialect)"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /notifications/sms/receipts [post]
+func (h *NotificationHandler) ReceiveSMSReceipt(c *gin.Context) {
+	sender, err := h.notificationService.GetSender(string(model.ContactChannelSMS))
+	if err != nil {
+		c.Error(middleware.NotFoundError("SMS provider"))
+		return
+	}
+	parser, ok := sender.(service.DeliveryReceiptParser)
+	if !ok {
+		c.Error(middleware.NotFoundError("SMS provider"))
+		return
+	}
+
+	receipt, err := parser.ParseDeliveryReceipt(c.Request)
+	if errors.Is(err, service.ErrInvalidReceiptSignature) {
+		c.Error(middleware.NewAppError(http.StatusUnauthorized, err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+
+	if err := h.notificationService.RecordDeliveryReceipt(receipt); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func toNotificationResponse(message *model.NotificationMessage) dto.NotificationResponse {
+	recipient := util.MaskEmail(message.Recipient)
+	if message.Channel == string(model.ContactChannelSMS) {
+		recipient = util.MaskPhone(message.Recipient)
+	}
+
+	return dto.NotificationResponse{
+		ID:             message.ID,
+		Channel:        message.Channel,
+		Recipient:      recipient,
+		Status:         string(message.Status),
+		Attempts:       message.Attempts,
+		MaxAttempts:    message.MaxAttempts,
+		NextAttemptAt:  message.NextAttemptAt,
+		LastError:      message.LastError,
+		SentAt:         message.SentAt,
+		ProviderStatus: message.ProviderStatus,
+		ProviderError:  message.ProviderError,
+		Replayable:     message.Replayable,
+		CreatedAt:      message.CreatedAt,
+	}
+}
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthHandler struct {
+	oauthService service.OAuthService
+}
+
+func NewOAuthHandler(oauthService service.OAuthService) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthService}
+}
+
+// RegisterClient godoc
+// @Summary Register a third-party app
+// @Description Register an app that acts for users with the scopes they consent to. The secret of a confidential client is only shown in this response.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.OAuthClientRequest true "Client"
+// @Success 201 {object} dto.OAuthClientResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /oauth/clients [post]
+func (h *OAuthHandler) RegisterClient(c *gin.Context) {
+	var req dto.OAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	client, err := h.oauthService.RegisterClient(c.GetUint("userID"), &req)
+	if errors.Is(err, service.ErrPublicClientRedirectURI) || errors.Is(err, service.ErrRedirectURIFragment) {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, client)
+}
+
+// ListClients godoc
+// @Summary List my third-party apps
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.OAuthClientResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /oauth/clients [get]
+func (h *OAuthHandler) ListClients(c *gin.Context) {
+	clients, err := h.oauthService.ListClients(c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// DeleteClient godoc
+// @Summary Delete a third-party app
+// @Description Delete one of my apps and revoke every token issued to it
+// @Tags oauth
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Success 204
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /oauth/clients/{client_id} [delete]
+func (h *OAuthHandler) DeleteClient(c *gin.Context) {
+	if err := h.oauthService.DeleteClient(c.GetUint("userID"), c.Param("client_id")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Consent godoc
+// @Summary Show the consent screen
+// @Description Validate an authorization request and describe the app and the scopes it asks for. PKCE with S256 is required.
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string false "Registered redirect URI"
+// @Param scope query string false "Space separated scopes"
+// @Param state query string false "Opaque value returned to the client"
+// @Param code_challenge query string true "S256 PKCE challenge"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 200 {object} dto.OAuthConsentResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /oauth/authorize [get]
+func (h *OAuthHandler) Consent(c *gin.Context) {
+	var req dto.OAuthAuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	consent, err := h.oauthService.Consent(c.GetUint("userID"), &req)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, consent)
+}
+
+// Authorize godoc
+// @Summary Approve or decline an authorization request
+// @Description Record the decision of the user. The response names the redirect URI of the app, with an authorization code or with error=access_denied.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.OAuthDecisionRequest true "Authorization request and decision"
+// @Success 200 {object} dto.OAuthDecisionResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /oauth/authorize [post]
+func (h *OAuthHandler) Authorize(c *gin.Context) {
+	var req dto.OAuthDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	decision, err := h.oauthService.Authorize(c.GetUint("userID"), &req)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, decision)
+}
+
+// Token godoc
+// @Summary Issue tokens to a third-party app
+// @Description Run the authorization_code, refresh_token or client_credentials grant. Confidential clients authenticate with HTTP Basic or client_id and client_secret in the body.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param request body dto.OAuthTokenRequest true "Token request"
+// @Success 200 {object} dto.OAuthTokenResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	var req dto.OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	tokens, err := h.oauthService.Token(clientCredentials(c, req.ClientID, req.ClientSecret), &req)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Introspect godoc
+// @Summary Introspect a token
+// @Description Describe an access or refresh token issued to the calling confidential client (RFC 7662)
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param request body dto.OAuthTokenActionRequest true "Token"
+// @Success 200 {object} dto.OAuthIntrospectionResponse
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	var req dto.OAuthTokenActionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	introspection, err := h.oauthService.Introspect(clientCredentials(c, req.ClientID, req.ClientSecret), &req)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, introspection)
+}
+
+// Revoke godoc
+// @Summary Revoke a token
+// @Description Revoke an access or refresh token issued to the calling client (RFC 7009). Revoking a refresh token ends the whole grant. Unknown tokens are accepted.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Param request body dto.OAuthTokenActionRequest true "Token"
+// @Success 200
+// @Failure 400 {object} dto.OAuthErrorResponse
+// @Failure 401 {object} dto.OAuthErrorResponse
+// @Router /oauth/revoke [post]
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	var req dto.OAuthTokenActionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	if err := h.oauthService.Revoke(clientCredentials(c, req.ClientID, req.ClientSecret), &req); err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// clientCredentials prefers HTTP Basic authentication, whose parts are form encoded
+// (RFC 6749 section 2.3.1), over credentials in the body
+func clientCredentials(c *gin.Context, clientID, clientSecret string) service.OAuthClientCredentials {
+	if user, password, ok := c.Request.BasicAuth(); ok {
+		if id, err := url.QueryUnescape(user); err == nil {
+			user = id
+		}
+		if secret, err := url.QueryUnescape(password); err == nil {
+			password = secret
+		}
+		return service.OAuthClientCredentials{ClientID: user, ClientSecret: password}
+	}
+	return service.OAuthClientCredentials{ClientID: clientID, ClientSecret: clientSecret}
+}
+
+// respondOAuthError answers OAuth errors in the format of RFC 6749 section 5.2 and hands
+// any other error to the error interceptor
+func respondOAuthError(c *gin.Context, err error) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.Error(err)
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == service.OAuthInvalidClient {
+		status = http.StatusUnauthorized
+		if _, _, basic := c.Request.BasicAuth(); basic {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, dto.OAuthErrorResponse{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+}
@@ -0,0 +1,400 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/policy"
+	"go-gin-template/api/service"
+	"go-gin-template/api/util"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OpenBankingHandler struct {
+	openBankingService service.OpenBankingService
+}
+
+func NewOpenBankingHandler(openBankingService service.OpenBankingService) *OpenBankingHandler {
+	return &OpenBankingHandler{openBankingService: openBankingService}
+}
+
+// CreateAccountAccessConsent godoc
+// @Summary Request access to accounts
+// @Description Create an account access consent for the user the token acts for. The app can read accounts once the user authorises the consent and picks the accounts to share.
+// @Tags open-banking
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.AccountAccessConsentRequest true "Permissions and expiry"
+// @Success 201 {object} dto.ConsentResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /open-banking/account-access-consents [post]
+func (h *OpenBankingHandler) CreateAccountAccessConsent(c *gin.Context) {
+	var req dto.AccountAccessConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	consent, err := h.openBankingService.CreateAccountAccessConsent(thirdParty(c), &req)
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, consent)
+}
+
+// CreatePaymentConsent godoc
+// @Summary Request to start a payment
+// @Description Create a payment consent for a transfer out of an account of the user the token acts for. The payment can be started once the user authorises the consent.
+// @Tags open-banking
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.PaymentConsentRequest true "Payment"
+// @Success 201 {object} dto.ConsentResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /open-banking/payment-consents [post]
+func (h *OpenBankingHandler) CreatePaymentConsent(c *gin.Context) {
+	var req dto.PaymentConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	consent, err := h.openBankingService.CreatePaymentConsent(thirdParty(c), &req)
+	if err != nil {
+		c.Error(paymentError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, consent)
+}
+
+// GetConsent godoc
+// @Summary Get a consent of the app
+// @Description Follow the status of a consent the app created, and of the payment started under it
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Success 200 {object} dto.ConsentResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /open-banking/account-access-consents/{id} [get]
+// @Router /open-banking/payment-consents/{id} [get]
+func (h *OpenBankingHandler) GetConsent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+
+	consent, err := h.openBankingService.GetConsent(thirdParty(c), uint(id))
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, consent)
+}
+
+// ListAccounts godoc
+// @Summary List shared accounts
+// @Description List the accounts the user shared under an account access consent with read_accounts
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Success 200 {array} dto.OpenBankingAccountResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /open-banking/account-access-consents/{id}/accounts [get]
+func (h *OpenBankingHandler) ListAccounts(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+
+	accounts, err := h.openBankingService.ListAccounts(thirdParty(c), uint(id))
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
+
+// GetBalance godoc
+// @Summary Get the balance of a shared account
+// @Description Requires an account access consent with read_balances that shares the account
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Param account_id path int true "Account ID"
+// @Success 200 {object} dto.BalanceResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /open-banking/account-access-consents/{id}/accounts/{account_id}/balance [get]
+func (h *OpenBankingHandler) GetBalance(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+	accountID, err := strconv.ParseUint(c.Param("account_id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid account ID"))
+		return
+	}
+
+	balance, err := h.openBankingService.GetBalance(thirdParty(c), uint(id), uint(accountID))
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+// GetTransactions godoc
+// @Summary List the transactions of a shared account
+// @Description Requires an account access consent with read_transactions that shares the account. Takes the filters and pagination of GET /accounts/{id}/transactions.
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Param account_id path int true "Account ID"
+// @Param type query string false "Transaction type" Enums(transfer, deposit, withdraw)
+// @Param status query string false "Transaction status" Enums(pending, verified, completed, failed, canceled)
+// @Param from query string false "Created at or after (RFC 3339)"
+// @Param to query string false "Created at or before (RFC 3339)"
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} dto.TransactionListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /open-banking/account-access-consents/{id}/accounts/{account_id}/transactions [get]
+func (h *OpenBankingHandler) GetTransactions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+	accountID, err := strconv.ParseUint(c.Param("account_id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid account ID"))
+		return
+	}
+
+	var query dto.TransactionHistoryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(err)
+		return
+	}
+
+	transactions, err := h.openBankingService.GetTransactions(thirdParty(c), uint(id), uint(accountID), &query)
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+// CreatePayment godoc
+// @Summary Start the payment of a consent
+// @Description Start the payment of an authorised payment consent as a pending transfer. The user completes it through the verification endpoints. A consent pays once.
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Success 201 {object} dto.PaymentResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /open-banking/payment-consents/{id}/payments [post]
+func (h *OpenBankingHandler) CreatePayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+
+	payment, err := h.openBankingService.CreatePayment(thirdParty(c), uint(id))
+	if err != nil {
+		c.Error(paymentError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+// ListConsents godoc
+// @Summary List my open banking consents
+// @Description List the consents third-party apps asked for, newest first
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.ConsentResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /open-banking/consents [get]
+func (h *OpenBankingHandler) ListConsents(c *gin.Context) {
+	consents, err := h.openBankingService.ListConsents(c.GetUint("userID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, consents)
+}
+
+// AuthoriseConsent godoc
+// @Summary Authorise a consent
+// @Description Approve a consent an app asked for. Account access consents share the given accounts of the user.
+// @Tags open-banking
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Param request body dto.AuthoriseConsentRequest false "Accounts to share"
+// @Success 200 {object} dto.ConsentResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /open-banking/consents/{id}/authorise [post]
+func (h *OpenBankingHandler) AuthoriseConsent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+
+	// Payment consents are authorised without a body
+	var req dto.AuthoriseConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(err)
+		return
+	}
+
+	consent, err := h.openBankingService.AuthoriseConsent(c.GetUint("userID"), uint(id), &req)
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, consent)
+}
+
+// RejectConsent godoc
+// @Summary Reject a consent
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Success 200 {object} dto.ConsentResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /open-banking/consents/{id}/reject [post]
+func (h *OpenBankingHandler) RejectConsent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+
+	consent, err := h.openBankingService.RejectConsent(c.GetUint("userID"), uint(id))
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, consent)
+}
+
+// RevokeConsent godoc
+// @Summary Revoke a consent
+// @Description Withdraw an authorised consent; the app loses access at once
+// @Tags open-banking
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Consent ID"
+// @Success 200 {object} dto.ConsentResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /open-banking/consents/{id} [delete]
+func (h *OpenBankingHandler) RevokeConsent(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid consent ID"))
+		return
+	}
+
+	consent, err := h.openBankingService.RevokeConsent(c.GetUint("userID"), uint(id))
+	if err != nil {
+		c.Error(consentError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, consent)
+}
+
+// thirdParty identifies the app and the user from the token checked by ThirdPartyGuard
+func thirdParty(c *gin.Context) service.ThirdParty {
+	claims := c.MustGet("claims").(*util.Claims)
+	return service.ThirdParty{ClientID: claims.ClientID, UserID: claims.UserID}
+}
+
+func consentError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrConsentNotActive),
+		errors.Is(err, service.ErrConsentPermission),
+		errors.Is(err, service.ErrConsentAccount):
+		return middleware.NewAppError(http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrConsentFinal):
+		return middleware.NewAppError(http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrConsentAccountsRequired),
+		errors.Is(err, service.ErrConsentExpiry):
+		return middleware.BadRequestError(err.Error())
+	}
+	return err
+}
+
+// paymentError reports the checks of a payment, like those of InitiateTransfer, as bad requests
+func paymentError(err error) error {
+	mapped := consentError(err)
+	var appErr *middleware.AppError
+	if errors.As(mapped, &appErr) || errors.Is(mapped, policy.ErrForbidden) || errors.Is(mapped, gorm.ErrRecordNotFound) {
+		return mapped
+	}
+	return middleware.BadRequestError(err.Error())
+}
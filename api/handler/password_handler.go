@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+	"go-gin-template/api/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	passwordService service.PasswordService
+}
+
+func NewPasswordHandler(passwordService service.PasswordService) *PasswordHandler {
+	return &PasswordHandler{passwordService: passwordService}
+}
+
+// ChangePassword godoc
+// @Summary Change my password
+// @Description Replace the password after checking the current one. Recently used passwords are rejected. All sessions, the current one included, are signed out.
+// @Tags users
+// @Accept json
+// @Param request body dto.ChangePasswordRequest true "Current and new password"
+// @Security BearerAuth
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /users/me/password [put]
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	var req dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err := h.passwordService.ChangePassword(getUserIDFromContext(c), req.CurrentPassword, req.NewPassword)
+	var policyErr *util.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.Error(middleware.NewFieldErrors("new_password", policyErr.Violations...))
+		return
+	}
+	if errors.Is(err, service.ErrIncorrectPassword) || errors.Is(err, service.ErrPasswordReused) {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset link
+// @Description Email a single-use link to reset the password. The response is the same whether or not the email belongs to an account.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body dto.ForgotPasswordRequest true "Account email"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /users/password/forgot [post]
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.passwordService.RequestReset(req.Email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email belongs to an account, a reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset the password
+// @Description Set a new password with the token of a reset link. All sessions are signed out.
+// @Tags users
+// @Accept json
+// @Param request body dto.ResetPasswordRequest true "Reset token and new password"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Router /users/password/reset [post]
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err := h.passwordService.ResetPassword(req.Token, req.NewPassword)
+	var policyErr *util.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.Error(middleware.NewFieldErrors("new_password", policyErr.Violations...))
+		return
+	}
+	if errors.Is(err, service.ErrInvalidResetToken) || errors.Is(err, service.ErrPasswordReused) {
+		c.Error(middleware.BadRequestError(err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
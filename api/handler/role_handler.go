@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	roleService service.RoleService
+}
+
+func NewRoleHandler(roleService service.RoleService) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+// ListRoles godoc
+// @Summary List roles
+// @Description List all roles with the permissions they grant
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.RoleResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /admin/roles [get]
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roleService.ListRoles()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// GetRole godoc
+// @Summary Get a role
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Success 200 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /admin/roles/{id} [get]
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid role ID"))
+		return
+	}
+
+	role, err := h.roleService.GetRole(uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Create a role granting the given permissions
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.RoleRequest true "Role"
+// @Success 201 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /admin/roles [post]
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req dto.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	role, err := h.roleService.CreateRole(&req)
+	if err != nil {
+		c.Error(roleError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole godoc
+// @Summary Update a role
+// @Description Rename a role and replace its permissions. Built-in roles cannot be renamed, and the admin role keeps roles:manage.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Param request body dto.RoleRequest true "Role"
+// @Success 200 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /admin/roles/{id} [put]
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid role ID"))
+		return
+	}
+
+	var req dto.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(uint(id), &req)
+	if err != nil {
+		c.Error(roleError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole godoc
+// @Summary Delete a role
+// @Description Delete a role that is not assigned to any user. Built-in roles cannot be deleted.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path int true "Role ID"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /admin/roles/{id} [delete]
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid role ID"))
+		return
+	}
+
+	if err := h.roleService.DeleteRole(uint(id)); err != nil {
+		c.Error(roleError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListPermissions godoc
+// @Summary List permissions
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} dto.PermissionResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Router /admin/permissions [get]
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.roleService.ListPermissions()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// CreatePermission godoc
+// @Summary Create a permission
+// @Description Create a permission that can be granted to roles
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body dto.PermissionRequest true "Permission"
+// @Success 201 {object} dto.PermissionResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /admin/permissions [post]
+func (h *RoleHandler) CreatePermission(c *gin.Context) {
+	var req dto.PermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	permission, err := h.roleService.CreatePermission(&req)
+	if err != nil {
+		c.Error(roleError(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, permission)
+}
+
+// UpdatePermission godoc
+// @Summary Update a permission
+// @Description Rename a permission or change its description. Built-in permissions cannot be renamed.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Permission ID"
+// @Param request body dto.PermissionRequest true "Permission"
+// @Success 200 {object} dto.PermissionResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /admin/permissions/{id} [put]
+func (h *RoleHandler) UpdatePermission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid permission ID"))
+		return
+	}
+
+	var req dto.PermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	permission, err := h.roleService.UpdatePermission(uint(id), &req)
+	if err != nil {
+		c.Error(roleError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, permission)
+}
+
+// DeletePermission godoc
+// @Summary Delete a permission
+// @Description Take a permission from every role and delete it. Built-in permissions cannot be deleted.
+// @Tags admin
+// @Security BearerAuth
+// @Param id path int true "Permission ID"
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /admin/permissions/{id} [delete]
+func (h *RoleHandler) DeletePermission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid permission ID"))
+		return
+	}
+
+	if err := h.roleService.DeletePermission(uint(id)); err != nil {
+		c.Error(roleError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AssignRole godoc
+// @Summary Assign a role to a user
+// @Description Change the role of a user. The user is signed out everywhere, so tokens carrying the old role stop working.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body dto.AssignRoleRequest true "Role name"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Router /admin/users/{id}/role [put]
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid user ID"))
+		return
+	}
+
+	var req dto.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := h.roleService.AssignRole(uint(id), req.Role)
+	if err != nil {
+		c.Error(roleError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func roleError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrRoleNameTaken),
+		errors.Is(err, service.ErrPermissionNameTaken),
+		errors.Is(err, service.ErrRoleInUse),
+		errors.Is(err, service.ErrBuiltIn),
+		errors.Is(err, service.ErrAdminRoleLocked):
+		return middleware.NewAppError(http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrUnknownPermission),
+		errors.Is(err, service.ErrUnknownRole):
+		return middleware.BadRequestError(err.Error())
+	}
+	return err
+}
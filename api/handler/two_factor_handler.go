@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService service.TwoFactorService
+}
+
+func NewTwoFactorHandler(twoFactorService service.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{twoFactorService: twoFactorService}
+}
+
+// GetStatus godoc
+// @Summary Get my two-factor settings
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.TwoFactorStatusResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /users/me/2fa [get]
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	status, err := h.twoFactorService.Status(getUserIDFromContext(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// BeginTOTPEnrollment godoc
+// @Summary Start authenticator enrollment
+// @Description Generate a TOTP secret and its otpauth:// URI. Two-factor authentication is turned on once a first code is confirmed.
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} dto.TOTPEnrollmentResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /users/me/2fa/totp [post]
+func (h *TwoFactorHandler) BeginTOTPEnrollment(c *gin.Context) {
+	enrollment, err := h.twoFactorService.BeginTOTPEnrollment(getUserIDFromContext(c))
+	if err != nil {
+		c.Error(twoFactorError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTPEnrollment godoc
+// @Summary Confirm authenticator enrollment
+// @Description Turn two-factor authentication on with a first code from the authenticator app. Returns single-use recovery codes, which are not shown again.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorCodeRequest true "TOTP code"
+// @Security BearerAuth
+// @Success 200 {object} dto.RecoveryCodesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Router /users/me/2fa/totp/confirm [post]
+func (h *TwoFactorHandler) ConfirmTOTPEnrollment(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	codes, err := h.twoFactorService.ConfirmTOTPEnrollment(getUserIDFromContext(c), req.Code)
+	if err != nil {
+		c.Error(twoFactorError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+// DisableTOTP godoc
+// @Summary Turn two-factor authentication off
+// @Description Remove the authenticator and the recovery codes. Takes a TOTP code or a recovery code.
+// @Tags users
+// @Accept json
+// @Param request body dto.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Security BearerAuth
+// @Success 204
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /users/me/2fa/totp/disable [post]
+func (h *TwoFactorHandler) DisableTOTP(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.twoFactorService.DisableTOTP(getUserIDFromContext(c), req.Code); err != nil {
+		c.Error(twoFactorError(err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Replace my recovery codes
+// @Description Issue new recovery codes; the previous ones stop working. Takes a TOTP code.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorCodeRequest true "TOTP code"
+// @Security BearerAuth
+// @Success 200 {object} dto.RecoveryCodesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /users/me/2fa/recovery-codes [post]
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(getUserIDFromContext(c), req.Code)
+	if err != nil {
+		c.Error(twoFactorError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, codes)
+}
+
+// twoFactorError maps the errors of the two-factor service to responses
+func twoFactorError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		return middleware.NewAppError(http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrTwoFactorNotEnabled),
+		errors.Is(err, service.ErrTwoFactorNotEnrolling),
+		errors.Is(err, service.ErrInvalidTwoFactorCode):
+		return middleware.BadRequestError(err.Error())
+	}
+	return err
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"go-gin-template/api/dto"
 	"go-gin-template/api/middleware"
+	"go-gin-template/api/policy"
 	"go-gin-template/api/service"
+	"go-gin-template/api/util"
 	"net/http"
 	"strconv"
 
@@ -36,6 +39,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 	}
 
 	user, err := h.userService.Register(&req)
+	var policyErr *util.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.Error(middleware.NewFieldErrors("password", policyErr.Violations...))
+		return
+	}
 	if err != nil {
 		c.Error(middleware.BadRequestError(err.Error()))
 		return
@@ -46,13 +54,14 @@ func (h *UserHandler) Register(c *gin.Context) {
 
 // Login godoc
 // @Summary User login
-// @Description Authenticate a user and return user information
+// @Description Authenticate a user and return user information. When two-factor authentication is on, the response only has mfa_required and a challenge_token for POST /users/login/2fa.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param credentials body dto.LoginRequest true "Login credentials"
 // @Success 200 {object} dto.LoginResponse
 // @Failure 401 {object} object "Authentication failed"
+// @Failure 429 {object} object "Too many failed attempts; see the Retry-After header"
 // @Router /users/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	var req dto.LoginRequest
@@ -61,7 +70,17 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userService.Login(&req)
+	response, err := h.userService.Login(&req, service.ClientInfo{
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		DeviceName: req.DeviceName,
+	})
+	var throttled *service.LoginThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(throttled.RetryAfterSeconds()))
+		c.Error(middleware.NewAppError(http.StatusTooManyRequests, throttled.Error()))
+		return
+	}
 	if err != nil {
 		c.Error(middleware.UnauthorizedError())
 		return
@@ -70,6 +89,37 @@ func (h *UserHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// LoginWithTwoFactor godoc
+// @Summary Complete a two-factor login
+// @Description Exchange the challenge token returned by login, together with a code from the authenticator app or a recovery code, for the tokens. A challenge expires after a few minutes or a few wrong codes.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body dto.TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} dto.LoginResponse
+// @Failure 400 {object} object "Invalid input"
+// @Failure 401 {object} object "Authentication failed"
+// @Router /users/login/2fa [post]
+func (h *UserHandler) LoginWithTwoFactor(c *gin.Context) {
+	var req dto.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	response, err := h.userService.LoginWithTwoFactor(&req)
+	if errors.Is(err, service.ErrInvalidLoginChallenge) || errors.Is(err, service.ErrInvalidTwoFactorCode) {
+		c.Error(middleware.NewAppError(http.StatusUnauthorized, err.Error()))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // GetProfile godoc
 // @Summary Get user profile
 // @Description Get user profile by ID
@@ -91,7 +141,11 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	// User ID has already been validated by OwnerOrAdminAuthMiddleware
+	if err := authorizeUser(c, policy.ActionRead, uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
 	user, err := h.userService.GetUserByID(uint(id))
 	if err != nil {
 		c.Error(middleware.NotFoundError("User"))
@@ -123,7 +177,11 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	// User ID has already been validated by OwnerOrAdminAuthMiddleware
+	if err := authorizeUser(c, policy.ActionUpdate, uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
 	var req dto.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.Error(err)
@@ -138,3 +196,40 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// UnlockUser godoc
+// @Summary Unlock a user's login
+// @Description Lift a lockout after too many failed logins and forget the user's failed attempts
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 204
+// @Failure 400 {object} object "Invalid user ID"
+// @Failure 401 {object} object "Unauthorized"
+// @Failure 403 {object} object "Forbidden"
+// @Failure 404 {object} object "User not found"
+// @Router /admin/users/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.Error(middleware.BadRequestError("Invalid user ID"))
+		return
+	}
+
+	if err := h.userService.UnlockUser(uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// authorizeUser checks the policy for an action on the profile of a user
+func authorizeUser(c *gin.Context, action policy.Action, userID uint) error {
+	subject, err := middleware.PolicySubject(c)
+	if err != nil {
+		return err
+	}
+	return policy.Authorize(subject, action, policy.User{ID: userID})
+}
@@ -1,28 +1,31 @@
 package handler
 
 import (
+	"errors"
 	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
 	"go-gin-template/api/service"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 type VerificationHandler struct {
 	verificationService service.VerificationService
-	notificationService service.NotificationService
+	userNotifier        service.UserNotifier
 }
 
-func NewVerificationHandler(verificationService service.VerificationService, notificationService service.NotificationService) *VerificationHandler {
+func NewVerificationHandler(verificationService service.VerificationService, userNotifier service.UserNotifier) *VerificationHandler {
 	return &VerificationHandler{
 		verificationService: verificationService,
-		notificationService: notificationService,
+		userNotifier:        userNotifier,
 	}
 }
 
 // @Summary Generate verification code for transaction
-// @Description Generate and send verification code for a pending transaction
+// @Description Generate a verification code for a pending transaction and send it to the user's email or phone. When type is omitted the user's preferred channel is used. With type otp nothing is sent and the code from the user's authenticator app is verified.
 // @Tags verification
 // @Accept json
 // @Produce json
@@ -41,21 +44,36 @@ func (h *VerificationHandler) GenerateVerification(c *gin.Context) {
 		return
 	}
 
-	verification, err := h.verificationService.GenerateVerification(userID, req.TransactionID, req.Type, "")
+	challenge, err := h.verificationService.GenerateVerification(userID, req.TransactionID, req.Type)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// OTP codes come from the user's authenticator app
+	if challenge.Recipient == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"verification_id": challenge.Verification.ID,
+			"channel":         challenge.Verification.Type,
+			"expires_at":      challenge.Verification.ExpiresAt,
+			"message":         "Enter the code from your authenticator app",
+		})
+		return
+	}
+
 	// Send notification
-	if err := h.notificationService.SendVerificationCode(req.Type, "", verification.Code); err != nil {
+	notification, err := h.sendCode(challenge)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification code"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"verification_id": verification.ID,
-		"expires_at":      verification.ExpiresAt,
+		"verification_id": challenge.Verification.ID,
+		"channel":         challenge.Recipient.Channel,
+		"sent_to":         service.MaskContact(challenge.Recipient),
+		"notification_id": notification.ID,
+		"expires_at":      challenge.Verification.ExpiresAt,
 		"message":         "Verification code sent successfully",
 	})
 }
@@ -93,8 +111,64 @@ func (h *VerificationHandler) VerifyCode(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"verified":       result.Verified,
-		"transaction_id": result.TransactionID,
-		"message":        "Verification successful",
+		"verified":           result.Verified,
+		"transaction_id":     result.TransactionID,
+		"transaction_status": result.TransactionStatus,
+		"message":            "Verification successful, transfer completed",
+	})
+}
+
+// @Summary Resend verification code
+// @Description Send a new code for a pending verification. The previous code stops working. Codes can be resent once per cooldown period.
+// @Tags verification
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path int true "Verification ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Router /verifications/{id}/resend [post]
+func (h *VerificationHandler) ResendCode(c *gin.Context) {
+	userID := getUserIDFromContext(c)
+	verificationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid verification ID"})
+		return
+	}
+
+	challenge, err := h.verificationService.ResendCode(userID, uint(verificationID))
+	if err != nil {
+		var cooldownErr *service.CooldownError
+		if errors.As(err, &cooldownErr) {
+			c.Header("Retry-After", strconv.Itoa(int(cooldownErr.RetryAfter.Seconds()+0.5)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notification, err := h.sendCode(challenge)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"verification_id": challenge.Verification.ID,
+		"channel":         challenge.Recipient.Channel,
+		"sent_to":         service.MaskContact(challenge.Recipient),
+		"notification_id": notification.ID,
+		"expires_at":      challenge.Verification.ExpiresAt,
+		"message":         "Verification code resent successfully",
 	})
-}
\ No newline at end of file
+}
+
+// sendCode queues the code for delivery; the outbox retries until it reaches the user
+func (h *VerificationHandler) sendCode(challenge *service.VerificationChallenge) (*model.NotificationMessage, error) {
+	return h.userNotifier.NotifyContact(challenge.Recipient, service.TemplateVerificationCode, map[string]string{
+		"Code":             challenge.Code,
+		"ExpiresInMinutes": strconv.Itoa(int(time.Until(challenge.Verification.ExpiresAt).Round(time.Minute).Minutes())),
+	})
+}
@@ -1,71 +1,319 @@
 package middleware
 
 import (
+	"bytes"
+	"errors"
 	"go-gin-template/api/config"
 	"go-gin-template/api/model"
+	"go-gin-template/api/policy"
+	"go-gin-template/api/repository"
 	"go-gin-template/api/util"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// AuthGuard verifies the JWT token and sets user information in the context
+const (
+	// sessionTouchInterval limits how often a session's last-seen time is written
+	sessionTouchInterval = time.Minute
+	// apiKeyTouchInterval limits how often an API key's last-used time is written
+	apiKeyTouchInterval = time.Minute
+	// signedRequestScheme is the Authorization scheme of requests signed with an API key
+	signedRequestScheme = "HMAC-SHA256"
+	// maxNonceLength bounds the nonces of signed requests, which are kept in Redis
+	maxNonceLength = 64
+)
+
+// AuthGuard verifies the JWT token, or the signature of a request signed with an API key,
+// and sets user information in the context. Tokens of third-party apps are rejected; routes
+// open to them use ScopedAuthGuard.
 func AuthGuard() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+		claims, ok := authenticate(c)
+		if !ok {
+			return
+		}
+
+		if claims.IsClientToken() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available to third-party apps"})
 			c.Abort()
 			return
 		}
 
-		// Check if the Authorization header has the correct format
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		c.Next()
+	}
+}
+
+// LoginAuthGuard is AuthGuard for routes that need a login of the user, such as managing
+// API keys, so that a leaked key cannot issue or rotate keys itself
+func LoginAuthGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c)
+		if !ok {
+			return
+		}
+
+		if claims.IsClientToken() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available to third-party apps"})
+			c.Abort()
+			return
+		}
+		if _, signed := c.Get("apiKeyID"); signed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available to API keys"})
 			c.Abort()
 			return
 		}
 
-		// Parse and validate the token
-		claims, err := util.ParseToken(parts[1])
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Next()
+	}
+}
+
+// ScopedAuthGuard is AuthGuard for routes third-party apps may call when they were granted
+// the scope. Tokens of the user's own logins are accepted as well.
+func ScopedAuthGuard(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c)
+		if !ok {
+			return
+		}
+
+		if claims.IsClientToken() && !claims.HasScope(scope) {
+			insufficientScope(c, scope)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ThirdPartyGuard admits only tokens of third-party apps that were granted the scope, for
+// routes that act under a consent given to the app
+func ThirdPartyGuard(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c)
+		if !ok {
+			return
+		}
+
+		if !claims.IsClientToken() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is only available to third-party apps"})
 			c.Abort()
 			return
 		}
+		if !claims.HasScope(scope) {
+			insufficientScope(c, scope)
+			return
+		}
 
-		// Set user information in the context
-		c.Set("userID", claims.UserID)
-		c.Set("userRole", claims.Role)
 		c.Next()
 	}
 }
 
-// AdminAuthGuard verifies if the user has admin role
-func AdminAuthGuard() gin.HandlerFunc {
+func insufficientScope(c *gin.Context, scope string) {
+	c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+	c.JSON(http.StatusForbidden, gin.H{"error": "Scope " + scope + " required"})
+	c.Abort()
+}
+
+// authenticate verifies the bearer token or the API key signature and sets user
+// information in the context. On failure it writes the response and aborts.
+func authenticate(c *gin.Context) (*util.Claims, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+		c.Abort()
+		return nil, false
+	}
+
+	// Check if the Authorization header has the correct format
+	parts := strings.Split(authHeader, " ")
+	if len(parts) == 2 && parts[0] == signedRequestScheme {
+		return authenticateSignedRequest(c, parts[1])
+	}
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+		c.Abort()
+		return nil, false
+	}
+
+	// Parse and validate the token
+	claims, err := util.ParseToken(parts[1])
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		c.Abort()
+		return nil, false
+	}
+
+	// Reject tokens revoked by a logout before they expire
+	revoked, err := repository.NewTokenRevocationRepository(config.Redis).IsRevoked(claims.ID, claims.SessionID, claims.ClientID)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify token"})
+		c.Abort()
+		return nil, false
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return nil, false
+	}
+
+	// Record activity for the session list; a failure must not block the request. Tokens
+	// of third-party apps have no login session.
+	if claims.SessionID != "" && !claims.IsClientToken() {
+		sessionRepo := repository.NewSessionRepository(config.DB, config.Redis)
+		if err := sessionRepo.TouchLastSeen(claims.SessionID, sessionTouchInterval); err != nil {
+			log.Printf("Failed to record activity of session %s: %v", claims.SessionID, err)
+		}
+	}
+
+	// Set user information in the context
+	c.Set("userID", claims.UserID)
+	c.Set("userRole", claims.Role)
+	c.Set("claims", claims)
+	return claims, true
+}
+
+// authenticateSignedRequest verifies a request signed with an API key. The Authorization
+// header carries "HMAC-SHA256 <key ID>:<signature>"; the signature covers the method, the
+// path, the X-Timestamp and X-Nonce headers and the body (see util.SignedRequest).
+func authenticateSignedRequest(c *gin.Context, credentials string) (*util.Claims, bool) {
+	keyID, signature, found := strings.Cut(credentials, ":")
+	if !found || keyID == "" || signature == "" {
+		return rejectRequest(c, http.StatusUnauthorized, "Invalid authorization header format")
+	}
+
+	// The timestamp bounds how long a nonce must be remembered to stop replays
+	apiKeyConfig := config.GetAPIKeyConfig()
+	timestamp := c.GetHeader("X-Timestamp")
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return rejectRequest(c, http.StatusUnauthorized, "X-Timestamp header must be a Unix time in seconds")
+	}
+	if skew := time.Since(time.Unix(signedAt, 0)); skew > apiKeyConfig.MaxClockSkew || skew < -apiKeyConfig.MaxClockSkew {
+		return rejectRequest(c, http.StatusUnauthorized, "Request timestamp is outside the allowed clock skew")
+	}
+	nonce := c.GetHeader("X-Nonce")
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return rejectRequest(c, http.StatusUnauthorized, "X-Nonce header is required and must be at most 64 characters")
+	}
+
+	apiKeyRepo := repository.NewAPIKeyRepository(config.DB, config.Redis)
+	key, err := apiKeyRepo.FindByKeyID(keyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rejectRequest(c, http.StatusUnauthorized, "Invalid API key")
+	}
+	if err != nil {
+		return rejectRequest(c, http.StatusServiceUnavailable, "Unable to verify signature")
+	}
+	if !key.IsActive(time.Now()) {
+		return rejectRequest(c, http.StatusUnauthorized, "API key has expired or been revoked")
+	}
+
+	secret, err := util.OpenSecret(apiKeyConfig.EncryptionKey, key.SecretCiphertext)
+	if err != nil {
+		log.Printf("Failed to open the secret of API key %s: %v", key.KeyID, err)
+		return rejectRequest(c, http.StatusServiceUnavailable, "Unable to verify signature")
+	}
+
+	// Read the body for its hash and put it back for the handler. The whole body is held in
+	// memory before the signature is checked, so its size is capped.
+	var body []byte
+	if c.Request.Body != nil {
+		reader := http.MaxBytesReader(c.Writer, c.Request.Body, apiKeyConfig.MaxBodyBytes)
+		if body, err = io.ReadAll(reader); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return rejectRequest(c, http.StatusRequestEntityTooLarge, "Request body is too large")
+			}
+			return rejectRequest(c, http.StatusBadRequest, "Unable to read request body")
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	request := util.SignedRequest{
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.RequestURI(),
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		Body:      body,
+	}
+	if !util.VerifyRequestSignature(secret, request, signature) {
+		return rejectRequest(c, http.StatusUnauthorized, "Invalid signature")
+	}
+
+	// Nonces are remembered for as long as their timestamp could still be accepted
+	fresh, err := apiKeyRepo.ClaimNonce(key.KeyID, nonce, 2*apiKeyConfig.MaxClockSkew)
+	if err != nil {
+		return rejectRequest(c, http.StatusServiceUnavailable, "Unable to verify signature")
+	}
+	if !fresh {
+		return rejectRequest(c, http.StatusUnauthorized, "Nonce has already been used")
+	}
+
+	// Record use for the key list; a failure must not block the request
+	if err := apiKeyRepo.TouchLastUsed(key.ID, apiKeyTouchInterval); err != nil {
+		log.Printf("Failed to record use of API key %s: %v", key.KeyID, err)
+	}
+
+	// Set user information in the context the same way as for tokens. The role is read
+	// from the user, so role changes apply to the next request.
+	roleName := "user"
+	if key.User.Role != nil {
+		roleName = key.User.Role.Name
+	}
+	claims := &util.Claims{UserID: key.UserID, Role: roleName}
+	c.Set("userID", claims.UserID)
+	c.Set("userRole", claims.Role)
+	c.Set("claims", claims)
+	c.Set("apiKeyID", key.KeyID)
+	return claims, true
+}
+
+func rejectRequest(c *gin.Context, status int, message string) (*util.Claims, bool) {
+	c.JSON(status, gin.H{"error": message})
+	c.Abort()
+	return nil, false
+}
+
+// RequirePermission lets the request through when the user's role grants the permission.
+// The permissions of a role are cached in Redis and dropped whenever the role changes.
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("userRole")
-		if !exists {
+		if _, exists := c.Get("userRole"); !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
 		}
 
-		if role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		permissions, err := rolePermissions(c)
+		if err != nil {
+			log.Printf("Failed to load permissions: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify permissions"})
 			c.Abort()
 			return
 		}
 
-		c.Next()
+		for _, granted := range permissions {
+			if granted == permission {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission " + permission + " required"})
+		c.Abort()
 	}
 }
 
-// OwnerOrAdminAuthGuard verifies if the user is the owner of the resource or an admin
-func OwnerOrAdminAuthGuard() gin.HandlerFunc {
+// VerifiedEmailGuard rejects users who have not verified their email address yet. It reads
+// the user instead of the token, so it lets the user through as soon as they verify.
+func VerifiedEmailGuard() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("userID")
 		if !exists {
@@ -74,12 +322,15 @@ func OwnerOrAdminAuthGuard() gin.HandlerFunc {
 			return
 		}
 
-		role, _ := c.Get("userRole")
-		resourceUserID := c.Param("id")
+		var user model.User
+		if err := config.DB.Select("status").First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
 
-		// Allow access if user is admin or the owner of the resource
-		if role != "admin" && resourceUserID != "" && userID != resourceUserID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		if user.Status != model.UserStatusActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address must be verified first"})
 			c.Abort()
 			return
 		}
@@ -88,23 +339,44 @@ func OwnerOrAdminAuthGuard() gin.HandlerFunc {
 	}
 }
 
-// AccountOwnershipGuard verifies if the user owns the account specified in the path parameter
-func AccountOwnershipGuard() gin.HandlerFunc {
+// PolicySubject describes the authenticated user for the policy rules. The permissions of
+// their role are loaded once per request.
+func PolicySubject(c *gin.Context) (policy.Subject, error) {
+	if subject, exists := c.Get("policySubject"); exists {
+		return subject.(policy.Subject), nil
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		return policy.Subject{}, UnauthorizedError()
+	}
+	// Third-party apps only get the access of the user as an owner
+	if isThirdPartyRequest(c) {
+		subject := policy.Owner(userID.(uint))
+		c.Set("policySubject", subject)
+		return subject, nil
+	}
+
+	permissions, err := rolePermissions(c)
+	if err != nil {
+		return policy.Subject{}, err
+	}
+
+	subject := policy.Subject{UserID: userID.(uint), Permissions: permissions}
+	c.Set("policySubject", subject)
+	return subject, nil
+}
+
+// AccountAccessGuard lets the request through when the policy allows the action on the
+// account specified in the path parameter
+func AccountAccessGuard(action policy.Action) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("userID")
-		if !exists {
+		if _, exists := c.Get("userID"); !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
 		}
 
-		// Check if user has admin role
-		role, _ := c.Get("userRole")
-		if role == "admin" {
-			c.Next()
-			return
-		}
-
 		// Get account ID from path parameter
 		accountIDStr := c.Param("id")
 		if accountIDStr == "" {
@@ -120,9 +392,23 @@ func AccountOwnershipGuard() gin.HandlerFunc {
 			return
 		}
 
-		// Query the account to verify ownership
-		var account model.Account
-		if err := config.DB.Where("id = ? AND user_id = ?", accountID, userID).First(&account).Error; err != nil {
+		subject, err := PolicySubject(c)
+		if err != nil {
+			log.Printf("Failed to load permissions: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify permissions"})
+			c.Abort()
+			return
+		}
+
+		// A missing account is reported like a foreign one, so account IDs cannot be probed
+		account, err := repository.NewAccountRepository(config.DB).FindByID(uint(accountID))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to load account %d: %v", accountID, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify access"})
+			c.Abort()
+			return
+		}
+		if err != nil || policy.Authorize(subject, action, policy.AccountOf(account)) != nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Account not found or access denied"})
 			c.Abort()
 			return
@@ -131,3 +417,25 @@ func AccountOwnershipGuard() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// rolePermissions returns the permissions granted by the role in the token, served from the
+// Redis cache when possible. Tokens without a role get the permissions of the user role.
+func rolePermissions(c *gin.Context) ([]string, error) {
+	// The permissions of the user's role are never delegated to third-party apps
+	if isThirdPartyRequest(c) {
+		return nil, nil
+	}
+
+	roleName := c.GetString("userRole")
+	if roleName == "" {
+		roleName = model.RoleUser
+	}
+	return repository.NewRoleRepository(config.DB, config.Redis).PermissionNames(roleName)
+}
+
+// isThirdPartyRequest reports whether the request was authenticated with a token issued to
+// a third-party app
+func isThirdPartyRequest(c *gin.Context) bool {
+	claims, exists := c.Get("claims")
+	return exists && claims.(*util.Claims).IsClientToken()
+}
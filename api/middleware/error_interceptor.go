@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"go-gin-template/api/policy"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
@@ -42,6 +44,7 @@ func ErrorInterceptor() gin.HandlerFunc {
 		err := c.Errors.Last().Err
 		var appErr *AppError
 		var validationErrs validator.ValidationErrors
+		var fieldErrs FieldErrors
 
 		// Handle different types of errors
 		switch {
@@ -51,6 +54,12 @@ func ErrorInterceptor() gin.HandlerFunc {
 				"error": appErr.Message,
 			})
 
+		case errors.Is(err, policy.ErrForbidden):
+			// Handle actions denied by a policy rule
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Access forbidden",
+			})
+
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			// Handle not found errors
 			c.JSON(http.StatusNotFound, gin.H{
@@ -59,13 +68,18 @@ func ErrorInterceptor() gin.HandlerFunc {
 
 		case errors.As(err, &validationErrs):
 			// Handle validation errors
-			var errMsgs []string
+			var fieldErrs FieldErrors
 			for _, e := range validationErrs {
-				errMsgs = append(errMsgs, formatValidationError(e))
+				fieldErrs = append(fieldErrs, ValidationError{
+					Field:   e.Field(),
+					Message: formatValidationError(e),
+				})
 			}
-			c.JSON(http.StatusBadRequest, gin.H{
-				"errors": errMsgs,
-			})
+			respondFieldErrors(c, fieldErrs)
+
+		case errors.As(err, &fieldErrs):
+			// Handle validation done by services, such as the password policy
+			respondFieldErrors(c, fieldErrs)
 
 		default:
 			// Handle unknown errors
@@ -78,7 +92,7 @@ func ErrorInterceptor() gin.HandlerFunc {
 
 // formatValidationError formats validator.ValidationErrors into readable messages
 func formatValidationError(e validator.FieldError) string {
-	field := strings.ToLower(e.Field())
+	field := e.Field()
 
 	switch e.Tag() {
 	case "required":
@@ -89,6 +103,14 @@ func formatValidationError(e validator.FieldError) string {
 		return field + " must be at least " + e.Param() + " characters long"
 	case "max":
 		return field + " must not be longer than " + e.Param() + " characters"
+	case "currency":
+		return field + " must be a supported ISO 4217 currency code"
+	case "money_positive":
+		return field + " must be a positive amount"
+	case "money_scale":
+		return field + " has too many decimal places for its currency"
+	case "permission_name":
+		return field + " must look like resource:action, e.g. books:write"
 	default:
 		return field + " is invalid"
 	}
@@ -100,6 +122,38 @@ type ValidationError struct {
 	Message string `json:"message"`
 }
 
+// FieldErrors reports invalid fields of a request that are only detected past binding
+type FieldErrors []ValidationError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// NewFieldErrors creates FieldErrors for one field; each message is prefixed with the field name
+func NewFieldErrors(field string, messages ...string) FieldErrors {
+	fieldErrs := make(FieldErrors, 0, len(messages))
+	for _, message := range messages {
+		fieldErrs = append(fieldErrs, ValidationError{Field: field, Message: field + " " + message})
+	}
+	return fieldErrs
+}
+
+// respondFieldErrors writes the messages, and the same messages keyed by field for forms
+func respondFieldErrors(c *gin.Context, fieldErrs FieldErrors) {
+	errMsgs := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		errMsgs = append(errMsgs, fieldErr.Message)
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"errors": errMsgs,
+		"fields": fieldErrs,
+	})
+}
+
 // NotFoundError creates a not found AppError
 func NotFoundError(resource string) *AppError {
 	return NewAppError(http.StatusNotFound, resource+" not found")
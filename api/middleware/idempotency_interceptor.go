@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	// idempotencyRenewInterval is how often an in-flight request renews its key, well within the lock TTL
+	idempotencyRenewInterval = repository.IdempotencyLockTTL / 3
+)
+
+// IdempotencyInterceptor replays the stored response when a request is retried with the same
+// Idempotency-Key header. Keys are scoped per user, so it must run after AuthGuard.
+// Requests without the header are processed normally.
+func IdempotencyInterceptor(store repository.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must not be longer than 255 characters"})
+			c.Abort()
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		uid := userID.(uint)
+
+		// Read and restore the request body so the handler can still bind it
+		var requestBody []byte
+		if c.Request.Body != nil {
+			requestBody, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		}
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, requestBody)
+
+		// The owner token ties the lock to this request, so a retry that took over an expired
+		// lock cannot have its response overwritten by this one
+		owner, err := util.GenerateOpaqueToken(16)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.Abort()
+			return
+		}
+
+		existing, reserved, err := store.Begin(uid, key, requestHash, owner)
+		if err != nil {
+			log.Printf("Failed to reserve idempotency key: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Idempotency store unavailable"})
+			c.Abort()
+			return
+		}
+
+		if !reserved {
+			replayIdempotentResponse(c, existing, requestHash)
+			return
+		}
+
+		// Capture the response so it can be replayed
+		blw := &bodyLogWriter{
+			ResponseWriter: c.Writer,
+			body:           bytes.NewBufferString(""),
+		}
+		c.Writer = blw
+
+		// Keep the key locked for as long as the handler runs
+		done := make(chan struct{})
+		go renewIdempotencyKey(store, uid, key, owner, done)
+		defer close(done)
+
+		defer func() {
+			// A panic or a server error leaves the operation in an unknown state; let the client retry
+			if r := recover(); r != nil {
+				store.Release(uid, key, owner)
+				panic(r)
+			}
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError || (len(c.Errors) > 0 && blw.body.Len() == 0) {
+			if err := store.Release(uid, key, owner); err != nil {
+				log.Printf("Failed to release idempotency key: %v", err)
+			}
+			return
+		}
+
+		if err := store.Complete(uid, key, owner, status, blw.body.Bytes()); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	}
+}
+
+// renewIdempotencyKey extends the lock of an in-flight request until done is closed
+func renewIdempotencyKey(store repository.IdempotencyRepository, userID uint, key, owner string, done <-chan struct{}) {
+	ticker := time.NewTicker(idempotencyRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := store.Extend(userID, key, owner); err != nil {
+				log.Printf("Failed to renew idempotency key: %v", err)
+				if errors.Is(err, repository.ErrIdempotencyLockLost) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, existing *model.IdempotencyRecord, requestHash string) {
+	defer c.Abort()
+
+	if existing.RequestHash != "" && existing.RequestHash != requestHash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request payload"})
+		return
+	}
+
+	if existing.Status == model.IdempotencyStatusProcessing {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	c.Header(IdempotencyReplayedHeader, "true")
+	c.Data(existing.ResponseStatus, "application/json; charset=utf-8", []byte(existing.ResponseBody))
+}
+
+// hashRequest fingerprints the request so a reused key with a different payload can be rejected
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// APIKey lets a service client act as its user by signing requests instead of logging in.
+// A rotated key keeps working until ExpiresAt, so the client can switch to the new one.
+type APIKey struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	User   User `gorm:"foreignKey:UserID" json:"-"`
+	// KeyID is the public identifier sent with every signed request
+	KeyID string `gorm:"size:32;not null;uniqueIndex" json:"key_id"`
+	Name  string `gorm:"size:100;not null" json:"name"`
+	// SecretCiphertext is the signing secret sealed with the API key encryption key; the
+	// secret has to be read back to check signatures, so it cannot be hashed
+	SecretCiphertext string     `gorm:"size:255;not null" json:"-"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether requests signed with the key are accepted now
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// IdempotencyStatus represents the state of a request made with an Idempotency-Key
+type IdempotencyStatus string
+
+const (
+	IdempotencyStatusProcessing IdempotencyStatus = "processing"
+	IdempotencyStatusCompleted  IdempotencyStatus = "completed"
+)
+
+// IdempotencyRecord stores the outcome of a request so retries with the same key can be replayed.
+// This table is the authoritative store; completed responses are also cached in Redis.
+type IdempotencyRecord struct {
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	UserID         uint              `gorm:"not null;uniqueIndex:idx_idempotency_user_key,priority:1" json:"user_id"`
+	Key            string            `gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_user_key,priority:2" json:"key"`
+	RequestHash    string            `gorm:"size:64;not null" json:"request_hash"`
+	Status         IdempotencyStatus `gorm:"size:20;not null" json:"status"`
+	ResponseStatus int               `json:"response_status"`
+	ResponseBody   string            `gorm:"type:text" json:"response_body"`
+	// LockOwner identifies the request processing the key; only it may complete or release it
+	LockOwner string `gorm:"size:64" json:"-"`
+	// LockedUntil bounds how long a processing record blocks retries if the server dies mid-request.
+	// The request renews it while it runs.
+	LockedUntil time.Time `json:"locked_until"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+// PostingDirection represents the side of a ledger posting
+type PostingDirection string
+
+const (
+	PostingDirectionDebit  PostingDirection = "debit"
+	PostingDirectionCredit PostingDirection = "credit"
+)
+
+// LedgerAccount identifies which ledger a posting belongs to.
+// Customer postings reference an Account row; system ledgers do not.
+type LedgerAccount string
+
+const (
+	// LedgerAccountCustomer is the ledger of customer accounts (a liability for the bank)
+	LedgerAccountCustomer LedgerAccount = "customer"
+	// LedgerAccountExternalCash is the contra ledger for money entering or leaving the bank
+	LedgerAccountExternalCash LedgerAccount = "external_cash"
+)
+
+// JournalEntry groups the balanced postings of a single money movement
+type JournalEntry struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	TransactionID uint         `gorm:"not null;index" json:"transaction_id"`
+	Description   string       `gorm:"type:text" json:"description"`
+	Transaction   *Transaction `gorm:"foreignKey:TransactionID" json:"transaction,omitempty"`
+	Postings      []Posting    `gorm:"foreignKey:JournalEntryID" json:"postings,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// Posting is one debit or credit line of a journal entry.
+// Customer accounts are liabilities: a credit increases the balance and a debit decreases it.
+type Posting struct {
+	ID             uint             `gorm:"primaryKey" json:"id"`
+	JournalEntryID uint             `gorm:"not null;index" json:"journal_entry_id"`
+	LedgerAccount  LedgerAccount    `gorm:"size:30;not null" json:"ledger_account"`
+	AccountID      *uint            `gorm:"index" json:"account_id,omitempty"`
+	Direction      PostingDirection `gorm:"size:10;not null" json:"direction"`
+	Amount         util.Money       `gorm:"type:decimal(20,8);not null" json:"amount"`
+	// BalanceAfter is the running balance of AccountID after this posting; nil for system ledgers
+	BalanceAfter *util.Money `gorm:"type:decimal(20,8)" json:"balance_after,omitempty"`
+	Account      *Account    `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
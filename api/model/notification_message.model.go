@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// NotificationStatus is the delivery status of an outbox message
+type NotificationStatus string
+
+const (
+	NotificationStatusPending    NotificationStatus = "pending"
+	NotificationStatusProcessing NotificationStatus = "processing"
+	NotificationStatusSent       NotificationStatus = "sent"
+	// NotificationStatusDead means all delivery attempts failed; an admin can replay the message
+	NotificationStatusDead NotificationStatus = "dead"
+)
+
+// NotificationMessage is a notification in the outbox. Messages are written before any
+// delivery is attempted and worked off by the notification workers, so nothing is lost
+// when a provider is down or the process restarts.
+type NotificationMessage struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Channel   string `gorm:"size:20;not null" json:"channel"`
+	Recipient string `gorm:"size:255;not null" json:"recipient"`
+	// Payload is the content handed to the sender. It is cleared once the message is sent,
+	// and when it fails for good unless the message is replayable.
+	Payload string `gorm:"type:text" json:"-"`
+	// Replayable is false for messages with one-time codes or links, which expire quickly
+	Replayable    bool               `gorm:"not null;default:false" json:"replayable"`
+	Status        NotificationStatus `gorm:"size:20;not null;default:'pending';index:idx_notification_due,priority:1" json:"status"`
+	Attempts      int                `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts   int                `gorm:"not null;default:8" json:"max_attempts"`
+	NextAttemptAt time.Time          `gorm:"not null;index:idx_notification_due,priority:2" json:"next_attempt_at"`
+	// LeaseOwner identifies the claim of the worker delivering the message
+	LeaseOwner  string     `gorm:"size:64" json:"-"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	// ProviderStatus is the delivery status last reported by the provider, e.g. delivered
+	ProviderStatus string    `gorm:"size:30" json:"provider_status,omitempty"`
+	ProviderError  string    `gorm:"type:text" json:"provider_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// OAuthClientType tells whether a client can keep a secret
+type OAuthClientType string
+
+const (
+	// OAuthClientConfidential clients run on a server and authenticate with their secret
+	OAuthClientConfidential OAuthClientType = "confidential"
+	// OAuthClientPublic clients (mobile and browser apps) have no secret and rely on PKCE
+	OAuthClientPublic OAuthClientType = "public"
+)
+
+// Scopes third-party apps can be granted. Each scope opens a fixed set of routes.
+const (
+	ScopeAccountsRead   = "accounts:read"
+	ScopeTransfersWrite = "transfers:write"
+)
+
+// OAuthScopes describes the scopes on the consent screen
+var OAuthScopes = map[string]string{
+	ScopeAccountsRead:   "View your accounts and their transactions",
+	ScopeTransfersWrite: "Start payments from your accounts that you authorise and confirm with a code sent to you",
+}
+
+// OAuthClient is a third-party app registered by a user. Client credentials tokens act as
+// that user.
+type OAuthClient struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	ClientID string `gorm:"size:64;not null;uniqueIndex" json:"client_id"`
+	// SecretHash is the SHA-256 of the secret of a confidential client; empty for public clients
+	SecretHash string          `gorm:"size:64" json:"-"`
+	Name       string          `gorm:"size:100;not null" json:"name"`
+	Type       OAuthClientType `gorm:"size:20;not null" json:"type"`
+	// RedirectURIs and Scopes are space separated, like the scope parameter of OAuth
+	RedirectURIs string    `gorm:"type:text;not null" json:"-"`
+	Scopes       string    `gorm:"type:text;not null" json:"-"`
+	OwnerID      uint      `gorm:"not null;index" json:"owner_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// RedirectURIList returns the registered redirect URIs
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+// ScopeList returns the scopes the client may ask for
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// OAuthAuthorizationCode is a one-time code handed to the client after the user consented
+type OAuthAuthorizationCode struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// CodeHash is the SHA-256 of the code; the code itself is never stored
+	CodeHash    string `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ClientID    uint   `gorm:"not null;index" json:"client_id"`
+	UserID      uint   `gorm:"not null;index" json:"user_id"`
+	RedirectURI string `gorm:"type:text;not null" json:"redirect_uri"`
+	Scope       string `gorm:"type:text;not null" json:"scope"`
+	// CodeChallenge is the S256 PKCE challenge the token request must answer
+	CodeChallenge string     `gorm:"size:128;not null" json:"-"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	// FamilyID is the refresh token family issued for the code, revoked if the code is replayed
+	FamilyID  string    `gorm:"size:64" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OAuthRefreshToken renews the access token of a client. Like RefreshToken it rotates on
+// every use, and presenting a rotated token revokes its family.
+type OAuthRefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ClientID  uint       `gorm:"not null;index" json:"client_id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"size:64;not null;index" json:"family_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scope     string     `gorm:"type:text;not null" json:"scope"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// OAuthConsent remembers the scopes a user granted to a client
+type OAuthConsent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client" json:"user_id"`
+	ClientID  uint      `gorm:"not null;uniqueIndex:idx_oauth_consent_user_client" json:"client_id"`
+	Scope     string    `gorm:"type:text;not null" json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+// ConsentType tells what a third-party app may do under an open banking consent
+type ConsentType string
+
+const (
+	// ConsentTypeAccountAccess lets the app read the accounts the user picked (AIS)
+	ConsentTypeAccountAccess ConsentType = "account_access"
+	// ConsentTypePayment lets the app start one payment (PIS)
+	ConsentTypePayment ConsentType = "payment"
+)
+
+// ConsentStatus is the lifecycle of a consent. A consent is created by the app, then
+// authorised or rejected by the user; the user can revoke an authorised consent at any time.
+type ConsentStatus string
+
+const (
+	ConsentStatusAwaitingAuthorisation ConsentStatus = "awaiting_authorisation"
+	ConsentStatusAuthorised            ConsentStatus = "authorised"
+	ConsentStatusRejected              ConsentStatus = "rejected"
+	ConsentStatusRevoked               ConsentStatus = "revoked"
+	// ConsentStatusConsumed marks a payment consent whose payment was started
+	ConsentStatusConsumed ConsentStatus = "consumed"
+)
+
+// Permissions an account access consent can grant
+const (
+	ConsentPermissionReadAccounts     = "read_accounts"
+	ConsentPermissionReadBalances     = "read_balances"
+	ConsentPermissionReadTransactions = "read_transactions"
+)
+
+// OpenBankingConsent records what a third-party app may do for a user: read some of their
+// accounts, or start one payment that the user still verifies
+type OpenBankingConsent struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+	// ClientID is the OAuthClient the consent was given to
+	ClientID uint          `gorm:"not null;index" json:"client_id"`
+	UserID   uint          `gorm:"not null;index" json:"user_id"`
+	Type     ConsentType   `gorm:"size:20;not null" json:"type"`
+	Status   ConsentStatus `gorm:"size:30;not null" json:"status"`
+	// Permissions of an account access consent, space separated
+	Permissions string `gorm:"type:text" json:"-"`
+	// Accounts the user picked when authorising an account access consent
+	Accounts []Account `gorm:"many2many:open_banking_consent_accounts;" json:"accounts,omitempty"`
+	// The payment of a payment consent
+	DebtorAccountID   *uint      `json:"debtor_account_id,omitempty"`
+	CreditorAccountID *uint      `json:"creditor_account_id,omitempty"`
+	Amount            util.Money `gorm:"type:decimal(20,8);not null;default:0" json:"amount"`
+	Currency          string     `gorm:"size:3" json:"currency,omitempty"`
+	Reference         string     `gorm:"size:140" json:"reference,omitempty"`
+	// TransactionID is the pending transfer created for a payment consent
+	TransactionID *uint      `json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	AuthorisedAt  *time.Time `json:"authorised_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// PermissionList returns the permissions of an account access consent
+func (c *OpenBankingConsent) PermissionList() []string {
+	return strings.Fields(c.Permissions)
+}
+
+// HasPermission reports whether an account access consent grants the permission
+func (c *OpenBankingConsent) HasPermission(permission string) bool {
+	for _, granted := range c.PermissionList() {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the app can use the consent now
+func (c *OpenBankingConsent) IsActive(now time.Time) bool {
+	return c.Status == ConsentStatusAuthorised && now.Before(c.ExpiresAt)
+}
+
+// CoversAccount reports whether the user shared the account under the consent
+func (c *OpenBankingConsent) CoversAccount(accountID uint) bool {
+	for _, account := range c.Accounts {
+		if account.ID == accountID {
+			return true
+		}
+	}
+	return false
+}
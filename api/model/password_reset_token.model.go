@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// PasswordResetToken is a single-use token sent by email to reset a forgotten password
+type PasswordResetToken struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	// TokenHash is the SHA-256 of the token; the token itself is only in the email
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -2,6 +2,18 @@ package model
 
 import "time"
 
+// Permissions checked by the API. Names are "<resource>:<action>".
+const (
+	PermissionBooksWrite          = "books:write"
+	PermissionLedgerReconcile     = "ledger:reconcile"
+	PermissionNotificationsManage = "notifications:manage"
+	PermissionUsersUnlock         = "users:unlock"
+	PermissionRolesManage         = "roles:manage"
+	PermissionUsersRead           = "users:read"
+	PermissionUsersWrite          = "users:write"
+	PermissionAccountsRead        = "accounts:read"
+)
+
 // Permission represents a system permission
 type Permission struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// RefreshToken is a single-use token that renews the access token of a login. Every
+// refresh replaces it with a new token of the same family; presenting a token that was
+// already rotated means it leaked, and the whole family is revoked.
+type RefreshToken struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	// FamilyID is shared by all tokens rotated from one login and is the sid claim of its access tokens
+	FamilyID string `gorm:"size:64;not null;index" json:"family_id"`
+	// TokenHash is the SHA-256 of the token; the token itself is never stored
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
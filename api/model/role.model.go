@@ -2,6 +2,18 @@ package model
 
 import "time"
 
+// Roles seeded on migration. Users without a role are treated as RoleUser.
+const (
+	RoleUser   = "user"
+	RoleTeller = "teller"
+	RoleAdmin  = "admin"
+)
+
+// RolePermissionsCacheKey is the Redis key caching the permission names of a role
+func RolePermissionsCacheKey(roleName string) string {
+	return "rbac:role:" + roleName + ":permissions"
+}
+
 // Role represents a user role
 type Role struct {
 	ID        uint         `gorm:"primaryKey" json:"id"`
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Session is a login on one device. Its ID is the family ID of the session's refresh
+// tokens and the sid claim of its access tokens.
+type Session struct {
+	ID          string    `gorm:"primaryKey;size:64" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	DeviceLabel string    `gorm:"size:100" json:"device_label"`
+	UserAgent   string    `gorm:"size:512" json:"user_agent"`
+	IP          string    `gorm:"size:45" json:"ip"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+	// ExpiresAt moves forward with every refresh, like the refresh token itself
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"go-gin-template/api/util"
 )
 
 // TransactionType represents the type of transaction
@@ -27,7 +29,8 @@ const (
 // Transaction represents a financial transaction
 type Transaction struct {
 	ID            uint             `gorm:"primaryKey" json:"id"`
-	Amount        float64          `gorm:"type:decimal(20,8);not null" json:"amount"`
+	Amount        util.Money       `gorm:"type:decimal(20,8);not null" json:"amount"`
+	Currency      string           `gorm:"size:3;not null;default:'USD'" json:"currency"`
 	Type          TransactionType  `gorm:"size:20;not null" json:"type"`
 	Status        TransactionStatus `gorm:"size:20;not null;default:'pending'" json:"status"`
 	Description   string           `gorm:"type:text" json:"description"`
@@ -28,12 +28,15 @@ type TransactionVerification struct {
 	ID            uint               `gorm:"primaryKey" json:"id"`
 	TransactionID uint               `gorm:"not null" json:"transaction_id"`
 	UserID        uint               `gorm:"not null" json:"user_id"`
-	Code          string             `gorm:"size:6;not null" json:"-"`
+	// CodeHash is a keyed hash of the code; the plain code is only ever sent to the user
+	CodeHash      string             `gorm:"column:code;size:64;not null" json:"-"`
 	Type          VerificationType   `gorm:"size:20;not null" json:"type"`
 	Status        VerificationStatus `gorm:"size:20;not null;default:'pending'" json:"status"`
 	ExpiresAt     time.Time         `gorm:"not null" json:"expires_at"`
 	AttemptCount  int               `gorm:"not null;default:0" json:"attempt_count"`
 	MaxAttempts   int               `gorm:"not null;default:3" json:"max_attempts"`
+	ResendCount   int               `gorm:"not null;default:0" json:"resend_count"`
+	LastSentAt    time.Time         `json:"last_sent_at"`
 	VerifiedAt    *time.Time        `json:"verified_at"`
 	Transaction   Transaction       `gorm:"foreignKey:TransactionID" json:"transaction,omitempty"`
 	User          User             `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// UserTOTP is the authenticator app enrolled by a user. It only protects logins and
+// transfers once ConfirmedAt is set, after the user proved the app produces valid codes.
+type UserTOTP struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	UserID uint `gorm:"not null;uniqueIndex" json:"user_id"`
+	// SecretCiphertext is the base32 secret sealed with the TOTP encryption key
+	SecretCiphertext string     `gorm:"size:255;not null" json:"-"`
+	ConfirmedAt      *time.Time `json:"confirmed_at,omitempty"`
+	// LastUsedStep is the time step of the last accepted code, so a code works only once
+	LastUsedStep int64     `gorm:"not null;default:0" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the authenticator is lost
+type RecoveryCode struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	// CodeHash is a keyed hash of the code; the code is only shown once, when issued
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
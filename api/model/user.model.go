@@ -2,6 +2,15 @@ package model
 
 import "time"
 
+// UserStatus is the lifecycle state of a user
+type UserStatus string
+
+const (
+	// UserStatusPendingVerification is a registered user whose email address is not confirmed yet
+	UserStatusPendingVerification UserStatus = "pending_verification"
+	UserStatusActive              UserStatus = "active"
+)
+
 // User represents a user in the system
 type User struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
@@ -9,6 +18,16 @@ type User struct {
 	Name      string    `gorm:"size:255;not null" json:"name"`
 	Phone     string    `gorm:"size:20" json:"phone"`
 	Address   string    `gorm:"type:text" json:"address"`
+	// PreferredChannel is where verification codes go when the client does not pick a channel
+	PreferredChannel ContactChannel `gorm:"size:20;not null;default:'email'" json:"preferred_channel"`
+	// Locale selects the language of notifications, e.g. en or zh-TW
+	Locale    string    `gorm:"size:10;not null;default:'en'" json:"locale"`
+	// Status defaults to active so users from before email verification keep their access
+	Status          UserStatus `gorm:"size:30;not null;default:'active';index" json:"status"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// VerificationSentAt is when the last verification email was queued; it throttles resends
+	VerificationSentAt *time.Time `json:"-"`
+	Contacts  []UserContact `gorm:"foreignKey:UserID" json:"contacts,omitempty"`
 	RoleID    *uint     `gorm:"column:role_id" json:"role_id,omitempty"`
 	Role      *Role     `gorm:"foreignKey:RoleID" json:"role,omitempty"`
 	Password  *UserPassword `gorm:"foreignKey:UserID" json:"password,omitempty"`
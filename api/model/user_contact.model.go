@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// ContactChannel is a channel through which a user can be reached
+type ContactChannel string
+
+const (
+	ContactChannelEmail ContactChannel = "email"
+	ContactChannelSMS   ContactChannel = "sms"
+)
+
+// UserContact is a contact address of a user for one channel, e.g. the email address
+// used for verification codes. VerifiedAt is set once the user proved they own it.
+type UserContact struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	UserID     uint           `gorm:"not null;uniqueIndex:idx_user_contact_channel" json:"user_id"`
+	Channel    ContactChannel `gorm:"size:20;not null;uniqueIndex:idx_user_contact_channel" json:"channel"`
+	Value      string         `gorm:"size:255;not null" json:"value"`
+	VerifiedAt *time.Time     `json:"verified_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// IsVerified reports whether the user proved ownership of the contact
+func (c *UserContact) IsVerified() bool {
+	return c.VerifiedAt != nil
+}
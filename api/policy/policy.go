@@ -0,0 +1,170 @@
+// Package policy decides whether a subject may perform an action on a resource. The rules
+// only look at the values they are given; callers load the resource and the subject's
+// permissions, so the same rules apply in middleware, handlers and services.
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"go-gin-template/api/model"
+)
+
+// ErrForbidden is returned when a rule denies the action
+var ErrForbidden = errors.New("access denied")
+
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	// ActionTransact moves money: deposits, withdrawals and transfers out of an account
+	ActionTransact Action = "transact"
+	// ActionVerify confirms a pending transfer
+	ActionVerify Action = "verify"
+)
+
+// Subject is the user performing an action, with the permissions granted by their role
+type Subject struct {
+	UserID      uint
+	Permissions []string
+}
+
+// Owner is a subject known only by its user ID, for rules that never defer to permissions
+func Owner(userID uint) Subject {
+	return Subject{UserID: userID}
+}
+
+// Can reports whether the subject's role grants the permission
+func (s Subject) Can(permission string) bool {
+	for _, granted := range s.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource is something a subject acts on. Each resource type carries its own rules.
+type Resource interface {
+	allows(subject Subject, action Action) bool
+	String() string
+}
+
+// Authorize returns ErrForbidden unless the rules of the resource allow the action
+func Authorize(subject Subject, action Action, resource Resource) error {
+	if subject.UserID == 0 || !resource.allows(subject, action) {
+		return fmt.Errorf("%w: cannot %s %s", ErrForbidden, action, resource)
+	}
+	return nil
+}
+
+// User is a user profile. Users read and update their own profile; staff need users:read
+// or users:write for other profiles.
+type User struct {
+	ID uint
+}
+
+func (r User) allows(subject Subject, action Action) bool {
+	self := subject.UserID == r.ID
+	switch action {
+	case ActionRead:
+		return self || subject.Can(model.PermissionUsersRead)
+	case ActionUpdate:
+		return self || subject.Can(model.PermissionUsersWrite)
+	}
+	return false
+}
+
+func (r User) String() string {
+	return fmt.Sprintf("user %d", r.ID)
+}
+
+// Account is a bank account. Only the owner moves money; accounts:read lets staff look at
+// the accounts of others.
+type Account struct {
+	ID      uint
+	OwnerID uint
+}
+
+// AccountOf describes a loaded account
+func AccountOf(account *model.Account) Account {
+	return Account{ID: account.ID, OwnerID: account.UserID}
+}
+
+func (r Account) allows(subject Subject, action Action) bool {
+	owner := r.OwnerID != 0 && subject.UserID == r.OwnerID
+	switch action {
+	case ActionRead:
+		return owner || subject.Can(model.PermissionAccountsRead)
+	case ActionTransact:
+		return owner
+	}
+	return false
+}
+
+func (r Account) String() string {
+	return fmt.Sprintf("account %d", r.ID)
+}
+
+// Transaction is a movement of money. Both sides may read it; only the owner of the source
+// account can verify a transfer out of it.
+type Transaction struct {
+	ID uint
+	// SourceOwnerID and TargetOwnerID are zero when the transaction has no such side,
+	// like the source of a deposit
+	SourceOwnerID uint
+	TargetOwnerID uint
+}
+
+// TransactionOf describes a transaction loaded with its accounts
+func TransactionOf(transaction *model.Transaction) Transaction {
+	resource := Transaction{ID: transaction.ID}
+	if transaction.FromAccount != nil {
+		resource.SourceOwnerID = transaction.FromAccount.UserID
+	}
+	if transaction.ToAccount != nil {
+		resource.TargetOwnerID = transaction.ToAccount.UserID
+	}
+	return resource
+}
+
+func (r Transaction) allows(subject Subject, action Action) bool {
+	source := r.SourceOwnerID != 0 && subject.UserID == r.SourceOwnerID
+	target := r.TargetOwnerID != 0 && subject.UserID == r.TargetOwnerID
+	switch action {
+	case ActionRead:
+		return source || target || subject.Can(model.PermissionAccountsRead)
+	case ActionVerify:
+		return source
+	}
+	return false
+}
+
+func (r Transaction) String() string {
+	return fmt.Sprintf("transaction %d", r.ID)
+}
+
+// Verification is a code check guarding a transfer. It belongs to the user it was issued
+// to, and nobody else may see or use it.
+type Verification struct {
+	ID      uint
+	OwnerID uint
+}
+
+// VerificationOf describes a loaded verification
+func VerificationOf(verification *model.TransactionVerification) Verification {
+	return Verification{ID: verification.ID, OwnerID: verification.UserID}
+}
+
+func (r Verification) allows(subject Subject, action Action) bool {
+	switch action {
+	case ActionRead, ActionVerify:
+		return r.OwnerID != 0 && subject.UserID == r.OwnerID
+	}
+	return false
+}
+
+func (r Verification) String() string {
+	return fmt.Sprintf("verification %d", r.ID)
+}
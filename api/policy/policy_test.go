@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"testing"
+
+	"go-gin-template/api/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rule struct {
+	name     string
+	subject  Subject
+	action   Action
+	resource Resource
+	allowed  bool
+}
+
+func checkRules(t *testing.T, rules []rule) {
+	for _, tt := range rules {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Authorize(tt.subject, tt.action, tt.resource)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrForbidden)
+		})
+	}
+}
+
+var (
+	alice  = Owner(1)
+	bob    = Owner(2)
+	teller = Subject{UserID: 3, Permissions: []string{model.PermissionUsersRead, model.PermissionAccountsRead}}
+	admin  = Subject{UserID: 4, Permissions: []string{model.PermissionUsersRead, model.PermissionUsersWrite, model.PermissionAccountsRead}}
+)
+
+func TestUserRules(t *testing.T) {
+	profile := User{ID: 1}
+
+	checkRules(t, []rule{
+		{"self reads", alice, ActionRead, profile, true},
+		{"self updates", alice, ActionUpdate, profile, true},
+		{"other user cannot read", bob, ActionRead, profile, false},
+		{"other user cannot update", bob, ActionUpdate, profile, false},
+		{"users:read reads", teller, ActionRead, profile, true},
+		{"users:read cannot update", teller, ActionUpdate, profile, false},
+		{"users:write updates", admin, ActionUpdate, profile, true},
+		{"unknown action", alice, ActionTransact, profile, false},
+		// 沒有使用者 ID 的主體一律拒絕，即使資源 ID 也是 0
+		{"anonymous", Subject{}, ActionRead, User{}, false},
+	})
+}
+
+func TestAccountRules(t *testing.T) {
+	account := Account{ID: 10, OwnerID: 1}
+
+	checkRules(t, []rule{
+		{"owner reads", alice, ActionRead, account, true},
+		{"owner transacts", alice, ActionTransact, account, true},
+		{"other user cannot read", bob, ActionRead, account, false},
+		{"other user cannot transact", bob, ActionTransact, account, false},
+		{"accounts:read reads", teller, ActionRead, account, true},
+		// 只有帳戶持有人能動用資金，管理員也不行
+		{"accounts:read cannot transact", admin, ActionTransact, account, false},
+		{"unknown action", alice, ActionVerify, account, false},
+		{"account without owner", Owner(0), ActionRead, Account{ID: 11}, false},
+	})
+}
+
+func TestTransactionRules(t *testing.T) {
+	transfer := Transaction{ID: 20, SourceOwnerID: 1, TargetOwnerID: 2}
+	deposit := Transaction{ID: 21, TargetOwnerID: 1}
+
+	checkRules(t, []rule{
+		{"source owner reads", alice, ActionRead, transfer, true},
+		{"target owner reads", bob, ActionRead, transfer, true},
+		{"source owner verifies", alice, ActionVerify, transfer, true},
+		{"target owner cannot verify", bob, ActionVerify, transfer, false},
+		{"stranger cannot read", Owner(5), ActionRead, transfer, false},
+		{"accounts:read reads", teller, ActionRead, transfer, true},
+		{"accounts:read cannot verify", admin, ActionVerify, transfer, false},
+		{"deposit has no source to verify", alice, ActionVerify, deposit, false},
+		{"unknown action", alice, ActionUpdate, transfer, false},
+	})
+}
+
+func TestVerificationRules(t *testing.T) {
+	verification := Verification{ID: 30, OwnerID: 1}
+
+	checkRules(t, []rule{
+		{"owner reads", alice, ActionRead, verification, true},
+		{"owner verifies", alice, ActionVerify, verification, true},
+		{"other user cannot read", bob, ActionRead, verification, false},
+		{"other user cannot verify", bob, ActionVerify, verification, false},
+		// 驗證碼只屬於收到它的人，任何權限都無法代為驗證
+		{"admin cannot verify", admin, ActionVerify, verification, false},
+		{"unknown action", alice, ActionUpdate, verification, false},
+	})
+}
+
+func TestResourcesFromModels(t *testing.T) {
+	source := &model.Account{ID: 10, UserID: 1}
+	target := &model.Account{ID: 11, UserID: 2}
+
+	assert.Equal(t, Account{ID: 10, OwnerID: 1}, AccountOf(source))
+	assert.Equal(t, Transaction{ID: 20, SourceOwnerID: 1, TargetOwnerID: 2},
+		TransactionOf(&model.Transaction{ID: 20, FromAccount: source, ToAccount: target}))
+	assert.Equal(t, Transaction{ID: 21, TargetOwnerID: 2},
+		TransactionOf(&model.Transaction{ID: 21, ToAccount: target}))
+	assert.Equal(t, Verification{ID: 30, OwnerID: 1},
+		VerificationOf(&model.TransactionVerification{ID: 30, UserID: 1}))
+}
@@ -1,18 +1,33 @@
 package repository
 
 import (
+	"errors"
+	"sort"
+	"time"
+
 	"go-gin-template/api/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrConcurrentUpdate is returned when an account row changed since it was read
+var ErrConcurrentUpdate = errors.New("account was modified concurrently")
+
 type AccountRepository interface {
 	Create(account *model.Account) error
 	FindByID(id uint) (*model.Account, error)
 	FindByUserID(userID uint) ([]*model.Account, error)
 	FindDefaultByUserID(userID uint) (*model.Account, error)
+	// FindByIDForUpdate reads an account with SELECT ... FOR UPDATE; call it inside a DB transaction
+	FindByIDForUpdate(id uint) (*model.Account, error)
+	// FindByIDsForUpdate locks several accounts in ascending ID order to avoid deadlocks
+	FindByIDsForUpdate(ids ...uint) (map[uint]*model.Account, error)
 	Update(account *model.Account) error
+	// UpdateBalance writes the balance and nonce only if the stored nonce still equals expectedNonce
+	UpdateBalance(account *model.Account, expectedNonce int) error
 	GetDB() *gorm.DB
+	WithTx(tx *gorm.DB) AccountRepository
 }
 
 type accountRepository struct {
@@ -54,10 +69,59 @@ func (r *accountRepository) FindDefaultByUserID(userID uint) (*model.Account, er
 	return &account, nil
 }
 
+func (r *accountRepository) FindByIDForUpdate(id uint) (*model.Account, error) {
+	var account model.Account
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *accountRepository) FindByIDsForUpdate(ids ...uint) (map[uint]*model.Account, error) {
+	sorted := append([]uint(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	accounts := make(map[uint]*model.Account, len(sorted))
+	for _, id := range sorted {
+		if _, locked := accounts[id]; locked {
+			continue
+		}
+		account, err := r.FindByIDForUpdate(id)
+		if err != nil {
+			return nil, err
+		}
+		accounts[id] = account
+	}
+	return accounts, nil
+}
+
 func (r *accountRepository) Update(account *model.Account) error {
 	return r.db.Save(account).Error
 }
 
+func (r *accountRepository) UpdateBalance(account *model.Account, expectedNonce int) error {
+	account.UpdatedAt = time.Now()
+	result := r.db.Model(&model.Account{}).
+		Where("id = ? AND nonce = ?", account.ID, expectedNonce).
+		Updates(map[string]interface{}{
+			"balance":    account.Balance,
+			"nonce":      account.Nonce,
+			"updated_at": account.UpdatedAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConcurrentUpdate
+	}
+	return nil
+}
+
 func (r *accountRepository) GetDB() *gorm.DB {
 	return r.db
 }
+
+func (r *accountRepository) WithTx(tx *gorm.DB) AccountRepository {
+	return &accountRepository{db: tx}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-gin-template/api/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAPIKeyStatus is returned when a key was rotated or revoked in the meantime
+	ErrAPIKeyStatus = errors.New("API key was already rotated or revoked")
+	// ErrAPIKeyLimit is returned when the user already has the maximum number of active keys
+	ErrAPIKeyLimit = errors.New("maximum number of active API keys reached")
+)
+
+type APIKeyRepository interface {
+	// CreateWithinLimit creates the key unless its user already has limit active keys, and
+	// fails with ErrAPIKeyLimit then. The user row is locked while counting, so concurrent
+	// requests cannot together exceed the limit.
+	CreateWithinLimit(key *model.APIKey, limit int) error
+	FindByID(id uint) (*model.APIKey, error)
+	// FindByKeyID loads the key with its user and the user's role
+	FindByKeyID(keyID string) (*model.APIKey, error)
+	// FindActiveByUserID returns the keys of the user that are not revoked or expired
+	FindActiveByUserID(userID uint) ([]*model.APIKey, error)
+	// Rotate sets the expiry of a key that was not rotated or revoked before and creates
+	// its replacement, failing with ErrAPIKeyStatus otherwise
+	Rotate(id uint, expiresAt time.Time, replacement *model.APIKey) error
+	Revoke(id uint) error
+	// TouchLastUsed records use of the key, writing to the database at most once per interval
+	TouchLastUsed(id uint, interval time.Duration) error
+	// ClaimNonce records the nonce of a signed request for ttl. It reports false when the
+	// key already used the nonce, i.e. the request is a replay.
+	ClaimNonce(keyID, nonce string, ttl time.Duration) (bool, error)
+}
+
+type apiKeyRepository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+func NewAPIKeyRepository(db *gorm.DB, rdb *redis.Client) APIKeyRepository {
+	return &apiKeyRepository{db: db, rdb: rdb}
+}
+
+func (r *apiKeyRepository) CreateWithinLimit(key *model.APIKey, limit int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, key.UserID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := active(tx, key.UserID).Model(&model.APIKey{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return ErrAPIKeyLimit
+		}
+		return tx.Create(key).Error
+	})
+}
+
+func (r *apiKeyRepository) FindByID(id uint) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := r.db.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) FindByKeyID(keyID string) (*model.APIKey, error) {
+	var key model.APIKey
+	if err := r.db.Preload("User.Role").Where("key_id = ?", keyID).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) FindActiveByUserID(userID uint) ([]*model.APIKey, error) {
+	var keys []*model.APIKey
+	err := active(r.db, userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// active scopes db to the keys of the user that are not revoked or expired
+func active(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now())
+}
+
+func (r *apiKeyRepository) Rotate(id uint, expiresAt time.Time, replacement *model.APIKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.APIKey{}).
+			Where("id = ? AND revoked_at IS NULL AND expires_at IS NULL", id).
+			Update("expires_at", expiresAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAPIKeyStatus
+		}
+		return tx.Create(replacement).Error
+	})
+}
+
+func (r *apiKeyRepository) Revoke(id uint) error {
+	return r.db.Model(&model.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *apiKeyRepository) TouchLastUsed(id uint, interval time.Duration) error {
+	// The Redis marker throttles writes across all instances of the API
+	acquired, err := r.rdb.SetNX(context.Background(), fmt.Sprintf("apikey:used:%d", id), 1, interval).Result()
+	if err != nil || !acquired {
+		return err
+	}
+
+	return r.db.Model(&model.APIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", time.Now()).Error
+}
+
+func (r *apiKeyRepository) ClaimNonce(keyID, nonce string, ttl time.Duration) (bool, error) {
+	return r.rdb.SetNX(context.Background(), "apikey:nonce:"+keyID+":"+nonce, 1, ttl).Result()
+}
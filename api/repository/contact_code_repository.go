@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-gin-template/api/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// ContactCode is a code sent to a contact value to prove the user owns it
+type ContactCode struct {
+	// Value is the contact value the code was sent to; the code only confirms that value
+	Value    string    `json:"value"`
+	CodeHash string    `json:"code_hash"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// ContactCodeRepository keeps the pending contact confirmation of each user and channel in
+// Redis until it expires
+type ContactCodeRepository interface {
+	// Create replaces the pending code of the channel and forgets the failures of the old one
+	Create(userID uint, channel model.ContactChannel, code *ContactCode, ttl time.Duration) error
+	// Find returns gorm.ErrRecordNotFound when no code is pending or it expired
+	Find(userID uint, channel model.ContactChannel) (*ContactCode, error)
+	// RecordFailure counts a wrong code and returns the number of failures so far
+	RecordFailure(userID uint, channel model.ContactChannel) (int64, error)
+	Delete(userID uint, channel model.ContactChannel) error
+}
+
+type contactCodeRepository struct {
+	rdb *redis.Client
+}
+
+func NewContactCodeRepository(rdb *redis.Client) ContactCodeRepository {
+	return &contactCodeRepository{rdb: rdb}
+}
+
+func contactCodeKey(userID uint, channel model.ContactChannel) string {
+	return fmt.Sprintf("contact:code:%d:%s", userID, channel)
+}
+
+func contactCodeFailuresKey(userID uint, channel model.ContactChannel) string {
+	return contactCodeKey(userID, channel) + ":failures"
+}
+
+func (r *contactCodeRepository) Create(userID uint, channel model.ContactChannel, code *ContactCode, ttl time.Duration) error {
+	ctx := context.Background()
+	payload, _ := json.Marshal(code)
+
+	pipe := r.rdb.TxPipeline()
+	pipe.Set(ctx, contactCodeKey(userID, channel), payload, ttl)
+	pipe.Del(ctx, contactCodeFailuresKey(userID, channel))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *contactCodeRepository) Find(userID uint, channel model.ContactChannel) (*ContactCode, error) {
+	data, err := r.rdb.Get(context.Background(), contactCodeKey(userID, channel)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var code ContactCode
+	if err := json.Unmarshal(data, &code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *contactCodeRepository) RecordFailure(userID uint, channel model.ContactChannel) (int64, error) {
+	ctx := context.Background()
+	key := contactCodeFailuresKey(userID, channel)
+
+	failures, err := r.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// The counter lives no longer than the code it belongs to
+	if ttl, err := r.rdb.PTTL(ctx, contactCodeKey(userID, channel)).Result(); err == nil && ttl > 0 {
+		r.rdb.PExpire(ctx, key, ttl)
+	}
+	return failures, nil
+}
+
+func (r *contactCodeRepository) Delete(userID uint, channel model.ContactChannel) error {
+	return r.rdb.Del(context.Background(), contactCodeKey(userID, channel), contactCodeFailuresKey(userID, channel)).Err()
+}
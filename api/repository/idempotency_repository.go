@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-gin-template/api/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// IdempotencyLockTTL is how long an in-flight request holds its key without renewing it
+	// before a retry may take over
+	IdempotencyLockTTL = time.Minute
+	// idempotencyRetention is how long a completed response is replayed
+	idempotencyRetention = 24 * time.Hour
+)
+
+// ErrIdempotencyLockLost is returned when the request no longer holds its key, because the
+// lock expired and a retry took it over
+var ErrIdempotencyLockLost = errors.New("idempotency key is no longer held by this request")
+
+type IdempotencyRepository interface {
+	// Begin reserves key for a new request made by owner. When the key is already known it
+	// returns the existing record instead and reserved is false.
+	Begin(userID uint, key, requestHash, owner string) (existing *model.IdempotencyRecord, reserved bool, err error)
+	// Extend renews the lock of a request that is still being processed
+	Extend(userID uint, key, owner string) error
+	// Complete stores the response of the request that reserved the key
+	Complete(userID uint, key, owner string, status int, body []byte) error
+	// Release forgets the key so that the request can be retried, e.g. after a server error
+	Release(userID uint, key, owner string) error
+}
+
+type idempotencyRepository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewIdempotencyRepository stores keys in Postgres. Completed responses are cached in Redis,
+// so replays do not hit the database; the cache is optional and Postgres decides.
+func NewIdempotencyRepository(rdb *redis.Client, db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db, rdb: rdb}
+}
+
+func idempotencyCacheKey(userID uint, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userID, key)
+}
+
+func (r *idempotencyRepository) Begin(userID uint, key, requestHash, owner string) (*model.IdempotencyRecord, bool, error) {
+	if cached := r.cached(userID, key); cached != nil {
+		return cached, false, nil
+	}
+
+	now := time.Now()
+	record := &model.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      model.IdempotencyStatusProcessing,
+		LockOwner:   owner,
+		LockedUntil: now.Add(IdempotencyLockTTL),
+		ExpiresAt:   now.Add(idempotencyRetention),
+	}
+
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	var existing model.IdempotencyRecord
+	if err := r.db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+
+	// Take over records that expired or whose request died while processing
+	stale := existing.ExpiresAt.Before(now) ||
+		(existing.Status == model.IdempotencyStatusProcessing && existing.LockedUntil.Before(now))
+	if stale {
+		takeover := r.db.Model(&model.IdempotencyRecord{}).
+			Where("id = ? AND updated_at = ?", existing.ID, existing.UpdatedAt).
+			Updates(map[string]interface{}{
+				"request_hash":    requestHash,
+				"status":          model.IdempotencyStatusProcessing,
+				"response_status": 0,
+				"response_body":   "",
+				"lock_owner":      owner,
+				"locked_until":    record.LockedUntil,
+				"expires_at":      record.ExpiresAt,
+			})
+		if takeover.Error != nil {
+			return nil, false, takeover.Error
+		}
+		if takeover.RowsAffected == 1 {
+			return nil, true, nil
+		}
+	}
+
+	if existing.Status == model.IdempotencyStatusCompleted {
+		r.cache(&existing)
+	}
+	return &existing, false, nil
+}
+
+func (r *idempotencyRepository) Extend(userID uint, key, owner string) error {
+	return r.whileHeld(userID, key, owner, map[string]interface{}{
+		"locked_until": time.Now().Add(IdempotencyLockTTL),
+	})
+}
+
+func (r *idempotencyRepository) Complete(userID uint, key, owner string, status int, body []byte) error {
+	now := time.Now()
+	err := r.whileHeld(userID, key, owner, map[string]interface{}{
+		"status":          model.IdempotencyStatusCompleted,
+		"response_status": status,
+		"response_body":   string(body),
+		"lock_owner":      "",
+		"locked_until":    now,
+		"expires_at":      now.Add(idempotencyRetention),
+	})
+	if err != nil {
+		return err
+	}
+
+	var record model.IdempotencyRecord
+	if err := r.db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&record).Error; err == nil {
+		r.cache(&record)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Release(userID uint, key, owner string) error {
+	result := r.db.Where("user_id = ? AND idempotency_key = ? AND status = ? AND lock_owner = ?",
+		userID, key, model.IdempotencyStatusProcessing, owner).
+		Delete(&model.IdempotencyRecord{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyLockLost
+	}
+	return nil
+}
+
+// whileHeld applies updates to the record only if owner still holds its lock
+func (r *idempotencyRepository) whileHeld(userID uint, key, owner string, updates map[string]interface{}) error {
+	result := r.db.Model(&model.IdempotencyRecord{}).
+		Where("user_id = ? AND idempotency_key = ? AND status = ? AND lock_owner = ?",
+			userID, key, model.IdempotencyStatusProcessing, owner).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyLockLost
+	}
+	return nil
+}
+
+// cached returns the completed record cached in Redis, or nil when there is none
+func (r *idempotencyRepository) cached(userID uint, key string) *model.IdempotencyRecord {
+	data, err := r.rdb.Get(context.Background(), idempotencyCacheKey(userID, key)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Failed to read cached idempotent response: %v", err)
+		}
+		return nil
+	}
+
+	var record model.IdempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil
+	}
+	return &record
+}
+
+// cache keeps a completed record in Redis until it expires; failures only cost a database read
+func (r *idempotencyRepository) cache(record *model.IdempotencyRecord) {
+	ttl := time.Until(record.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	if err := r.rdb.Set(context.Background(), idempotencyCacheKey(record.UserID, record.Key), payload, ttl).Err(); err != nil {
+		log.Printf("Failed to cache idempotent response: %v", err)
+	}
+}
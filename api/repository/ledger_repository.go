@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"go-gin-template/api/model"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+type LedgerRepository interface {
+	CreateEntry(entry *model.JournalEntry) error
+	FindEntriesByTransactionID(transactionID uint) ([]*model.JournalEntry, error)
+	FindPostingsByAccountID(accountID uint) ([]*model.Posting, error)
+	FindLastPostingByAccountID(accountID uint) (*model.Posting, error)
+	SumAccountPostings(accountID uint) (credits util.Money, debits util.Money, err error)
+	WithTx(tx *gorm.DB) LedgerRepository
+}
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+// CreateEntry inserts the journal entry together with its postings
+func (r *ledgerRepository) CreateEntry(entry *model.JournalEntry) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *ledgerRepository) FindEntriesByTransactionID(transactionID uint) ([]*model.JournalEntry, error) {
+	var entries []*model.JournalEntry
+	err := r.db.Preload("Postings").
+		Where("transaction_id = ?", transactionID).
+		Order("id ASC").
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *ledgerRepository) FindPostingsByAccountID(accountID uint) ([]*model.Posting, error) {
+	var postings []*model.Posting
+	err := r.db.Where("account_id = ?", accountID).Order("id ASC").Find(&postings).Error
+	return postings, err
+}
+
+func (r *ledgerRepository) FindLastPostingByAccountID(accountID uint) (*model.Posting, error) {
+	var posting model.Posting
+	if err := r.db.Where("account_id = ?", accountID).Order("id DESC").First(&posting).Error; err != nil {
+		return nil, err
+	}
+	return &posting, nil
+}
+
+// SumAccountPostings returns the total credited and debited amounts of a customer account
+func (r *ledgerRepository) SumAccountPostings(accountID uint) (util.Money, util.Money, error) {
+	var totals struct {
+		Credits util.Money
+		Debits  util.Money
+	}
+	err := r.db.Model(&model.Posting{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE 0 END), 0) AS credits, "+
+			"COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE 0 END), 0) AS debits",
+			model.PostingDirectionCredit, model.PostingDirectionDebit).
+		Where("account_id = ?", accountID).
+		Scan(&totals).Error
+	return totals.Credits, totals.Debits, err
+}
+
+func (r *ledgerRepository) WithTx(tx *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: tx}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginAttemptRepository counts failed logins per email and per IP in Redis. Counters,
+// delays and locks all expire on their own.
+type LoginAttemptRepository interface {
+	// RecordFailure counts a failed login and returns the failures of the email and of the IP
+	// within the window, which restarts with every failure
+	RecordFailure(email, ip string, window time.Duration) (emailFailures, ipFailures int64, err error)
+	// Delay rejects attempts for the email and the IP until their delays pass; zero means no delay
+	Delay(email, ip string, emailDelay, ipDelay time.Duration) error
+	// RetryAfter returns how long the email or the IP must still wait, zero when an attempt is allowed
+	RetryAfter(email, ip string) (time.Duration, error)
+	// Lock locks the email and reports whether it was not locked already
+	Lock(email string, ttl time.Duration) (bool, error)
+	IsLocked(email string) (bool, error)
+	// Clear forgets the failures, delay and lock of the email
+	Clear(email string) error
+}
+
+type loginAttemptRepository struct {
+	rdb *redis.Client
+}
+
+func NewLoginAttemptRepository(rdb *redis.Client) LoginAttemptRepository {
+	return &loginAttemptRepository{rdb: rdb}
+}
+
+func loginFailuresKey(subject string) string {
+	return "login:failures:" + subject
+}
+
+func loginDelayKey(subject string) string {
+	return "login:delay:" + subject
+}
+
+func loginLockKey(email string) string {
+	return "login:lock:email:" + email
+}
+
+func (r *loginAttemptRepository) RecordFailure(email, ip string, window time.Duration) (int64, int64, error) {
+	ctx := context.Background()
+	pipe := r.rdb.TxPipeline()
+	emailCount := pipe.Incr(ctx, loginFailuresKey("email:"+email))
+	pipe.Expire(ctx, loginFailuresKey("email:"+email), window)
+	ipCount := pipe.Incr(ctx, loginFailuresKey("ip:"+ip))
+	pipe.Expire(ctx, loginFailuresKey("ip:"+ip), window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+	return emailCount.Val(), ipCount.Val(), nil
+}
+
+func (r *loginAttemptRepository) Delay(email, ip string, emailDelay, ipDelay time.Duration) error {
+	ctx := context.Background()
+	pipe := r.rdb.TxPipeline()
+	if emailDelay > 0 {
+		pipe.Set(ctx, loginDelayKey("email:"+email), 1, emailDelay)
+	}
+	if ipDelay > 0 {
+		pipe.Set(ctx, loginDelayKey("ip:"+ip), 1, ipDelay)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *loginAttemptRepository) RetryAfter(email, ip string) (time.Duration, error) {
+	ctx := context.Background()
+	pipe := r.rdb.Pipeline()
+	emailTTL := pipe.PTTL(ctx, loginDelayKey("email:"+email))
+	ipTTL := pipe.PTTL(ctx, loginDelayKey("ip:"+ip))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	// PTTL is negative for keys that do not exist
+	wait := emailTTL.Val()
+	if ipTTL.Val() > wait {
+		wait = ipTTL.Val()
+	}
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+func (r *loginAttemptRepository) Lock(email string, ttl time.Duration) (bool, error) {
+	return r.rdb.SetNX(context.Background(), loginLockKey(email), 1, ttl).Result()
+}
+
+func (r *loginAttemptRepository) IsLocked(email string) (bool, error) {
+	count, err := r.rdb.Exists(context.Background(), loginLockKey(email)).Result()
+	return count > 0, err
+}
+
+func (r *loginAttemptRepository) Clear(email string) error {
+	return r.rdb.Del(context.Background(),
+		loginFailuresKey("email:"+email),
+		loginDelayKey("email:"+email),
+		loginLockKey(email),
+	).Err()
+}
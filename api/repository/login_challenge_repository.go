@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// LoginChallenge is a login whose password was verified and that waits for the second factor
+type LoginChallenge struct {
+	UserID     uint   `json:"user_id"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	DeviceName string `json:"device_name"`
+}
+
+// LoginChallengeRepository keeps pending two-factor logins in Redis until they expire
+type LoginChallengeRepository interface {
+	Create(tokenHash string, challenge *LoginChallenge, ttl time.Duration) error
+	// Find returns gorm.ErrRecordNotFound when the challenge does not exist or expired
+	Find(tokenHash string) (*LoginChallenge, error)
+	// RecordFailure counts a wrong code and returns the number of failures so far
+	RecordFailure(tokenHash string) (int64, error)
+	Delete(tokenHash string) error
+}
+
+type loginChallengeRepository struct {
+	rdb *redis.Client
+}
+
+func NewLoginChallengeRepository(rdb *redis.Client) LoginChallengeRepository {
+	return &loginChallengeRepository{rdb: rdb}
+}
+
+func loginChallengeKey(tokenHash string) string {
+	return "login:challenge:" + tokenHash
+}
+
+func loginChallengeFailuresKey(tokenHash string) string {
+	return "login:challenge:" + tokenHash + ":failures"
+}
+
+func (r *loginChallengeRepository) Create(tokenHash string, challenge *LoginChallenge, ttl time.Duration) error {
+	payload, _ := json.Marshal(challenge)
+	return r.rdb.Set(context.Background(), loginChallengeKey(tokenHash), payload, ttl).Err()
+}
+
+func (r *loginChallengeRepository) Find(tokenHash string) (*LoginChallenge, error) {
+	data, err := r.rdb.Get(context.Background(), loginChallengeKey(tokenHash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge LoginChallenge
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *loginChallengeRepository) RecordFailure(tokenHash string) (int64, error) {
+	ctx := context.Background()
+	key := loginChallengeFailuresKey(tokenHash)
+
+	failures, err := r.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// The counter lives no longer than the challenge it belongs to
+	if ttl, err := r.rdb.PTTL(ctx, loginChallengeKey(tokenHash)).Result(); err == nil && ttl > 0 {
+		r.rdb.PExpire(ctx, key, ttl)
+	}
+	return failures, nil
+}
+
+func (r *loginChallengeRepository) Delete(tokenHash string) error {
+	return r.rdb.Del(context.Background(), loginChallengeKey(tokenHash), loginChallengeFailuresKey(tokenHash)).Err()
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-gin-template/api/model"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrNotificationNotDead is returned when replaying a message that did not fail permanently
+	ErrNotificationNotDead = errors.New("only dead notifications can be replayed")
+	// ErrNotificationExpired is returned when replaying a message whose content was discarded
+	ErrNotificationExpired = errors.New("notification held a one-time code or link and cannot be replayed")
+	// ErrNotificationLeaseLost is returned when a worker records the outcome of a message it
+	// no longer holds, because its lease expired and another worker claimed the message
+	ErrNotificationLeaseLost = errors.New("notification lease is held by another worker")
+)
+
+type NotificationRepository interface {
+	Create(message *model.NotificationMessage) error
+	FindByID(id uint) (*model.NotificationMessage, error)
+	// FindByStatus lists messages with the given status, newest first; an empty status lists all
+	FindByStatus(status model.NotificationStatus, limit, offset int) ([]*model.NotificationMessage, int64, error)
+	// ClaimDue leases up to limit messages that are due for delivery. Messages whose lease
+	// expired, e.g. because a worker died, are claimed again. Each claimed message carries
+	// the LeaseOwner that must be passed when its outcome is recorded.
+	ClaimDue(limit int, lease time.Duration) ([]*model.NotificationMessage, error)
+	MarkSent(id uint, owner string) error
+	// MarkFailed records a failed attempt and schedules the next one at nextAttemptAt
+	MarkFailed(id uint, owner, lastError string, nextAttemptAt time.Time) error
+	// MarkDead gives up on a message. Unless it is replayable, its payload is discarded.
+	MarkDead(id uint, owner, lastError string) error
+	// Replay moves a dead, replayable message back to pending with a fresh set of attempts
+	Replay(id uint) error
+	UpdateProviderStatus(id uint, status, providerError string) error
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Create(message *model.NotificationMessage) error {
+	if message.Status == "" {
+		message.Status = model.NotificationStatusPending
+	}
+	if message.NextAttemptAt.IsZero() {
+		message.NextAttemptAt = time.Now()
+	}
+	return r.db.Create(message).Error
+}
+
+func (r *notificationRepository) FindByID(id uint) (*model.NotificationMessage, error) {
+	var message model.NotificationMessage
+	if err := r.db.First(&message, id).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *notificationRepository) FindByStatus(status model.NotificationStatus, limit, offset int) ([]*model.NotificationMessage, int64, error) {
+	query := r.db.Model(&model.NotificationMessage{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var messages []*model.NotificationMessage
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, total, err
+}
+
+func (r *notificationRepository) ClaimDue(limit int, lease time.Duration) ([]*model.NotificationMessage, error) {
+	var messages []*model.NotificationMessage
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// SKIP LOCKED lets several workers claim disjoint batches without waiting on each other
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				model.NotificationStatusPending, now, model.NotificationStatusProcessing, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+
+		owner, err := util.GenerateOpaqueToken(16)
+		if err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(lease)
+		err = tx.Model(&model.NotificationMessage{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":       model.NotificationStatusProcessing,
+				"lease_owner":  owner,
+				"locked_until": lockedUntil,
+				"attempts":     gorm.Expr("attempts + 1"),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			message.Status = model.NotificationStatusProcessing
+			message.LeaseOwner = owner
+			message.LockedUntil = &lockedUntil
+			message.Attempts++
+		}
+		return nil
+	})
+
+	return messages, err
+}
+
+func (r *notificationRepository) MarkSent(id uint, owner string) error {
+	// The payload may contain secrets such as verification codes; drop it once delivered
+	return r.whileLeased(id, owner, map[string]interface{}{
+		"status":       model.NotificationStatusSent,
+		"sent_at":      time.Now(),
+		"lease_owner":  "",
+		"locked_until": nil,
+		"last_error":   "",
+		"payload":      "",
+	})
+}
+
+func (r *notificationRepository) MarkFailed(id uint, owner, lastError string, nextAttemptAt time.Time) error {
+	return r.whileLeased(id, owner, map[string]interface{}{
+		"status":          model.NotificationStatusPending,
+		"next_attempt_at": nextAttemptAt,
+		"lease_owner":     "",
+		"locked_until":    nil,
+		"last_error":      lastError,
+	})
+}
+
+func (r *notificationRepository) MarkDead(id uint, owner, lastError string) error {
+	// Codes and links must not outlive their message; they would be expired by the time
+	// anyone replays it
+	return r.whileLeased(id, owner, map[string]interface{}{
+		"status":       model.NotificationStatusDead,
+		"lease_owner":  "",
+		"locked_until": nil,
+		"last_error":   lastError,
+		"payload":      gorm.Expr("CASE WHEN replayable THEN payload ELSE '' END"),
+	})
+}
+
+// whileLeased applies updates to the message only if owner still holds its lease
+func (r *notificationRepository) whileLeased(id uint, owner string, updates map[string]interface{}) error {
+	result := r.db.Model(&model.NotificationMessage{}).
+		Where("id = ? AND status = ? AND lease_owner = ?", id, model.NotificationStatusProcessing, owner).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotificationLeaseLost
+	}
+	return nil
+}
+
+func (r *notificationRepository) Replay(id uint) error {
+	result := r.db.Model(&model.NotificationMessage{}).
+		Where("id = ? AND status = ? AND replayable AND payload <> ''", id, model.NotificationStatusDead).
+		Updates(map[string]interface{}{
+			"status":          model.NotificationStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		message, err := r.FindByID(id)
+		if err != nil {
+			return err
+		}
+		if message.Status != model.NotificationStatusDead {
+			return ErrNotificationNotDead
+		}
+		return ErrNotificationExpired
+	}
+	return nil
+}
+
+func (r *notificationRepository) UpdateProviderStatus(id uint, status, providerError string) error {
+	result := r.db.Model(&model.NotificationMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"provider_status": status,
+			"provider_error":  providerError,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
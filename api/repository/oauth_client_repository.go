@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OAuthClientRepository interface {
+	Create(client *model.OAuthClient) error
+	FindByID(id uint) (*model.OAuthClient, error)
+	FindByClientID(clientID string) (*model.OAuthClient, error)
+	FindByOwnerID(ownerID uint) ([]*model.OAuthClient, error)
+	// Delete removes the client with its codes, refresh tokens and OAuth and open banking consents
+	Delete(id uint) error
+	FindConsent(userID, clientID uint) (*model.OAuthConsent, error)
+	// SaveConsent creates the consent of the user for the client or replaces its scope
+	SaveConsent(consent *model.OAuthConsent) error
+}
+
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+func (r *oauthClientRepository) Create(client *model.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *oauthClientRepository) FindByID(id uint) (*model.OAuthClient, error) {
+	var client model.OAuthClient
+	if err := r.db.First(&client, id).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) FindByClientID(clientID string) (*model.OAuthClient, error) {
+	var client model.OAuthClient
+	if err := r.db.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) FindByOwnerID(ownerID uint) ([]*model.OAuthClient, error) {
+	var clients []*model.OAuthClient
+	err := r.db.Where("owner_id = ?", ownerID).Order("created_at").Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthClientRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Open banking consents given to the app go with it
+		err := tx.Exec("DELETE FROM open_banking_consent_accounts WHERE open_banking_consent_id IN (SELECT id FROM open_banking_consents WHERE client_id = ?)", id).Error
+		if err != nil {
+			return err
+		}
+		for _, dependent := range []interface{}{&model.OAuthAuthorizationCode{}, &model.OAuthRefreshToken{}, &model.OAuthConsent{}, &model.OpenBankingConsent{}} {
+			if err := tx.Where("client_id = ?", id).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.OAuthClient{}, id).Error
+	})
+}
+
+func (r *oauthClientRepository) FindConsent(userID, clientID uint) (*model.OAuthConsent, error) {
+	var consent model.OAuthConsent
+	if err := r.db.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *oauthClientRepository) SaveConsent(consent *model.OAuthConsent) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scope", "updated_at"}),
+	}).Create(consent).Error
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"time"
+
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OAuthTokenRepository stores the authorization codes and refresh tokens of third-party
+// apps. Access tokens are JWTs and are not stored.
+type OAuthTokenRepository interface {
+	CreateCode(code *model.OAuthAuthorizationCode) error
+	// FindCodeByHashForUpdate reads a code with SELECT ... FOR UPDATE; call it inside a DB transaction
+	FindCodeByHashForUpdate(codeHash string) (*model.OAuthAuthorizationCode, error)
+	// MarkCodeUsed records that the code was exchanged for the given refresh token family
+	MarkCodeUsed(id uint, familyID string) error
+	CreateRefreshToken(token *model.OAuthRefreshToken) error
+	FindRefreshTokenByHash(tokenHash string) (*model.OAuthRefreshToken, error)
+	// FindRefreshTokenByHashForUpdate reads a token with SELECT ... FOR UPDATE; call it inside a DB transaction
+	FindRefreshTokenByHashForUpdate(tokenHash string) (*model.OAuthRefreshToken, error)
+	MarkRefreshTokenRotated(id uint) error
+	RevokeFamily(familyID string) error
+	WithTx(tx *gorm.DB) OAuthTokenRepository
+	GetDB() *gorm.DB
+}
+
+type oauthTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthTokenRepository(db *gorm.DB) OAuthTokenRepository {
+	return &oauthTokenRepository{db: db}
+}
+
+func (r *oauthTokenRepository) CreateCode(code *model.OAuthAuthorizationCode) error {
+	return r.db.Create(code).Error
+}
+
+func (r *oauthTokenRepository) FindCodeByHashForUpdate(codeHash string) (*model.OAuthAuthorizationCode, error) {
+	var code model.OAuthAuthorizationCode
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code_hash = ?", codeHash).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *oauthTokenRepository) MarkCodeUsed(id uint, familyID string) error {
+	return r.db.Model(&model.OAuthAuthorizationCode{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"used_at": time.Now(), "family_id": familyID}).Error
+}
+
+func (r *oauthTokenRepository) CreateRefreshToken(token *model.OAuthRefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *oauthTokenRepository) FindRefreshTokenByHash(tokenHash string) (*model.OAuthRefreshToken, error) {
+	var token model.OAuthRefreshToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *oauthTokenRepository) FindRefreshTokenByHashForUpdate(tokenHash string) (*model.OAuthRefreshToken, error) {
+	var token model.OAuthRefreshToken
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *oauthTokenRepository) MarkRefreshTokenRotated(id uint) error {
+	return r.db.Model(&model.OAuthRefreshToken{}).
+		Where("id = ?", id).
+		Update("rotated_at", time.Now()).Error
+}
+
+func (r *oauthTokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&model.OAuthRefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *oauthTokenRepository) WithTx(tx *gorm.DB) OAuthTokenRepository {
+	return &oauthTokenRepository{db: tx}
+}
+
+func (r *oauthTokenRepository) GetDB() *gorm.DB {
+	return r.db
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrConsentStatus is returned when a consent is no longer in the status a change expects
+var ErrConsentStatus = errors.New("consent is not in the expected status")
+
+type OpenBankingConsentRepository interface {
+	Create(consent *model.OpenBankingConsent) error
+	// FindByID loads the consent with the accounts it covers
+	FindByID(id uint) (*model.OpenBankingConsent, error)
+	FindByUserID(userID uint) ([]*model.OpenBankingConsent, error)
+	// Authorise moves an awaiting consent to authorised and records the accounts it covers
+	Authorise(consent *model.OpenBankingConsent, accounts []*model.Account) error
+	// TransitionStatus moves a consent from one status to another, failing with
+	// ErrConsentStatus if it is no longer in from
+	TransitionStatus(id uint, from, to model.ConsentStatus) error
+	// SetTransaction records the transfer started under a payment consent
+	SetTransaction(id, transactionID uint) error
+}
+
+type openBankingConsentRepository struct {
+	db *gorm.DB
+}
+
+func NewOpenBankingConsentRepository(db *gorm.DB) OpenBankingConsentRepository {
+	return &openBankingConsentRepository{db: db}
+}
+
+func (r *openBankingConsentRepository) Create(consent *model.OpenBankingConsent) error {
+	return r.db.Create(consent).Error
+}
+
+func (r *openBankingConsentRepository) FindByID(id uint) (*model.OpenBankingConsent, error) {
+	var consent model.OpenBankingConsent
+	if err := r.db.Preload("Accounts").First(&consent, id).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *openBankingConsentRepository) FindByUserID(userID uint) ([]*model.OpenBankingConsent, error) {
+	var consents []*model.OpenBankingConsent
+	err := r.db.Preload("Accounts").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&consents).Error
+	return consents, err
+}
+
+func (r *openBankingConsentRepository) Authorise(consent *model.OpenBankingConsent, accounts []*model.Account) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.OpenBankingConsent{}).
+			Where("id = ? AND status = ?", consent.ID, model.ConsentStatusAwaitingAuthorisation).
+			Updates(map[string]interface{}{"status": model.ConsentStatusAuthorised, "authorised_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConsentStatus
+		}
+
+		if len(accounts) > 0 {
+			if err := tx.Model(consent).Association("Accounts").Replace(accounts); err != nil {
+				return err
+			}
+		}
+		consent.Status = model.ConsentStatusAuthorised
+		consent.AuthorisedAt = &now
+		return nil
+	})
+}
+
+func (r *openBankingConsentRepository) TransitionStatus(id uint, from, to model.ConsentStatus) error {
+	updates := map[string]interface{}{"status": to}
+	if to == model.ConsentStatusRevoked {
+		updates["revoked_at"] = time.Now()
+	}
+
+	result := r.db.Model(&model.OpenBankingConsent{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrConsentStatus
+	}
+	return nil
+}
+
+func (r *openBankingConsentRepository) SetTransaction(id, transactionID uint) error {
+	return r.db.Model(&model.OpenBankingConsent{}).
+		Where("id = ?", id).
+		Update("transaction_id", transactionID).Error
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PasswordResetRepository interface {
+	Create(token *model.PasswordResetToken) error
+	// FindByHashForUpdate reads a token with SELECT ... FOR UPDATE; call it inside a DB transaction
+	FindByHashForUpdate(tokenHash string) (*model.PasswordResetToken, error)
+	CountCreatedSince(userID uint, since time.Time) (int64, error)
+	// InvalidateForUser marks every unused token of the user as used
+	InvalidateForUser(userID uint) error
+	WithTx(tx *gorm.DB) PasswordResetRepository
+	GetDB() *gorm.DB
+}
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (r *passwordResetRepository) Create(token *model.PasswordResetToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *passwordResetRepository) FindByHashForUpdate(tokenHash string) (*model.PasswordResetToken, error) {
+	var token model.PasswordResetToken
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *passwordResetRepository) CountCreatedSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *passwordResetRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&model.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+func (r *passwordResetRepository) WithTx(tx *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: tx}
+}
+
+func (r *passwordResetRepository) GetDB() *gorm.DB {
+	return r.db
+}
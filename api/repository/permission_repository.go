@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+)
+
+type PermissionRepository interface {
+	FindAll() ([]*model.Permission, error)
+	FindByID(id uint) (*model.Permission, error)
+	// FindByNames returns the permissions with the given names; unknown names are left out
+	FindByNames(names []string) ([]*model.Permission, error)
+	Create(permission *model.Permission) error
+	Update(permission *model.Permission) error
+	// Delete removes the permission from every role and then deletes it
+	Delete(id uint) error
+	// FindRoleNames lists the roles that have the permission
+	FindRoleNames(permissionID uint) ([]string, error)
+}
+
+type permissionRepository struct {
+	db *gorm.DB
+}
+
+func NewPermissionRepository(db *gorm.DB) PermissionRepository {
+	return &permissionRepository{db: db}
+}
+
+func (r *permissionRepository) FindAll() ([]*model.Permission, error) {
+	var permissions []*model.Permission
+	err := r.db.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *permissionRepository) FindByID(id uint) (*model.Permission, error) {
+	var permission model.Permission
+	if err := r.db.First(&permission, id).Error; err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (r *permissionRepository) FindByNames(names []string) ([]*model.Permission, error) {
+	var permissions []*model.Permission
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	err := r.db.Where("name IN ?", names).Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *permissionRepository) Create(permission *model.Permission) error {
+	return r.db.Omit("Roles").Create(permission).Error
+}
+
+func (r *permissionRepository) Update(permission *model.Permission) error {
+	return r.db.Model(permission).Updates(map[string]interface{}{
+		"name":        permission.Name,
+		"description": permission.Description,
+	}).Error
+}
+
+func (r *permissionRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		permission := &model.Permission{ID: id}
+		if err := tx.Model(permission).Association("Roles").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(permission).Error
+	})
+}
+
+func (r *permissionRepository) FindRoleNames(permissionID uint) ([]string, error) {
+	var names []string
+	err := r.db.Model(&model.Role{}).
+		Joins("JOIN role_permissions ON role_permissions.role_id = roles.id").
+		Where("role_permissions.permission_id = ?", permissionID).
+		Pluck("roles.name", &names).Error
+	return names, err
+}
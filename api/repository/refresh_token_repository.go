@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RefreshTokenRepository interface {
+	Create(token *model.RefreshToken) error
+	// FindByHashForUpdate reads a token with SELECT ... FOR UPDATE; call it inside a DB transaction
+	FindByHashForUpdate(tokenHash string) (*model.RefreshToken, error)
+	MarkRotated(id uint) error
+	RevokeFamily(familyID string) error
+	// RevokeAllForUser revokes every active family of the user and returns their IDs
+	RevokeAllForUser(userID uint) ([]string, error)
+	WithTx(tx *gorm.DB) RefreshTokenRepository
+	GetDB() *gorm.DB
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(token *model.RefreshToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *refreshTokenRepository) FindByHashForUpdate(tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) MarkRotated(id uint) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("id = ?", id).
+		Update("rotated_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepository) RevokeAllForUser(userID uint) ([]string, error) {
+	var familyIDs []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+			Distinct().Pluck("family_id", &familyIDs).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+	return familyIDs, err
+}
+
+func (r *refreshTokenRepository) WithTx(tx *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: tx}
+}
+
+func (r *refreshTokenRepository) GetDB() *gorm.DB {
+	return r.db
+}
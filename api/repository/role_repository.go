@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-gin-template/api/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// rolePermissionsCacheTTL bounds how long a cached permission set can outlive a change
+// made without invalidating it, e.g. directly in the database
+const rolePermissionsCacheTTL = 10 * time.Minute
+
+type RoleRepository interface {
+	// FindAll lists the roles with their permissions
+	FindAll() ([]*model.Role, error)
+	FindByID(id uint) (*model.Role, error)
+	FindByName(name string) (*model.Role, error)
+	Create(role *model.Role) error
+	Update(role *model.Role) error
+	Delete(id uint) error
+	// ReplacePermissions sets the permissions of the role to exactly the given ones
+	ReplacePermissions(role *model.Role, permissions []*model.Permission) error
+	CountUsers(roleID uint) (int64, error)
+	// AssignToUser sets the role of a user
+	AssignToUser(userID, roleID uint) error
+	// PermissionNames returns the permission names of the role, served from the Redis
+	// cache when possible. An unknown role has no permissions.
+	PermissionNames(roleName string) ([]string, error)
+	// InvalidatePermissions drops the cached permissions of the roles
+	InvalidatePermissions(roleNames ...string) error
+}
+
+type roleRepository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+func NewRoleRepository(db *gorm.DB, rdb *redis.Client) RoleRepository {
+	return &roleRepository{db: db, rdb: rdb}
+}
+
+func (r *roleRepository) FindAll() ([]*model.Role, error) {
+	var roles []*model.Role
+	err := r.db.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) FindByID(id uint) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.Preload("Permissions").First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) FindByName(name string) (*model.Role, error) {
+	var role model.Role
+	if err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) Create(role *model.Role) error {
+	return r.db.Omit("Permissions").Create(role).Error
+}
+
+func (r *roleRepository) Update(role *model.Role) error {
+	return r.db.Model(role).Update("name", role.Name).Error
+}
+
+func (r *roleRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		role := &model.Role{ID: id}
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
+
+func (r *roleRepository) ReplacePermissions(role *model.Role, permissions []*model.Permission) error {
+	return r.db.Model(role).Association("Permissions").Replace(permissions)
+}
+
+func (r *roleRepository) CountUsers(roleID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.User{}).Where("role_id = ?", roleID).Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) AssignToUser(userID, roleID uint) error {
+	result := r.db.Model(&model.User{}).Where("id = ?", userID).Update("role_id", roleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *roleRepository) PermissionNames(roleName string) ([]string, error) {
+	ctx := context.Background()
+	key := model.RolePermissionsCacheKey(roleName)
+
+	cached, err := r.rdb.Get(ctx, key).Bytes()
+	if err == nil {
+		var names []string
+		if err := json.Unmarshal(cached, &names); err == nil {
+			return names, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	names := []string{}
+	err = r.db.Model(&model.Permission{}).
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.name = ?", roleName).
+		Order("permissions.name").
+		Pluck("permissions.name", &names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Unknown roles are cached as well, so a bogus role cannot hit the database every time
+	encoded, err := json.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.rdb.Set(ctx, key, encoded, rolePermissionsCacheTTL).Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (r *roleRepository) InvalidatePermissions(roleNames ...string) error {
+	if len(roleNames) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(roleNames))
+	for _, name := range roleNames {
+		keys = append(keys, model.RolePermissionsCacheKey(name))
+	}
+	return r.rdb.Del(context.Background(), keys...).Err()
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-gin-template/api/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(session *model.Session) error
+	FindByID(id string) (*model.Session, error)
+	// FindActiveByUserID lists the sessions that are neither revoked nor expired, most recently used first
+	FindActiveByUserID(userID uint) ([]*model.Session, error)
+	Extend(id string, expiresAt time.Time) error
+	Revoke(id string) error
+	RevokeAllForUser(userID uint) error
+	// TouchLastSeen records activity of the session, writing to the database at most once per interval
+	TouchLastSeen(id string, interval time.Duration) error
+}
+
+type sessionRepository struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+func NewSessionRepository(db *gorm.DB, rdb *redis.Client) SessionRepository {
+	return &sessionRepository{db: db, rdb: rdb}
+}
+
+func (r *sessionRepository) Create(session *model.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepository) FindByID(id string) (*model.Session, error) {
+	var session model.Session
+	if err := r.db.Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) FindActiveByUserID(userID uint) ([]*model.Session, error) {
+	var sessions []*model.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *sessionRepository) Extend(id string, expiresAt time.Time) error {
+	return r.db.Model(&model.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"expires_at":   expiresAt,
+			"last_seen_at": time.Now(),
+		}).Error
+}
+
+func (r *sessionRepository) Revoke(id string) error {
+	return r.db.Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) TouchLastSeen(id string, interval time.Duration) error {
+	// The Redis marker throttles writes across all instances of the API
+	acquired, err := r.rdb.SetNX(context.Background(), "session:seen:"+id, 1, interval).Result()
+	if err != nil || !acquired {
+		return err
+	}
+
+	return r.db.Model(&model.Session{}).
+		Where("id = ?", id).
+		Update("last_seen_at", time.Now()).Error
+}
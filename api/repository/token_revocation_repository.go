@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenRevocationRepository blocks access tokens before they expire. Entries only need to
+// live as long as the tokens they block, so Redis expires them on its own.
+type TokenRevocationRepository interface {
+	// RevokeToken blocks the access token with the given jti for ttl
+	RevokeToken(jti string, ttl time.Duration) error
+	// RevokeSession blocks all access tokens with the given sid for ttl
+	RevokeSession(sessionID string, ttl time.Duration) error
+	// RevokeClient blocks all access tokens issued to a third-party app for ttl
+	RevokeClient(clientID string, ttl time.Duration) error
+	// IsRevoked checks the jti, the sid and, for tokens of third-party apps, the client
+	IsRevoked(jti, sessionID, clientID string) (bool, error)
+}
+
+type tokenRevocationRepository struct {
+	rdb *redis.Client
+}
+
+func NewTokenRevocationRepository(rdb *redis.Client) TokenRevocationRepository {
+	return &tokenRevocationRepository{rdb: rdb}
+}
+
+func revokedTokenKey(jti string) string {
+	return "revoked:jti:" + jti
+}
+
+func revokedSessionKey(sessionID string) string {
+	return "revoked:sid:" + sessionID
+}
+
+func revokedClientKey(clientID string) string {
+	return "revoked:client:" + clientID
+}
+
+func (r *tokenRevocationRepository) RevokeToken(jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return r.rdb.Set(context.Background(), revokedTokenKey(jti), 1, ttl).Err()
+}
+
+func (r *tokenRevocationRepository) RevokeSession(sessionID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return r.rdb.Set(context.Background(), revokedSessionKey(sessionID), 1, ttl).Err()
+}
+
+func (r *tokenRevocationRepository) RevokeClient(clientID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return r.rdb.Set(context.Background(), revokedClientKey(clientID), 1, ttl).Err()
+}
+
+func (r *tokenRevocationRepository) IsRevoked(jti, sessionID, clientID string) (bool, error) {
+	keys := []string{revokedTokenKey(jti)}
+	if sessionID != "" {
+		keys = append(keys, revokedSessionKey(sessionID))
+	}
+	if clientID != "" {
+		keys = append(keys, revokedClientKey(clientID))
+	}
+
+	count, err := r.rdb.Exists(context.Background(), keys...).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -1,16 +1,44 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"go-gin-template/api/model"
+	"go-gin-template/api/util"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrStatusTransition is returned when a transaction is not in the expected status
+var ErrStatusTransition = errors.New("transaction is not in the expected status")
+
+// TransactionFilter narrows down the transactions of an account.
+// Results are ordered newest first; BeforeID is the keyset cursor.
+type TransactionFilter struct {
+	AccountID uint
+	Type      model.TransactionType
+	Status    model.TransactionStatus
+	From      *time.Time
+	To        *time.Time
+	MinAmount *util.Money
+	MaxAmount *util.Money
+	BeforeID  uint
+	Limit     int
+}
+
 type TransactionRepository interface {
 	Create(transaction *model.Transaction) error
 	FindByID(transactionID uint) (*model.Transaction, error)
+	FindByAccount(filter TransactionFilter) ([]*model.Transaction, error)
+	// FindByIDForUpdate reads a transaction with SELECT ... FOR UPDATE; call it inside a DB transaction
+	FindByIDForUpdate(transactionID uint) (*model.Transaction, error)
 	Update(transaction *model.Transaction) error
 	UpdateStatus(transactionID uint, status model.TransactionStatus) error
+	// TransitionStatus moves a transaction from one status to another, failing if it is no longer in from
+	TransitionStatus(transactionID uint, from, to model.TransactionStatus) error
 	GetDB() *gorm.DB
+	WithTx(tx *gorm.DB) TransactionRepository
 }
 
 type transactionRepository struct {
@@ -32,6 +60,47 @@ func (r *transactionRepository) FindByID(transactionID uint) (*model.Transaction
 	return &transaction, err
 }
 
+func (r *transactionRepository) FindByAccount(filter TransactionFilter) ([]*model.Transaction, error) {
+	query := r.db.Preload("FromAccount.User").Preload("ToAccount.User").
+		Where("(from_account_id = ? OR to_account_id = ?)", filter.AccountID, filter.AccountID)
+
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var transactions []*model.Transaction
+	err := query.Order("id DESC").Limit(filter.Limit).Find(&transactions).Error
+	return transactions, err
+}
+
+func (r *transactionRepository) FindByIDForUpdate(transactionID uint) (*model.Transaction, error) {
+	var transaction model.Transaction
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", transactionID).First(&transaction).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
 func (r *transactionRepository) Update(transaction *model.Transaction) error {
 	return r.db.Save(transaction).Error
 }
@@ -42,6 +111,23 @@ func (r *transactionRepository) UpdateStatus(transactionID uint, status model.Tr
 		Update("status", status).Error
 }
 
+func (r *transactionRepository) TransitionStatus(transactionID uint, from, to model.TransactionStatus) error {
+	result := r.db.Model(&model.Transaction{}).
+		Where("id = ? AND status = ?", transactionID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStatusTransition
+	}
+	return nil
+}
+
 func (r *transactionRepository) GetDB() *gorm.DB {
 	return r.db
-}
\ No newline at end of file
+}
+
+func (r *transactionRepository) WithTx(tx *gorm.DB) TransactionRepository {
+	return &transactionRepository{db: tx}
+}
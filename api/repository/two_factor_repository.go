@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"time"
+
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+)
+
+type TwoFactorRepository interface {
+	FindTOTPByUserID(userID uint) (*model.UserTOTP, error)
+	// SaveTOTP creates or replaces the user's authenticator enrollment
+	SaveTOTP(totp *model.UserTOTP) error
+	ConfirmTOTP(id uint) error
+	// AdvanceLastUsedStep records a used time step. It returns false when the step is not
+	// newer than the last one, i.e. the code was already used.
+	AdvanceLastUsedStep(id uint, step int64) (bool, error)
+	// DeleteForUser removes the enrollment and the recovery codes of the user
+	DeleteForUser(userID uint) error
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores new ones
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	// UseRecoveryCode marks an unused code as used. It returns false when no such code exists.
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(userID uint) (int64, error)
+}
+
+type twoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) TwoFactorRepository {
+	return &twoFactorRepository{db: db}
+}
+
+func (r *twoFactorRepository) FindTOTPByUserID(userID uint) (*model.UserTOTP, error) {
+	var totp model.UserTOTP
+	if err := r.db.Where("user_id = ?", userID).First(&totp).Error; err != nil {
+		return nil, err
+	}
+	return &totp, nil
+}
+
+func (r *twoFactorRepository) SaveTOTP(totp *model.UserTOTP) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", totp.UserID).Delete(&model.UserTOTP{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(totp).Error
+	})
+}
+
+func (r *twoFactorRepository) ConfirmTOTP(id uint) error {
+	return r.db.Model(&model.UserTOTP{}).
+		Where("id = ?", id).
+		Update("confirmed_at", time.Now()).Error
+}
+
+func (r *twoFactorRepository) AdvanceLastUsedStep(id uint, step int64) (bool, error) {
+	// The condition makes concurrent uses of the same code race on the row; only one wins
+	result := r.db.Model(&model.UserTOTP{}).
+		Where("id = ? AND last_used_step < ?", id, step).
+		Update("last_used_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *twoFactorRepository) DeleteForUser(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.UserTOTP{}).Error
+	})
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]model.RecoveryCode, 0, len(codeHashes))
+		for _, codeHash := range codeHashes {
+			codes = append(codes, model.RecoveryCode{UserID: userID, CodeHash: codeHash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *twoFactorRepository) CountUnusedRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"time"
+
+	"go-gin-template/api/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserContactRepository interface {
+	FindByUserID(userID uint) ([]*model.UserContact, error)
+	FindByUserAndChannel(userID uint, channel model.ContactChannel) (*model.UserContact, error)
+	// Upsert stores the contact of a user for its channel, replacing the previous value
+	Upsert(contact *model.UserContact) error
+	// MarkVerified marks the contact verified if it still has the given value, and reports
+	// whether it did
+	MarkVerified(userID uint, channel model.ContactChannel, value string) (bool, error)
+	WithTx(tx *gorm.DB) UserContactRepository
+}
+
+type userContactRepository struct {
+	db *gorm.DB
+}
+
+func NewUserContactRepository(db *gorm.DB) UserContactRepository {
+	return &userContactRepository{db: db}
+}
+
+func (r *userContactRepository) FindByUserID(userID uint) ([]*model.UserContact, error) {
+	var contacts []*model.UserContact
+	err := r.db.Where("user_id = ?", userID).Order("channel ASC").Find(&contacts).Error
+	return contacts, err
+}
+
+func (r *userContactRepository) FindByUserAndChannel(userID uint, channel model.ContactChannel) (*model.UserContact, error) {
+	var contact model.UserContact
+	if err := r.db.Where("user_id = ? AND channel = ?", userID, channel).First(&contact).Error; err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+func (r *userContactRepository) Upsert(contact *model.UserContact) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "verified_at", "updated_at"}),
+	}).Create(contact).Error
+}
+
+func (r *userContactRepository) MarkVerified(userID uint, channel model.ContactChannel, value string) (bool, error) {
+	result := r.db.Model(&model.UserContact{}).
+		Where("user_id = ? AND channel = ? AND value = ?", userID, channel, value).
+		Update("verified_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *userContactRepository) WithTx(tx *gorm.DB) UserContactRepository {
+	return &userContactRepository{db: tx}
+}
@@ -10,6 +10,10 @@ type UserPasswordRepository interface {
 	Create(password *model.UserPassword) error
 	FindActiveByUserID(userID uint) (*model.UserPassword, error)
 	DeactivateAll(userID uint) error
+	// FindRecentByUserID returns the user's latest passwords, the active one included, newest first
+	FindRecentByUserID(userID uint, limit int) ([]*model.UserPassword, error)
+	WithTx(tx *gorm.DB) UserPasswordRepository
+	GetDB() *gorm.DB
 }
 
 type userPasswordRepository struct {
@@ -35,3 +39,20 @@ func (r *userPasswordRepository) FindActiveByUserID(userID uint) (*model.UserPas
 func (r *userPasswordRepository) DeactivateAll(userID uint) error {
 	return r.db.Model(&model.UserPassword{}).Where("user_id = ?", userID).Update("is_active", false).Error
 }
+
+func (r *userPasswordRepository) FindRecentByUserID(userID uint, limit int) ([]*model.UserPassword, error) {
+	var passwords []*model.UserPassword
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&passwords).Error
+	return passwords, err
+}
+
+func (r *userPasswordRepository) WithTx(tx *gorm.DB) UserPasswordRepository {
+	return &userPasswordRepository{db: tx}
+}
+
+func (r *userPasswordRepository) GetDB() *gorm.DB {
+	return r.db
+}
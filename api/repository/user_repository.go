@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"time"
+
 	"go-gin-template/api/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
@@ -12,6 +15,15 @@ type UserRepository interface {
 	FindByEmail(email string) (*model.User, error)
 	Update(user *model.User) error
 	Delete(id uint) error
+	// Activate marks a pending user's email as verified. It reports false when the user was
+	// not pending, so concurrent verifications activate the user only once.
+	Activate(id uint) (bool, error)
+	MarkVerificationSent(id uint, sentAt time.Time) error
+	// PurgeUnverified deletes users still pending verification that registered before the
+	// given time, together with their passwords, contacts, sessions and second factors
+	PurgeUnverified(registeredBefore time.Time) (int64, error)
+	WithTx(tx *gorm.DB) UserRepository
+	GetDB() *gorm.DB
 }
 
 type userRepository struct {
@@ -49,3 +61,67 @@ func (r *userRepository) Update(user *model.User) error {
 func (r *userRepository) Delete(id uint) error {
 	return r.db.Delete(&model.User{}, id).Error
 }
+
+func (r *userRepository) Activate(id uint) (bool, error) {
+	result := r.db.Model(&model.User{}).
+		Where("id = ? AND status = ?", id, model.UserStatusPendingVerification).
+		Updates(map[string]interface{}{
+			"status":            model.UserStatusActive,
+			"email_verified_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *userRepository) MarkVerificationSent(id uint, sentAt time.Time) error {
+	return r.db.Model(&model.User{}).Where("id = ?", id).Update("verification_sent_at", sentAt).Error
+}
+
+func (r *userRepository) PurgeUnverified(registeredBefore time.Time) (int64, error) {
+	var purged int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the users so none of them is verified while its rows are being deleted
+		var ids []uint
+		err := tx.Model(&model.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND created_at < ?", model.UserStatusPendingVerification, registeredBefore).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		// Unverified users cannot open accounts, move money, issue API keys or authorise
+		// apps, so only the rows written at registration and login can refer to them. The
+		// key and app rows are still cleared in case any were written before those checks.
+		dependents := []interface{}{
+			&model.UserPassword{},
+			&model.UserContact{},
+			&model.RefreshToken{},
+			&model.Session{},
+			&model.PasswordResetToken{},
+			&model.RecoveryCode{},
+			&model.UserTOTP{},
+			&model.APIKey{},
+			&model.OAuthAuthorizationCode{},
+			&model.OAuthRefreshToken{},
+			&model.OAuthConsent{},
+			&model.OpenBankingConsent{},
+		}
+		for _, dependent := range dependents {
+			if err := tx.Where("user_id IN ?", ids).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("id IN ? AND status = ?", ids, model.UserStatusPendingVerification).Delete(&model.User{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+func (r *userRepository) WithTx(tx *gorm.DB) UserRepository {
+	return &userRepository{db: tx}
+}
+
+func (r *userRepository) GetDB() *gorm.DB {
+	return r.db
+}
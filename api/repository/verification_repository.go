@@ -4,15 +4,19 @@ import (
 	"go-gin-template/api/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type VerificationRepository interface {
 	Create(verification *model.TransactionVerification) error
 	FindByID(verificationID uint) (*model.TransactionVerification, error)
+	// FindByIDForUpdate reads a verification with SELECT ... FOR UPDATE; call it inside a DB transaction
+	FindByIDForUpdate(verificationID uint) (*model.TransactionVerification, error)
 	FindByTransactionID(transactionID uint) ([]*model.TransactionVerification, error)
 	Update(verification *model.TransactionVerification) error
 	UpdateStatus(verificationID uint, status model.VerificationStatus) error
 	FindActiveByTransactionID(transactionID uint) (*model.TransactionVerification, error)
+	WithTx(tx *gorm.DB) VerificationRepository
 }
 
 type verificationRepository struct {
@@ -33,6 +37,16 @@ func (r *verificationRepository) FindByID(verificationID uint) (*model.Transacti
 	return &verification, err
 }
 
+func (r *verificationRepository) FindByIDForUpdate(verificationID uint) (*model.TransactionVerification, error) {
+	var verification model.TransactionVerification
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", verificationID).First(&verification).Error
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
 func (r *verificationRepository) FindByTransactionID(transactionID uint) ([]*model.TransactionVerification, error) {
 	var verifications []*model.TransactionVerification
 	err := r.db.Where("transaction_id = ?", transactionID).Find(&verifications).Error
@@ -55,4 +69,8 @@ func (r *verificationRepository) FindActiveByTransactionID(transactionID uint) (
 		Order("created_at DESC").
 		First(&verification).Error
 	return &verification, err
-}
\ No newline at end of file
+}
+
+func (r *verificationRepository) WithTx(tx *gorm.DB) VerificationRepository {
+	return &verificationRepository{db: tx}
+}
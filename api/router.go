@@ -1,62 +1,266 @@
 package api
 
 import (
+	"log"
+
 	"go-gin-template/api/config"
+	"go-gin-template/api/dto"
 	"go-gin-template/api/handler"
 	"go-gin-template/api/middleware"
+	"go-gin-template/api/model"
+	"go-gin-template/api/policy"
 	"go-gin-template/api/repository"
 	"go-gin-template/api/service"
+	"go-gin-template/api/util"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InitRouter() *gin.Engine {
+func InitRouter(notificationService service.NotificationService) *gin.Engine {
+	// Register custom binding validations used by the DTOs
+	if err := dto.RegisterValidations(); err != nil {
+		log.Fatalf("Failed to register validations: %v", err)
+	}
+
+	// The keys for stored codes and secrets have no defaults
+	if err := config.CheckSecrets(); err != nil {
+		log.Fatalf("Failed to load secrets: %v", err)
+	}
+
+	// Load the token signing keys
+	jwtConfig := config.GetJWTConfig()
+	keyManager, err := util.NewKeyManager(jwtConfig.Current, jwtConfig.Previous, jwtConfig.AccessTokenTTL)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	util.SetKeyManager(keyManager)
+
+	// Load the password policy and the breached password list
+	passwordConfig := config.GetPasswordConfig()
+	breachedPasswords, err := util.LoadBreachedPasswords(passwordConfig.BreachedFile)
+	if err != nil {
+		log.Fatalf("Failed to load breached passwords: %v", err)
+	}
+	passwordPolicy := passwordConfig.Policy
+	passwordPolicy.Breached = breachedPasswords
+
 	// Initialize repositories
 	bookRepo := repository.NewBookRepository(config.DB)
 	userRepo := repository.NewUserRepository(config.DB)
 	accountRepo := repository.NewAccountRepository(config.DB)
 	passwordRepo := repository.NewUserPasswordRepository(config.DB)
 	transactionRepo := repository.NewTransactionRepository(config.DB)
+	ledgerRepo := repository.NewLedgerRepository(config.DB)
+	idempotencyRepo := repository.NewIdempotencyRepository(config.Redis, config.DB)
+	verificationRepo := repository.NewVerificationRepository(config.DB)
+	contactRepo := repository.NewUserContactRepository(config.DB)
+	contactCodeRepo := repository.NewContactCodeRepository(config.Redis)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(config.DB)
+	sessionRepo := repository.NewSessionRepository(config.DB, config.Redis)
+	revocationRepo := repository.NewTokenRevocationRepository(config.Redis)
+	twoFactorRepo := repository.NewTwoFactorRepository(config.DB)
+	loginChallengeRepo := repository.NewLoginChallengeRepository(config.Redis)
+	passwordResetRepo := repository.NewPasswordResetRepository(config.DB)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(config.Redis)
+	roleRepo := repository.NewRoleRepository(config.DB, config.Redis)
+	permissionRepo := repository.NewPermissionRepository(config.DB)
+	oauthClientRepo := repository.NewOAuthClientRepository(config.DB)
+	oauthTokenRepo := repository.NewOAuthTokenRepository(config.DB)
+	consentRepo := repository.NewOpenBankingConsentRepository(config.DB)
+	apiKeyRepo := repository.NewAPIKeyRepository(config.DB, config.Redis)
 	r := gin.Default()
 
+	// Login throttling is keyed on the client IP, so forwarded headers are only believed
+	// when they come from a known proxy
+	if err := r.SetTrustedProxies(config.GetTrustedProxies()); err != nil {
+		log.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+
 	// Use recovery middleware
 	r.Use(gin.Recovery())
 
 	// Use custom error handler
 	r.Use(middleware.ErrorInterceptor())
 
+	// Public signing keys for services that verify our tokens
+	jwksHandler := handler.NewJWKSHandler(keyManager)
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// Book endpoints
 	bookService := service.NewBookService(bookRepo)
 	bookHandler := handler.NewBookHandler(bookService)
 
 	r.GET("/books", bookHandler.GetBooks)
 	r.GET("/books/:id", bookHandler.GetBook)
-	r.POST("/books", middleware.AuthGuard(), bookHandler.CreateBook)
-	r.PUT("/books/:id", middleware.AuthGuard(), bookHandler.UpdateBook)
-	r.DELETE("/books/:id", middleware.AuthGuard(), bookHandler.DeleteBook)
+	r.POST("/books", middleware.AuthGuard(), middleware.RequirePermission(model.PermissionBooksWrite), bookHandler.CreateBook)
+	r.PUT("/books/:id", middleware.AuthGuard(), middleware.RequirePermission(model.PermissionBooksWrite), bookHandler.UpdateBook)
+	r.DELETE("/books/:id", middleware.AuthGuard(), middleware.RequirePermission(model.PermissionBooksWrite), bookHandler.DeleteBook)
 
 	// User endpoints
-	accountService := service.NewAccountService(accountRepo, transactionRepo)
+	contactService := service.NewContactService(userRepo, contactRepo)
+	userNotifier := service.NewUserNotifier(userRepo, contactService, notificationService)
+	ledgerService := service.NewLedgerService(ledgerRepo, accountRepo)
+	accountService := service.NewAccountService(accountRepo, transactionRepo, ledgerService, userNotifier)
 
-	userService := service.NewUserService(userRepo, passwordRepo, accountService)
+	tokenService := service.NewTokenService(userRepo, refreshTokenRepo, sessionRepo, revocationRepo, jwtConfig.AccessTokenTTL, jwtConfig.RefreshTokenTTL)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, userRepo)
+	loginProtectionService := service.NewLoginProtectionService(loginAttemptRepo, userNotifier)
+	emailVerificationService := service.NewEmailVerificationService(userRepo, accountService, contactService, userNotifier)
+	userService := service.NewUserService(userRepo, passwordRepo, contactService, userNotifier, tokenService, twoFactorService, loginChallengeRepo, &passwordPolicy, loginProtectionService, emailVerificationService)
+	passwordService := service.NewPasswordService(userRepo, passwordRepo, passwordResetRepo, contactService, userNotifier, tokenService, &passwordPolicy)
 	userHandler := handler.NewUserHandler(userService)
+	passwordHandler := handler.NewPasswordHandler(passwordService)
+	contactVerificationService := service.NewContactVerificationService(userRepo, contactRepo, contactCodeRepo, contactService, userNotifier)
+	contactHandler := handler.NewContactHandler(contactService, contactVerificationService)
+	authHandler := handler.NewAuthHandler(tokenService)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService)
+	emailVerificationHandler := handler.NewEmailVerificationHandler(emailVerificationService)
+	apiKeyHandler := handler.NewAPIKeyHandler(service.NewAPIKeyService(apiKeyRepo))
+	// Opening accounts, moving money and granting access to other clients require a
+	// verified email address
+	verifiedEmail := middleware.VerifiedEmailGuard()
 	users := r.Group("/users")
 	{
 		users.POST("/login", userHandler.Login)
+		users.POST("/login/2fa", userHandler.LoginWithTwoFactor)
 		users.POST("/register", userHandler.Register)
+		users.POST("/email/verify", emailVerificationHandler.VerifyEmail)
+		users.POST("/me/email/verify/resend", middleware.AuthGuard(), emailVerificationHandler.ResendVerification)
+		users.POST("/password/forgot", passwordHandler.ForgotPassword)
+		users.POST("/password/reset", passwordHandler.ResetPassword)
+		users.PUT("/me/password", middleware.AuthGuard(), passwordHandler.ChangePassword)
+		users.GET("/me/contacts", middleware.AuthGuard(), contactHandler.GetContacts)
+		users.PUT("/me/contacts/preferred", middleware.AuthGuard(), contactHandler.SetPreferredChannel)
+		users.POST("/me/contacts/sms/verify", middleware.AuthGuard(), verifiedEmail, contactHandler.SendSMSCode)
+		users.POST("/me/contacts/sms/confirm", middleware.AuthGuard(), contactHandler.ConfirmSMS)
+		users.GET("/me/sessions", middleware.AuthGuard(), authHandler.ListSessions)
+		users.DELETE("/me/sessions/:id", middleware.AuthGuard(), authHandler.RevokeSession)
+		users.GET("/me/2fa", middleware.AuthGuard(), twoFactorHandler.GetStatus)
+		users.POST("/me/2fa/totp", middleware.AuthGuard(), twoFactorHandler.BeginTOTPEnrollment)
+		users.POST("/me/2fa/totp/confirm", middleware.AuthGuard(), twoFactorHandler.ConfirmTOTPEnrollment)
+		users.POST("/me/2fa/totp/disable", middleware.AuthGuard(), twoFactorHandler.DisableTOTP)
+		users.POST("/me/2fa/recovery-codes", middleware.AuthGuard(), twoFactorHandler.RegenerateRecoveryCodes)
+		// Service clients sign requests with API keys; the keys are managed from a login only
+		users.GET("/me/api-keys", middleware.LoginAuthGuard(), apiKeyHandler.ListKeys)
+		users.POST("/me/api-keys", middleware.LoginAuthGuard(), verifiedEmail, apiKeyHandler.IssueKey)
+		users.POST("/me/api-keys/:id/rotate", middleware.LoginAuthGuard(), verifiedEmail, apiKeyHandler.RotateKey)
+		users.DELETE("/me/api-keys/:id", middleware.LoginAuthGuard(), apiKeyHandler.RevokeKey)
 		users.GET("/:id", middleware.AuthGuard(), userHandler.GetProfile)
 		users.PUT("/:id", middleware.AuthGuard(), userHandler.UpdateProfile)
 	}
 
+	// Auth endpoints
+	auth := r.Group("/auth")
+	{
+		auth.POST("/refresh", authHandler.Refresh)
+		auth.POST("/logout", middleware.AuthGuard(), authHandler.Logout)
+		auth.POST("/logout-all", middleware.AuthGuard(), authHandler.LogoutAll)
+	}
+
 	// Account endpoints
 	accountHandler := handler.NewAccountHandler(accountService)
-	accounts := r.Group("/accounts", middleware.AuthGuard())
+	idempotency := middleware.IdempotencyInterceptor(idempotencyRepo)
+	// Third-party apps can read accounts; they start payments through payment consents
+	// under /open-banking. The other routes are for the user's own logins only.
+	readAccounts := middleware.ScopedAuthGuard(model.ScopeAccountsRead)
+	accounts := r.Group("/accounts")
 	{
-		accounts.POST("", accountHandler.CreateAccount)
-		accounts.GET("", accountHandler.GetAccounts)
-		accounts.POST("/:id/deposit", middleware.AccountOwnershipGuard(), accountHandler.Deposit)
-		accounts.POST("/:id/withdraw", middleware.AccountOwnershipGuard(), accountHandler.Withdraw)
-		accounts.POST("/:id/transfer", middleware.AccountOwnershipGuard(), accountHandler.Transfer)
+		accounts.POST("", middleware.AuthGuard(), verifiedEmail, accountHandler.CreateAccount)
+		accounts.GET("", readAccounts, accountHandler.GetAccounts)
+		accounts.GET("/:id/transactions", readAccounts, middleware.AccountAccessGuard(policy.ActionRead), accountHandler.GetTransactions)
+		accounts.POST("/:id/deposit", middleware.AuthGuard(), verifiedEmail, middleware.AccountAccessGuard(policy.ActionTransact), idempotency, accountHandler.Deposit)
+		accounts.POST("/:id/withdraw", middleware.AuthGuard(), verifiedEmail, middleware.AccountAccessGuard(policy.ActionTransact), idempotency, accountHandler.Withdraw)
+		accounts.POST("/:id/transfer", middleware.AuthGuard(), verifiedEmail, middleware.AccountAccessGuard(policy.ActionTransact), idempotency, accountHandler.Transfer)
+		accounts.POST("/:id/transfer/init", middleware.AuthGuard(), verifiedEmail, middleware.AccountAccessGuard(policy.ActionTransact), idempotency, accountHandler.InitiateTransfer)
+	}
+
+	// Verification endpoints
+	verificationService := service.NewVerificationService(verificationRepo, transactionRepo, accountService, contactService, twoFactorService)
+	verificationHandler := handler.NewVerificationHandler(verificationService, userNotifier)
+	// Only the user confirms transfers, including payments started by third-party apps
+	verifications := r.Group("/verifications", middleware.AuthGuard(), verifiedEmail)
+	{
+		verifications.POST("", verificationHandler.GenerateVerification)
+		verifications.POST("/:id/verify", verificationHandler.VerifyCode)
+		verifications.POST("/:id/resend", verificationHandler.ResendCode)
+	}
+
+	// OAuth endpoints; the token endpoints authenticate the client instead of a user
+	oauthService := service.NewOAuthService(oauthClientRepo, oauthTokenRepo, userRepo, revocationRepo, jwtConfig.AccessTokenTTL)
+	oauthHandler := handler.NewOAuthHandler(oauthService)
+	oauth := r.Group("/oauth")
+	{
+		oauth.POST("/clients", middleware.AuthGuard(), verifiedEmail, oauthHandler.RegisterClient)
+		oauth.GET("/clients", middleware.AuthGuard(), oauthHandler.ListClients)
+		oauth.DELETE("/clients/:client_id", middleware.AuthGuard(), oauthHandler.DeleteClient)
+		oauth.GET("/authorize", middleware.AuthGuard(), verifiedEmail, oauthHandler.Consent)
+		oauth.POST("/authorize", middleware.AuthGuard(), verifiedEmail, oauthHandler.Authorize)
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/introspect", oauthHandler.Introspect)
+		oauth.POST("/revoke", oauthHandler.Revoke)
+	}
+
+	// Open banking endpoints. Apps create consents and use them with their OAuth tokens;
+	// users authorise, reject and revoke consents from their own logins.
+	openBankingService := service.NewOpenBankingService(consentRepo, oauthClientRepo, accountRepo, transactionRepo, accountService)
+	openBankingHandler := handler.NewOpenBankingHandler(openBankingService)
+	ais := middleware.ThirdPartyGuard(model.ScopeAccountsRead)
+	pis := middleware.ThirdPartyGuard(model.ScopeTransfersWrite)
+	openBanking := r.Group("/open-banking")
+	{
+		openBanking.POST("/account-access-consents", ais, openBankingHandler.CreateAccountAccessConsent)
+		openBanking.GET("/account-access-consents/:id", ais, openBankingHandler.GetConsent)
+		openBanking.GET("/account-access-consents/:id/accounts", ais, openBankingHandler.ListAccounts)
+		openBanking.GET("/account-access-consents/:id/accounts/:account_id/balance", ais, openBankingHandler.GetBalance)
+		openBanking.GET("/account-access-consents/:id/accounts/:account_id/transactions", ais, openBankingHandler.GetTransactions)
+		openBanking.POST("/payment-consents", pis, verifiedEmail, openBankingHandler.CreatePaymentConsent)
+		openBanking.GET("/payment-consents/:id", pis, openBankingHandler.GetConsent)
+		openBanking.POST("/payment-consents/:id/payments", pis, verifiedEmail, openBankingHandler.CreatePayment)
+		openBanking.GET("/consents", middleware.AuthGuard(), openBankingHandler.ListConsents)
+		openBanking.POST("/consents/:id/authorise", middleware.AuthGuard(), openBankingHandler.AuthoriseConsent)
+		openBanking.POST("/consents/:id/reject", middleware.AuthGuard(), openBankingHandler.RejectConsent)
+		openBanking.DELETE("/consents/:id", middleware.AuthGuard(), openBankingHandler.RevokeConsent)
+	}
+
+	// Admin endpoints
+	ledgerHandler := handler.NewLedgerHandler(ledgerService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	roleHandler := handler.NewRoleHandler(service.NewRoleService(roleRepo, permissionRepo, userRepo, tokenService))
+	manageNotifications := middleware.RequirePermission(model.PermissionNotificationsManage)
+	manageRoles := middleware.RequirePermission(model.PermissionRolesManage)
+	admin := r.Group("/admin", middleware.AuthGuard())
+	{
+		admin.GET("/accounts/:id/reconcile", middleware.RequirePermission(model.PermissionLedgerReconcile), ledgerHandler.ReconcileAccount)
+		admin.GET("/notifications", manageNotifications, notificationHandler.ListNotifications)
+		admin.GET("/notifications/:id", manageNotifications, notificationHandler.GetNotification)
+		admin.POST("/notifications/:id/replay", manageNotifications, notificationHandler.ReplayNotification)
+		admin.POST("/users/:id/unlock", middleware.RequirePermission(model.PermissionUsersUnlock), userHandler.UnlockUser)
+		admin.PUT("/users/:id/role", manageRoles, roleHandler.AssignRole)
+		admin.GET("/roles", manageRoles, roleHandler.ListRoles)
+		admin.POST("/roles", manageRoles, roleHandler.CreateRole)
+		admin.GET("/roles/:id", manageRoles, roleHandler.GetRole)
+		admin.PUT("/roles/:id", manageRoles, roleHandler.UpdateRole)
+		admin.DELETE("/roles/:id", manageRoles, roleHandler.DeleteRole)
+		admin.GET("/permissions", manageRoles, roleHandler.ListPermissions)
+		admin.POST("/permissions", manageRoles, roleHandler.CreatePermission)
+		admin.PUT("/permissions/:id", manageRoles, roleHandler.UpdatePermission)
+		admin.DELETE("/permissions/:id", manageRoles, roleHandler.DeletePermission)
+	}
+
+	// Provider callbacks; authenticated by the provider signature instead of a token, so
+	// receipts are only accepted when there is a key to check the signature with
+	if config.GetSMSConfig().AuthToken != "" {
+		r.POST("/notifications/sms/receipts", notificationHandler.ReceiveSMSReceipt)
+	}
+
+	// Development endpoints; the inbox is only available when notifications are captured
+	if inbox := notificationService.Inbox(); inbox != nil && gin.Mode() != gin.ReleaseMode {
+		devHandler := handler.NewDevHandler(inbox)
+		dev := r.Group("/dev")
+		{
+			dev.GET("/inbox", devHandler.ListInbox)
+			dev.GET("/inbox/:id", devHandler.GetInboxMessage)
+		}
 	}
 
 	return r
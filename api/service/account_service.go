@@ -1,38 +1,78 @@
 package service
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"go-gin-template/api/config"
 	"go-gin-template/api/dto"
 	"go-gin-template/api/model"
+	"go-gin-template/api/policy"
 	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
 )
 
+// notificationTimeLayout formats times in notifications
+const notificationTimeLayout = "2006-01-02 15:04:05 MST"
+
+// ErrInsufficientBalance is returned when the source account cannot cover the amount
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
 type AccountService interface {
 	CreateAccount(userID uint, req *dto.CreateAccountRequest) (*dto.AccountResponse, error)
 	GetUserAccounts(userID uint) ([]*dto.AccountResponse, error)
-	Deposit(userID, accountID uint, amount float64) (*dto.AccountResponse, error)
-	Withdraw(userID, accountID uint, amount float64) (*dto.AccountResponse, error)
-	Transfer(userID uint, sourceAccountID uint, targetAccountID uint, amount float64) (*dto.AccountResponse, error)
-	InitiateTransfer(userID uint, sourceAccountID uint, targetAccountID uint, amount float64) (*model.Transaction, error)
-	CreateDefaultAccount(userID uint) (*dto.AccountResponse, error)
+	// currency may be empty, in which case the account currency is assumed
+	Deposit(userID, accountID uint, amount util.Money, currency string) (*dto.AccountResponse, error)
+	Withdraw(userID, accountID uint, amount util.Money, currency string) (*dto.AccountResponse, error)
+	Transfer(userID uint, sourceAccountID uint, targetAccountID uint, amount util.Money, currency string) (*dto.AccountResponse, error)
+	InitiateTransfer(userID uint, sourceAccountID uint, targetAccountID uint, amount util.Money, currency string, description string) (*model.Transaction, error)
+	// ExecuteTransfer completes a verified transfer within tx, checking the balance again at
+	// execution time. When the balance is too low it marks the transfer failed in tx and
+	// returns ErrInsufficientBalance, so the caller can commit that outcome. The returned
+	// function sends the receipt and must only be called once tx committed.
+	ExecuteTransfer(tx *gorm.DB, transactionID uint) (*model.Transaction, func(), error)
+	// CreateDefaultAccount opens the first account of a newly verified user within tx
+	CreateDefaultAccount(tx *gorm.DB, userID uint) (*dto.AccountResponse, error)
+	// GetTransactions lists the transactions of an account the subject may read
+	GetTransactions(subject policy.Subject, accountID uint, query *dto.TransactionHistoryQuery) (*dto.TransactionListResponse, error)
 }
 
 type accountService struct {
 	accountRepo     repository.AccountRepository
 	transactionRepo repository.TransactionRepository
+	ledgerService   LedgerService
+	userNotifier    UserNotifier
+	// lowBalanceThreshold triggers a warning when a debit takes a balance below it
+	lowBalanceThreshold util.Money
 }
 
-func NewAccountService(accountRepo repository.AccountRepository, transactionRepo repository.TransactionRepository) AccountService {
+func NewAccountService(accountRepo repository.AccountRepository, transactionRepo repository.TransactionRepository, ledgerService LedgerService, userNotifier UserNotifier) AccountService {
 	return &accountService{
-		accountRepo:     accountRepo,
-		transactionRepo: transactionRepo,
+		accountRepo:         accountRepo,
+		transactionRepo:     transactionRepo,
+		ledgerService:       ledgerService,
+		userNotifier:        userNotifier,
+		lowBalanceThreshold: config.GetNotificationConfig().LowBalanceThreshold,
 	}
 }
 
 func (s *accountService) CreateAccount(userID uint, req *dto.CreateAccountRequest) (*dto.AccountResponse, error) {
+	currency := util.DefaultCurrency
+	if req.Currency != "" {
+		currency = strings.ToUpper(req.Currency)
+	}
+
 	account := &model.Account{
-		UserID: userID,
-		Name:   req.Name,
+		UserID:   userID,
+		Name:     req.Name,
+		Currency: currency,
 	}
 
 	if err := s.accountRepo.Create(account); err != nil {
@@ -55,121 +95,187 @@ func (s *accountService) GetUserAccounts(userID uint) ([]*dto.AccountResponse, e
 	return responses, nil
 }
 
-func (s *accountService) Deposit(userID, accountID uint, amount float64) (*dto.AccountResponse, error) {
-	account, err := s.accountRepo.FindByID(accountID)
-	if err != nil {
-		return nil, err
-	}
+func (s *accountService) Deposit(userID, accountID uint, amount util.Money, currency string) (*dto.AccountResponse, error) {
+	var account *model.Account
 
-	if account.UserID != userID {
-		return nil, errors.New("unauthorized access to account")
-	}
+	err := s.inTransaction(func(tx *gorm.DB) error {
+		var err error
+		account, err = s.accountRepo.WithTx(tx).FindByIDForUpdate(accountID)
+		if err != nil {
+			return err
+		}
 
-	account.Balance += amount
-	account.Nonce++
+		if err := policy.Authorize(policy.Owner(userID), policy.ActionTransact, policy.AccountOf(account)); err != nil {
+			return err
+		}
 
-	if err := s.accountRepo.Update(account); err != nil {
-		return nil, err
-	}
+		if err := checkAmountCurrency(account, amount, currency); err != nil {
+			return err
+		}
 
-	return toAccountResponse(account), nil
-}
+		transaction := &model.Transaction{
+			ToAccountID: &accountID,
+			Amount:      amount,
+			Currency:    account.Currency,
+			Type:        model.TransactionTypeDeposit,
+			Status:      model.TransactionStatusCompleted,
+			Description: "Deposit",
+		}
+		if err := s.transactionRepo.WithTx(tx).Create(transaction); err != nil {
+			return err
+		}
 
-func (s *accountService) Withdraw(userID, accountID uint, amount float64) (*dto.AccountResponse, error) {
-	account, err := s.accountRepo.FindByID(accountID)
+		// Cash enters the bank and is owed to the customer
+		_, err = s.ledgerService.Post(tx, transaction, []LedgerLine{
+			{LedgerAccount: model.LedgerAccountExternalCash, Direction: model.PostingDirectionDebit, Amount: amount},
+			{LedgerAccount: model.LedgerAccountCustomer, Account: account, Direction: model.PostingDirectionCredit, Amount: amount},
+		})
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if account.UserID != userID {
-		return nil, errors.New("unauthorized access to account")
-	}
+	return toAccountResponse(account), nil
+}
 
-	if account.Balance < amount {
-		return nil, errors.New("insufficient balance")
-	}
+func (s *accountService) Withdraw(userID, accountID uint, amount util.Money, currency string) (*dto.AccountResponse, error) {
+	var account *model.Account
+	var previousBalance util.Money
 
-	account.Balance -= amount
-	account.Nonce++
+	err := s.inTransaction(func(tx *gorm.DB) error {
+		var err error
+		account, err = s.accountRepo.WithTx(tx).FindByIDForUpdate(accountID)
+		if err != nil {
+			return err
+		}
 
-	if err := s.accountRepo.Update(account); err != nil {
-		return nil, err
-	}
+		if err := policy.Authorize(policy.Owner(userID), policy.ActionTransact, policy.AccountOf(account)); err != nil {
+			return err
+		}
 
-	return toAccountResponse(account), nil
-}
+		if err := checkAmountCurrency(account, amount, currency); err != nil {
+			return err
+		}
 
-func (s *accountService) Transfer(userID uint, sourceAccountID uint, targetAccountID uint, amount float64) (*dto.AccountResponse, error) {
-	// Get source account
-	sourceAccount, err := s.accountRepo.FindByID(sourceAccountID)
+		if account.Balance.LessThan(amount) {
+			return ErrInsufficientBalance
+		}
+		previousBalance = account.Balance
+
+		transaction := &model.Transaction{
+			FromAccountID: &accountID,
+			Amount:        amount,
+			Currency:      account.Currency,
+			Type:          model.TransactionTypeWithdraw,
+			Status:        model.TransactionStatusCompleted,
+			Description:   "Withdrawal",
+		}
+		if err := s.transactionRepo.WithTx(tx).Create(transaction); err != nil {
+			return err
+		}
+
+		// Cash leaves the bank and the customer is owed less
+		_, err = s.ledgerService.Post(tx, transaction, []LedgerLine{
+			{LedgerAccount: model.LedgerAccountCustomer, Account: account, Direction: model.PostingDirectionDebit, Amount: amount},
+			{LedgerAccount: model.LedgerAccountExternalCash, Direction: model.PostingDirectionCredit, Amount: amount},
+		})
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Verify ownership of source account
-	if sourceAccount.UserID != userID {
-		return nil, errors.New("unauthorized access to source account")
-	}
+	s.notifyLowBalance(account, previousBalance)
 
-	// Get target account
-	targetAccount, err := s.accountRepo.FindByID(targetAccountID)
-	if err != nil {
-		return nil, err
-	}
+	return toAccountResponse(account), nil
+}
 
-	// Check sufficient balance
-	if sourceAccount.Balance < amount {
-		return nil, errors.New("insufficient balance")
+func (s *accountService) Transfer(userID uint, sourceAccountID uint, targetAccountID uint, amount util.Money, currency string) (*dto.AccountResponse, error) {
+	if sourceAccountID == targetAccountID {
+		return nil, errors.New("cannot transfer to the same account")
 	}
 
-	// Update source account balance
-	sourceAccount.Balance -= amount
-	sourceAccount.Nonce++
+	var sourceAccount, targetAccount *model.Account
+	var transaction *model.Transaction
+	var previousBalance util.Money
 
-	// Update target account balance
-	targetAccount.Balance += amount
-	targetAccount.Nonce++
+	// Use a transaction so the balances and the ledger entry succeed or fail together
+	err := s.inTransaction(func(tx *gorm.DB) error {
+		// Lock both accounts in ascending ID order
+		accounts, err := s.accountRepo.WithTx(tx).FindByIDsForUpdate(sourceAccountID, targetAccountID)
+		if err != nil {
+			return err
+		}
+		sourceAccount = accounts[sourceAccountID]
+		targetAccount = accounts[targetAccountID]
 
-	// Use a transaction to ensure both updates succeed or both fail
-	tx := s.accountRepo.GetDB().Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+		// Verify ownership of source account
+		if err := policy.Authorize(policy.Owner(userID), policy.ActionTransact, policy.AccountOf(sourceAccount)); err != nil {
+			return err
 		}
-	}()
 
-	if err := tx.Save(sourceAccount).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+		if err := checkAmountCurrency(sourceAccount, amount, currency); err != nil {
+			return err
+		}
+		if targetAccount.Currency != sourceAccount.Currency {
+			return errors.New("source and target accounts use different currencies")
+		}
 
-	if err := tx.Save(targetAccount).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+		// Check sufficient balance
+		if sourceAccount.Balance.LessThan(amount) {
+			return ErrInsufficientBalance
+		}
+		previousBalance = sourceAccount.Balance
+
+		transaction = &model.Transaction{
+			FromAccountID: &sourceAccountID,
+			ToAccountID:   &targetAccountID,
+			Amount:        amount,
+			Currency:      sourceAccount.Currency,
+			Type:          model.TransactionTypeTransfer,
+			Status:        model.TransactionStatusCompleted,
+			Description:   "Transfer",
+		}
+		if err := s.transactionRepo.WithTx(tx).Create(transaction); err != nil {
+			return err
+		}
 
-	if err := tx.Commit().Error; err != nil {
+		_, err = s.ledgerService.Post(tx, transaction, []LedgerLine{
+			{LedgerAccount: model.LedgerAccountCustomer, Account: sourceAccount, Direction: model.PostingDirectionDebit, Amount: amount},
+			{LedgerAccount: model.LedgerAccountCustomer, Account: targetAccount, Direction: model.PostingDirectionCredit, Amount: amount},
+		})
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	s.notifyTransferCompleted(transaction, sourceAccount, targetAccount, previousBalance)
+
 	return toAccountResponse(sourceAccount), nil
 }
 
-func (s *accountService) CreateDefaultAccount(userID uint) (*dto.AccountResponse, error) {
+func (s *accountService) CreateDefaultAccount(tx *gorm.DB, userID uint) (*dto.AccountResponse, error) {
 	account := &model.Account{
 		UserID:    userID,
 		Name:      "Default Account",
+		Currency:  util.DefaultCurrency,
 		IsDefault: true,
 	}
 
-	if err := s.accountRepo.Create(account); err != nil {
+	if err := s.accountRepo.WithTx(tx).Create(account); err != nil {
 		return nil, err
 	}
 
 	return toAccountResponse(account), nil
 }
 
-func (s *accountService) InitiateTransfer(userID uint, sourceAccountID uint, targetAccountID uint, amount float64) (*model.Transaction, error) {
+func (s *accountService) InitiateTransfer(userID uint, sourceAccountID uint, targetAccountID uint, amount util.Money, currency string, description string) (*model.Transaction, error) {
+	if sourceAccountID == targetAccountID {
+		return nil, errors.New("cannot transfer to the same account")
+	}
+
 	// Get source account
 	sourceAccount, err := s.accountRepo.FindByID(sourceAccountID)
 	if err != nil {
@@ -177,19 +283,26 @@ func (s *accountService) InitiateTransfer(userID uint, sourceAccountID uint, tar
 	}
 
 	// Verify ownership of source account
-	if sourceAccount.UserID != userID {
-		return nil, errors.New("unauthorized access to source account")
+	if err := policy.Authorize(policy.Owner(userID), policy.ActionTransact, policy.AccountOf(sourceAccount)); err != nil {
+		return nil, err
 	}
 
 	// Verify target account exists
-	_, err = s.accountRepo.FindByID(targetAccountID)
+	targetAccount, err := s.accountRepo.FindByID(targetAccountID)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkAmountCurrency(sourceAccount, amount, currency); err != nil {
+		return nil, err
+	}
+	if targetAccount.Currency != sourceAccount.Currency {
+		return nil, errors.New("source and target accounts use different currencies")
+	}
+
 	// Check sufficient balance
-	if sourceAccount.Balance < amount {
-		return nil, errors.New("insufficient balance")
+	if sourceAccount.Balance.LessThan(amount) {
+		return nil, ErrInsufficientBalance
 	}
 
 	// Create pending transaction
@@ -197,8 +310,13 @@ func (s *accountService) InitiateTransfer(userID uint, sourceAccountID uint, tar
 		FromAccountID: &sourceAccountID,
 		ToAccountID:   &targetAccountID,
 		Amount:        amount,
+		Currency:      sourceAccount.Currency,
 		Status:        model.TransactionStatusPending,
 		Type:          model.TransactionTypeTransfer,
+		Description:   description,
+	}
+	if transaction.Description == "" {
+		transaction.Description = "Transfer"
 	}
 
 	if err := s.transactionRepo.Create(transaction); err != nil {
@@ -208,12 +326,275 @@ func (s *accountService) InitiateTransfer(userID uint, sourceAccountID uint, tar
 	return transaction, nil
 }
 
+func (s *accountService) ExecuteTransfer(tx *gorm.DB, transactionID uint) (*model.Transaction, func(), error) {
+	transactionRepo := s.transactionRepo.WithTx(tx)
+
+	transaction, err := transactionRepo.FindByIDForUpdate(transactionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if transaction.Type != model.TransactionTypeTransfer || transaction.FromAccountID == nil || transaction.ToAccountID == nil {
+		return nil, nil, errors.New("transaction is not a transfer")
+	}
+	if transaction.Status != model.TransactionStatusVerified {
+		return nil, nil, errors.New("transaction is not verified")
+	}
+
+	// Lock both accounts in ascending ID order
+	accounts, err := s.accountRepo.WithTx(tx).FindByIDsForUpdate(*transaction.FromAccountID, *transaction.ToAccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	sourceAccount := accounts[*transaction.FromAccountID]
+	targetAccount := accounts[*transaction.ToAccountID]
+
+	// The balance may have changed since the transfer was initiated
+	if sourceAccount.Balance.LessThan(transaction.Amount) {
+		// Nothing moved; record the outcome so the transfer cannot be executed later
+		if err := transactionRepo.TransitionStatus(transactionID, model.TransactionStatusVerified, model.TransactionStatusFailed); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrInsufficientBalance
+	}
+	previousBalance := sourceAccount.Balance
+
+	_, err = s.ledgerService.Post(tx, transaction, []LedgerLine{
+		{LedgerAccount: model.LedgerAccountCustomer, Account: sourceAccount, Direction: model.PostingDirectionDebit, Amount: transaction.Amount},
+		{LedgerAccount: model.LedgerAccountCustomer, Account: targetAccount, Direction: model.PostingDirectionCredit, Amount: transaction.Amount},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transaction.Status = model.TransactionStatusCompleted
+	if err := transactionRepo.TransitionStatus(transactionID, model.TransactionStatusVerified, model.TransactionStatusCompleted); err != nil {
+		return nil, nil, err
+	}
+
+	notify := func() {
+		s.notifyTransferCompleted(transaction, sourceAccount, targetAccount, previousBalance)
+	}
+	return transaction, notify, nil
+}
+
+// notifyTransferCompleted sends the transfer receipt to the owner of the source account
+func (s *accountService) notifyTransferCompleted(transaction *model.Transaction, source, target *model.Account, previousBalance util.Money) {
+	s.userNotifier.NotifyUser(source.UserID, TemplateTransferReceipt, map[string]string{
+		"Amount":        transaction.Amount.Format(transaction.Currency),
+		"Currency":      transaction.Currency,
+		"FromAccount":   source.Name,
+		"ToAccount":     fmt.Sprintf("#%d", target.ID),
+		"Description":   transaction.Description,
+		"TransactionID": strconv.FormatUint(uint64(transaction.ID), 10),
+		"Time":          transaction.UpdatedAt.Format(notificationTimeLayout),
+		"Balance":       source.Balance.Format(source.Currency),
+	})
+
+	s.notifyLowBalance(source, previousBalance)
+}
+
+// notifyLowBalance warns the owner when a debit took the balance below the threshold.
+// Only crossing the threshold triggers a warning, so later debits do not repeat it.
+func (s *accountService) notifyLowBalance(account *model.Account, previousBalance util.Money) {
+	if !account.Balance.LessThan(s.lowBalanceThreshold) || previousBalance.LessThan(s.lowBalanceThreshold) {
+		return
+	}
+
+	s.userNotifier.NotifyUser(account.UserID, TemplateLowBalance, map[string]string{
+		"AccountName": account.Name,
+		"Balance":     account.Balance.Format(account.Currency),
+		"Currency":    account.Currency,
+		"Threshold":   s.lowBalanceThreshold.Format(account.Currency),
+	})
+}
+
+const (
+	defaultTransactionPageSize = 20
+	maxTransactionPageSize     = 100
+)
+
+func (s *accountService) GetTransactions(subject policy.Subject, accountID uint, query *dto.TransactionHistoryQuery) (*dto.TransactionListResponse, error) {
+	account, err := s.accountRepo.FindByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := policy.Authorize(subject, policy.ActionRead, policy.AccountOf(account)); err != nil {
+		return nil, err
+	}
+
+	filter := repository.TransactionFilter{
+		AccountID: accountID,
+		Type:      model.TransactionType(query.Type),
+		Status:    model.TransactionStatus(query.Status),
+		From:      query.From,
+		To:        query.To,
+		Limit:     query.Limit,
+	}
+	if filter.Limit <= 0 || filter.Limit > maxTransactionPageSize {
+		filter.Limit = defaultTransactionPageSize
+	}
+
+	if query.MinAmount != "" {
+		minAmount, err := util.NewMoneyFromString(query.MinAmount)
+		if err != nil {
+			return nil, errors.New("invalid min_amount")
+		}
+		filter.MinAmount = &minAmount
+	}
+	if query.MaxAmount != "" {
+		maxAmount, err := util.NewMoneyFromString(query.MaxAmount)
+		if err != nil {
+			return nil, errors.New("invalid max_amount")
+		}
+		filter.MaxAmount = &maxAmount
+	}
+	if query.Cursor != "" {
+		beforeID, err := decodeTransactionCursor(query.Cursor)
+		if err != nil {
+			return nil, errors.New("invalid cursor")
+		}
+		filter.BeforeID = beforeID
+	}
+
+	// Fetch one extra row to know whether another page exists
+	pageSize := filter.Limit
+	filter.Limit++
+	transactions, err := s.transactionRepo.FindByAccount(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.TransactionListResponse{Transactions: []dto.TransactionResponse{}}
+	if len(transactions) > pageSize {
+		transactions = transactions[:pageSize]
+		response.NextCursor = encodeTransactionCursor(transactions[pageSize-1].ID)
+	}
+	for _, transaction := range transactions {
+		response.Transactions = append(response.Transactions, toTransactionResponse(transaction, accountID))
+	}
+
+	return response, nil
+}
+
+func encodeTransactionCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeTransactionCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+// toTransactionResponse describes a transaction from the point of view of accountID
+func toTransactionResponse(transaction *model.Transaction, accountID uint) dto.TransactionResponse {
+	response := dto.TransactionResponse{
+		ID:          transaction.ID,
+		Type:        string(transaction.Type),
+		Status:      string(transaction.Status),
+		Direction:   "incoming",
+		Amount:      presentAmount(transaction.Amount, transaction.Currency),
+		Currency:    transaction.Currency,
+		Description: transaction.Description,
+		CreatedAt:   transaction.CreatedAt,
+	}
+
+	counterparty := transaction.FromAccount
+	if transaction.FromAccountID != nil && *transaction.FromAccountID == accountID {
+		response.Direction = "outgoing"
+		counterparty = transaction.ToAccount
+	}
+
+	if counterparty != nil {
+		response.Counterparty = &dto.CounterpartyResponse{
+			AccountID:   counterparty.ID,
+			AccountName: counterparty.Name,
+			OwnerName:   counterparty.User.Name,
+		}
+	}
+
+	return response
+}
+
+// maxConflictRetries bounds how often a money movement is retried after a concurrent update
+const maxConflictRetries = 5
+
+// inTransaction runs fn in a DB transaction of the account repository, see runInTransaction
+func (s *accountService) inTransaction(fn func(tx *gorm.DB) error) error {
+	return runInTransaction(s.accountRepo.GetDB(), fn)
+}
+
+// runInTransaction runs fn in a DB transaction and retries it when it loses a race:
+// a nonce compare-and-swap conflict, a serialization failure or a deadlock.
+func runInTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	var err error
+	for attempt := 0; attempt < maxConflictRetries; attempt++ {
+		err = db.Transaction(fn)
+		if !isRetryableConflict(err) {
+			return err
+		}
+		// Back off with jitter so competing requests do not collide again
+		backoff := time.Duration(1<<attempt) * 5 * time.Millisecond
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+	}
+	return err
+}
+
+// isRetryableConflict reports whether err is a lost race that is safe to retry
+func isRetryableConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, repository.ErrConcurrentUpdate) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// serialization_failure, deadlock_detected, lock_not_available
+		return pgErr.Code == "40001" || pgErr.Code == "40P01" || pgErr.Code == "55P03"
+	}
+	return false
+}
+
+// checkAmountCurrency rejects amounts in a different currency or with more decimals than the account currency allows
+func checkAmountCurrency(account *model.Account, amount util.Money, currency string) error {
+	if currency != "" && !strings.EqualFold(currency, account.Currency) {
+		return errors.New("currency does not match account currency")
+	}
+	if !amount.IsPositive() {
+		return errors.New("amount must be positive")
+	}
+	if !amount.FitsCurrency(account.Currency) {
+		return errors.New("amount has too many decimal places for " + account.Currency)
+	}
+	return nil
+}
+
 func toAccountResponse(account *model.Account) *dto.AccountResponse {
 	return &dto.AccountResponse{
 		ID:        account.ID,
 		UserID:    account.UserID,
 		Name:      account.Name,
-		Balance:   account.Balance,
+		Balance:   presentAmount(account.Balance, account.Currency),
+		Currency:  account.Currency,
 		IsDefault: account.IsDefault,
 	}
 }
+
+// presentAmount rounds an amount kept at storage scale to the minor units of its currency
+func presentAmount(amount util.Money, currency string) util.Money {
+	rounded, err := amount.RoundToCurrency(currency)
+	if err != nil {
+		return amount
+	}
+	return rounded
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyIDPrefix    = "ak_"
+	apiKeyIDBytes     = 12
+	apiKeySecretBytes = 32
+)
+
+var (
+	ErrAPIKeyLimit = errors.New("maximum number of active API keys reached")
+	// ErrAPIKeyNotRotatable is returned when a key that was rotated, revoked or expired is rotated
+	ErrAPIKeyNotRotatable = errors.New("API key was already rotated or revoked")
+)
+
+type APIKeyService interface {
+	ListKeys(userID uint) ([]dto.APIKeyResponse, error)
+	// IssueKey creates a key; its secret is only returned here
+	IssueKey(userID uint, req *dto.APIKeyRequest) (*dto.APIKeyResponse, error)
+	// RotateKey issues a replacement with the same name. The rotated key keeps working for
+	// the grace period, so clients can switch over without failing requests.
+	RotateKey(userID, id uint) (*dto.APIKeyRotationResponse, error)
+	// RevokeKey stops the key at once
+	RevokeKey(userID, id uint) error
+}
+
+type apiKeyService struct {
+	apiKeyRepo     repository.APIKeyRepository
+	encryptionKey  string
+	rotationGrace  time.Duration
+	maxKeysPerUser int
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) APIKeyService {
+	apiKeyConfig := config.GetAPIKeyConfig()
+	return &apiKeyService{
+		apiKeyRepo:     apiKeyRepo,
+		encryptionKey:  apiKeyConfig.EncryptionKey,
+		rotationGrace:  apiKeyConfig.RotationGracePeriod,
+		maxKeysPerUser: apiKeyConfig.MaxKeysPerUser,
+	}
+}
+
+func (s *apiKeyService) ListKeys(userID uint) ([]dto.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.FindActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		responses = append(responses, toAPIKeyResponse(key))
+	}
+	return responses, nil
+}
+
+func (s *apiKeyService) IssueKey(userID uint, req *dto.APIKeyRequest) (*dto.APIKeyResponse, error) {
+	key, secret, err := s.newKey(userID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apiKeyRepo.CreateWithinLimit(key, s.maxKeysPerUser); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyLimit) {
+			return nil, ErrAPIKeyLimit
+		}
+		return nil, err
+	}
+
+	response := toAPIKeyResponse(key)
+	response.Secret = secret
+	return &response, nil
+}
+
+func (s *apiKeyService) RotateKey(userID, id uint) (*dto.APIKeyRotationResponse, error) {
+	previous, err := s.findOwnKey(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if previous.ExpiresAt != nil || !previous.IsActive(time.Now()) {
+		return nil, ErrAPIKeyNotRotatable
+	}
+
+	key, secret, err := s.newKey(userID, previous.Name)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.rotationGrace)
+	if err := s.apiKeyRepo.Rotate(previous.ID, expiresAt, key); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyStatus) {
+			return nil, ErrAPIKeyNotRotatable
+		}
+		return nil, err
+	}
+
+	response := &dto.APIKeyRotationResponse{
+		APIKeyResponse:       toAPIKeyResponse(key),
+		PreviousKeyExpiresAt: expiresAt,
+	}
+	response.Secret = secret
+	return response, nil
+}
+
+func (s *apiKeyService) RevokeKey(userID, id uint) error {
+	if _, err := s.findOwnKey(userID, id); err != nil {
+		return err
+	}
+	return s.apiKeyRepo.Revoke(id)
+}
+
+// findOwnKey reports keys of other users as missing rather than forbidden
+func (s *apiKeyService) findOwnKey(userID, id uint) (*model.APIKey, error) {
+	key, err := s.apiKeyRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return key, nil
+}
+
+// newKey generates a key ID and a secret and seals the secret for storage
+func (s *apiKeyService) newKey(userID uint, name string) (*model.APIKey, string, error) {
+	keyID, err := util.GenerateOpaqueToken(apiKeyIDBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := util.GenerateOpaqueToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, err := util.SealSecret(s.encryptionKey, secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &model.APIKey{
+		UserID:           userID,
+		KeyID:            apiKeyIDPrefix + keyID,
+		Name:             name,
+		SecretCiphertext: sealed,
+	}, secret, nil
+}
+
+func toAPIKeyResponse(key *model.APIKey) dto.APIKeyResponse {
+	return dto.APIKeyResponse{
+		ID:         key.ID,
+		KeyID:      key.KeyID,
+		Name:       key.Name,
+		LastUsedAt: key.LastUsedAt,
+		ExpiresAt:  key.ExpiresAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-gin-template/api/util"
+)
+
+// ErrCapturedMessageNotFound is returned when the inbox holds no message with the given ID
+var ErrCapturedMessageNotFound = errors.New("captured message not found")
+
+// CapturedMessage is a notification stored by a CaptureSender instead of being sent
+type CapturedMessage struct {
+	ID         string            `json:"id"`
+	Channel    string            `json:"channel"`
+	To         string            `json:"to"`
+	TemplateID string            `json:"template_id"`
+	Locale     string            `json:"locale"`
+	Variables  map[string]string `json:"variables"`
+	Subject    string            `json:"subject"`
+	Text       string            `json:"text"`
+	HTML       string            `json:"html"`
+	SMS        string            `json:"sms"`
+	Reference  string            `json:"reference"`
+	CapturedAt time.Time         `json:"captured_at"`
+}
+
+// Inbox stores captured messages for development and tests
+type Inbox interface {
+	Save(message *CapturedMessage) error
+	// List returns the captured messages, newest first; a non-empty to filters by recipient
+	List(to string) ([]*CapturedMessage, error)
+	Get(id string) (*CapturedMessage, error)
+}
+
+// CaptureSender implements NotificationSender by rendering messages into an Inbox.
+// It replaces the real senders outside release mode when MAIL_CAPTURE is set.
+type CaptureSender struct {
+	channel   string
+	templates TemplateRegistry
+	inbox     Inbox
+}
+
+func NewCaptureSender(channel string, templates TemplateRegistry, inbox Inbox) NotificationSender {
+	return &CaptureSender{channel: channel, templates: templates, inbox: inbox}
+}
+
+func (s *CaptureSender) Send(message *Message) error {
+	content, err := s.templates.Render(message.TemplateID, message.Locale, message.Variables)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	captured := &CapturedMessage{
+		ID:         fmt.Sprintf("%d-%s", time.Now().UnixNano(), util.GenerateRandomString(6)),
+		Channel:    s.channel,
+		To:         message.To,
+		TemplateID: message.TemplateID,
+		Locale:     message.Locale,
+		Variables:  message.Variables,
+		Subject:    content.Subject,
+		Text:       content.Text,
+		HTML:       content.HTML,
+		SMS:        content.SMS,
+		Reference:  message.Reference,
+		CapturedAt: time.Now(),
+	}
+	if err := s.inbox.Save(captured); err != nil {
+		return err
+	}
+
+	log.Printf("📥 Captured %s to %s (%s)", s.channel, message.To, captured.ID)
+	return nil
+}
+
+func (s *CaptureSender) GetType() string {
+	return s.channel
+}
+
+// memoryInbox keeps captured messages in process memory
+type memoryInbox struct {
+	mu       sync.RWMutex
+	messages []*CapturedMessage
+}
+
+func NewMemoryInbox() Inbox {
+	return &memoryInbox{}
+}
+
+func (i *memoryInbox) Save(message *CapturedMessage) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.messages = append(i.messages, message)
+	return nil
+}
+
+func (i *memoryInbox) List(to string) ([]*CapturedMessage, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	messages := make([]*CapturedMessage, 0, len(i.messages))
+	for j := len(i.messages) - 1; j >= 0; j-- {
+		if to == "" || strings.EqualFold(i.messages[j].To, to) {
+			messages = append(messages, i.messages[j])
+		}
+	}
+	return messages, nil
+}
+
+func (i *memoryInbox) Get(id string) (*CapturedMessage, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, message := range i.messages {
+		if message.ID == id {
+			return message, nil
+		}
+	}
+	return nil, ErrCapturedMessageNotFound
+}
+
+// spoolInbox writes each captured message to <dir>/<id>.json, so messages survive
+// restarts and can be read by other processes
+type spoolInbox struct {
+	dir string
+}
+
+func NewSpoolInbox(dir string) (Inbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &spoolInbox{dir: dir}, nil
+}
+
+func (i *spoolInbox) Save(message *CapturedMessage) error {
+	data, err := json.MarshalIndent(message, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial message
+	path := filepath.Join(i.dir, message.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (i *spoolInbox) List(to string) ([]*CapturedMessage, error) {
+	paths, err := filepath.Glob(filepath.Join(i.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*CapturedMessage, 0, len(paths))
+	for _, path := range paths {
+		message, err := readCapturedMessage(path)
+		if err != nil {
+			return nil, err
+		}
+		if to == "" || strings.EqualFold(message.To, to) {
+			messages = append(messages, message)
+		}
+	}
+
+	sort.Slice(messages, func(a, b int) bool {
+		return messages[a].CapturedAt.After(messages[b].CapturedAt)
+	})
+	return messages, nil
+}
+
+func (i *spoolInbox) Get(id string) (*CapturedMessage, error) {
+	// IDs come from the URL; reject anything that could leave the spool directory
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, ErrCapturedMessageNotFound
+	}
+
+	message, err := readCapturedMessage(filepath.Join(i.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCapturedMessageNotFound
+	}
+	return message, err
+}
+
+func readCapturedMessage(path string) (*CapturedMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var message CapturedMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return &message, nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrContactUnavailable = errors.New("no contact on file for this channel")
+	ErrContactUnverified  = errors.New("the contact for this channel is not verified")
+)
+
+type ContactService interface {
+	// SyncFromUser records the email and phone of the profile as the user's contacts.
+	// A changed value loses its verified state.
+	SyncFromUser(user *model.User) error
+	// ResolveRecipient returns the verified contact to deliver to, or ErrContactUnverified.
+	// An empty channel selects the user's preferred channel, falling back to email while
+	// the preferred contact is not verified.
+	ResolveRecipient(userID uint, channel model.ContactChannel) (*model.UserContact, error)
+	GetContacts(userID uint) (*dto.ContactListResponse, error)
+	SetPreferredChannel(userID uint, channel model.ContactChannel) (*dto.ContactListResponse, error)
+	// MarkVerified records within tx that the user proved they own the value of the channel.
+	// A contact whose value changed since is left unverified.
+	MarkVerified(tx *gorm.DB, userID uint, channel model.ContactChannel, value string) error
+}
+
+type contactService struct {
+	userRepo    repository.UserRepository
+	contactRepo repository.UserContactRepository
+}
+
+func NewContactService(userRepo repository.UserRepository, contactRepo repository.UserContactRepository) ContactService {
+	return &contactService{
+		userRepo:    userRepo,
+		contactRepo: contactRepo,
+	}
+}
+
+func (s *contactService) SyncFromUser(user *model.User) error {
+	profile := map[model.ContactChannel]string{
+		model.ContactChannelEmail: user.Email,
+		model.ContactChannelSMS:   user.Phone,
+	}
+
+	for channel, value := range profile {
+		if value == "" {
+			continue
+		}
+
+		existing, err := s.contactRepo.FindByUserAndChannel(user.ID, channel)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if existing != nil && existing.Value == value {
+			continue
+		}
+
+		// Upsert clears verified_at, so the new value has to be confirmed again before
+		// anything is sent to it
+		if err := s.contactRepo.Upsert(&model.UserContact{
+			UserID:  user.ID,
+			Channel: channel,
+			Value:   value,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *contactService) ResolveRecipient(userID uint, channel model.ContactChannel) (*model.UserContact, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if channel != "" {
+		return s.verifiedContact(user, channel)
+	}
+
+	// Messages go to the email address while the preferred contact is not verified
+	preferred := preferredChannel(user)
+	contact, err := s.verifiedContact(user, preferred)
+	if preferred != model.ContactChannelEmail && (errors.Is(err, ErrContactUnverified) || errors.Is(err, ErrContactUnavailable)) {
+		return s.verifiedContact(user, model.ContactChannelEmail)
+	}
+	return contact, err
+}
+
+// verifiedContact returns the contact of the channel if the user verified it
+func (s *contactService) verifiedContact(user *model.User, channel model.ContactChannel) (*model.UserContact, error) {
+	contact, err := s.contactRepo.FindByUserAndChannel(user.ID, channel)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if contact == nil {
+		if contact, err = s.contactFromProfile(user, channel); err != nil {
+			return nil, err
+		}
+	}
+
+	verified, err := s.isVerified(user, contact)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrContactUnverified
+	}
+	return contact, nil
+}
+
+// contactFromProfile records the profile value of the channel as a contact. Users
+// registered before contacts were recorded only have their profile.
+func (s *contactService) contactFromProfile(user *model.User, channel model.ContactChannel) (*model.UserContact, error) {
+	var value string
+	switch channel {
+	case model.ContactChannelEmail:
+		value = user.Email
+	case model.ContactChannelSMS:
+		value = user.Phone
+	default:
+		return nil, fmt.Errorf("unsupported contact channel '%s'", channel)
+	}
+	if value == "" {
+		return nil, ErrContactUnavailable
+	}
+
+	contact := &model.UserContact{UserID: user.ID, Channel: channel, Value: value}
+	if err := s.contactRepo.Upsert(contact); err != nil {
+		return nil, err
+	}
+	return contact, nil
+}
+
+// isVerified reports whether messages may be sent to the contact. The email address of an
+// active user was confirmed with the verification link, or registered before links were
+// sent, so it is recorded as verified the first time it is looked up.
+func (s *contactService) isVerified(user *model.User, contact *model.UserContact) (bool, error) {
+	if contact.IsVerified() {
+		return true, nil
+	}
+	if contact.Channel != model.ContactChannelEmail || contact.Value != user.Email || user.Status != model.UserStatusActive {
+		return false, nil
+	}
+
+	if _, err := s.contactRepo.MarkVerified(user.ID, contact.Channel, contact.Value); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	contact.VerifiedAt = &now
+	return true, nil
+}
+
+func (s *contactService) GetContacts(userID uint) (*dto.ContactListResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SyncFromUser(user); err != nil {
+		return nil, err
+	}
+
+	contacts, err := s.contactRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.ContactListResponse{
+		PreferredChannel: string(preferredChannel(user)),
+		Contacts:         make([]dto.ContactResponse, 0, len(contacts)),
+	}
+	for _, contact := range contacts {
+		if _, err := s.isVerified(user, contact); err != nil {
+			return nil, err
+		}
+		response.Contacts = append(response.Contacts, dto.ContactResponse{
+			Channel:    string(contact.Channel),
+			Hint:       MaskContact(contact),
+			Verified:   contact.IsVerified(),
+			VerifiedAt: contact.VerifiedAt,
+		})
+	}
+	return response, nil
+}
+
+func (s *contactService) SetPreferredChannel(userID uint, channel model.ContactChannel) (*dto.ContactListResponse, error) {
+	if _, err := s.ResolveRecipient(userID, channel); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	user.PreferredChannel = channel
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return s.GetContacts(userID)
+}
+
+func (s *contactService) MarkVerified(tx *gorm.DB, userID uint, channel model.ContactChannel, value string) error {
+	_, err := s.contactRepo.WithTx(tx).MarkVerified(userID, channel, value)
+	return err
+}
+
+// MaskContact returns a hint of the contact value that is safe to show in responses
+func MaskContact(contact *model.UserContact) string {
+	if contact.Channel == model.ContactChannelSMS {
+		return util.MaskPhone(contact.Value)
+	}
+	return util.MaskEmail(contact.Value)
+}
+
+func preferredChannel(user *model.User) model.ContactChannel {
+	if user.PreferredChannel == "" {
+		return model.ContactChannelEmail
+	}
+	return user.PreferredChannel
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+// maxContactCodeFailures is the number of wrong codes after which a contact code is dropped
+const maxContactCodeFailures = 5
+
+var (
+	ErrContactAlreadyVerified = errors.New("the contact for this channel is already verified")
+	ErrInvalidContactCode     = errors.New("invalid or expired confirmation code")
+)
+
+// ContactVerificationService confirms that a user owns the phone number of their profile by
+// texting it a code. The email address is confirmed by EmailVerificationService.
+type ContactVerificationService interface {
+	// SendCode texts a confirmation code to the user's phone number and returns the contact
+	// it was sent to. A new code replaces the previous one.
+	SendCode(userID uint) (*model.UserContact, error)
+	// Confirm marks the phone number verified when the code matches
+	Confirm(userID uint, code string) error
+}
+
+type contactVerificationService struct {
+	userRepo       repository.UserRepository
+	contactRepo    repository.UserContactRepository
+	codeRepo       repository.ContactCodeRepository
+	contactService ContactService
+	userNotifier   UserNotifier
+	codeSecret     string
+}
+
+func NewContactVerificationService(userRepo repository.UserRepository, contactRepo repository.UserContactRepository, codeRepo repository.ContactCodeRepository, contactService ContactService, userNotifier UserNotifier) ContactVerificationService {
+	return &contactVerificationService{
+		userRepo:       userRepo,
+		contactRepo:    contactRepo,
+		codeRepo:       codeRepo,
+		contactService: contactService,
+		userNotifier:   userNotifier,
+		codeSecret:     config.GetVerificationConfig().CodeSecret,
+	}
+}
+
+func (s *contactVerificationService) SendCode(userID uint) (*model.UserContact, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	// Users registered before contacts were recorded only have their profile
+	if err := s.contactService.SyncFromUser(user); err != nil {
+		return nil, err
+	}
+
+	contact, err := s.contactRepo.FindByUserAndChannel(userID, model.ContactChannelSMS)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrContactUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	if contact.IsVerified() {
+		return nil, ErrContactAlreadyVerified
+	}
+
+	pending, err := s.codeRepo.Find(userID, model.ContactChannelSMS)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if pending != nil {
+		if wait := VerificationResendCooldown - time.Since(pending.SentAt); wait > 0 {
+			return nil, &CooldownError{RetryAfter: wait}
+		}
+	}
+
+	code, err := util.GenerateNumericCode(verificationCodeDigits)
+	if err != nil {
+		return nil, errors.New("failed to generate confirmation code")
+	}
+
+	err = s.codeRepo.Create(userID, model.ContactChannelSMS, &repository.ContactCode{
+		Value:    contact.Value,
+		CodeHash: util.HashCode(s.codeSecret, contactCodeScope(contact), code),
+		SentAt:   time.Now(),
+	}, verificationCodeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.userNotifier.NotifyContact(contact, TemplateContactVerification, map[string]string{
+		"Code":             code,
+		"ExpiresInMinutes": strconv.Itoa(int(verificationCodeTTL.Minutes())),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return contact, nil
+}
+
+func (s *contactVerificationService) Confirm(userID uint, code string) error {
+	pending, err := s.codeRepo.Find(userID, model.ContactChannelSMS)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidContactCode
+	}
+	if err != nil {
+		return err
+	}
+
+	// The code only confirms the number it was sent to
+	contact := &model.UserContact{UserID: userID, Channel: model.ContactChannelSMS, Value: pending.Value}
+	if !util.CompareCodeHash(s.codeSecret, contactCodeScope(contact), code, pending.CodeHash) {
+		failures, err := s.codeRepo.RecordFailure(userID, model.ContactChannelSMS)
+		if err != nil {
+			return err
+		}
+		if failures >= maxContactCodeFailures {
+			if err := s.codeRepo.Delete(userID, model.ContactChannelSMS); err != nil {
+				return err
+			}
+		}
+		return ErrInvalidContactCode
+	}
+
+	if err := s.codeRepo.Delete(userID, model.ContactChannelSMS); err != nil {
+		return err
+	}
+
+	// A number changed since the code was sent stays unverified
+	verified, err := s.contactRepo.MarkVerified(userID, model.ContactChannelSMS, pending.Value)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrInvalidContactCode
+	}
+	return nil
+}
+
+// contactCodeScope binds a code hash to the user and the contact value it was sent to
+func contactCodeScope(contact *model.UserContact) string {
+	return fmt.Sprintf("contact:%d:%s:%s", contact.UserID, contact.Channel, contact.Value)
+}
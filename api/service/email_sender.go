@@ -1,70 +1,60 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
 	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
-	"sync"
+	"net/textproto"
 
 	"go-gin-template/api/config"
 )
 
 // EmailSender implements NotificationSender for email notifications
-type EmailSender struct{
-	// wg is used to wait for all email sending goroutines to complete
-	wg sync.WaitGroup
+type EmailSender struct {
+	templates TemplateRegistry
 }
 
-func NewEmailSender() NotificationSender {
-	return &EmailSender{}
+func NewEmailSender(templates TemplateRegistry) NotificationSender {
+	return &EmailSender{templates: templates}
 }
 
-func (e *EmailSender) Send(to, code string) error {
-	// Check email configuration before starting the goroutine
+func (e *EmailSender) Send(message *Message) error {
+	// Check email configuration
 	config := config.GetEmailConfig()
 	if config.SMTPUsername == "" || config.SMTPPassword == "" {
 		return fmt.Errorf("email credentials not configured")
 	}
-	
-	// Increment the wait group counter
-	e.wg.Add(1)
-	
-	// Launch a goroutine to send the email
-	go func(recipient, verificationCode string) {
-		// Ensure the wait group counter is decremented when the goroutine completes
-		defer e.wg.Done()
-		
-		subject := "Transfer Verification Code"
-		body := fmt.Sprintf("Your verification code is: %s\nThis code will expire in 5 minutes.", verificationCode)
 
-		// Set up authentication information
-		auth := smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, config.SMTPHost)
+	content, err := e.templates.Render(message.TemplateID, message.Locale, message.Variables)
+	if err != nil {
+		return err
+	}
 
-		// Compose email
-		mime := "MIME-version: 1.0;\n" +
-			"Content-Type: text/plain; charset=\"UTF-8\";\n\n"
-		emailBody := fmt.Sprintf("Subject: %s\n%s\n%s", subject, mime, body)
+	emailBody, err := buildEmail(config.SMTPUsername, message.To, content)
+	if err != nil {
+		return err
+	}
 
-		// Send email
-		err := smtp.SendMail(
-			fmt.Sprintf("%s:%d", config.SMTPHost, config.SMTPPort),
-			auth,
-			config.SMTPUsername,
-			[]string{recipient},
-			[]byte(emailBody),
-		)
+	// Set up authentication information
+	auth := smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, config.SMTPHost)
 
-		if err != nil {
-			log.Printf("Failed to send email: %v", err)
-			return
-		}
+	// Send email; failures are retried by the notification outbox
+	err = smtp.SendMail(
+		fmt.Sprintf("%s:%d", config.SMTPHost, config.SMTPPort),
+		auth,
+		config.SMTPUsername,
+		[]string{message.To},
+		emailBody,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
 
-		log.Printf("📧 Email sent successfully to %s", recipient)
-	}(to, code)
-	
-	// Return immediately, not waiting for the email to be sent
-	// The caller can continue execution while the email is being sent in the background
-	log.Printf("📧 Email sending initiated to %s", to)
+	log.Printf("📧 Email sent successfully to %s", message.To)
 	return nil
 }
 
@@ -72,9 +62,44 @@ func (e *EmailSender) GetType() string {
 	return "email"
 }
 
-// WaitForCompletion waits for all email sending goroutines to complete
-// This can be called when the application is shutting down to ensure all emails are sent
-func (e *EmailSender) WaitForCompletion() {
-	e.wg.Wait()
-	log.Printf("All email sending operations completed")
-}
\ No newline at end of file
+// buildEmail composes a multipart/alternative message with a plain text and an HTML part
+func buildEmail(from, to string, content *RenderedMessage) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=\"UTF-8\"", content.Text},
+		{"text/html; charset=\"UTF-8\"", content.HTML},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var email bytes.Buffer
+	fmt.Fprintf(&email, "From: %s\r\n", from)
+	fmt.Fprintf(&email, "To: %s\r\n", to)
+	fmt.Fprintf(&email, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", content.Subject))
+	fmt.Fprintf(&email, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&email, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", writer.Boundary())
+	email.Write(body.Bytes())
+
+	return email.Bytes(), nil
+}
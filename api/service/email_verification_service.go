@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidEmailVerificationToken = errors.New("invalid or expired email verification token")
+	ErrEmailAlreadyVerified          = errors.New("email address is already verified")
+	ErrVerificationResendTooSoon     = errors.New("a verification email was sent recently, try again later")
+)
+
+// EmailVerificationService confirms that a new user owns the email address they registered
+// with. The links carry a signed token, so nothing has to be stored to check them.
+type EmailVerificationService interface {
+	// SendVerification emails a verification link to a pending user
+	SendVerification(user *model.User) error
+	// Resend emails a new link; earlier links keep working until they expire
+	Resend(userID uint) error
+	// Verify activates the user named in the token, marks their email contact verified and
+	// opens their default account, all or nothing. Using a link again after the user was
+	// activated succeeds without doing anything.
+	Verify(token string) error
+}
+
+type emailVerificationService struct {
+	userRepo       repository.UserRepository
+	accountService AccountService
+	contactService ContactService
+	userNotifier   UserNotifier
+	codeSecret     string
+	config         config.EmailVerificationConfig
+}
+
+func NewEmailVerificationService(userRepo repository.UserRepository, accountService AccountService, contactService ContactService, userNotifier UserNotifier) EmailVerificationService {
+	return &emailVerificationService{
+		userRepo:       userRepo,
+		accountService: accountService,
+		contactService: contactService,
+		userNotifier:   userNotifier,
+		codeSecret:     config.GetVerificationConfig().CodeSecret,
+		config:         config.GetEmailVerificationConfig(),
+	}
+}
+
+func (s *emailVerificationService) SendVerification(user *model.User) error {
+	// The link goes to the email address being verified, the one contact that may receive
+	// messages before it is verified
+	contact := &model.UserContact{UserID: user.ID, Channel: model.ContactChannelEmail, Value: user.Email}
+
+	expiresAt := time.Now().Add(s.config.TokenTTL)
+	_, err := s.userNotifier.NotifyContact(contact, TemplateEmailVerification, map[string]string{
+		"VerifyURL":      s.verifyURL(s.signToken(user, expiresAt)),
+		"ExpiresInHours": strconv.Itoa(int(s.config.TokenTTL.Hours())),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.MarkVerificationSent(user.ID, time.Now())
+}
+
+func (s *emailVerificationService) Resend(userID uint) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Status != model.UserStatusPendingVerification {
+		return ErrEmailAlreadyVerified
+	}
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < s.config.ResendInterval {
+		return ErrVerificationResendTooSoon
+	}
+
+	return s.SendVerification(user)
+}
+
+func (s *emailVerificationService) Verify(token string) error {
+	userID, expiresAt, signature, ok := parseEmailVerificationToken(token)
+	if !ok || time.Now().After(expiresAt) {
+		return ErrInvalidEmailVerificationToken
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return ErrInvalidEmailVerificationToken
+	}
+	// The signature covers the email, so a link stops working when the address changes
+	if !util.CompareCodeHash(s.codeSecret, emailVerificationScope(user), strconv.FormatInt(expiresAt.Unix(), 10), signature) {
+		return ErrInvalidEmailVerificationToken
+	}
+
+	return s.userRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		activated, err := s.userRepo.WithTx(tx).Activate(user.ID)
+		if err != nil || !activated {
+			return err
+		}
+
+		// The link was sent to the email contact, so following it proves the address
+		if err := s.contactService.MarkVerified(tx, user.ID, model.ContactChannelEmail, user.Email); err != nil {
+			return err
+		}
+
+		_, err = s.accountService.CreateDefaultAccount(tx, user.ID)
+		return err
+	})
+}
+
+// signToken returns "<user ID>.<expiry>.<signature>"
+func (s *emailVerificationService) signToken(user *model.User, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	signature := util.HashCode(s.codeSecret, emailVerificationScope(user), expires)
+	return fmt.Sprintf("%d.%s.%s", user.ID, expires, signature)
+}
+
+func (s *emailVerificationService) verifyURL(token string) string {
+	verifyURL, err := url.Parse(s.config.URL)
+	if err != nil {
+		return s.config.URL + "?token=" + url.QueryEscape(token)
+	}
+
+	query := verifyURL.Query()
+	query.Set("token", token)
+	verifyURL.RawQuery = query.Encode()
+	return verifyURL.String()
+}
+
+func emailVerificationScope(user *model.User) string {
+	return fmt.Sprintf("email-verification:%d:%s", user.ID, user.Email)
+}
+
+func parseEmailVerificationToken(token string) (uint, time.Time, string, bool) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return 0, time.Time{}, "", false
+	}
+
+	userID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, time.Time{}, "", false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, "", false
+	}
+	return uint(userID), time.Unix(expires, 0), parts[2], true
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnbalancedEntry    = errors.New("journal entry debits and credits do not balance")
+	ErrInvalidLedgerLine  = errors.New("invalid ledger line")
+	ErrLedgerAccountEmpty = errors.New("customer ledger line requires an account")
+	ErrMixedCurrencyEntry = errors.New("journal entry must not mix currencies")
+)
+
+// LedgerLine describes one side of a money movement before it is posted
+type LedgerLine struct {
+	LedgerAccount model.LedgerAccount
+	// Account is required for customer ledger lines; its balance is updated in place
+	Account   *model.Account
+	Direction model.PostingDirection
+	Amount    util.Money
+}
+
+// LedgerReconciliation compares the cached account balance with the ledger
+type LedgerReconciliation struct {
+	AccountID      uint
+	CachedBalance  util.Money
+	LedgerBalance  util.Money
+	RunningBalance util.Money
+	PostingCount   int
+	Consistent     bool
+}
+
+type LedgerService interface {
+	// Post writes a balanced journal entry for transaction and applies it to the
+	// customer account balances. It must be called inside the DB transaction tx,
+	// after the accounts were locked; balances are written with a nonce compare-and-swap.
+	Post(tx *gorm.DB, transaction *model.Transaction, lines []LedgerLine) (*model.JournalEntry, error)
+	Reconcile(accountID uint) (*LedgerReconciliation, error)
+}
+
+type ledgerService struct {
+	ledgerRepo  repository.LedgerRepository
+	accountRepo repository.AccountRepository
+}
+
+func NewLedgerService(ledgerRepo repository.LedgerRepository, accountRepo repository.AccountRepository) LedgerService {
+	return &ledgerService{
+		ledgerRepo:  ledgerRepo,
+		accountRepo: accountRepo,
+	}
+}
+
+func (s *ledgerService) Post(tx *gorm.DB, transaction *model.Transaction, lines []LedgerLine) (*model.JournalEntry, error) {
+	if err := validateLedgerLines(lines); err != nil {
+		return nil, err
+	}
+
+	entry := &model.JournalEntry{
+		TransactionID: transaction.ID,
+		Description:   transaction.Description,
+	}
+
+	accountRepo := s.accountRepo.WithTx(tx)
+	for _, line := range lines {
+		posting := model.Posting{
+			LedgerAccount: line.LedgerAccount,
+			Direction:     line.Direction,
+			Amount:        line.Amount,
+		}
+
+		if line.LedgerAccount == model.LedgerAccountCustomer {
+			account := line.Account
+			expectedNonce := account.Nonce
+			if line.Direction == model.PostingDirectionCredit {
+				account.Balance = account.Balance.Add(line.Amount)
+			} else {
+				account.Balance = account.Balance.Sub(line.Amount)
+			}
+			account.Nonce++
+
+			if err := accountRepo.UpdateBalance(account, expectedNonce); err != nil {
+				return nil, err
+			}
+
+			balanceAfter := account.Balance
+			posting.AccountID = &account.ID
+			posting.BalanceAfter = &balanceAfter
+		}
+
+		entry.Postings = append(entry.Postings, posting)
+	}
+
+	if err := s.ledgerRepo.WithTx(tx).CreateEntry(entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (s *ledgerService) Reconcile(accountID uint) (*LedgerReconciliation, error) {
+	account, err := s.accountRepo.FindByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	credits, debits, err := s.ledgerRepo.SumAccountPostings(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	postings, err := s.ledgerRepo.FindPostingsByAccountID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LedgerReconciliation{
+		AccountID:     accountID,
+		CachedBalance: account.Balance,
+		LedgerBalance: credits.Sub(debits),
+		PostingCount:  len(postings),
+	}
+	if len(postings) > 0 && postings[len(postings)-1].BalanceAfter != nil {
+		result.RunningBalance = *postings[len(postings)-1].BalanceAfter
+	}
+
+	result.Consistent = result.CachedBalance.Equal(result.LedgerBalance) &&
+		result.RunningBalance.Equal(result.LedgerBalance)
+
+	return result, nil
+}
+
+// validateLedgerLines checks that every line is well-formed, that the customer accounts
+// share one currency and that debits equal credits
+func validateLedgerLines(lines []LedgerLine) error {
+	if len(lines) < 2 {
+		return ErrUnbalancedEntry
+	}
+
+	var debits, credits util.Money
+	var currency string
+	for _, line := range lines {
+		if !line.Amount.IsPositive() {
+			return ErrInvalidLedgerLine
+		}
+		if line.LedgerAccount == model.LedgerAccountCustomer {
+			if line.Account == nil {
+				return ErrLedgerAccountEmpty
+			}
+			if currency != "" && line.Account.Currency != currency {
+				return ErrMixedCurrencyEntry
+			}
+			currency = line.Account.Currency
+		}
+
+		switch line.Direction {
+		case model.PostingDirectionDebit:
+			debits = debits.Add(line.Amount)
+		case model.PostingDirectionCredit:
+			credits = credits.Add(line.Amount)
+		default:
+			return ErrInvalidLedgerLine
+		}
+	}
+
+	if !debits.Equal(credits) {
+		return ErrUnbalancedEntry
+	}
+	return nil
+}
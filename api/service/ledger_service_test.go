@@ -0,0 +1,122 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+)
+
+func TestValidateLedgerLines(t *testing.T) {
+	usd := &model.Account{ID: 1, Currency: "USD"}
+	otherUSD := &model.Account{ID: 2, Currency: "USD"}
+	eur := &model.Account{ID: 3, Currency: "EUR"}
+
+	customer := func(account *model.Account, direction model.PostingDirection, amount string) LedgerLine {
+		return LedgerLine{LedgerAccount: model.LedgerAccountCustomer, Account: account, Direction: direction, Amount: util.MustMoney(amount)}
+	}
+	cash := func(direction model.PostingDirection, amount string) LedgerLine {
+		return LedgerLine{LedgerAccount: model.LedgerAccountExternalCash, Direction: direction, Amount: util.MustMoney(amount)}
+	}
+	debit, credit := model.PostingDirectionDebit, model.PostingDirectionCredit
+
+	tests := []struct {
+		name  string
+		lines []LedgerLine
+		err   error
+	}{
+		{"deposit", []LedgerLine{cash(debit, "10.00"), customer(usd, credit, "10.00")}, nil},
+		{"transfer", []LedgerLine{customer(usd, debit, "4.25"), customer(otherUSD, credit, "4.25")}, nil},
+		{"split credit", []LedgerLine{customer(usd, debit, "3.00"), customer(otherUSD, credit, "1.00"), cash(credit, "2.00")}, nil},
+		{"single line", []LedgerLine{customer(usd, credit, "10.00")}, ErrUnbalancedEntry},
+		{"no lines", nil, ErrUnbalancedEntry},
+		{"debits exceed credits", []LedgerLine{customer(usd, debit, "10.01"), customer(otherUSD, credit, "10.00")}, ErrUnbalancedEntry},
+		{"same direction", []LedgerLine{customer(usd, credit, "5.00"), cash(credit, "5.00")}, ErrUnbalancedEntry},
+		{"zero amount", []LedgerLine{cash(debit, "0"), customer(usd, credit, "0")}, ErrInvalidLedgerLine},
+		{"negative amount", []LedgerLine{cash(debit, "-5.00"), customer(usd, credit, "-5.00")}, ErrInvalidLedgerLine},
+		{"unknown direction", []LedgerLine{cash("sideways", "5.00"), customer(usd, credit, "5.00")}, ErrInvalidLedgerLine},
+		{"customer line without account", []LedgerLine{cash(debit, "5.00"), customer(nil, credit, "5.00")}, ErrLedgerAccountEmpty},
+		{"mixed currencies", []LedgerLine{customer(usd, debit, "5.00"), customer(eur, credit, "5.00")}, ErrMixedCurrencyEntry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLedgerLines(tt.lines)
+			if tt.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+// 只實作 Reconcile 會用到的方法
+type stubLedgerRepository struct {
+	repository.LedgerRepository
+	credits, debits util.Money
+	postings        []*model.Posting
+}
+
+func (r *stubLedgerRepository) SumAccountPostings(accountID uint) (util.Money, util.Money, error) {
+	return r.credits, r.debits, nil
+}
+
+func (r *stubLedgerRepository) FindPostingsByAccountID(accountID uint) ([]*model.Posting, error) {
+	return r.postings, nil
+}
+
+type stubAccountRepository struct {
+	repository.AccountRepository
+	account *model.Account
+}
+
+func (r *stubAccountRepository) FindByID(id uint) (*model.Account, error) {
+	return r.account, nil
+}
+
+func TestReconcile(t *testing.T) {
+	balanceAfter := func(amount string) *util.Money {
+		m := util.MustMoney(amount)
+		return &m
+	}
+	postings := []*model.Posting{
+		{Direction: model.PostingDirectionCredit, Amount: util.MustMoney("100.00"), BalanceAfter: balanceAfter("100.00")},
+		{Direction: model.PostingDirectionDebit, Amount: util.MustMoney("30.00"), BalanceAfter: balanceAfter("70.00")},
+	}
+
+	tests := []struct {
+		name       string
+		balance    string
+		postings   []*model.Posting
+		running    string
+		consistent bool
+	}{
+		{"balance matches ledger", "70.00", postings, "70.00", true},
+		{"cached balance drifted", "75.00", postings, "70.00", false},
+		{"running balance drifted", "70.00", []*model.Posting{postings[0], {Direction: model.PostingDirectionDebit, Amount: util.MustMoney("30.00"), BalanceAfter: balanceAfter("60.00")}}, "60.00", false},
+		{"no postings", "0", nil, "0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledgerRepo := &stubLedgerRepository{postings: tt.postings}
+			if tt.postings != nil {
+				ledgerRepo.credits, ledgerRepo.debits = util.MustMoney("100.00"), util.MustMoney("30.00")
+			}
+			accountRepo := &stubAccountRepository{account: &model.Account{ID: 1, Balance: util.MustMoney(tt.balance)}}
+
+			result, err := NewLedgerService(ledgerRepo, accountRepo).Reconcile(1)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.consistent, result.Consistent)
+			assert.True(t, util.MustMoney(tt.balance).Equal(result.CachedBalance))
+			assert.True(t, util.MustMoney(tt.running).Equal(result.RunningBalance), result.RunningBalance.String())
+			assert.Equal(t, len(tt.postings), result.PostingCount)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/repository"
+)
+
+// LoginThrottledError is returned when a login is attempted before the delay of earlier
+// failures has passed
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds rounds the wait up, as the Retry-After header only carries whole seconds
+func (e *LoginThrottledError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// LoginProtectionService slows down password guessing. Failures are counted per email and
+// per IP; each failure past the free attempts doubles the delay before the next attempt,
+// and too many failures for one email lock it for a while. Emails without an account are
+// counted the same way, so the behaviour does not reveal which accounts exist.
+type LoginProtectionService interface {
+	// Check returns a *LoginThrottledError while the email or the IP has to wait
+	Check(email, ip string) error
+	IsLocked(email string) (bool, error)
+	// RecordFailure counts a failed login. userID is zero when the email has no account.
+	RecordFailure(email, ip string, userID uint) error
+	// RecordSuccess forgets the failures of the email
+	RecordSuccess(email string) error
+	// Unlock lifts a lockout and forgets the failures of the email
+	Unlock(email string) error
+}
+
+type loginProtectionService struct {
+	attemptRepo  repository.LoginAttemptRepository
+	userNotifier UserNotifier
+	config       config.LoginProtectionConfig
+}
+
+func NewLoginProtectionService(attemptRepo repository.LoginAttemptRepository, userNotifier UserNotifier) LoginProtectionService {
+	return &loginProtectionService{
+		attemptRepo:  attemptRepo,
+		userNotifier: userNotifier,
+		config:       config.GetLoginProtectionConfig(),
+	}
+}
+
+func (s *loginProtectionService) Check(email, ip string) error {
+	wait, err := s.attemptRepo.RetryAfter(email, ip)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+func (s *loginProtectionService) IsLocked(email string) (bool, error) {
+	return s.attemptRepo.IsLocked(email)
+}
+
+func (s *loginProtectionService) RecordFailure(email, ip string, userID uint) error {
+	emailFailures, ipFailures, err := s.attemptRepo.RecordFailure(email, ip, s.config.FailureWindow)
+	if err != nil {
+		return err
+	}
+
+	err = s.attemptRepo.Delay(email, ip,
+		s.delay(emailFailures, s.config.FreeAttempts),
+		s.delay(ipFailures, s.config.IPFreeAttempts),
+	)
+	if err != nil {
+		return err
+	}
+
+	if emailFailures < int64(s.config.LockoutThreshold) {
+		return nil
+	}
+	locked, err := s.attemptRepo.Lock(email, s.config.LockoutDuration)
+	if err != nil {
+		return err
+	}
+	// Only the failure that locks the account notifies, not every attempt made while locked
+	if locked && userID != 0 {
+		log.Printf("Login for user %d locked after %d failed attempts", userID, emailFailures)
+		s.userNotifier.NotifyUser(userID, TemplateAccountLocked, map[string]string{
+			"Time":    time.Now().Format(notificationTimeLayout),
+			"IP":      ip,
+			"Minutes": strconv.Itoa(int(s.config.LockoutDuration.Minutes())),
+		})
+	}
+	return nil
+}
+
+func (s *loginProtectionService) RecordSuccess(email string) error {
+	return s.attemptRepo.Clear(email)
+}
+
+func (s *loginProtectionService) Unlock(email string) error {
+	return s.attemptRepo.Clear(email)
+}
+
+// delay returns the wait before the next attempt after the given number of failures:
+// nothing for the free attempts, then the base delay doubling up to the maximum
+func (s *loginProtectionService) delay(failures int64, freeAttempts int) time.Duration {
+	excess := failures - int64(freeAttempts)
+	if excess <= 0 {
+		return 0
+	}
+	// Cap the exponent before shifting so the delay cannot overflow
+	if excess > 20 {
+		return s.config.MaxDelay
+	}
+	delay := s.config.BaseDelay << (excess - 1)
+	if delay > s.config.MaxDelay {
+		return s.config.MaxDelay
+	}
+	return delay
+}
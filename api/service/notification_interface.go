@@ -1,17 +1,78 @@
 package service
 
-// NotificationSender defines the interface for different notification strategies
+import (
+	"errors"
+	"net/http"
+
+	"go-gin-template/api/model"
+)
+
+// NotificationSender defines the interface for different notification strategies.
+// Send renders and delivers one message synchronously; retries and concurrency are
+// handled by the NotificationService, so senders must not start goroutines of their own.
+// Failures that retrying cannot fix should be wrapped with Permanent.
 type NotificationSender interface {
-	Send(to, code string) error
+	Send(message *Message) error
 	GetType() string
 }
 
+// DeliveryReceipt is a delivery status reported by a provider after the message was accepted
+type DeliveryReceipt struct {
+	// Reference is the outbox message ID that was passed to the provider
+	Reference uint
+	Status    string
+	Error     string
+}
+
+// DeliveryReceiptParser is implemented by senders whose provider reports the delivery
+// status through a callback. It must authenticate the request.
+type DeliveryReceiptParser interface {
+	ParseDeliveryReceipt(r *http.Request) (*DeliveryReceipt, error)
+}
+
+// PermanentError marks a delivery failure that retrying cannot fix, e.g. an invalid
+// recipient. The outbox dead-letters such messages without further attempts.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err as a PermanentError
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err is a delivery failure that must not be retried
+func IsPermanent(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
 // NotificationService manages different notification strategies
 type NotificationService interface {
-	SendVerificationCode(notificationType, to, code string) error
+	// Notify queues the message in the outbox for delivery over the given channel
+	Notify(notificationType string, message *Message) (*model.NotificationMessage, error)
 	GetSender(notificationType string) (NotificationSender, error)
 	RegisterSender(sender NotificationSender)
 	GetAvailableTypes() []string
-	// WaitForCompletion waits for all asynchronous notification operations to complete
+	// Start launches the worker pool that delivers queued messages
+	Start()
+	// WaitForCompletion stops the workers once the messages in flight were handled
 	WaitForCompletion()
-}
\ No newline at end of file
+
+	GetMessage(id uint) (*model.NotificationMessage, error)
+	ListMessages(status model.NotificationStatus, limit, offset int) ([]*model.NotificationMessage, int64, error)
+	// ReplayMessage queues a dead message for delivery again, unless it held a one-time code or link
+	ReplayMessage(id uint) (*model.NotificationMessage, error)
+	// RecordDeliveryReceipt stores the delivery status a provider reported for a sent message
+	RecordDeliveryReceipt(receipt *DeliveryReceipt) error
+	// Inbox returns the captured messages in development, or nil when notifications are really sent
+	Inbox() Inbox
+}
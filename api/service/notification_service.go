@@ -1,53 +1,138 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+)
+
+const (
+	// notificationLease is how long a worker owns a claimed message before another may retry it
+	notificationLease = 2 * time.Minute
+	// notificationBaseBackoff doubles with every failed attempt up to notificationMaxBackoff
+	notificationBaseBackoff = 5 * time.Second
+	notificationMaxBackoff  = time.Hour
+	// notificationMaxAttempts is the number of attempts before a message is dead-lettered
+	notificationMaxAttempts = 8
 )
 
-// notificationService implements NotificationService
+// notificationService implements NotificationService on top of a persisted outbox
 type notificationService struct {
+	mu      sync.RWMutex
 	senders map[string]NotificationSender
+
+	notificationRepo repository.NotificationRepository
+	config           config.NotificationConfig
+	// inbox holds captured messages; nil unless capture mode is on
+	inbox Inbox
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	wg        sync.WaitGroup
 }
 
-func NewNotificationService() NotificationService {
+func NewNotificationService(notificationRepo repository.NotificationRepository) NotificationService {
 	service := &notificationService{
-		senders: make(map[string]NotificationSender),
+		senders:          make(map[string]NotificationSender),
+		notificationRepo: notificationRepo,
+		config:           config.GetNotificationConfig(),
+		stop:             make(chan struct{}),
 	}
-	
+
+	templates := MustTemplateRegistry()
+
 	// Register email sender
-	emailSender := NewEmailSender()
+	emailSender := NewEmailSender(templates)
 	service.senders[emailSender.GetType()] = emailSender
-	
-	// Register SMS sender
-	smsSender := NewSMSSender()
+
+	// Register SMS sender; without a provider the messages are only logged
+	smsSender := NewSMSSender(templates)
+	if smsConfig := config.GetSMSConfig(); smsConfig.ProviderURL != "" {
+		smsSender = NewHTTPSMSSender(smsConfig, templates)
+	}
 	service.senders[smsSender.GetType()] = smsSender
-	
+
+	// Outside release mode, email and SMS can be captured into an inbox instead of sent
+	if captureConfig := config.GetCaptureConfig(); captureConfig.Enabled() {
+		inbox := NewMemoryInbox()
+		if captureConfig.Mode == config.CaptureModeSpool {
+			spool, err := NewSpoolInbox(captureConfig.SpoolDir)
+			if err != nil {
+				log.Fatalf("Failed to create mail capture spool: %v", err)
+			}
+			inbox = spool
+		}
+
+		service.inbox = inbox
+		for _, channel := range []string{emailSender.GetType(), smsSender.GetType()} {
+			service.senders[channel] = NewCaptureSender(channel, templates, inbox)
+		}
+		log.Printf("Capturing outgoing notifications (%s mode)", captureConfig.Mode)
+	}
+
 	return service
 }
 
-func (n *notificationService) SendVerificationCode(notificationType, to, code string) error {
-	sender, err := n.GetSender(notificationType)
+func (n *notificationService) Notify(notificationType string, message *Message) (*model.NotificationMessage, error) {
+	// Reject unknown channels right away instead of dead-lettering them later
+	if _, err := n.GetSender(notificationType); err != nil {
+		return nil, err
+	}
+	if message.Locale == "" {
+		message.Locale = DefaultLocale
+	}
+
+	payload, err := json.Marshal(message)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	
-	return sender.Send(to, code)
+
+	outboxMessage := &model.NotificationMessage{
+		Channel:     notificationType,
+		Recipient:   message.To,
+		Payload:     string(payload),
+		Replayable:  !oneTimeTemplates[message.TemplateID],
+		MaxAttempts: notificationMaxAttempts,
+	}
+	if err := n.notificationRepo.Create(outboxMessage); err != nil {
+		return nil, err
+	}
+
+	return outboxMessage, nil
 }
 
 func (n *notificationService) GetSender(notificationType string) (NotificationSender, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
 	sender, exists := n.senders[notificationType]
 	if !exists {
 		return nil, fmt.Errorf("notification type '%s' is not supported", notificationType)
 	}
-	
+
 	return sender, nil
 }
 
 func (n *notificationService) RegisterSender(sender NotificationSender) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
 	n.senders[sender.GetType()] = sender
 }
 
 func (n *notificationService) GetAvailableTypes() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
 	types := make([]string, 0, len(n.senders))
 	for senderType := range n.senders {
 		types = append(types, senderType)
@@ -55,16 +140,141 @@ func (n *notificationService) GetAvailableTypes() []string {
 	return types
 }
 
-// WaitForCompletion waits for all asynchronous notification operations to complete
+func (n *notificationService) Start() {
+	n.startOnce.Do(func() {
+		jobs := make(chan *model.NotificationMessage)
+
+		for i := 0; i < n.config.Workers; i++ {
+			n.wg.Add(1)
+			go n.work(jobs)
+		}
+
+		n.wg.Add(1)
+		go n.poll(jobs)
+
+		log.Printf("Started %d notification workers", n.config.Workers)
+	})
+}
+
+// WaitForCompletion stops polling the outbox and waits for the workers to finish the
+// messages they hold. Messages that are still queued are delivered after the next start.
 func (n *notificationService) WaitForCompletion() {
-	// Wait for email sender to complete all operations
-	if emailSender, ok := n.senders["email"].(*EmailSender); ok {
-		emailSender.WaitForCompletion()
-	}
-	
-	// If there are other asynchronous senders, wait for them here
-	// For example, if SMS sender also uses goroutines:
-	// if smsSender, ok := n.senders["sms"].(*SMSSender); ok {
-	//     smsSender.WaitForCompletion()
-	// }
-}
\ No newline at end of file
+	n.stopOnce.Do(func() {
+		close(n.stop)
+	})
+	n.wg.Wait()
+}
+
+func (n *notificationService) GetMessage(id uint) (*model.NotificationMessage, error) {
+	return n.notificationRepo.FindByID(id)
+}
+
+func (n *notificationService) ListMessages(status model.NotificationStatus, limit, offset int) ([]*model.NotificationMessage, int64, error) {
+	return n.notificationRepo.FindByStatus(status, limit, offset)
+}
+
+func (n *notificationService) ReplayMessage(id uint) (*model.NotificationMessage, error) {
+	if err := n.notificationRepo.Replay(id); err != nil {
+		return nil, err
+	}
+	return n.notificationRepo.FindByID(id)
+}
+
+func (n *notificationService) Inbox() Inbox {
+	return n.inbox
+}
+
+func (n *notificationService) RecordDeliveryReceipt(receipt *DeliveryReceipt) error {
+	return n.notificationRepo.UpdateProviderStatus(receipt.Reference, receipt.Status, receipt.Error)
+}
+
+// poll claims due messages and hands them to the workers until the service is stopped
+func (n *notificationService) poll(jobs chan<- *model.NotificationMessage) {
+	defer n.wg.Done()
+	defer close(jobs)
+
+	ticker := time.NewTicker(n.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+		}
+
+		messages, err := n.notificationRepo.ClaimDue(n.config.Workers, notificationLease)
+		if err != nil {
+			log.Printf("Failed to claim notifications: %v", err)
+			continue
+		}
+
+		for _, message := range messages {
+			select {
+			case jobs <- message:
+			case <-n.stop:
+				// Unhandled messages are picked up again once their lease expires
+				return
+			}
+		}
+	}
+}
+
+func (n *notificationService) work(jobs <-chan *model.NotificationMessage) {
+	defer n.wg.Done()
+
+	for message := range jobs {
+		n.deliver(message)
+	}
+}
+
+// deliver makes one delivery attempt and records its outcome in the outbox
+func (n *notificationService) deliver(message *model.NotificationMessage) {
+	sender, err := n.GetSender(message.Channel)
+	if err == nil {
+		var payload Message
+		if err = json.Unmarshal([]byte(message.Payload), &payload); err == nil {
+			payload.To = message.Recipient
+			payload.Reference = strconv.FormatUint(uint64(message.ID), 10)
+			err = sender.Send(&payload)
+		}
+	}
+
+	if err == nil {
+		if err := n.notificationRepo.MarkSent(message.ID, message.LeaseOwner); err != nil {
+			log.Printf("Failed to mark notification %d as sent: %v", message.ID, err)
+		}
+		return
+	}
+
+	if IsPermanent(err) || message.Attempts >= message.MaxAttempts {
+		log.Printf("Notification %d failed permanently after %d attempts: %v", message.ID, message.Attempts, err)
+		if err := n.notificationRepo.MarkDead(message.ID, message.LeaseOwner, err.Error()); err != nil {
+			log.Printf("Failed to dead-letter notification %d: %v", message.ID, err)
+		}
+		return
+	}
+
+	next := time.Now().Add(notificationBackoff(message.Attempts))
+	log.Printf("Notification %d failed (attempt %d/%d), retrying at %s: %v",
+		message.ID, message.Attempts, message.MaxAttempts, next.Format(time.RFC3339), err)
+	if err := n.notificationRepo.MarkFailed(message.ID, message.LeaseOwner, err.Error(), next); err != nil {
+		log.Printf("Failed to reschedule notification %d: %v", message.ID, err)
+	}
+}
+
+// notificationBackoff returns the delay before the next attempt: exponential with jitter,
+// so messages that failed together do not all retry at the same moment
+func notificationBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := notificationMaxBackoff
+	if attempts < 20 {
+		delay = notificationBaseBackoff << (attempts - 1)
+	}
+	if delay > notificationMaxBackoff || delay <= 0 {
+		delay = notificationMaxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+)
+
+// 記錄投遞結果的 outbox，只實作 deliver 會用到的方法
+type recordingNotificationRepository struct {
+	repository.NotificationRepository
+	outcome       model.NotificationStatus
+	owner         string
+	lastError     string
+	nextAttemptAt time.Time
+}
+
+func (r *recordingNotificationRepository) MarkSent(id uint, owner string) error {
+	r.outcome, r.owner = model.NotificationStatusSent, owner
+	return nil
+}
+
+func (r *recordingNotificationRepository) MarkFailed(id uint, owner, lastError string, nextAttemptAt time.Time) error {
+	r.outcome, r.owner, r.lastError, r.nextAttemptAt = model.NotificationStatusPending, owner, lastError, nextAttemptAt
+	return nil
+}
+
+func (r *recordingNotificationRepository) MarkDead(id uint, owner, lastError string) error {
+	r.outcome, r.owner, r.lastError = model.NotificationStatusDead, owner, lastError
+	return nil
+}
+
+// 回傳固定結果的 sender
+type stubSender struct {
+	err  error
+	sent []*Message
+}
+
+func (s *stubSender) Send(message *Message) error {
+	s.sent = append(s.sent, message)
+	return s.err
+}
+
+func (s *stubSender) GetType() string {
+	return "email"
+}
+
+func newTestNotificationService(sender NotificationSender) (*notificationService, *recordingNotificationRepository) {
+	repo := &recordingNotificationRepository{}
+	return &notificationService{
+		senders:          map[string]NotificationSender{sender.GetType(): sender},
+		notificationRepo: repo,
+	}, repo
+}
+
+func claimedMessage(attempts int) *model.NotificationMessage {
+	return &model.NotificationMessage{
+		ID:          7,
+		Channel:     "email",
+		Recipient:   "user@example.com",
+		Payload:     `{"template_id":"login_alert","locale":"en","variables":{"IP":"127.0.0.1"}}`,
+		Status:      model.NotificationStatusProcessing,
+		Attempts:    attempts,
+		MaxAttempts: notificationMaxAttempts,
+		LeaseOwner:  "lease-1",
+	}
+}
+
+func TestDeliverOutcome(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+		want     model.NotificationStatus
+	}{
+		{"送達後標記為已送出", nil, 1, model.NotificationStatusSent},
+		{"暫時性失敗排入重試", errors.New("connection refused"), 1, model.NotificationStatusPending},
+		{"最後一次嘗試仍失敗即放棄", errors.New("connection refused"), notificationMaxAttempts, model.NotificationStatusDead},
+		{"永久性失敗不再重試", Permanent(errors.New("invalid recipient")), 1, model.NotificationStatusDead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &stubSender{err: tt.err}
+			service, repo := newTestNotificationService(sender)
+
+			service.deliver(claimedMessage(tt.attempts))
+
+			require.Len(t, sender.sent, 1)
+			assert.Equal(t, "user@example.com", sender.sent[0].To)
+			assert.Equal(t, "7", sender.sent[0].Reference)
+			assert.Equal(t, tt.want, repo.outcome)
+			// 結果只能由持有租約的 worker 記錄
+			assert.Equal(t, "lease-1", repo.owner)
+			if tt.err != nil {
+				assert.Equal(t, tt.err.Error(), repo.lastError)
+			}
+		})
+	}
+}
+
+func TestDeliverSchedulesRetryWithBackoff(t *testing.T) {
+	service, repo := newTestNotificationService(&stubSender{err: errors.New("timeout")})
+
+	before := time.Now()
+	service.deliver(claimedMessage(3))
+
+	// 第三次失敗後等待 base * 2^2 的一半到全部
+	require.Equal(t, model.NotificationStatusPending, repo.outcome)
+	delay := notificationBaseBackoff << 2
+	assert.False(t, repo.nextAttemptAt.Before(before.Add(delay/2)), repo.nextAttemptAt)
+	assert.False(t, repo.nextAttemptAt.After(time.Now().Add(delay)), repo.nextAttemptAt)
+}
+
+func TestNotificationBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		max      time.Duration
+	}{
+		{"第一次失敗", 1, notificationBaseBackoff},
+		{"未嘗試視為第一次", 0, notificationBaseBackoff},
+		{"每次失敗加倍", 4, notificationBaseBackoff << 3},
+		{"不超過上限", 15, notificationMaxBackoff},
+		{"次數極大時不溢位", 100, notificationMaxBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				delay := notificationBackoff(tt.attempts)
+				assert.GreaterOrEqual(t, delay, tt.max/2)
+				assert.LessOrEqual(t, delay, tt.max)
+			}
+		})
+	}
+}
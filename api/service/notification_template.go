@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template IDs of the notifications the application sends
+const (
+	TemplateVerificationCode    = "verification_code"
+	TemplateTransferReceipt     = "transfer_receipt"
+	TemplateLoginAlert          = "login_alert"
+	TemplateLowBalance          = "low_balance"
+	TemplatePasswordReset       = "password_reset"
+	TemplatePasswordChanged     = "password_changed"
+	TemplateAccountLocked       = "account_locked"
+	TemplateEmailVerification   = "email_verification"
+	TemplateContactVerification = "contact_verification"
+)
+
+// oneTimeTemplates carry codes or links that expire within minutes. Their messages are
+// not kept for replay; the user asks for a new code or link instead.
+var oneTimeTemplates = map[string]bool{
+	TemplateVerificationCode:    true,
+	TemplatePasswordReset:       true,
+	TemplateEmailVerification:   true,
+	TemplateContactVerification: true,
+}
+
+// DefaultLocale is used when a user has no locale or a template is not translated
+const DefaultLocale = "en"
+
+// Message is a notification to deliver: a template plus the variables to render it with.
+// Senders render it for their channel, so the same message works for email and SMS.
+type Message struct {
+	To         string            `json:"to"`
+	TemplateID string            `json:"template_id"`
+	Locale     string            `json:"locale"`
+	Variables  map[string]string `json:"variables"`
+	// Reference identifies the outbox message, so providers can report delivery receipts for it
+	Reference string `json:"-"`
+}
+
+// RenderedMessage is the content of a message in one locale
+type RenderedMessage struct {
+	Subject string
+	Text    string
+	HTML    string
+	// SMS is a short form of Text for channels with a length limit
+	SMS string
+}
+
+// TemplateRegistry renders notification templates keyed by template ID and locale
+type TemplateRegistry interface {
+	Render(templateID, locale string, variables map[string]string) (*RenderedMessage, error)
+	Locales() []string
+}
+
+//go:embed templates
+var templateFiles embed.FS
+
+type localizedTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+type templateRegistry struct {
+	// templates maps locale, then template ID, to the parsed template
+	templates map[string]map[string]*localizedTemplate
+}
+
+// NewTemplateRegistry loads the templates embedded under templates/<locale>/<template_id>.tmpl.
+// Each file defines the blocks "subject", "text", "html" and "sms"; the html block is
+// parsed with html/template so variables are escaped.
+func NewTemplateRegistry() (TemplateRegistry, error) {
+	registry := &templateRegistry{templates: make(map[string]map[string]*localizedTemplate)}
+
+	err := fs.WalkDir(templateFiles, "templates", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(filePath) != ".tmpl" {
+			return err
+		}
+
+		locale := path.Base(path.Dir(filePath))
+		templateID := strings.TrimSuffix(path.Base(filePath), ".tmpl")
+
+		source, err := templateFiles.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		text, err := texttemplate.New(templateID).Option("missingkey=error").Parse(string(source))
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", filePath, err)
+		}
+		html, err := htmltemplate.New(templateID).Option("missingkey=error").Parse(string(source))
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", filePath, err)
+		}
+
+		if registry.templates[locale] == nil {
+			registry.templates[locale] = make(map[string]*localizedTemplate)
+		}
+		registry.templates[locale][templateID] = &localizedTemplate{text: text, html: html}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// MustTemplateRegistry is like NewTemplateRegistry but panics if the embedded templates are invalid
+func MustTemplateRegistry() TemplateRegistry {
+	registry, err := NewTemplateRegistry()
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+func (r *templateRegistry) Render(templateID, locale string, variables map[string]string) (*RenderedMessage, error) {
+	tmpl := r.lookup(templateID, locale)
+	if tmpl == nil {
+		return nil, fmt.Errorf("notification template '%s' not found", templateID)
+	}
+
+	rendered := &RenderedMessage{}
+	for name, target := range map[string]*string{"subject": &rendered.Subject, "text": &rendered.Text, "sms": &rendered.SMS} {
+		var buf bytes.Buffer
+		if err := tmpl.text.ExecuteTemplate(&buf, name, variables); err != nil {
+			return nil, err
+		}
+		*target = strings.TrimSpace(buf.String())
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.html.ExecuteTemplate(&buf, "html", variables); err != nil {
+		return nil, err
+	}
+	rendered.HTML = strings.TrimSpace(buf.String())
+
+	return rendered, nil
+}
+
+func (r *templateRegistry) Locales() []string {
+	locales := make([]string, 0, len(r.templates))
+	for locale := range r.templates {
+		locales = append(locales, locale)
+	}
+	return locales
+}
+
+// lookup falls back from the requested locale to its language (zh-TW to zh) and then to DefaultLocale
+func (r *templateRegistry) lookup(templateID, locale string) *localizedTemplate {
+	candidates := []string{locale}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, DefaultLocale)
+
+	for _, candidate := range candidates {
+		if tmpl, ok := r.templates[candidate][templateID]; ok {
+			return tmpl
+		}
+	}
+	return nil
+}
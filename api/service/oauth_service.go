@@ -0,0 +1,685 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+const (
+	oauthClientIDBytes     = 12
+	oauthClientSecretBytes = 32
+	oauthCodeBytes         = 32
+)
+
+// Error codes of RFC 6749 section 5.2 and 4.1.2.1
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+)
+
+var (
+	ErrPublicClientRedirectURI = errors.New("public clients must register at least one redirect URI")
+	ErrRedirectURIFragment     = errors.New("redirect URIs must not contain a fragment")
+)
+
+// OAuthError is an error answered in the format of RFC 6749, with the code the client
+// acts on and a description for its developers
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthClientCredentials identifies the client making a request. Public clients only send
+// the client ID.
+type OAuthClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// OAuthService is the authorization server for third-party apps. Apps get access tokens
+// limited to the scopes the user consented to; the tokens are JWTs signed like the tokens
+// of first-party logins, with the client and scope in their claims.
+type OAuthService interface {
+	// RegisterClient registers an app owned by the user. The secret of a confidential
+	// client is only returned here.
+	RegisterClient(ownerID uint, req *dto.OAuthClientRequest) (*dto.OAuthClientResponse, error)
+	ListClients(ownerID uint) ([]dto.OAuthClientResponse, error)
+	// DeleteClient deletes an app of the user and revokes every token issued to it
+	DeleteClient(ownerID uint, clientID string) error
+	// Consent validates an authorization request and describes it for the consent screen
+	Consent(userID uint, req *dto.OAuthAuthorizeRequest) (*dto.OAuthConsentResponse, error)
+	// Authorize records the decision of the user and returns where to redirect them: with
+	// an authorization code when they approved, with access_denied otherwise
+	Authorize(userID uint, req *dto.OAuthDecisionRequest) (*dto.OAuthDecisionResponse, error)
+	// Token runs the authorization_code, refresh_token and client_credentials grants
+	Token(credentials OAuthClientCredentials, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error)
+	// Introspect describes a token issued to the calling confidential client
+	Introspect(credentials OAuthClientCredentials, req *dto.OAuthTokenActionRequest) (*dto.OAuthIntrospectionResponse, error)
+	// Revoke revokes a token issued to the calling client. Unknown tokens are ignored.
+	Revoke(credentials OAuthClientCredentials, req *dto.OAuthTokenActionRequest) error
+}
+
+type oauthService struct {
+	clientRepo      repository.OAuthClientRepository
+	tokenRepo       repository.OAuthTokenRepository
+	userRepo        repository.UserRepository
+	revocationRepo  repository.TokenRevocationRepository
+	accessTokenTTL  time.Duration
+	codeTTL         time.Duration
+	refreshTokenTTL time.Duration
+}
+
+func NewOAuthService(clientRepo repository.OAuthClientRepository, tokenRepo repository.OAuthTokenRepository, userRepo repository.UserRepository, revocationRepo repository.TokenRevocationRepository, accessTokenTTL time.Duration) OAuthService {
+	oauthConfig := config.GetOAuthConfig()
+	return &oauthService{
+		clientRepo:      clientRepo,
+		tokenRepo:       tokenRepo,
+		userRepo:        userRepo,
+		revocationRepo:  revocationRepo,
+		accessTokenTTL:  accessTokenTTL,
+		codeTTL:         oauthConfig.CodeTTL,
+		refreshTokenTTL: oauthConfig.RefreshTokenTTL,
+	}
+}
+
+func (s *oauthService) RegisterClient(ownerID uint, req *dto.OAuthClientRequest) (*dto.OAuthClientResponse, error) {
+	clientType := model.OAuthClientType(req.Type)
+	if clientType == model.OAuthClientPublic && len(req.RedirectURIs) == 0 {
+		return nil, ErrPublicClientRedirectURI
+	}
+	for _, redirectURI := range req.RedirectURIs {
+		if strings.Contains(redirectURI, "#") {
+			return nil, ErrRedirectURIFragment
+		}
+	}
+
+	clientID, err := util.GenerateOpaqueToken(oauthClientIDBytes)
+	if err != nil {
+		return nil, err
+	}
+	client := &model.OAuthClient{
+		ClientID:     clientID,
+		Name:         req.Name,
+		Type:         clientType,
+		RedirectURIs: strings.Join(req.RedirectURIs, " "),
+		Scopes:       strings.Join(uniqueScopes(req.Scopes), " "),
+		OwnerID:      ownerID,
+	}
+
+	var secret string
+	if clientType == model.OAuthClientConfidential {
+		secret, err = util.GenerateOpaqueToken(oauthClientSecretBytes)
+		if err != nil {
+			return nil, err
+		}
+		client.SecretHash = util.HashToken(secret)
+	}
+
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, err
+	}
+
+	response := toOAuthClientResponse(client)
+	response.ClientSecret = secret
+	return &response, nil
+}
+
+func (s *oauthService) ListClients(ownerID uint) ([]dto.OAuthClientResponse, error) {
+	clients, err := s.clientRepo.FindByOwnerID(ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.OAuthClientResponse, 0, len(clients))
+	for _, client := range clients {
+		responses = append(responses, toOAuthClientResponse(client))
+	}
+	return responses, nil
+}
+
+func (s *oauthService) DeleteClient(ownerID uint, clientID string) error {
+	client, err := s.clientRepo.FindByClientID(clientID)
+	if err != nil {
+		return err
+	}
+	// Apps of other users are reported as missing rather than forbidden
+	if client.OwnerID != ownerID {
+		return gorm.ErrRecordNotFound
+	}
+
+	if err := s.clientRepo.Delete(client.ID); err != nil {
+		return err
+	}
+	// Refresh tokens went with the client; access tokens stay valid until they expire unless blocked
+	return s.revocationRepo.RevokeClient(client.ClientID, s.accessTokenTTL)
+}
+
+func (s *oauthService) Consent(userID uint, req *dto.OAuthAuthorizeRequest) (*dto.OAuthConsentResponse, error) {
+	client, redirectURI, scope, err := s.checkAuthorizeRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := map[string]bool{}
+	consent, err := s.clientRepo.FindConsent(userID, client.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if consent != nil {
+		for _, name := range strings.Fields(consent.Scope) {
+			granted[name] = true
+		}
+	}
+
+	response := &dto.OAuthConsentResponse{
+		ClientID:    client.ClientID,
+		ClientName:  client.Name,
+		RedirectURI: redirectURI,
+	}
+	for _, name := range strings.Fields(scope) {
+		response.Scopes = append(response.Scopes, dto.OAuthScopeResponse{
+			Name:        name,
+			Description: model.OAuthScopes[name],
+			Granted:     granted[name],
+		})
+		if !granted[name] {
+			response.ConsentRequired = true
+		}
+	}
+	return response, nil
+}
+
+func (s *oauthService) Authorize(userID uint, req *dto.OAuthDecisionRequest) (*dto.OAuthDecisionResponse, error) {
+	client, redirectURI, scope, err := s.checkAuthorizeRequest(&req.OAuthAuthorizeRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Approve {
+		return redirectWith(redirectURI, map[string]string{
+			"error":             OAuthAccessDenied,
+			"error_description": "The user declined the request",
+			"state":             req.State,
+		})
+	}
+
+	if err := s.grantConsent(userID, client.ID, scope); err != nil {
+		return nil, err
+	}
+
+	code, err := util.GenerateOpaqueToken(oauthCodeBytes)
+	if err != nil {
+		return nil, err
+	}
+	err = s.tokenRepo.CreateCode(&model.OAuthAuthorizationCode{
+		CodeHash:      util.HashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   redirectURI,
+		Scope:         scope,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.codeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return redirectWith(redirectURI, map[string]string{"code": code, "state": req.State})
+}
+
+func (s *oauthService) Token(credentials OAuthClientCredentials, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		return s.exchangeCode(client, req)
+	case "refresh_token":
+		return s.refresh(client, req)
+	case "client_credentials":
+		return s.clientCredentials(client, req)
+	}
+	return nil, oauthError(OAuthUnsupportedGrantType, "grant_type must be authorization_code, refresh_token or client_credentials")
+}
+
+func (s *oauthService) Introspect(credentials OAuthClientCredentials, req *dto.OAuthTokenActionRequest) (*dto.OAuthIntrospectionResponse, error) {
+	client, err := s.authenticateClient(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if client.Type != model.OAuthClientConfidential {
+		return nil, oauthError(OAuthUnauthorizedClient, "only confidential clients can introspect tokens")
+	}
+
+	inactive := &dto.OAuthIntrospectionResponse{Active: false}
+
+	if req.TokenTypeHint != "refresh_token" {
+		claims, ok, err := s.clientAccessToken(client, req.Token)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &dto.OAuthIntrospectionResponse{
+				Active:    true,
+				Scope:     claims.Scope,
+				ClientID:  claims.ClientID,
+				Subject:   strconv.FormatUint(uint64(claims.UserID), 10),
+				TokenType: "access_token",
+				ExpiresAt: claims.ExpiresAt.Unix(),
+				IssuedAt:  claims.IssuedAt.Unix(),
+			}, nil
+		}
+	}
+
+	token, err := s.tokenRepo.FindRefreshTokenByHash(util.HashToken(req.Token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return inactive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.ClientID != client.ID || token.RevokedAt != nil || token.RotatedAt != nil || time.Now().After(token.ExpiresAt) {
+		return inactive, nil
+	}
+	return &dto.OAuthIntrospectionResponse{
+		Active:    true,
+		Scope:     token.Scope,
+		ClientID:  client.ClientID,
+		Subject:   strconv.FormatUint(uint64(token.UserID), 10),
+		TokenType: "refresh_token",
+		ExpiresAt: token.ExpiresAt.Unix(),
+		IssuedAt:  token.CreatedAt.Unix(),
+	}, nil
+}
+
+func (s *oauthService) Revoke(credentials OAuthClientCredentials, req *dto.OAuthTokenActionRequest) error {
+	client, err := s.authenticateClient(credentials)
+	if err != nil {
+		return err
+	}
+
+	if req.TokenTypeHint != "refresh_token" {
+		claims, ok, err := s.clientAccessToken(client, req.Token)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return s.revocationRepo.RevokeToken(claims.ID, time.Until(claims.ExpiresAt.Time))
+		}
+	}
+
+	token, err := s.tokenRepo.FindRefreshTokenByHash(util.HashToken(req.Token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if token.ClientID != client.ID {
+		return nil
+	}
+	// Revoking a refresh token ends the grant, including the access tokens issued with it
+	return s.revokeFamily(s.tokenRepo, token.FamilyID)
+}
+
+// checkAuthorizeRequest validates an authorization request and resolves its redirect URI
+// and scope
+func (s *oauthService) checkAuthorizeRequest(req *dto.OAuthAuthorizeRequest) (*model.OAuthClient, string, string, error) {
+	client, err := s.clientRepo.FindByClientID(req.ClientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", "", oauthError(OAuthInvalidClient, "unknown client")
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	// The redirect URI is checked first: until it is trusted, errors must not be sent there
+	redirectURIs := client.RedirectURIList()
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(redirectURIs) == 1 {
+		redirectURI = redirectURIs[0]
+	}
+	if redirectURI == "" || !containsString(redirectURIs, redirectURI) {
+		return nil, "", "", oauthError(OAuthInvalidRequest, "redirect_uri is not registered for this client")
+	}
+
+	if req.ResponseType != "code" {
+		return nil, "", "", oauthError(OAuthUnsupportedResponseType, "response_type must be code")
+	}
+	// PKCE is required of every client, so an intercepted code is useless on its own
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		return nil, "", "", oauthError(OAuthInvalidRequest, "a S256 code_challenge is required")
+	}
+
+	scope, err := resolveScope(req.Scope, client.ScopeList())
+	if err != nil {
+		return nil, "", "", err
+	}
+	return client, redirectURI, scope, nil
+}
+
+// grantConsent adds the scope to what the user granted the client before
+func (s *oauthService) grantConsent(userID, clientID uint, scope string) error {
+	scopes := strings.Fields(scope)
+	consent, err := s.clientRepo.FindConsent(userID, clientID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if consent != nil {
+		scopes = append(strings.Fields(consent.Scope), scopes...)
+	}
+
+	return s.clientRepo.SaveConsent(&model.OAuthConsent{
+		UserID:   userID,
+		ClientID: clientID,
+		Scope:    strings.Join(uniqueScopes(scopes), " "),
+	})
+}
+
+func (s *oauthService) exchangeCode(client *model.OAuthClient, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, oauthError(OAuthInvalidRequest, "code and code_verifier are required")
+	}
+
+	var code *model.OAuthAuthorizationCode
+	var familyID, refreshToken string
+	// replayed is reported after the transaction commits, so that the revocation is persisted
+	var replayed bool
+
+	err := s.tokenRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		tokenRepo := s.tokenRepo.WithTx(tx)
+
+		// Lock the code so two concurrent exchanges cannot both use it
+		var err error
+		code, err = tokenRepo.FindCodeByHashForUpdate(util.HashToken(req.Code))
+		if err != nil || code.ClientID != client.ID {
+			return oauthError(OAuthInvalidGrant, "invalid authorization code")
+		}
+
+		if code.UsedAt != nil {
+			// A code works once; a second use means it leaked, so the tokens it gave are withdrawn
+			replayed = true
+			return tokenRepo.RevokeFamily(code.FamilyID)
+		}
+		if time.Now().After(code.ExpiresAt) {
+			return oauthError(OAuthInvalidGrant, "authorization code expired")
+		}
+		if req.RedirectURI != "" && req.RedirectURI != code.RedirectURI {
+			return oauthError(OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+		}
+		if !util.VerifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+			return oauthError(OAuthInvalidGrant, "code_verifier does not match the code_challenge")
+		}
+
+		familyID, err = util.GenerateOpaqueToken(16)
+		if err != nil {
+			return err
+		}
+		if err := tokenRepo.MarkCodeUsed(code.ID, familyID); err != nil {
+			return err
+		}
+
+		refreshToken, err = s.createRefreshToken(tokenRepo, client.ID, code.UserID, familyID, code.Scope)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		log.Printf("Authorization code replay detected for client %s, revoking grant %s", client.ClientID, code.FamilyID)
+		if code.FamilyID != "" {
+			if err := s.revocationRepo.RevokeSession(code.FamilyID, s.accessTokenTTL); err != nil {
+				return nil, err
+			}
+		}
+		return nil, oauthError(OAuthInvalidGrant, "authorization code was already used")
+	}
+
+	return s.issueTokens(client, code.UserID, code.Scope, familyID, refreshToken)
+}
+
+func (s *oauthService) refresh(client *model.OAuthClient, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, oauthError(OAuthInvalidRequest, "refresh_token is required")
+	}
+
+	var current *model.OAuthRefreshToken
+	var next string
+	var reused bool
+
+	err := s.tokenRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		tokenRepo := s.tokenRepo.WithTx(tx)
+
+		var err error
+		current, err = tokenRepo.FindRefreshTokenByHashForUpdate(util.HashToken(req.RefreshToken))
+		if err != nil || current.ClientID != client.ID {
+			return oauthError(OAuthInvalidGrant, "invalid refresh token")
+		}
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+			return oauthError(OAuthInvalidGrant, "refresh token expired or revoked")
+		}
+
+		if current.RotatedAt != nil {
+			// Only a stolen copy can present a rotated token; end the whole grant
+			reused = true
+			return tokenRepo.RevokeFamily(current.FamilyID)
+		}
+
+		if err := tokenRepo.MarkRefreshTokenRotated(current.ID); err != nil {
+			return err
+		}
+		next, err = s.createRefreshToken(tokenRepo, client.ID, current.UserID, current.FamilyID, current.Scope)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		log.Printf("OAuth refresh token reuse detected for client %s, revoking grant %s", client.ClientID, current.FamilyID)
+		if err := s.revocationRepo.RevokeSession(current.FamilyID, s.accessTokenTTL); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(OAuthInvalidGrant, "refresh token was already used, the grant has been revoked")
+	}
+
+	// A narrower scope only applies to the new access token; the grant keeps its scope
+	scope := current.Scope
+	if req.Scope != "" {
+		scope, err = resolveScope(req.Scope, strings.Fields(current.Scope))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.issueTokens(client, current.UserID, scope, current.FamilyID, next)
+}
+
+// clientCredentials lets a confidential client act as the user who registered it
+func (s *oauthService) clientCredentials(client *model.OAuthClient, req *dto.OAuthTokenRequest) (*dto.OAuthTokenResponse, error) {
+	if client.Type != model.OAuthClientConfidential {
+		return nil, oauthError(OAuthUnauthorizedClient, "the client_credentials grant requires a confidential client")
+	}
+
+	scope, err := resolveScope(req.Scope, client.ScopeList())
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(client, client.OwnerID, scope, "", "")
+}
+
+// authenticateClient checks the client ID and, for confidential clients, the secret
+func (s *oauthService) authenticateClient(credentials OAuthClientCredentials) (*model.OAuthClient, error) {
+	invalid := oauthError(OAuthInvalidClient, "client authentication failed")
+	if credentials.ClientID == "" {
+		return nil, invalid
+	}
+
+	client, err := s.clientRepo.FindByClientID(credentials.ClientID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch client.Type {
+	case model.OAuthClientConfidential:
+		hash := util.HashToken(credentials.ClientSecret)
+		if credentials.ClientSecret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+			return nil, invalid
+		}
+	case model.OAuthClientPublic:
+		if credentials.ClientSecret != "" {
+			return nil, invalid
+		}
+	}
+	return client, nil
+}
+
+// clientAccessToken parses an access token issued to the client. ok is false for tokens
+// that are invalid, expired, revoked or were issued to someone else.
+func (s *oauthService) clientAccessToken(client *model.OAuthClient, token string) (*util.Claims, bool, error) {
+	claims, err := util.ParseToken(token)
+	if err != nil || claims.ClientID != client.ClientID {
+		return nil, false, nil
+	}
+
+	revoked, err := s.revocationRepo.IsRevoked(claims.ID, claims.SessionID, claims.ClientID)
+	if err != nil {
+		return nil, false, err
+	}
+	return claims, !revoked, nil
+}
+
+// revokeFamily ends a grant: its refresh tokens and the access tokens issued with them
+func (s *oauthService) revokeFamily(tokenRepo repository.OAuthTokenRepository, familyID string) error {
+	if err := tokenRepo.RevokeFamily(familyID); err != nil {
+		return err
+	}
+	return s.revocationRepo.RevokeSession(familyID, s.accessTokenTTL)
+}
+
+func (s *oauthService) createRefreshToken(tokenRepo repository.OAuthTokenRepository, clientID, userID uint, familyID, scope string) (string, error) {
+	token, err := util.GenerateOpaqueToken(refreshTokenBytes)
+	if err != nil {
+		return "", err
+	}
+
+	err = tokenRepo.CreateRefreshToken(&model.OAuthRefreshToken{
+		ClientID:  clientID,
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: util.HashToken(token),
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *oauthService) issueTokens(client *model.OAuthClient, userID uint, scope, familyID, refreshToken string) (*dto.OAuthTokenResponse, error) {
+	// The token carries no role: apps act as the user's owner only, never with the
+	// permissions of a staff role
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, oauthError(OAuthInvalidGrant, "the user of this grant no longer exists")
+	}
+
+	accessToken, err := util.GenerateClientToken(user.ID, client.ClientID, scope, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}, nil
+}
+
+// resolveScope checks a requested scope against the allowed scopes; an empty request asks
+// for all of them
+func resolveScope(requested string, allowed []string) (string, error) {
+	if requested == "" {
+		return strings.Join(allowed, " "), nil
+	}
+
+	scopes := uniqueScopes(strings.Fields(requested))
+	for _, scope := range scopes {
+		if !containsString(allowed, scope) {
+			return "", oauthError(OAuthInvalidScope, "scope "+scope+" is not available to this client")
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func uniqueScopes(scopes []string) []string {
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !containsString(unique, scope) {
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
+
+// redirectWith adds the parameters to the query of the redirect URI, leaving out empty ones
+func redirectWith(redirectURI string, params map[string]string) (*dto.OAuthDecisionResponse, error) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+	for name, value := range params {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return &dto.OAuthDecisionResponse{RedirectURI: u.String()}, nil
+}
+
+func toOAuthClientResponse(client *model.OAuthClient) dto.OAuthClientResponse {
+	return dto.OAuthClientResponse{
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		Type:         string(client.Type),
+		RedirectURIs: client.RedirectURIList(),
+		Scopes:       client.ScopeList(),
+		CreatedAt:    client.CreatedAt,
+	}
+}
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/policy"
+	"go-gin-template/api/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrConsentNotActive  = errors.New("consent is not authorised or has expired")
+	ErrConsentPermission = errors.New("consent does not grant this permission")
+	ErrConsentAccount    = errors.New("account is not shared under this consent")
+	// ErrConsentFinal is returned when the consent is no longer in a status the change applies to
+	ErrConsentFinal            = errors.New("consent can no longer be changed")
+	ErrConsentAccountsRequired = errors.New("choose at least one account to share")
+	ErrConsentExpiry           = errors.New("expires_at must be in the future and within the longest consent age")
+)
+
+// ThirdParty identifies an app calling the open banking endpoints and the user it acts for
+type ThirdParty struct {
+	ClientID string
+	UserID   uint
+}
+
+// OpenBankingService manages the consents under which third-party apps read accounts
+// (AIS) and start payments (PIS). Apps create consents; users authorise, reject and revoke
+// them. Payments are pending transfers that the user still verifies.
+type OpenBankingService interface {
+	CreateAccountAccessConsent(app ThirdParty, req *dto.AccountAccessConsentRequest) (*dto.ConsentResponse, error)
+	CreatePaymentConsent(app ThirdParty, req *dto.PaymentConsentRequest) (*dto.ConsentResponse, error)
+	// GetConsent returns a consent of the app, to follow its status
+	GetConsent(app ThirdParty, consentID uint) (*dto.ConsentResponse, error)
+	ListConsents(userID uint) ([]dto.ConsentResponse, error)
+	// AuthoriseConsent approves a consent; account access consents share the given accounts
+	AuthoriseConsent(userID, consentID uint, req *dto.AuthoriseConsentRequest) (*dto.ConsentResponse, error)
+	RejectConsent(userID, consentID uint) (*dto.ConsentResponse, error)
+	// RevokeConsent withdraws an authorised consent; the app loses access at once
+	RevokeConsent(userID, consentID uint) (*dto.ConsentResponse, error)
+	ListAccounts(app ThirdParty, consentID uint) ([]dto.OpenBankingAccountResponse, error)
+	GetBalance(app ThirdParty, consentID, accountID uint) (*dto.BalanceResponse, error)
+	GetTransactions(app ThirdParty, consentID, accountID uint, query *dto.TransactionHistoryQuery) (*dto.TransactionListResponse, error)
+	// CreatePayment starts the payment of a consent as a pending transfer. A consent pays once.
+	CreatePayment(app ThirdParty, consentID uint) (*dto.PaymentResponse, error)
+}
+
+type openBankingService struct {
+	consentRepo         repository.OpenBankingConsentRepository
+	clientRepo          repository.OAuthClientRepository
+	accountRepo         repository.AccountRepository
+	transactionRepo     repository.TransactionRepository
+	accountService      AccountService
+	accountAccessMaxAge time.Duration
+	paymentConsentTTL   time.Duration
+}
+
+func NewOpenBankingService(consentRepo repository.OpenBankingConsentRepository, clientRepo repository.OAuthClientRepository, accountRepo repository.AccountRepository, transactionRepo repository.TransactionRepository, accountService AccountService) OpenBankingService {
+	openBankingConfig := config.GetOpenBankingConfig()
+	return &openBankingService{
+		consentRepo:         consentRepo,
+		clientRepo:          clientRepo,
+		accountRepo:         accountRepo,
+		transactionRepo:     transactionRepo,
+		accountService:      accountService,
+		accountAccessMaxAge: openBankingConfig.AccountAccessMaxAge,
+		paymentConsentTTL:   openBankingConfig.PaymentConsentTTL,
+	}
+}
+
+func (s *openBankingService) CreateAccountAccessConsent(app ThirdParty, req *dto.AccountAccessConsentRequest) (*dto.ConsentResponse, error) {
+	client, err := s.clientRepo.FindByClientID(app.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.accountAccessMaxAge)
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) || req.ExpiresAt.After(expiresAt) {
+			return nil, fmt.Errorf("%w of %d days", ErrConsentExpiry, int(s.accountAccessMaxAge.Hours()/24))
+		}
+		expiresAt = *req.ExpiresAt
+	}
+
+	consent := &model.OpenBankingConsent{
+		ClientID:    client.ID,
+		UserID:      app.UserID,
+		Type:        model.ConsentTypeAccountAccess,
+		Status:      model.ConsentStatusAwaitingAuthorisation,
+		Permissions: strings.Join(uniqueScopes(req.Permissions), " "),
+		ExpiresAt:   expiresAt,
+	}
+	if err := s.consentRepo.Create(consent); err != nil {
+		return nil, err
+	}
+
+	return s.toConsentResponse(consent, client)
+}
+
+func (s *openBankingService) CreatePaymentConsent(app ThirdParty, req *dto.PaymentConsentRequest) (*dto.ConsentResponse, error) {
+	client, err := s.clientRepo.FindByClientID(app.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the payment now, so the user is not asked to approve one that cannot be made
+	if req.DebtorAccountID == req.CreditorAccountID {
+		return nil, errors.New("cannot transfer to the same account")
+	}
+	debtor, err := s.accountRepo.FindByID(req.DebtorAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Authorize(policy.Owner(app.UserID), policy.ActionTransact, policy.AccountOf(debtor)); err != nil {
+		return nil, err
+	}
+	creditor, err := s.accountRepo.FindByID(req.CreditorAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAmountCurrency(debtor, req.Amount, req.Currency); err != nil {
+		return nil, err
+	}
+	if creditor.Currency != debtor.Currency {
+		return nil, errors.New("source and target accounts use different currencies")
+	}
+
+	consent := &model.OpenBankingConsent{
+		ClientID:          client.ID,
+		UserID:            app.UserID,
+		Type:              model.ConsentTypePayment,
+		Status:            model.ConsentStatusAwaitingAuthorisation,
+		DebtorAccountID:   &debtor.ID,
+		CreditorAccountID: &creditor.ID,
+		Amount:            req.Amount,
+		Currency:          debtor.Currency,
+		Reference:         req.Reference,
+		ExpiresAt:         time.Now().Add(s.paymentConsentTTL),
+	}
+	if err := s.consentRepo.Create(consent); err != nil {
+		return nil, err
+	}
+
+	return s.toConsentResponse(consent, client)
+}
+
+func (s *openBankingService) GetConsent(app ThirdParty, consentID uint) (*dto.ConsentResponse, error) {
+	consent, client, err := s.findAppConsent(app, consentID, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.toConsentResponse(consent, client)
+}
+
+func (s *openBankingService) ListConsents(userID uint) ([]dto.ConsentResponse, error) {
+	consents, err := s.consentRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.ConsentResponse, 0, len(consents))
+	clients := map[uint]*model.OAuthClient{}
+	for _, consent := range consents {
+		client, ok := clients[consent.ClientID]
+		if !ok {
+			client, err = s.clientRepo.FindByID(consent.ClientID)
+			if err != nil {
+				return nil, err
+			}
+			clients[consent.ClientID] = client
+		}
+
+		response, err := s.toConsentResponse(consent, client)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, *response)
+	}
+	return responses, nil
+}
+
+func (s *openBankingService) AuthoriseConsent(userID, consentID uint, req *dto.AuthoriseConsentRequest) (*dto.ConsentResponse, error) {
+	consent, err := s.findUserConsent(userID, consentID)
+	if err != nil {
+		return nil, err
+	}
+	if consent.Status != model.ConsentStatusAwaitingAuthorisation || !time.Now().Before(consent.ExpiresAt) {
+		return nil, ErrConsentFinal
+	}
+
+	var accounts []*model.Account
+	if consent.Type == model.ConsentTypeAccountAccess {
+		if len(req.AccountIDs) == 0 {
+			return nil, ErrConsentAccountsRequired
+		}
+		for _, accountID := range req.AccountIDs {
+			account, err := s.accountRepo.FindByID(accountID)
+			if err != nil {
+				return nil, err
+			}
+			// Users share their own accounts only, whatever their role allows them to read
+			if err := policy.Authorize(policy.Owner(userID), policy.ActionTransact, policy.AccountOf(account)); err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, account)
+		}
+	}
+
+	if err := s.consentRepo.Authorise(consent, accounts); err != nil {
+		return nil, consentStatusError(err)
+	}
+	return s.userConsentResponse(consent.ID)
+}
+
+func (s *openBankingService) RejectConsent(userID, consentID uint) (*dto.ConsentResponse, error) {
+	return s.transitionUserConsent(userID, consentID, model.ConsentStatusAwaitingAuthorisation, model.ConsentStatusRejected)
+}
+
+func (s *openBankingService) RevokeConsent(userID, consentID uint) (*dto.ConsentResponse, error) {
+	return s.transitionUserConsent(userID, consentID, model.ConsentStatusAuthorised, model.ConsentStatusRevoked)
+}
+
+func (s *openBankingService) ListAccounts(app ThirdParty, consentID uint) ([]dto.OpenBankingAccountResponse, error) {
+	consent, _, err := s.accountAccess(app, consentID, model.ConsentPermissionReadAccounts)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.OpenBankingAccountResponse, 0, len(consent.Accounts))
+	for _, account := range consent.Accounts {
+		responses = append(responses, dto.OpenBankingAccountResponse{
+			ID:       account.ID,
+			Name:     account.Name,
+			Currency: account.Currency,
+		})
+	}
+	return responses, nil
+}
+
+func (s *openBankingService) GetBalance(app ThirdParty, consentID, accountID uint) (*dto.BalanceResponse, error) {
+	if _, _, err := s.accountAccess(app, consentID, model.ConsentPermissionReadBalances, accountID); err != nil {
+		return nil, err
+	}
+
+	// Read the account again; the copy loaded with the consent is as old as the authorisation
+	account, err := s.accountRepo.FindByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.BalanceResponse{
+		AccountID: account.ID,
+		Balance:   presentAmount(account.Balance, account.Currency),
+		Currency:  account.Currency,
+	}, nil
+}
+
+func (s *openBankingService) GetTransactions(app ThirdParty, consentID, accountID uint, query *dto.TransactionHistoryQuery) (*dto.TransactionListResponse, error) {
+	consent, _, err := s.accountAccess(app, consentID, model.ConsentPermissionReadTransactions, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.accountService.GetTransactions(policy.Owner(consent.UserID), accountID, query)
+}
+
+func (s *openBankingService) CreatePayment(app ThirdParty, consentID uint) (*dto.PaymentResponse, error) {
+	consent, _, err := s.findAppConsent(app, consentID, model.ConsentTypePayment)
+	if err != nil {
+		return nil, err
+	}
+	if !consent.IsActive(time.Now()) {
+		return nil, ErrConsentNotActive
+	}
+
+	// Claim the consent first so that two concurrent requests cannot both pay
+	err = s.consentRepo.TransitionStatus(consent.ID, model.ConsentStatusAuthorised, model.ConsentStatusConsumed)
+	if err != nil {
+		if errors.Is(err, repository.ErrConsentStatus) {
+			return nil, ErrConsentNotActive
+		}
+		return nil, err
+	}
+
+	transaction, err := s.accountService.InitiateTransfer(consent.UserID, *consent.DebtorAccountID, *consent.CreditorAccountID, consent.Amount, consent.Currency, paymentDescription(consent))
+	if err != nil {
+		// The payment was not started; the app may try again while the consent lasts
+		if releaseErr := s.consentRepo.TransitionStatus(consent.ID, model.ConsentStatusConsumed, model.ConsentStatusAuthorised); releaseErr != nil {
+			return nil, releaseErr
+		}
+		return nil, err
+	}
+	if err := s.consentRepo.SetTransaction(consent.ID, transaction.ID); err != nil {
+		return nil, err
+	}
+
+	return &dto.PaymentResponse{
+		ConsentID:     consent.ID,
+		TransactionID: transaction.ID,
+		Status:        string(transaction.Status),
+		Amount:        presentAmount(transaction.Amount, transaction.Currency),
+		Currency:      transaction.Currency,
+		Message:       "Payment initiated. The user must verify the transfer to complete it.",
+	}, nil
+}
+
+// accountAccess loads an active account access consent of the app that grants the
+// permission and, when accountIDs are given, shares those accounts
+func (s *openBankingService) accountAccess(app ThirdParty, consentID uint, permission string, accountIDs ...uint) (*model.OpenBankingConsent, *model.OAuthClient, error) {
+	consent, client, err := s.findAppConsent(app, consentID, model.ConsentTypeAccountAccess)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consent.IsActive(time.Now()) {
+		return nil, nil, ErrConsentNotActive
+	}
+	if !consent.HasPermission(permission) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrConsentPermission, permission)
+	}
+	for _, accountID := range accountIDs {
+		if !consent.CoversAccount(accountID) {
+			return nil, nil, ErrConsentAccount
+		}
+	}
+	return consent, client, nil
+}
+
+// findAppConsent loads a consent the app created for the user it acts for. Other consents,
+// and consents of another type when consentType is set, are reported as missing.
+func (s *openBankingService) findAppConsent(app ThirdParty, consentID uint, consentType model.ConsentType) (*model.OpenBankingConsent, *model.OAuthClient, error) {
+	client, err := s.clientRepo.FindByClientID(app.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	consent, err := s.consentRepo.FindByID(consentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if consent.ClientID != client.ID || consent.UserID != app.UserID || (consentType != "" && consent.Type != consentType) {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	return consent, client, nil
+}
+
+// findUserConsent loads a consent of the user; consents of other users are reported as missing
+func (s *openBankingService) findUserConsent(userID, consentID uint) (*model.OpenBankingConsent, error) {
+	consent, err := s.consentRepo.FindByID(consentID)
+	if err != nil {
+		return nil, err
+	}
+	if consent.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return consent, nil
+}
+
+func (s *openBankingService) transitionUserConsent(userID, consentID uint, from, to model.ConsentStatus) (*dto.ConsentResponse, error) {
+	consent, err := s.findUserConsent(userID, consentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.consentRepo.TransitionStatus(consent.ID, from, to); err != nil {
+		return nil, consentStatusError(err)
+	}
+	return s.userConsentResponse(consent.ID)
+}
+
+func (s *openBankingService) userConsentResponse(consentID uint) (*dto.ConsentResponse, error) {
+	consent, err := s.consentRepo.FindByID(consentID)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.clientRepo.FindByID(consent.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	return s.toConsentResponse(consent, client)
+}
+
+func (s *openBankingService) toConsentResponse(consent *model.OpenBankingConsent, client *model.OAuthClient) (*dto.ConsentResponse, error) {
+	response := &dto.ConsentResponse{
+		ID:           consent.ID,
+		Type:         string(consent.Type),
+		Status:       string(consent.Status),
+		ClientID:     client.ClientID,
+		ClientName:   client.Name,
+		ExpiresAt:    consent.ExpiresAt,
+		AuthorisedAt: consent.AuthorisedAt,
+		RevokedAt:    consent.RevokedAt,
+		CreatedAt:    consent.CreatedAt,
+	}
+	// Consents still open past their expiry are reported as expired
+	if (consent.Status == model.ConsentStatusAwaitingAuthorisation || consent.Status == model.ConsentStatusAuthorised) &&
+		!time.Now().Before(consent.ExpiresAt) {
+		response.Status = "expired"
+	}
+
+	switch consent.Type {
+	case model.ConsentTypeAccountAccess:
+		response.Permissions = consent.PermissionList()
+		for _, account := range consent.Accounts {
+			response.AccountIDs = append(response.AccountIDs, account.ID)
+		}
+	case model.ConsentTypePayment:
+		amount := presentAmount(consent.Amount, consent.Currency)
+		response.DebtorAccountID = consent.DebtorAccountID
+		response.CreditorAccountID = consent.CreditorAccountID
+		response.Amount = &amount
+		response.Currency = consent.Currency
+		response.Reference = consent.Reference
+		response.TransactionID = consent.TransactionID
+		if consent.TransactionID != nil {
+			transaction, err := s.transactionRepo.FindByID(*consent.TransactionID)
+			if err != nil {
+				return nil, err
+			}
+			response.PaymentStatus = string(transaction.Status)
+		}
+	}
+	return response, nil
+}
+
+func consentStatusError(err error) error {
+	if errors.Is(err, repository.ErrConsentStatus) {
+		return ErrConsentFinal
+	}
+	return err
+}
+
+func paymentDescription(consent *model.OpenBankingConsent) string {
+	if consent.Reference != "" {
+		return consent.Reference
+	}
+	return "Open banking payment"
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	resetTokenBytes = 32
+	// maxResetRequestsPerHour limits how many reset emails one account receives
+	maxResetRequestsPerHour = 3
+)
+
+var (
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrPasswordReused    = errors.New("password was used recently, choose a different one")
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+)
+
+type PasswordService interface {
+	// ChangePassword replaces the password after checking the current one, and signs the
+	// user out everywhere. A new password that breaks the policy returns a *util.PasswordPolicyError.
+	ChangePassword(userID uint, currentPassword, newPassword string) error
+	// RequestReset emails a single-use reset link. Unknown emails are ignored, so the
+	// result does not reveal whether an account exists.
+	RequestReset(email string) error
+	// ResetPassword sets a new password with a token from a reset link
+	ResetPassword(token, newPassword string) error
+}
+
+type passwordService struct {
+	userRepo       repository.UserRepository
+	passwordRepo   repository.UserPasswordRepository
+	resetRepo      repository.PasswordResetRepository
+	contactService ContactService
+	userNotifier   UserNotifier
+	tokenService   TokenService
+	policy         *util.PasswordPolicy
+	config         config.PasswordConfig
+}
+
+func NewPasswordService(userRepo repository.UserRepository, passwordRepo repository.UserPasswordRepository, resetRepo repository.PasswordResetRepository, contactService ContactService, userNotifier UserNotifier, tokenService TokenService, policy *util.PasswordPolicy) PasswordService {
+	return &passwordService{
+		userRepo:       userRepo,
+		passwordRepo:   passwordRepo,
+		resetRepo:      resetRepo,
+		contactService: contactService,
+		userNotifier:   userNotifier,
+		tokenService:   tokenService,
+		policy:         policy,
+		config:         config.GetPasswordConfig(),
+	}
+}
+
+func (s *passwordService) ChangePassword(userID uint, currentPassword, newPassword string) error {
+	active, err := s.passwordRepo.FindActiveByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(active.HashedPassword), []byte(currentPassword)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.passwordRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		return s.setPassword(tx, user, newPassword)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.passwordChanged(userID)
+}
+
+func (s *passwordService) RequestReset(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	recent, err := s.resetRepo.CountCreatedSince(user.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if recent >= maxResetRequestsPerHour {
+		log.Printf("Password reset for user %d throttled", user.ID)
+		return nil
+	}
+
+	// Reset links always go to the email address, the identity the account is registered
+	// with. Until the address is verified nothing is sent, as for an unknown email.
+	contact, err := s.contactService.ResolveRecipient(user.ID, model.ContactChannelEmail)
+	if errors.Is(err, ErrContactUnverified) {
+		log.Printf("Password reset for user %d skipped, email address not verified", user.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := util.GenerateOpaqueToken(resetTokenBytes)
+	if err != nil {
+		return err
+	}
+
+	err = s.resetRepo.Create(&model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: time.Now().Add(s.config.ResetTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.userNotifier.NotifyContact(contact, TemplatePasswordReset, map[string]string{
+		"ResetURL":         s.resetURL(token),
+		"ExpiresInMinutes": strconv.Itoa(int(s.config.ResetTokenTTL.Minutes())),
+	})
+	return err
+}
+
+func (s *passwordService) ResetPassword(token, newPassword string) error {
+	var userID uint
+
+	err := s.resetRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		resetRepo := s.resetRepo.WithTx(tx)
+
+		// Lock the token so two concurrent resets cannot both use it
+		resetToken, err := resetRepo.FindByHashForUpdate(util.HashToken(token))
+		if err != nil {
+			return ErrInvalidResetToken
+		}
+		if resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+			return ErrInvalidResetToken
+		}
+		userID = resetToken.UserID
+
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil {
+			return err
+		}
+
+		// Using one link invalidates the others sent before it
+		if err := resetRepo.InvalidateForUser(userID); err != nil {
+			return err
+		}
+		return s.setPassword(tx, user, newPassword)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.passwordChanged(userID)
+}
+
+// setPassword stores the new password as the active one, rejecting passwords that break
+// the policy or were used recently
+func (s *passwordService) setPassword(tx *gorm.DB, user *model.User, newPassword string) error {
+	if err := s.policy.Check(newPassword, user.Email, user.Name); err != nil {
+		return err
+	}
+
+	passwordRepo := s.passwordRepo.WithTx(tx)
+
+	recent, err := passwordRepo.FindRecentByUserID(user.ID, s.config.HistorySize)
+	if err != nil {
+		return err
+	}
+	for _, previous := range recent {
+		if bcrypt.CompareHashAndPassword([]byte(previous.HashedPassword), []byte(newPassword)) == nil {
+			return ErrPasswordReused
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// Older passwords stay as inactive rows; they are the history checked above
+	if err := passwordRepo.DeactivateAll(user.ID); err != nil {
+		return err
+	}
+	return passwordRepo.Create(&model.UserPassword{
+		UserID:         user.ID,
+		HashedPassword: string(hashedPassword),
+		IsActive:       true,
+	})
+}
+
+// passwordChanged ends every session, since any of them may belong to whoever knew the old password
+func (s *passwordService) passwordChanged(userID uint) error {
+	if err := s.resetRepo.InvalidateForUser(userID); err != nil {
+		return err
+	}
+	if err := s.tokenService.LogoutAll(userID); err != nil {
+		return err
+	}
+
+	s.userNotifier.NotifyUser(userID, TemplatePasswordChanged, map[string]string{
+		"Time": time.Now().Format(notificationTimeLayout),
+	})
+	return nil
+}
+
+func (s *passwordService) resetURL(token string) string {
+	resetURL, err := url.Parse(s.config.ResetURL)
+	if err != nil {
+		return s.config.ResetURL + "?token=" + url.QueryEscape(token)
+	}
+
+	query := resetURL.Query()
+	query.Set("token", token)
+	resetURL.RawQuery = query.Encode()
+	return resetURL.String()
+}
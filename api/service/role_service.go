@@ -0,0 +1,378 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNameTaken       = errors.New("a role with this name already exists")
+	ErrPermissionNameTaken = errors.New("a permission with this name already exists")
+	ErrUnknownPermission   = errors.New("unknown permission")
+	ErrUnknownRole         = errors.New("unknown role")
+	ErrRoleInUse           = errors.New("role is assigned to users")
+	// ErrBuiltIn protects the roles and permissions the code refers to by name
+	ErrBuiltIn = errors.New("built-in roles and permissions cannot be renamed or deleted")
+	// ErrAdminRoleLocked keeps admins from locking themselves out of role management
+	ErrAdminRoleLocked = fmt.Errorf("the %s role must keep the %s permission", model.RoleAdmin, model.PermissionRolesManage)
+)
+
+// RoleService manages roles, the permissions they grant and the roles of users. Every
+// change drops the cached permission sets of the affected roles.
+type RoleService interface {
+	ListRoles() ([]dto.RoleResponse, error)
+	GetRole(id uint) (*dto.RoleResponse, error)
+	CreateRole(req *dto.RoleRequest) (*dto.RoleResponse, error)
+	// UpdateRole renames the role and replaces its permissions
+	UpdateRole(id uint, req *dto.RoleRequest) (*dto.RoleResponse, error)
+	// DeleteRole deletes a role that no user has
+	DeleteRole(id uint) error
+	ListPermissions() ([]dto.PermissionResponse, error)
+	CreatePermission(req *dto.PermissionRequest) (*dto.PermissionResponse, error)
+	UpdatePermission(id uint, req *dto.PermissionRequest) (*dto.PermissionResponse, error)
+	// DeletePermission removes the permission from every role and deletes it
+	DeletePermission(id uint) error
+	// AssignRole changes the role of a user and signs them out everywhere, so no access
+	// token with the old role stays usable
+	AssignRole(userID uint, roleName string) (*dto.UserResponse, error)
+}
+
+type roleService struct {
+	roleRepo       repository.RoleRepository
+	permissionRepo repository.PermissionRepository
+	userRepo       repository.UserRepository
+	tokenService   TokenService
+}
+
+func NewRoleService(roleRepo repository.RoleRepository, permissionRepo repository.PermissionRepository, userRepo repository.UserRepository, tokenService TokenService) RoleService {
+	return &roleService{
+		roleRepo:       roleRepo,
+		permissionRepo: permissionRepo,
+		userRepo:       userRepo,
+		tokenService:   tokenService,
+	}
+}
+
+func (s *roleService) ListRoles() ([]dto.RoleResponse, error) {
+	roles, err := s.roleRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		responses = append(responses, toRoleResponse(role))
+	}
+	return responses, nil
+}
+
+func (s *roleService) GetRole(id uint) (*dto.RoleResponse, error) {
+	role, err := s.roleRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	response := toRoleResponse(role)
+	return &response, nil
+}
+
+func (s *roleService) CreateRole(req *dto.RoleRequest) (*dto.RoleResponse, error) {
+	if err := s.checkRoleNameFree(req.Name, 0); err != nil {
+		return nil, err
+	}
+	permissions, err := s.findPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &model.Role{Name: req.Name}
+	if err := s.roleRepo.Create(role); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.ReplacePermissions(role, permissions); err != nil {
+		return nil, err
+	}
+	// A role with this name may have been looked up, and cached as empty, before it existed
+	if err := s.roleRepo.InvalidatePermissions(role.Name); err != nil {
+		return nil, err
+	}
+
+	return s.GetRole(role.ID)
+}
+
+func (s *roleService) UpdateRole(id uint, req *dto.RoleRequest) (*dto.RoleResponse, error) {
+	role, err := s.roleRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	oldName := role.Name
+
+	if req.Name != oldName {
+		if isBuiltInRole(oldName) {
+			return nil, ErrBuiltIn
+		}
+		if err := s.checkRoleNameFree(req.Name, role.ID); err != nil {
+			return nil, err
+		}
+	}
+	if oldName == model.RoleAdmin && !containsString(req.Permissions, model.PermissionRolesManage) {
+		return nil, ErrAdminRoleLocked
+	}
+
+	permissions, err := s.findPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role.Name = req.Name
+	if err := s.roleRepo.Update(role); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.ReplacePermissions(role, permissions); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.InvalidatePermissions(oldName, role.Name); err != nil {
+		return nil, err
+	}
+
+	return s.GetRole(role.ID)
+}
+
+func (s *roleService) DeleteRole(id uint) error {
+	role, err := s.roleRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if isBuiltInRole(role.Name) {
+		return ErrBuiltIn
+	}
+
+	users, err := s.roleRepo.CountUsers(role.ID)
+	if err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse
+	}
+
+	if err := s.roleRepo.Delete(role.ID); err != nil {
+		return err
+	}
+	return s.roleRepo.InvalidatePermissions(role.Name)
+}
+
+func (s *roleService) ListPermissions() ([]dto.PermissionResponse, error) {
+	permissions, err := s.permissionRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.PermissionResponse, 0, len(permissions))
+	for _, permission := range permissions {
+		responses = append(responses, toPermissionResponse(permission))
+	}
+	return responses, nil
+}
+
+func (s *roleService) CreatePermission(req *dto.PermissionRequest) (*dto.PermissionResponse, error) {
+	if err := s.checkPermissionNameFree(req.Name); err != nil {
+		return nil, err
+	}
+
+	permission := &model.Permission{Name: req.Name, Description: req.Description}
+	if err := s.permissionRepo.Create(permission); err != nil {
+		return nil, err
+	}
+
+	response := toPermissionResponse(permission)
+	return &response, nil
+}
+
+func (s *roleService) UpdatePermission(id uint, req *dto.PermissionRequest) (*dto.PermissionResponse, error) {
+	permission, err := s.permissionRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := req.Name != permission.Name
+	if renamed {
+		if isBuiltInPermission(permission.Name) {
+			return nil, ErrBuiltIn
+		}
+		if err := s.checkPermissionNameFree(req.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	permission.Name = req.Name
+	permission.Description = req.Description
+	if err := s.permissionRepo.Update(permission); err != nil {
+		return nil, err
+	}
+
+	if renamed {
+		if err := s.invalidateRolesWith(permission.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	response := toPermissionResponse(permission)
+	return &response, nil
+}
+
+func (s *roleService) DeletePermission(id uint) error {
+	permission, err := s.permissionRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if isBuiltInPermission(permission.Name) {
+		return ErrBuiltIn
+	}
+
+	// Look the roles up before the permission is taken from them
+	roleNames, err := s.permissionRepo.FindRoleNames(permission.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.permissionRepo.Delete(permission.ID); err != nil {
+		return err
+	}
+	return s.roleRepo.InvalidatePermissions(roleNames...)
+}
+
+func (s *roleService) AssignRole(userID uint, roleName string) (*dto.UserResponse, error) {
+	role, err := s.roleRepo.FindByName(roleName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRole, roleName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.AssignToUser(userID, role.ID); err != nil {
+		return nil, err
+	}
+	if err := s.tokenService.LogoutAll(userID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return toUserResponse(user), nil
+}
+
+// findPermissions resolves permission names, failing on any name that does not exist
+func (s *roleService) findPermissions(names []string) ([]*model.Permission, error) {
+	permissions, err := s.permissionRepo.FindByNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, strings.Join(missing, ", "))
+	}
+	return permissions, nil
+}
+
+func (s *roleService) checkRoleNameFree(name string, roleID uint) error {
+	existing, err := s.roleRepo.FindByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != roleID {
+		return ErrRoleNameTaken
+	}
+	return nil
+}
+
+func (s *roleService) checkPermissionNameFree(name string) error {
+	existing, err := s.permissionRepo.FindByNames([]string{name})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return ErrPermissionNameTaken
+	}
+	return nil
+}
+
+func (s *roleService) invalidateRolesWith(permissionID uint) error {
+	roleNames, err := s.permissionRepo.FindRoleNames(permissionID)
+	if err != nil {
+		return err
+	}
+	return s.roleRepo.InvalidatePermissions(roleNames...)
+}
+
+func isBuiltInRole(name string) bool {
+	switch name {
+	case model.RoleUser, model.RoleTeller, model.RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func isBuiltInPermission(name string) bool {
+	switch name {
+	case model.PermissionBooksWrite, model.PermissionLedgerReconcile, model.PermissionNotificationsManage,
+		model.PermissionUsersUnlock, model.PermissionRolesManage, model.PermissionUsersRead, model.PermissionUsersWrite,
+		model.PermissionAccountsRead:
+		return true
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func toRoleResponse(role *model.Role) dto.RoleResponse {
+	permissions := make([]string, 0, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		permissions = append(permissions, permission.Name)
+	}
+
+	return dto.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+func toPermissionResponse(permission *model.Permission) dto.PermissionResponse {
+	return dto.PermissionResponse{
+		ID:          permission.ID,
+		Name:        permission.Name,
+		Description: permission.Description,
+		CreatedAt:   permission.CreatedAt,
+		UpdatedAt:   permission.UpdatedAt,
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/util"
+)
+
+// maxSMSErrorBody limits how much of a provider error response is kept in the outbox
+const maxSMSErrorBody = 512
+
+// ErrInvalidReceiptSignature is returned when a delivery receipt is not signed by the provider
+var ErrInvalidReceiptSignature = errors.New("invalid delivery receipt signature")
+
+// HTTPSMSSender delivers SMS through an HTTP provider. It speaks either the Twilio
+// Messages API (form POST with basic auth) or a generic JSON webhook, so any gateway
+// offering one of the two can be used, including an httptest server in tests.
+type HTTPSMSSender struct {
+	templates TemplateRegistry
+	config    config.SMSConfig
+	client    *http.Client
+}
+
+func NewHTTPSMSSender(smsConfig config.SMSConfig, templates TemplateRegistry) *HTTPSMSSender {
+	return &HTTPSMSSender{
+		templates: templates,
+		config:    smsConfig,
+		client:    &http.Client{Timeout: smsConfig.Timeout},
+	}
+}
+
+func (s *HTTPSMSSender) GetType() string {
+	return "sms"
+}
+
+// Send submits the message to the provider. Invalid numbers and requests the provider
+// rejects (4xx other than 429) fail permanently; timeouts, 429 and 5xx are retried.
+func (s *HTTPSMSSender) Send(message *Message) error {
+	to, err := util.NormalizeE164(message.To, s.config.DefaultCountryCode)
+	if err != nil {
+		return Permanent(fmt.Errorf("%w: %s", err, util.MaskPhone(message.To)))
+	}
+
+	content, err := s.templates.Render(message.TemplateID, message.Locale, message.Variables)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	request, err := s.newRequest(to, content.SMS, message.Reference)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		io.Copy(io.Discard, response.Body)
+		log.Printf("📱 SMS to %s accepted by provider", util.MaskPhone(to))
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxSMSErrorBody))
+	err = fmt.Errorf("SMS provider returned %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500 {
+		return err
+	}
+	return Permanent(err)
+}
+
+func (s *HTTPSMSSender) newRequest(to, body, reference string) (*http.Request, error) {
+	baseURL := strings.TrimSuffix(s.config.ProviderURL, "/")
+
+	switch s.config.Dialect {
+	case config.SMSDialectTwilio:
+		form := url.Values{}
+		form.Set("To", to)
+		form.Set("From", s.config.From)
+		form.Set("Body", body)
+		if callbackURL := s.callbackURL(reference); callbackURL != "" {
+			form.Set("StatusCallback", callbackURL)
+		}
+
+		endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", baseURL, url.PathEscape(s.config.AccountSID))
+		request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		request.SetBasicAuth(s.config.AccountSID, s.config.AuthToken)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request, nil
+
+	case config.SMSDialectWebhook:
+		payload, err := json.Marshal(map[string]string{
+			"to":           to,
+			"from":         s.config.From,
+			"body":         body,
+			"reference":    reference,
+			"callback_url": s.callbackURL(reference),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := http.NewRequest(http.MethodPost, baseURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		if s.config.AuthToken != "" {
+			request.Header.Set("Authorization", "Bearer "+s.config.AuthToken)
+		}
+		request.Header.Set("Content-Type", "application/json")
+		return request, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported SMS provider dialect '%s'", s.config.Dialect)
+	}
+}
+
+// callbackURL is the receipt endpoint with the outbox reference attached
+func (s *HTTPSMSSender) callbackURL(reference string) string {
+	if s.config.StatusCallbackURL == "" || reference == "" {
+		return ""
+	}
+
+	separator := "?"
+	if strings.Contains(s.config.StatusCallbackURL, "?") {
+		separator = "&"
+	}
+	return s.config.StatusCallbackURL + separator + "ref=" + url.QueryEscape(reference)
+}
+
+// ParseDeliveryReceipt authenticates and decodes a status callback from the provider.
+// Without an auth token anyone could sign a receipt, so all receipts are refused.
+func (s *HTTPSMSSender) ParseDeliveryReceipt(r *http.Request) (*DeliveryReceipt, error) {
+	if s.config.AuthToken == "" {
+		return nil, ErrInvalidReceiptSignature
+	}
+
+	switch s.config.Dialect {
+	case config.SMSDialectTwilio:
+		return s.parseTwilioReceipt(r)
+	case config.SMSDialectWebhook:
+		return s.parseWebhookReceipt(r)
+	default:
+		return nil, fmt.Errorf("unsupported SMS provider dialect '%s'", s.config.Dialect)
+	}
+}
+
+// parseTwilioReceipt checks X-Twilio-Signature: the base64 HMAC-SHA1, keyed with the auth
+// token, of the callback URL followed by the sorted form parameters
+func (s *HTTPSMSSender) parseTwilioReceipt(r *http.Request) (*DeliveryReceipt, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	// Sign the URL the provider was given; the public host may differ from the one we see
+	signedURL := s.config.StatusCallbackURL
+	if signedURL == "" {
+		return nil, ErrInvalidReceiptSignature
+	}
+	if i := strings.Index(signedURL, "?"); i >= 0 {
+		signedURL = signedURL[:i]
+	}
+	if r.URL.RawQuery != "" {
+		signedURL += "?" + r.URL.RawQuery
+	}
+
+	keys := make([]string, 0, len(r.PostForm))
+	for key := range r.PostForm {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var data strings.Builder
+	data.WriteString(signedURL)
+	for _, key := range keys {
+		for _, value := range r.PostForm[key] {
+			data.WriteString(key)
+			data.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(s.config.AuthToken))
+	mac.Write([]byte(data.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("X-Twilio-Signature"))) {
+		return nil, ErrInvalidReceiptSignature
+	}
+
+	reference, err := parseReceiptReference(r.URL.Query().Get("ref"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeliveryReceipt{
+		Reference: reference,
+		Status:    r.PostForm.Get("MessageStatus"),
+		Error:     r.PostForm.Get("ErrorCode"),
+	}, nil
+}
+
+// parseWebhookReceipt checks X-Webhook-Signature: the hex HMAC-SHA256 of the raw body
+// keyed with the auth token
+func (s *HTTPSMSSender) parseWebhookReceipt(r *http.Request) (*DeliveryReceipt, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.config.AuthToken))
+	mac.Write(body)
+	signature, err := hex.DecodeString(r.Header.Get("X-Webhook-Signature"))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidReceiptSignature
+	}
+
+	var payload struct {
+		Reference string `json:"reference"`
+		Status    string `json:"status"`
+		Error     string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	reference, err := parseReceiptReference(payload.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeliveryReceipt{Reference: reference, Status: payload.Status, Error: payload.Error}, nil
+}
+
+func parseReceiptReference(reference string) (uint, error) {
+	id, err := strconv.ParseUint(reference, 10, 32)
+	if err != nil || id == 0 {
+		return 0, errors.New("delivery receipt has no valid reference")
+	}
+	return uint(id), nil
+}
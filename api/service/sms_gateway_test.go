@@ -0,0 +1,227 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/util"
+)
+
+// 測試用的 SMS 供應商設定
+func testSMSConfig(providerURL, dialect string) config.SMSConfig {
+	return config.SMSConfig{
+		ProviderURL:        providerURL,
+		Dialect:            dialect,
+		AccountSID:         "AC123",
+		AuthToken:          "secret-token",
+		From:               "+15550000000",
+		DefaultCountryCode: "886",
+		StatusCallbackURL:  "https://bank.example.com/notifications/sms/receipts",
+		Timeout:            time.Second,
+	}
+}
+
+func testSMSMessage() *Message {
+	return &Message{
+		To:         "0912-345-678",
+		TemplateID: TemplateVerificationCode,
+		Locale:     "en",
+		Variables:  map[string]string{"Code": "123456", "ExpiresInMinutes": "5"},
+		Reference:  "42",
+	}
+}
+
+func TestHTTPSMSSenderTwilioDialect(t *testing.T) {
+	var received *http.Request
+	var form url.Values
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer provider.Close()
+
+	sender := NewHTTPSMSSender(testSMSConfig(provider.URL, config.SMSDialectTwilio), MustTemplateRegistry())
+	require.NoError(t, sender.Send(testSMSMessage()))
+
+	// 確認請求格式與 Twilio Messages API 相容
+	assert.Equal(t, "/2010-04-01/Accounts/AC123/Messages.json", received.URL.Path)
+	user, password, ok := received.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "AC123", user)
+	assert.Equal(t, "secret-token", password)
+	assert.Equal(t, "+886912345678", form.Get("To"))
+	assert.Equal(t, "+15550000000", form.Get("From"))
+	assert.Contains(t, form.Get("Body"), "123456")
+	assert.Equal(t, "https://bank.example.com/notifications/sms/receipts?ref=42", form.Get("StatusCallback"))
+}
+
+func TestHTTPSMSSenderWebhookDialect(t *testing.T) {
+	var payload map[string]string
+	var authorization string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer provider.Close()
+
+	sender := NewHTTPSMSSender(testSMSConfig(provider.URL, config.SMSDialectWebhook), MustTemplateRegistry())
+	require.NoError(t, sender.Send(testSMSMessage()))
+
+	assert.Equal(t, "Bearer secret-token", authorization)
+	assert.Equal(t, "+886912345678", payload["to"])
+	assert.Equal(t, "42", payload["reference"])
+	assert.Contains(t, payload["body"], "123456")
+}
+
+func TestHTTPSMSSenderErrorClassification(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		permanent bool
+	}{
+		{"rate limited", http.StatusTooManyRequests, false},
+		{"provider outage", http.StatusServiceUnavailable, false},
+		{"rejected request", http.StatusBadRequest, true},
+		{"bad credentials", http.StatusUnauthorized, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer provider.Close()
+
+			sender := NewHTTPSMSSender(testSMSConfig(provider.URL, config.SMSDialectTwilio), MustTemplateRegistry())
+			err := sender.Send(testSMSMessage())
+			require.Error(t, err)
+			assert.Equal(t, tt.permanent, IsPermanent(err))
+		})
+	}
+
+	t.Run("invalid number", func(t *testing.T) {
+		sender := NewHTTPSMSSender(testSMSConfig("http://127.0.0.1:1", config.SMSDialectTwilio), MustTemplateRegistry())
+		message := testSMSMessage()
+		message.To = "12"
+		err := sender.Send(message)
+		assert.True(t, IsPermanent(err))
+	})
+
+	t.Run("unreachable provider", func(t *testing.T) {
+		provider := httptest.NewServer(http.NotFoundHandler())
+		provider.Close()
+
+		sender := NewHTTPSMSSender(testSMSConfig(provider.URL, config.SMSDialectTwilio), MustTemplateRegistry())
+		err := sender.Send(testSMSMessage())
+		require.Error(t, err)
+		assert.False(t, IsPermanent(err))
+	})
+}
+
+func TestHTTPSMSSenderTwilioReceipt(t *testing.T) {
+	smsConfig := testSMSConfig("http://unused", config.SMSDialectTwilio)
+	sender := NewHTTPSMSSender(smsConfig, MustTemplateRegistry())
+
+	form := url.Values{"MessageStatus": {"undelivered"}, "ErrorCode": {"30003"}, "MessageSid": {"SM1"}}
+	// Twilio 的簽章：回呼網址加上依鍵排序的表單參數
+	data := smsConfig.StatusCallbackURL + "?ref=42" + "ErrorCode30003MessageSidSM1MessageStatusundelivered"
+	mac := hmac.New(sha1.New, []byte(smsConfig.AuthToken))
+	mac.Write([]byte(data))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	newRequest := func(signature string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/notifications/sms/receipts?ref=42", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Twilio-Signature", signature)
+		return r
+	}
+
+	receipt, err := sender.ParseDeliveryReceipt(newRequest(signature))
+	require.NoError(t, err)
+	assert.Equal(t, uint(42), receipt.Reference)
+	assert.Equal(t, "undelivered", receipt.Status)
+	assert.Equal(t, "30003", receipt.Error)
+
+	_, err = sender.ParseDeliveryReceipt(newRequest("forged"))
+	assert.ErrorIs(t, err, ErrInvalidReceiptSignature)
+}
+
+func TestHTTPSMSSenderWebhookReceipt(t *testing.T) {
+	smsConfig := testSMSConfig("http://unused", config.SMSDialectWebhook)
+	sender := NewHTTPSMSSender(smsConfig, MustTemplateRegistry())
+
+	body := `{"reference":"42","status":"delivered"}`
+	mac := hmac.New(sha256.New, []byte(smsConfig.AuthToken))
+	mac.Write([]byte(body))
+
+	r := httptest.NewRequest(http.MethodPost, "/notifications/sms/receipts", strings.NewReader(body))
+	r.Header.Set("X-Webhook-Signature", hex.EncodeToString(mac.Sum(nil)))
+	receipt, err := sender.ParseDeliveryReceipt(r)
+	require.NoError(t, err)
+	assert.Equal(t, uint(42), receipt.Reference)
+	assert.Equal(t, "delivered", receipt.Status)
+
+	r = httptest.NewRequest(http.MethodPost, "/notifications/sms/receipts", strings.NewReader(body))
+	r.Header.Set("X-Webhook-Signature", hex.EncodeToString([]byte("forged")))
+	_, err = sender.ParseDeliveryReceipt(r)
+	assert.ErrorIs(t, err, ErrInvalidReceiptSignature)
+}
+
+func TestNormalizeE164(t *testing.T) {
+	tests := []struct {
+		phone    string
+		expected string
+		valid    bool
+	}{
+		{"0912-345-678", "+886912345678", true},
+		{"+44 20 7946 0958", "+442079460958", true},
+		{"0044 20 7946 0958", "+442079460958", true},
+		{"(02) 2345-6789", "+886223456789", true},
+		{"12", "", false},
+		{"+0123456789", "", false},
+		{"phone", "", false},
+	}
+
+	for _, tt := range tests {
+		normalized, err := util.NormalizeE164(tt.phone, "886")
+		if !tt.valid {
+			assert.ErrorIs(t, err, util.ErrInvalidPhoneNumber, tt.phone)
+			continue
+		}
+		require.NoError(t, err, tt.phone)
+		assert.Equal(t, tt.expected, normalized)
+	}
+}
+
+func TestHTTPSMSSenderRefusesReceiptsWithoutAuthToken(t *testing.T) {
+	smsConfig := testSMSConfig("http://unused", config.SMSDialectWebhook)
+	smsConfig.AuthToken = ""
+	sender := NewHTTPSMSSender(smsConfig, MustTemplateRegistry())
+
+	// 沒有金鑰時，以空金鑰計算的簽章也不能通過
+	body := `{"reference":"42","status":"delivered"}`
+	mac := hmac.New(sha256.New, nil)
+	mac.Write([]byte(body))
+
+	r := httptest.NewRequest(http.MethodPost, "/notifications/sms/receipts", strings.NewReader(body))
+	r.Header.Set("X-Webhook-Signature", hex.EncodeToString(mac.Sum(nil)))
+	_, err := sender.ParseDeliveryReceipt(r)
+	assert.ErrorIs(t, err, ErrInvalidReceiptSignature)
+}
@@ -5,28 +5,28 @@ import (
 	"log"
 )
 
-// SMSSender implements NotificationSender for SMS notifications
-type SMSSender struct{}
+// SMSSender implements NotificationSender for SMS notifications by logging them.
+// It is used when no provider is configured; see HTTPSMSSender.
+type SMSSender struct {
+	templates TemplateRegistry
+}
 
-func NewSMSSender() NotificationSender {
-	return &SMSSender{}
+func NewSMSSender(templates TemplateRegistry) NotificationSender {
+	return &SMSSender{templates: templates}
 }
 
-func (s *SMSSender) Send(to, code string) error {
-	message := fmt.Sprintf("Your verification code is: %s. This code will expire in 5 minutes.", code)
-	
-	// TODO: Implement actual SMS sending using providers like:
-	// - Twilio
-	// - AWS SNS
-	// - Vonage (Nexmo)
-	// - Firebase Cloud Messaging
-	
-	log.Printf("Sending SMS to %s: %s", to, message)
-	fmt.Printf("📱 SMS sent to %s\nMessage: %s\n", to, message)
-	
+func (s *SMSSender) Send(message *Message) error {
+	content, err := s.templates.Render(message.TemplateID, message.Locale, message.Variables)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Sending SMS to %s: %s", message.To, content.SMS)
+	fmt.Printf("📱 SMS sent to %s\nMessage: %s\n", message.To, content.SMS)
+
 	return nil
 }
 
 func (s *SMSSender) GetType() string {
 	return "sms"
-}
\ No newline at end of file
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+const refreshTokenBytes = 32
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when a rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("refresh token was already used, all tokens of this login have been revoked")
+)
+
+// ClientInfo describes the device a login comes from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+	// DeviceName is chosen by the client; the label is derived from the user agent when empty
+	DeviceName string
+}
+
+// TokenPair is the access and refresh token handed to the client
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// ExpiresIn is the lifetime of the access token
+	ExpiresIn time.Duration
+}
+
+type TokenService interface {
+	// IssueTokens starts a new session, with its own refresh token family, for a successful login
+	IssueTokens(user *model.User, client ClientInfo) (*TokenPair, error)
+	// Refresh exchanges a refresh token for a new pair. Each refresh token works once.
+	Refresh(refreshToken string) (*TokenPair, error)
+	// Logout revokes the access token and the login session it belongs to
+	Logout(claims *util.Claims) error
+	// LogoutAll revokes every session of the user
+	LogoutAll(userID uint) error
+	// ListSessions lists the active sessions of the user, marking the one making the request
+	ListSessions(userID uint, currentSessionID string) (*dto.SessionListResponse, error)
+	// RevokeSession signs the user out of one session
+	RevokeSession(userID uint, sessionID string) error
+}
+
+type tokenService struct {
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	sessionRepo      repository.SessionRepository
+	revocationRepo   repository.TokenRevocationRepository
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+}
+
+func NewTokenService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, sessionRepo repository.SessionRepository, revocationRepo repository.TokenRevocationRepository, accessTokenTTL, refreshTokenTTL time.Duration) TokenService {
+	return &tokenService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionRepo:      sessionRepo,
+		revocationRepo:   revocationRepo,
+		accessTokenTTL:   accessTokenTTL,
+		refreshTokenTTL:  refreshTokenTTL,
+	}
+}
+
+func (s *tokenService) IssueTokens(user *model.User, client ClientInfo) (*TokenPair, error) {
+	familyID, err := util.GenerateOpaqueToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceLabel := client.DeviceName
+	if deviceLabel == "" {
+		deviceLabel = util.DeviceLabel(client.UserAgent)
+	}
+	if len(client.UserAgent) > 512 {
+		client.UserAgent = client.UserAgent[:512]
+	}
+
+	now := time.Now()
+	err = s.sessionRepo.Create(&model.Session{
+		ID:          familyID,
+		UserID:      user.ID,
+		DeviceLabel: deviceLabel,
+		UserAgent:   client.UserAgent,
+		IP:          client.IP,
+		LastSeenAt:  now,
+		ExpiresAt:   now.Add(s.refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.createRefreshToken(s.refreshTokenRepo, user.ID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newPair(user, familyID, refreshToken)
+}
+
+func (s *tokenService) Refresh(refreshToken string) (*TokenPair, error) {
+	var current *model.RefreshToken
+	var next string
+	// reused is reported after the transaction commits, so that the revocation is persisted
+	var reused bool
+
+	err := s.refreshTokenRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		refreshTokenRepo := s.refreshTokenRepo.WithTx(tx)
+
+		// Lock the token so two concurrent refreshes cannot both rotate it
+		var err error
+		current, err = refreshTokenRepo.FindByHashForUpdate(util.HashToken(refreshToken))
+		if err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		if current.RotatedAt != nil {
+			// Only a stolen copy can present a rotated token; end the whole login
+			reused = true
+			return refreshTokenRepo.RevokeFamily(current.FamilyID)
+		}
+
+		if err := refreshTokenRepo.MarkRotated(current.ID); err != nil {
+			return err
+		}
+
+		next, err = s.createRefreshToken(refreshTokenRepo, current.UserID, current.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		log.Printf("Refresh token reuse detected for user %d, revoking session %s", current.UserID, current.FamilyID)
+		if err := s.endSession(current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if err := s.sessionRepo.Extend(current.FamilyID, time.Now().Add(s.refreshTokenTTL)); err != nil {
+		return nil, err
+	}
+
+	// The role may have changed since the login
+	user, err := s.userRepo.FindByID(current.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.newPair(user, current.FamilyID, next)
+}
+
+func (s *tokenService) Logout(claims *util.Claims) error {
+	if claims.SessionID != "" {
+		if err := s.refreshTokenRepo.RevokeFamily(claims.SessionID); err != nil {
+			return err
+		}
+		if err := s.endSession(claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	var ttl time.Duration
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	return s.revocationRepo.RevokeToken(claims.ID, ttl)
+}
+
+func (s *tokenService) LogoutAll(userID uint) error {
+	familyIDs, err := s.refreshTokenRepo.RevokeAllForUser(userID)
+	if err != nil {
+		return err
+	}
+	// Access tokens of these sessions stay valid until they expire unless blocked
+	for _, familyID := range familyIDs {
+		if err := s.endSession(familyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *tokenService) ListSessions(userID uint, currentSessionID string) (*dto.SessionListResponse, error) {
+	sessions, err := s.sessionRepo.FindActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.SessionListResponse{Sessions: make([]dto.SessionResponse, 0, len(sessions))}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, dto.SessionResponse{
+			ID:          session.ID,
+			DeviceLabel: session.DeviceLabel,
+			UserAgent:   session.UserAgent,
+			IP:          session.IP,
+			CreatedAt:   session.CreatedAt,
+			LastSeenAt:  session.LastSeenAt,
+			Current:     session.ID == currentSessionID,
+		})
+	}
+	return response, nil
+}
+
+func (s *tokenService) RevokeSession(userID uint, sessionID string) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil {
+		return err
+	}
+	// Other users' sessions are reported as missing rather than forbidden
+	if session.UserID != userID || session.RevokedAt != nil {
+		return gorm.ErrRecordNotFound
+	}
+
+	if err := s.refreshTokenRepo.RevokeFamily(sessionID); err != nil {
+		return err
+	}
+	return s.endSession(sessionID)
+}
+
+// endSession marks the session revoked and blocks its access tokens until they expire
+func (s *tokenService) endSession(sessionID string) error {
+	if err := s.sessionRepo.Revoke(sessionID); err != nil {
+		return err
+	}
+	return s.revocationRepo.RevokeSession(sessionID, s.accessTokenTTL)
+}
+
+func (s *tokenService) createRefreshToken(refreshTokenRepo repository.RefreshTokenRepository, userID uint, familyID string) (string, error) {
+	token, err := util.GenerateOpaqueToken(refreshTokenBytes)
+	if err != nil {
+		return "", err
+	}
+
+	err = refreshTokenRepo.Create(&model.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: time.Now().Add(s.refreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *tokenService) newPair(user *model.User, familyID, refreshToken string) (*TokenPair, error) {
+	roleName := "user"
+	if user.Role != nil {
+		roleName = user.Role.Name
+	}
+
+	accessToken, err := util.GenerateToken(user.ID, roleName, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.accessTokenTTL,
+	}, nil
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
+
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet leaves out characters that are easily confused when copied by hand
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeLength   = 10
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	// ErrTwoFactorNotEnrolling is returned when a code is confirmed before an enrollment was started
+	ErrTwoFactorNotEnrolling = errors.New("no authenticator enrollment in progress")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+)
+
+type TwoFactorService interface {
+	Status(userID uint) (*dto.TwoFactorStatusResponse, error)
+	// BeginTOTPEnrollment generates a new secret. It replaces an unconfirmed enrollment.
+	BeginTOTPEnrollment(userID uint) (*dto.TOTPEnrollmentResponse, error)
+	// ConfirmTOTPEnrollment turns two-factor authentication on once the user entered a
+	// first valid code, and issues the recovery codes
+	ConfirmTOTPEnrollment(userID uint, code string) (*dto.RecoveryCodesResponse, error)
+	// DisableTOTP turns two-factor authentication off; it takes a TOTP or recovery code
+	DisableTOTP(userID uint, code string) error
+	// RegenerateRecoveryCodes replaces all recovery codes; it takes a TOTP code
+	RegenerateRecoveryCodes(userID uint, code string) (*dto.RecoveryCodesResponse, error)
+	IsEnabled(userID uint) (bool, error)
+	// VerifyTOTP checks a code of the authenticator app. Each code is accepted once.
+	VerifyTOTP(userID uint, code string) error
+	// VerifyTOTPOrRecoveryCode also accepts, and uses up, a recovery code
+	VerifyTOTPOrRecoveryCode(userID uint, code string) error
+}
+
+type twoFactorService struct {
+	twoFactorRepo repository.TwoFactorRepository
+	userRepo      repository.UserRepository
+	issuer        string
+	encryptionKey string
+	codeSecret    string
+}
+
+func NewTwoFactorService(twoFactorRepo repository.TwoFactorRepository, userRepo repository.UserRepository) TwoFactorService {
+	twoFactorConfig := config.GetTwoFactorConfig()
+	return &twoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		userRepo:      userRepo,
+		issuer:        twoFactorConfig.Issuer,
+		encryptionKey: twoFactorConfig.EncryptionKey,
+		codeSecret:    config.GetVerificationConfig().CodeSecret,
+	}
+}
+
+func (s *twoFactorService) Status(userID uint) (*dto.TwoFactorStatusResponse, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.TwoFactorStatusResponse{Enabled: enabled}
+	if enabled {
+		if response.RecoveryCodesRemaining, err = s.twoFactorRepo.CountUnusedRecoveryCodes(userID); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+func (s *twoFactorService) BeginTOTPEnrollment(userID uint) (*dto.TOTPEnrollmentResponse, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := util.SealSecret(s.encryptionKey, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.SaveTOTP(&model.UserTOTP{UserID: userID, SecretCiphertext: sealed}); err != nil {
+		return nil, err
+	}
+
+	return &dto.TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: util.TOTPURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *twoFactorService) ConfirmTOTPEnrollment(userID uint, code string) (*dto.RecoveryCodesResponse, error) {
+	totp, err := s.twoFactorRepo.FindTOTPByUserID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTwoFactorNotEnrolling
+	}
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	if err := s.checkTOTP(totp, code); err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.ConfirmTOTP(totp.ID); err != nil {
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+func (s *twoFactorService) DisableTOTP(userID uint, code string) error {
+	if err := s.VerifyTOTPOrRecoveryCode(userID, code); err != nil {
+		return err
+	}
+	return s.twoFactorRepo.DeleteForUser(userID)
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint, code string) (*dto.RecoveryCodesResponse, error) {
+	if err := s.VerifyTOTP(userID, code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(userID)
+}
+
+func (s *twoFactorService) IsEnabled(userID uint) (bool, error) {
+	totp, err := s.twoFactorRepo.FindTOTPByUserID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.ConfirmedAt != nil, nil
+}
+
+func (s *twoFactorService) VerifyTOTP(userID uint, code string) error {
+	totp, err := s.confirmedTOTP(userID)
+	if err != nil {
+		return err
+	}
+	return s.checkTOTP(totp, code)
+}
+
+func (s *twoFactorService) VerifyTOTPOrRecoveryCode(userID uint, code string) error {
+	totp, err := s.confirmedTOTP(userID)
+	if err != nil {
+		return err
+	}
+
+	// TOTP codes have six digits, recovery codes are longer
+	if len(strings.TrimSpace(code)) == util.TOTPDigits {
+		return s.checkTOTP(totp, code)
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(userID, s.hashRecoveryCode(userID, code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s *twoFactorService) confirmedTOTP(userID uint) (*model.UserTOTP, error) {
+	totp, err := s.twoFactorRepo.FindTOTPByUserID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return totp, nil
+}
+
+// checkTOTP validates the code and consumes its time step, so it cannot be replayed
+func (s *twoFactorService) checkTOTP(totp *model.UserTOTP, code string) error {
+	secret, err := util.OpenSecret(s.encryptionKey, totp.SecretCiphertext)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+
+	step, ok := util.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	advanced, err := s.twoFactorRepo.AdvanceLastUsedStep(totp.ID, step)
+	if err != nil {
+		return err
+	}
+	if !advanced {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s *twoFactorService) issueRecoveryCodes(userID uint) (*dto.RecoveryCodesResponse, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, s.hashRecoveryCode(userID, code))
+	}
+
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// hashRecoveryCode ignores case and the separator, which users often get wrong
+func (s *twoFactorService) hashRecoveryCode(userID uint, code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return util.HashCode(s.codeSecret, fmt.Sprintf("recovery:%d", userID), normalized)
+}
+
+// generateRecoveryCode returns a code like "k7p2m-x9c4d"
+func generateRecoveryCode() (string, error) {
+	alphabetSize := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	code := make([]byte, 0, recoveryCodeLength+1)
+	for i := 0; i < recoveryCodeLength; i++ {
+		if i == recoveryCodeLength/2 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, recoveryCodeAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
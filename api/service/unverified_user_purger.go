@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/repository"
+)
+
+// UnverifiedUserPurger deletes registrations whose email address was not verified in time
+type UnverifiedUserPurger interface {
+	// PurgeUnverified deletes the registrations that are overdue now
+	PurgeUnverified() (int64, error)
+	// Run purges periodically until ctx is done
+	Run(ctx context.Context)
+}
+
+type unverifiedUserPurger struct {
+	userRepo repository.UserRepository
+	config   config.EmailVerificationConfig
+}
+
+func NewUnverifiedUserPurger(userRepo repository.UserRepository) UnverifiedUserPurger {
+	return &unverifiedUserPurger{
+		userRepo: userRepo,
+		config:   config.GetEmailVerificationConfig(),
+	}
+}
+
+func (p *unverifiedUserPurger) PurgeUnverified() (int64, error) {
+	if p.config.PurgeAfter <= 0 {
+		return 0, nil
+	}
+	return p.userRepo.PurgeUnverified(time.Now().Add(-p.config.PurgeAfter))
+}
+
+func (p *unverifiedUserPurger) Run(ctx context.Context) {
+	if p.config.PurgeAfter <= 0 || p.config.PurgeInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.config.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := p.PurgeUnverified()
+		if err != nil {
+			log.Printf("Failed to purge unverified users: %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("Purged %d unverified users", purged)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"log"
+
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+)
+
+// UserNotifier sends templated notifications to users in their own locale
+type UserNotifier interface {
+	// NotifyUser queues the template for the user's preferred channel. It is used for
+	// informational messages, so failures are logged instead of returned.
+	NotifyUser(userID uint, templateID string, variables map[string]string)
+	// NotifyContact queues the template for one specific contact of the user
+	NotifyContact(contact *model.UserContact, templateID string, variables map[string]string) (*model.NotificationMessage, error)
+}
+
+type userNotifier struct {
+	userRepo            repository.UserRepository
+	contactService      ContactService
+	notificationService NotificationService
+}
+
+func NewUserNotifier(userRepo repository.UserRepository, contactService ContactService, notificationService NotificationService) UserNotifier {
+	return &userNotifier{
+		userRepo:            userRepo,
+		contactService:      contactService,
+		notificationService: notificationService,
+	}
+}
+
+func (n *userNotifier) NotifyUser(userID uint, templateID string, variables map[string]string) {
+	contact, err := n.contactService.ResolveRecipient(userID, "")
+	if err != nil {
+		log.Printf("Failed to resolve contact of user %d for %s: %v", userID, templateID, err)
+		return
+	}
+
+	if _, err := n.NotifyContact(contact, templateID, variables); err != nil {
+		log.Printf("Failed to queue %s for user %d: %v", templateID, userID, err)
+	}
+}
+
+func (n *userNotifier) NotifyContact(contact *model.UserContact, templateID string, variables map[string]string) (*model.NotificationMessage, error) {
+	user, err := n.userRepo.FindByID(contact.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.notificationService.Notify(string(contact.Channel), &Message{
+		To:         contact.Value,
+		TemplateID: templateID,
+		Locale:     user.Locale,
+		Variables:  variables,
+	})
+}
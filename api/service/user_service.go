@@ -2,33 +2,81 @@ package service
 
 import (
 	"errors"
+	"sync"
+	"time"
 
+	"go-gin-template/api/config"
 	"go-gin-template/api/dto"
 	"go-gin-template/api/model"
 	"go-gin-template/api/repository"
 	"go-gin-template/api/util"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// maxLoginChallengeFailures is the number of wrong codes after which a login challenge is dropped
+const maxLoginChallengeFailures = 5
+
+var (
+	// ErrInvalidCredentials is returned for a wrong password, an unknown email and a locked
+	// account alike, so the response does not tell them apart
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidLoginChallenge is returned for an unknown, expired or exhausted challenge token
+	ErrInvalidLoginChallenge = errors.New("invalid or expired login challenge")
+)
+
+// dummyPasswordHash is compared against when the email has no account, so that a login
+// takes as long as one with a wrong password
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password for timing"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
 type UserService interface {
 	Register(req *dto.RegisterRequest) (*dto.UserResponse, error)
-	Login(req *dto.LoginRequest) (*dto.LoginResponse, error)
+	// Login authenticates the user and starts a session for the client's device. When
+	// two-factor authentication is on, it returns a challenge token instead of tokens.
+	// Repeated failures are answered with a *LoginThrottledError.
+	Login(req *dto.LoginRequest, client ClientInfo) (*dto.LoginResponse, error)
+	// LoginWithTwoFactor completes a login challenge with a TOTP or recovery code
+	LoginWithTwoFactor(req *dto.TwoFactorLoginRequest) (*dto.LoginResponse, error)
 	GetUserByID(id uint) (*dto.UserResponse, error)
 	UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.UserResponse, error)
+	// UnlockUser lifts a login lockout and forgets the user's failed attempts
+	UnlockUser(id uint) error
 }
 
 type userService struct {
-	userRepo         repository.UserRepository
-	passwordRepo     repository.UserPasswordRepository
-	accountService   AccountService
+	userRepo          repository.UserRepository
+	passwordRepo      repository.UserPasswordRepository
+	contactService    ContactService
+	userNotifier      UserNotifier
+	tokenService      TokenService
+	twoFactorService  TwoFactorService
+	challengeRepo     repository.LoginChallengeRepository
+	challengeTTL      time.Duration
+	passwordPolicy    *util.PasswordPolicy
+	loginProtection   LoginProtectionService
+	emailVerification EmailVerificationService
 }
 
-func NewUserService(userRepo repository.UserRepository, passwordRepo repository.UserPasswordRepository, accountService AccountService) UserService {
+func NewUserService(userRepo repository.UserRepository, passwordRepo repository.UserPasswordRepository, contactService ContactService, userNotifier UserNotifier, tokenService TokenService, twoFactorService TwoFactorService, challengeRepo repository.LoginChallengeRepository, passwordPolicy *util.PasswordPolicy, loginProtection LoginProtectionService, emailVerification EmailVerificationService) UserService {
 	return &userService{
-		userRepo:       userRepo,
-		passwordRepo:   passwordRepo,
-		accountService: accountService,
+		userRepo:          userRepo,
+		passwordRepo:      passwordRepo,
+		contactService:    contactService,
+		userNotifier:      userNotifier,
+		tokenService:      tokenService,
+		twoFactorService:  twoFactorService,
+		challengeRepo:     challengeRepo,
+		challengeTTL:      config.GetTwoFactorConfig().ChallengeTTL,
+		passwordPolicy:    passwordPolicy,
+		loginProtection:   loginProtection,
+		emailVerification: emailVerification,
 	}
 }
 
@@ -38,18 +86,28 @@ func (s *userService) Register(req *dto.RegisterRequest) (*dto.UserResponse, err
 		return nil, errors.New("email already registered")
 	}
 
+	// A password that breaks the policy returns a *util.PasswordPolicyError
+	if err := s.passwordPolicy.Check(req.Password, req.Email, req.Name); err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create user
+	// Create user; it stays pending until the email address is verified
 	user := &model.User{
 		Email:   req.Email,
 		Name:    req.Name,
 		Phone:   req.Phone,
 		Address: req.Address,
+		Locale:  req.Locale,
+		Status:  model.UserStatusPendingVerification,
+	}
+	if user.Locale == "" {
+		user.Locale = DefaultLocale
 	}
 
 	// Create user first
@@ -61,7 +119,7 @@ func (s *userService) Register(req *dto.RegisterRequest) (*dto.UserResponse, err
 	password := &model.UserPassword{
 		UserID:         user.ID,
 		HashedPassword: string(hashedPassword),
-		IsActive:      true,
+		IsActive:       true,
 	}
 
 	// Set password on user for response
@@ -76,49 +134,178 @@ func (s *userService) Register(req *dto.RegisterRequest) (*dto.UserResponse, err
 		return nil, err
 	}
 
-	// Create default account for new user
-	_, err = s.accountService.CreateDefaultAccount(user.ID)
-	if err != nil {
+	// Record the email and phone as contacts for verification codes
+	if err := s.contactService.SyncFromUser(user); err != nil {
+		return nil, err
+	}
+
+	// The default account is opened once the email address is verified
+	if err := s.emailVerification.SendVerification(user); err != nil {
 		return nil, err
 	}
 
 	return toUserResponse(user), nil
 }
 
-func (s *userService) Login(req *dto.LoginRequest) (*dto.LoginResponse, error) {
-	user, err := s.userRepo.FindByEmail(req.Email)
+func (s *userService) Login(req *dto.LoginRequest, client ClientInfo) (*dto.LoginResponse, error) {
+	if err := s.loginProtection.Check(req.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	// Every path below runs exactly one bcrypt comparison, so the response time does not
+	// reveal whether the email exists
+	user, hashedPassword := s.findLoginCredentials(req.Email)
+	passwordErr := bcrypt.CompareHashAndPassword(hashedPassword, []byte(req.Password))
+
+	// A locked account is refused even with the right password, with the same error
+	locked, err := s.loginProtection.IsLocked(req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || passwordErr != nil || locked {
+		var userID uint
+		if user != nil {
+			userID = user.ID
+		}
+		if err := s.loginProtection.RecordFailure(req.Email, client.IP, userID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	// With two-factor authentication on, the password alone only earns a challenge, and the
+	// failures are only forgotten once the second factor is verified too
+	enabled, err := s.twoFactorService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return s.startTwoFactorLogin(user, client)
+	}
+
+	if err := s.loginProtection.RecordSuccess(req.Email); err != nil {
+		return nil, err
+	}
+	return s.completeLogin(user, client)
+}
+
+func (s *userService) LoginWithTwoFactor(req *dto.TwoFactorLoginRequest) (*dto.LoginResponse, error) {
+	challengeHash := util.HashToken(req.ChallengeToken)
+	challenge, err := s.challengeRepo.Find(challengeHash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, ErrInvalidLoginChallenge
+	}
+
+	// An account locked since the password was checked cannot finish the login
+	locked, err := s.loginProtection.IsLocked(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		if err := s.challengeRepo.Delete(challengeHash); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidLoginChallenge
+	}
+
+	if err := s.twoFactorService.VerifyTOTPOrRecoveryCode(challenge.UserID, req.Code); err != nil {
+		if !errors.Is(err, ErrInvalidTwoFactorCode) {
+			return nil, err
+		}
+		// Wrong codes count toward the same delays and lockout as wrong passwords
+		if recordErr := s.loginProtection.RecordFailure(user.Email, challenge.IP, user.ID); recordErr != nil {
+			return nil, recordErr
+		}
+		// A challenge allows a few guesses; after that the password must be entered again
+		failures, recordErr := s.challengeRepo.RecordFailure(challengeHash)
+		if recordErr != nil {
+			return nil, recordErr
+		}
+		if failures >= maxLoginChallengeFailures {
+			if err := s.challengeRepo.Delete(challengeHash); err != nil {
+				return nil, err
+			}
+		}
+		return nil, err
+	}
+
+	// The challenge is single use
+	if err := s.challengeRepo.Delete(challengeHash); err != nil {
+		return nil, err
+	}
+
+	if err := s.loginProtection.RecordSuccess(user.Email); err != nil {
+		return nil, err
+	}
+	return s.completeLogin(user, ClientInfo{
+		IP:         challenge.IP,
+		UserAgent:  challenge.UserAgent,
+		DeviceName: challenge.DeviceName,
+	})
+}
+
+// findLoginCredentials returns the user and the hash of their active password. When there
+// is no such user, or no active password, it returns nil and a hash no password matches.
+func (s *userService) findLoginCredentials(email string) (*model.User, []byte) {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return nil, dummyPasswordHash()
 	}
 
-	// Get active password
 	activePassword, err := s.passwordRepo.FindActiveByUserID(user.ID)
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return nil, dummyPasswordHash()
 	}
 
-	// Set active password on user
 	user.Password = activePassword
+	return user, []byte(activePassword.HashedPassword)
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password.HashedPassword), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid email or password")
+// startTwoFactorLogin stores the verified login and returns the token that refers to it
+func (s *userService) startTwoFactorLogin(user *model.User, client ClientInfo) (*dto.LoginResponse, error) {
+	challengeToken, err := util.GenerateOpaqueToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get role name for token
-	roleName := "user"
-	if user.Role != nil {
-		roleName = user.Role.Name
+	err = s.challengeRepo.Create(util.HashToken(challengeToken), &repository.LoginChallenge{
+		UserID:     user.ID,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		DeviceName: client.DeviceName,
+	}, s.challengeTTL)
+	if err != nil {
+		return nil, err
 	}
 
-	// Generate JWT token
-	token, err := util.GenerateToken(user.ID, roleName)
+	return &dto.LoginResponse{MFARequired: true, ChallengeToken: challengeToken}, nil
+}
+
+// completeLogin issues an access token and the refresh token of a new session
+func (s *userService) completeLogin(user *model.User, client ClientInfo) (*dto.LoginResponse, error) {
+	tokens, err := s.tokenService.IssueTokens(user, client)
 	if err != nil {
 		return nil, err
 	}
 
+	s.userNotifier.NotifyUser(user.ID, TemplateLoginAlert, map[string]string{
+		"Time": time.Now().Format(notificationTimeLayout),
+		"IP":   client.IP,
+	})
+
+	tokenResponse := ToTokenResponse(tokens)
 	return &dto.LoginResponse{
-		User:  *toUserResponse(user),
-		Token: token,
+		User:          toUserResponse(user),
+		TokenResponse: &tokenResponse,
 	}, nil
 }
 
@@ -130,6 +317,14 @@ func (s *userService) GetUserByID(id uint) (*dto.UserResponse, error) {
 	return toUserResponse(user), nil
 }
 
+func (s *userService) UnlockUser(id uint) error {
+	user, err := s.userRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	return s.loginProtection.Unlock(user.Email)
+}
+
 func (s *userService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.UserResponse, error) {
 	user, err := s.userRepo.FindByID(id)
 	if err != nil {
@@ -145,11 +340,18 @@ func (s *userService) UpdateUser(id uint, req *dto.UpdateUserRequest) (*dto.User
 	if req.Address != "" {
 		user.Address = req.Address
 	}
+	if req.Locale != "" {
+		user.Locale = req.Locale
+	}
 
 	if err := s.userRepo.Update(user); err != nil {
 		return nil, err
 	}
 
+	if err := s.contactService.SyncFromUser(user); err != nil {
+		return nil, err
+	}
+
 	return toUserResponse(user), nil
 }
 
@@ -167,5 +369,17 @@ func toUserResponse(user *model.User) *dto.UserResponse {
 		Phone:   user.Phone,
 		Address: user.Address,
 		Role:    roleName,
+		Locale:  user.Locale,
+		Status:  string(user.Status),
+	}
+}
+
+// ToTokenResponse converts a token pair to its response body
+func ToTokenResponse(tokens *TokenPair) dto.TokenResponse {
+	return dto.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
 	}
 }
@@ -1,45 +1,88 @@
 package service
 
 import (
-	"crypto/rand"
 	"errors"
+	"fmt"
+	"go-gin-template/api/config"
 	"go-gin-template/api/model"
+	"go-gin-template/api/policy"
 	"go-gin-template/api/repository"
+	"go-gin-template/api/util"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	verificationCodeDigits = 6
+	verificationCodeTTL    = 5 * time.Minute
+	// VerificationResendCooldown is the minimum time between two codes sent for the same verification
+	VerificationResendCooldown = time.Minute
+	maxVerificationResends     = 3
 )
 
+// CooldownError is returned when a code is resent before the cooldown elapsed
+type CooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("verification code was sent recently, retry in %d seconds", int(e.RetryAfter.Seconds()+0.5))
+}
+
 type VerificationService interface {
-	GenerateVerification(userID uint, transactionID uint, notificationType string, contactInfo string) (*model.TransactionVerification, error)
+	// GenerateVerification creates a verification for the transaction. An empty channel
+	// selects the user's preferred channel; the otp channel checks the user's authenticator
+	// app and returns a challenge without code or recipient.
+	GenerateVerification(userID uint, transactionID uint, channel string) (*VerificationChallenge, error)
 	VerifyCode(userID uint, verificationID uint, code string) (*VerificationResult, error)
+	// ResendCode replaces the code of a pending verification, invalidating the previous one
+	ResendCode(userID uint, verificationID uint) (*VerificationChallenge, error)
+}
+
+// VerificationChallenge is a freshly issued code and the contact it must be delivered to
+type VerificationChallenge struct {
+	Verification *model.TransactionVerification
+	Code         string
+	Recipient    *model.UserContact
 }
 
 type VerificationResult struct {
-	Verified      bool
-	TransactionID uint
+	Verified          bool
+	TransactionID     uint
+	TransactionStatus model.TransactionStatus
 }
 
 type verificationService struct {
 	verificationRepo repository.VerificationRepository
 	transactionRepo  repository.TransactionRepository
+	accountService   AccountService
+	contactService   ContactService
+	twoFactorService TwoFactorService
+	codeSecret       string
 }
 
-func NewVerificationService(verificationRepo repository.VerificationRepository, transactionRepo repository.TransactionRepository) VerificationService {
+func NewVerificationService(verificationRepo repository.VerificationRepository, transactionRepo repository.TransactionRepository, accountService AccountService, contactService ContactService, twoFactorService TwoFactorService) VerificationService {
 	return &verificationService{
 		verificationRepo: verificationRepo,
 		transactionRepo:  transactionRepo,
+		accountService:   accountService,
+		contactService:   contactService,
+		twoFactorService: twoFactorService,
+		codeSecret:       config.GetVerificationConfig().CodeSecret,
 	}
 }
 
-func (s *verificationService) GenerateVerification(userID uint, transactionID uint, notificationType string, contactInfo string) (*model.TransactionVerification, error) {
-	// Verify the transaction exists and belongs to the user
+func (s *verificationService) GenerateVerification(userID uint, transactionID uint, channel string) (*VerificationChallenge, error) {
+	// Verify the transaction exists and moves money out of the user's account. A foreign
+	// transaction is reported as missing, so transaction IDs cannot be probed.
 	transaction, err := s.transactionRepo.FindByID(transactionID)
 	if err != nil {
 		return nil, errors.New("transaction not found")
 	}
-
-	// Note: Transaction model doesn't have UserID field
-	// We need to verify ownership through account relationships
-	// For now, we'll proceed without this check
+	if err := policy.Authorize(policy.Owner(userID), policy.ActionVerify, policy.TransactionOf(transaction)); err != nil {
+		return nil, errors.New("transaction not found")
+	}
 
 	// Check if transaction is in pending status
 	if transaction.Status != model.TransactionStatusPending {
@@ -52,79 +95,241 @@ func (s *verificationService) GenerateVerification(userID uint, transactionID ui
 		return nil, errors.New("verification already exists for this transaction")
 	}
 
-	// Generate verification code
-	code, err := generateVerificationCode()
-	if err != nil {
-		return nil, errors.New("failed to generate verification code")
-	}
-
-	// Create verification record
+	now := time.Now()
 	verification := &model.TransactionVerification{
 		TransactionID: transactionID,
 		UserID:        userID,
-		Code:          code,
-		Type:          model.VerificationType(notificationType),
+		Type:          model.VerificationType(channel),
 		Status:        model.VerificationStatusPending,
-		ExpiresAt:     time.Now().Add(5 * time.Minute), // 5 minutes expiry
+		ExpiresAt:     now.Add(verificationCodeTTL),
+		LastSentAt:    now,
 	}
 
+	// OTP verifications are checked against the authenticator app; there is nothing to send
+	if verification.Type == model.VerificationTypeOTP {
+		enabled, err := s.twoFactorService.IsEnabled(userID)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return nil, ErrTwoFactorNotEnabled
+		}
+
+		if err := s.verificationRepo.Create(verification); err != nil {
+			return nil, err
+		}
+		return &VerificationChallenge{Verification: verification}, nil
+	}
+
+	// Resolve where the code goes before issuing it
+	recipient, err := s.contactService.ResolveRecipient(userID, model.ContactChannel(channel))
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate verification code
+	code, err := util.GenerateNumericCode(verificationCodeDigits)
+	if err != nil {
+		return nil, errors.New("failed to generate verification code")
+	}
+
+	// Create verification record; only the hash of the code is stored
+	verification.CodeHash = s.hashCode(transactionID, code)
+	verification.Type = model.VerificationType(recipient.Channel)
+
 	if err := s.verificationRepo.Create(verification); err != nil {
 		return nil, err
 	}
 
-	return verification, nil
+	return &VerificationChallenge{Verification: verification, Code: code, Recipient: recipient}, nil
 }
 
 func (s *verificationService) VerifyCode(userID uint, verificationID uint, code string) (*VerificationResult, error) {
-	// Get verification record
-	verification, err := s.verificationRepo.FindByID(verificationID)
-	if err != nil {
-		return nil, errors.New("verification not found")
-	}
+	var verification *model.TransactionVerification
+	// failure is reported after the transaction commits, so that attempt counters are persisted
+	var failure error
 
-	// Verify ownership through the verification record's UserID field
-	if verification.UserID != userID {
-		return nil, errors.New("unauthorized access to verification")
+	// A TOTP code is checked before the verification is locked, since accepting it records
+	// the used time step on the user's enrollment
+	var otpMatches bool
+	if pending, err := s.verificationRepo.FindByID(verificationID); err == nil &&
+		pending.Type == model.VerificationTypeOTP && pending.Status == model.VerificationStatusPending &&
+		policy.Authorize(policy.Owner(userID), policy.ActionVerify, policy.VerificationOf(pending)) == nil {
+		if otpMatches, err = s.otpMatches(userID, code); err != nil {
+			return nil, err
+		}
 	}
 
-	// Check if verification is still valid
-	if verification.Status != model.VerificationStatusPending {
-		return nil, errors.New("verification is not in pending status")
-	}
+	// The transfer runs in the same transaction as the verification. If it fails for any
+	// reason other than the balance, nothing is committed: the verification stays pending
+	// and the user can submit the code again.
+	var transaction *model.Transaction
+	var notify func()
+	err := runInTransaction(s.transactionRepo.GetDB(), func(tx *gorm.DB) error {
+		verificationRepo := s.verificationRepo.WithTx(tx)
+		failure = nil
 
-	if time.Now().After(verification.ExpiresAt) {
-		// Mark as expired
-		s.verificationRepo.UpdateStatus(verificationID, model.VerificationStatusExpired)
-		return nil, errors.New("verification code has expired")
-	}
+		// Lock the verification so concurrent attempts are counted one by one
+		var err error
+		verification, err = verificationRepo.FindByIDForUpdate(verificationID)
+		if err != nil {
+			return errors.New("verification not found")
+		}
 
-	// Verify the code
-	if verification.Code != code {
-		return nil, errors.New("invalid verification code")
-	}
+		if err := policy.Authorize(policy.Owner(userID), policy.ActionVerify, policy.VerificationOf(verification)); err != nil {
+			return err
+		}
+
+		// Check if verification is still valid
+		if verification.Status != model.VerificationStatusPending {
+			return errors.New("verification is not in pending status")
+		}
+
+		if time.Now().After(verification.ExpiresAt) {
+			// Mark as expired
+			failure = errors.New("verification code has expired")
+			return verificationRepo.UpdateStatus(verificationID, model.VerificationStatusExpired)
+		}
+
+		// Verify the code
+		matches := otpMatches
+		if verification.Type != model.VerificationTypeOTP {
+			matches = util.CompareCodeHash(s.codeSecret, verificationScope(verification.TransactionID), code, verification.CodeHash)
+		}
+		if !matches {
+			verification.AttemptCount++
+			failure = fmt.Errorf("invalid verification code, attempts remaining: %d", verification.MaxAttempts-verification.AttemptCount)
+
+			if verification.AttemptCount >= verification.MaxAttempts {
+				// Too many failures; cancel the verification and the transfer it guards
+				verification.Status = model.VerificationStatusCanceled
+				failure = errors.New("too many failed attempts, the transfer has been canceled")
+				err := s.transactionRepo.WithTx(tx).TransitionStatus(verification.TransactionID,
+					model.TransactionStatusPending, model.TransactionStatusCanceled)
+				if err != nil && !errors.Is(err, repository.ErrStatusTransition) {
+					return err
+				}
+			}
+			return verificationRepo.Update(verification)
+		}
+
+		// Mark the verification and its transaction as verified together
+		now := time.Now()
+		verification.Status = model.VerificationStatusVerified
+		verification.VerifiedAt = &now
+		if err := verificationRepo.Update(verification); err != nil {
+			return err
+		}
+
+		err = s.transactionRepo.WithTx(tx).TransitionStatus(verification.TransactionID,
+			model.TransactionStatusPending, model.TransactionStatusVerified)
+		if err != nil {
+			return err
+		}
 
-	// Mark verification as verified
-	if err := s.verificationRepo.UpdateStatus(verificationID, model.VerificationStatusVerified); err != nil {
+		// Move the funds now that the user confirmed the transfer
+		transaction, notify, err = s.accountService.ExecuteTransfer(tx, verification.TransactionID)
+		if errors.Is(err, ErrInsufficientBalance) {
+			// The transfer failed for good; commit that outcome with the verification
+			failure = err
+			return nil
+		}
+		return err
+	})
+	if errors.Is(err, repository.ErrStatusTransition) {
+		return nil, errors.New("transaction is no longer pending")
+	}
+	if err != nil {
 		return nil, err
 	}
+	if failure != nil {
+		return nil, failure
+	}
+	notify()
 
 	return &VerificationResult{
-		Verified:      true,
-		TransactionID: verification.TransactionID,
+		Verified:          true,
+		TransactionID:     verification.TransactionID,
+		TransactionStatus: transaction.Status,
 	}, nil
 }
 
-func generateVerificationCode() (string, error) {
-	const digits = "0123456789"
-	b := make([]byte, 6)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+func (s *verificationService) ResendCode(userID uint, verificationID uint) (*VerificationChallenge, error) {
+	var verification *model.TransactionVerification
+	var recipient *model.UserContact
+	var code string
+
+	err := s.transactionRepo.GetDB().Transaction(func(tx *gorm.DB) error {
+		verificationRepo := s.verificationRepo.WithTx(tx)
+
+		var err error
+		verification, err = verificationRepo.FindByIDForUpdate(verificationID)
+		if err != nil {
+			return errors.New("verification not found")
+		}
+
+		if err := policy.Authorize(policy.Owner(userID), policy.ActionVerify, policy.VerificationOf(verification)); err != nil {
+			return err
+		}
+
+		if verification.Status != model.VerificationStatusPending {
+			return errors.New("verification is not in pending status")
+		}
+
+		if verification.Type == model.VerificationTypeOTP {
+			return errors.New("OTP verifications use the code from the authenticator app and cannot be resent")
+		}
+
+		if wait := VerificationResendCooldown - time.Since(verification.LastSentAt); wait > 0 {
+			return &CooldownError{RetryAfter: wait}
+		}
+
+		if verification.ResendCount >= maxVerificationResends {
+			return errors.New("maximum number of resends reached")
+		}
+
+		// The contact may have changed since the first code was sent
+		recipient, err = s.contactService.ResolveRecipient(userID, model.ContactChannel(verification.Type))
+		if err != nil {
+			return err
+		}
+
+		code, err = util.GenerateNumericCode(verificationCodeDigits)
+		if err != nil {
+			return errors.New("failed to generate verification code")
+		}
+
+		// Replacing the hash invalidates the previous code. Failed attempts are kept,
+		// so resending does not grant extra guesses.
+		now := time.Now()
+		verification.CodeHash = s.hashCode(verification.TransactionID, code)
+		verification.ExpiresAt = now.Add(verificationCodeTTL)
+		verification.LastSentAt = now
+		verification.ResendCount++
+
+		return verificationRepo.Update(verification)
+	})
+	if err != nil {
+		return nil, err
 	}
-	
-	code := make([]byte, 6)
-	for i, v := range b {
-		code[i] = digits[v%byte(len(digits))]
+
+	return &VerificationChallenge{Verification: verification, Code: code, Recipient: recipient}, nil
+}
+
+// otpMatches checks a code of the user's authenticator app
+func (s *verificationService) otpMatches(userID uint, code string) (bool, error) {
+	err := s.twoFactorService.VerifyTOTP(userID, code)
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		return false, nil
 	}
-	
-	return string(code), nil
-}
\ No newline at end of file
+	return err == nil, err
+}
+
+func (s *verificationService) hashCode(transactionID uint, code string) string {
+	return util.HashCode(s.codeSecret, verificationScope(transactionID), code)
+}
+
+// verificationScope binds a code hash to the transaction it verifies
+func verificationScope(transactionID uint) string {
+	return fmt.Sprintf("transaction:%d", transactionID)
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashCode returns a keyed HMAC-SHA256 hash of a one-time code. The scope binds the
+// hash to its context (e.g. a transaction), so equal codes never produce equal hashes.
+func HashCode(secret, scope, code string) string {
+	return hex.EncodeToString(codeMAC(secret, scope, code))
+}
+
+// CompareCodeHash reports whether code matches hash, in constant time
+func CompareCodeHash(secret, scope, code, hash string) bool {
+	expected, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(codeMAC(secret, scope, code), expected)
+}
+
+func codeMAC(secret, scope, code string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(code))
+	return mac.Sum(nil)
+}
+
+// HashToken returns the SHA-256 of a random token. Unlike codes, tokens have enough
+// entropy that an unkeyed hash is safe to store.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,51 +1,408 @@
 package util
 
 import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Supported JWT signing algorithms
 const (
-	jwtSecret = "your-secret-key" // TODO: Move to environment variable
-	tokenExpiry = 24 * time.Hour
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
 )
 
+// ErrKeyManagerNotConfigured is returned when tokens are used before SetKeyManager was called
+var ErrKeyManagerNotConfigured = errors.New("JWT signing keys are not configured")
+
+// Claims of an access token. RegisteredClaims.ID is the jti, used to revoke a single token.
 type Claims struct {
 	UserID uint   `json:"user_id"`
 	Role   string `json:"role"`
+	// SessionID is the refresh token family the token was issued for
+	SessionID string `json:"sid,omitempty"`
+	// ClientID and Scope are set on tokens issued to third-party apps through OAuth
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(userID uint, role string) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpiry)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// IsClientToken reports whether the token was issued to a third-party app
+func (c *Claims) IsClientToken() bool {
+	return c.ClientID != ""
+}
+
+// HasScope reports whether the token of a third-party app was granted the scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == scope {
+			return true
+		}
 	}
+	return false
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtSecret))
+// JWTKeyConfig describes one key of the keyring. HS256 keys use Secret; RS256 and EdDSA
+// keys are PEM encoded, inline in PEM or read from PEMFile. The current key needs the
+// private key, previous keys may be public keys only.
+type JWTKeyConfig struct {
+	Algorithm string
+	// ID is the kid header; when empty it is derived from the key, so it stays stable
+	// when the key later moves to the previous keys
+	ID      string
+	Secret  string
+	PEM     string
+	PEMFile string
 }
 
-// ParseToken parses a JWT token and returns the claims
-func ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(jwtSecret), nil
-	})
+// signingKey is a loaded key of the keyring
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	// signKey is nil for keys that only verify
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeyManager signs tokens with the current key and verifies them against the current
+// and previous keys, so keys can be rotated without invalidating issued tokens
+type KeyManager struct {
+	current *signingKey
+	keys    map[string]*signingKey
+	// order lists the key IDs, current first, for a stable JWKS
+	order  []string
+	expiry time.Duration
+}
+
+// JSONWebKey is the public part of a key as published in a JWKS (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 curve and public key
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the document served at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewKeyManager loads the current signing key and the previous keys that are still accepted
+func NewKeyManager(current JWTKeyConfig, previous []JWTKeyConfig, expiry time.Duration) (*KeyManager, error) {
+	currentKey, err := loadSigningKey(current)
+	if err != nil {
+		return nil, fmt.Errorf("current JWT key: %w", err)
+	}
+	if currentKey.signKey == nil {
+		return nil, errors.New("current JWT key: a private key is required for signing")
+	}
+
+	manager := &KeyManager{
+		current: currentKey,
+		keys:    map[string]*signingKey{currentKey.id: currentKey},
+		order:   []string{currentKey.id},
+		expiry:  expiry,
+	}
+
+	for i, keyConfig := range previous {
+		key, err := loadSigningKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("previous JWT key %d: %w", i+1, err)
+		}
+		if _, exists := manager.keys[key.id]; exists {
+			continue
+		}
+		manager.keys[key.id] = key
+		manager.order = append(manager.order, key.id)
+	}
 
+	return manager, nil
+}
+
+// Sign issues a token for the claims with the current key and its kid header
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(m.current.method, claims)
+	token.Header["kid"] = m.current.id
+	return token.SignedString(m.current.signKey)
+}
+
+// Parse verifies the token against the key named by its kid header and decodes its claims
+func (m *KeyManager) Parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := m.keys[kid]
+		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		// The algorithm is fixed by the key, never chosen by the token
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing algorithm")
+		}
+		return key.verifyKey, nil
+	}, jwt.WithValidMethods([]string{JWTAlgorithmHS256, JWTAlgorithmRS256, JWTAlgorithmEdDSA}))
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// Expiry is the lifetime of issued access tokens
+func (m *KeyManager) Expiry() time.Duration {
+	return m.expiry
+}
+
+// JWKS returns the public keys of the keyring. HS256 secrets are never published.
+func (m *KeyManager) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, id := range m.order {
+		key := m.keys[id]
+		switch publicKey := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JSONWebKey{
+				KeyType:   "RSA",
+				KeyID:     key.id,
+				Use:       "sig",
+				Algorithm: key.method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JSONWebKey{
+				KeyType:   "OKP",
+				KeyID:     key.id,
+				Use:       "sig",
+				Algorithm: key.method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+	return set
+}
+
+func loadSigningKey(keyConfig JWTKeyConfig) (*signingKey, error) {
+	algorithm := keyConfig.Algorithm
+	if algorithm == "" && keyConfig.Secret != "" {
+		algorithm = JWTAlgorithmHS256
+	}
+
+	if algorithm == JWTAlgorithmHS256 {
+		if keyConfig.Secret == "" {
+			return nil, errors.New("a secret is required for HS256")
+		}
+		secret := []byte(keyConfig.Secret)
+		return &signingKey{
+			id:        keyIDOrDefault(keyConfig.ID, hmacKeyID(secret)),
+			method:    jwt.SigningMethodHS256,
+			signKey:   secret,
+			verifyKey: secret,
+		}, nil
+	}
+
+	pemData := []byte(keyConfig.PEM)
+	if keyConfig.PEMFile != "" {
+		var err error
+		if pemData, err = os.ReadFile(keyConfig.PEMFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(pemData) == 0 {
+		return nil, fmt.Errorf("a PEM key is required for %s", algorithm)
+	}
+
+	var key *signingKey
+	var err error
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		key, err = loadRSAKey(pemData)
+	case JWTAlgorithmEdDSA:
+		key, err = loadEdDSAKey(pemData)
+	case "":
+		// Previous keys may omit the algorithm; it follows from the key type
+		if key, err = loadEdDSAKey(pemData); err != nil {
+			key, err = loadRSAKey(pemData)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm '%s'", algorithm)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	key.id = keyIDOrDefault(keyConfig.ID, key.id)
+	return key, nil
+}
+
+func loadRSAKey(pemData []byte) (*signingKey, error) {
+	key := &signingKey{method: jwt.SigningMethodRS256}
+	var publicKey *rsa.PublicKey
+	if isPrivatePEM(pemData) {
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey = privateKey
+		publicKey = &privateKey.PublicKey
+	} else {
+		var err error
+		if publicKey, err = jwt.ParseRSAPublicKeyFromPEM(pemData); err != nil {
+			return nil, err
+		}
+	}
+
+	key.verifyKey = publicKey
+	key.id = thumbprint(map[string]string{
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+	})
+	return key, nil
+}
+
+func loadEdDSAKey(pemData []byte) (*signingKey, error) {
+	key := &signingKey{method: jwt.SigningMethodEdDSA}
+	var publicKey ed25519.PublicKey
+	if isPrivatePEM(pemData) {
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an Ed25519 private key")
+		}
+		key.signKey = privateKey
+		publicKey = privateKey.Public().(ed25519.PublicKey)
+	} else {
+		parsed, err := jwt.ParseEdPublicKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if publicKey, ok = parsed.(ed25519.PublicKey); !ok {
+			return nil, errors.New("not an Ed25519 public key")
+		}
+	}
+
+	key.verifyKey = publicKey
+	key.id = thumbprint(map[string]string{
+		"crv": "Ed25519",
+		"kty": "OKP",
+		"x":   base64.RawURLEncoding.EncodeToString(publicKey),
+	})
+	return key, nil
+}
+
+func isPrivatePEM(pemData []byte) bool {
+	return strings.Contains(string(pemData), "PRIVATE KEY-----")
+}
+
+// thumbprint is the RFC 7638 JWK thumbprint of the required public key members
+func thumbprint(members map[string]string) string {
+	// json.Marshal sorts map keys, as the RFC requires
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// hmacKeyID derives a kid from a secret without revealing it
+func hmacKeyID(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("jwt-key-id"))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func keyIDOrDefault(id, derived string) string {
+	if id != "" {
+		return id
+	}
+	return derived
+}
+
+var (
+	keyManagerMu sync.RWMutex
+	keyManager   *KeyManager
+)
+
+// SetKeyManager installs the keyring used by GenerateToken and ParseToken
+func SetKeyManager(manager *KeyManager) {
+	keyManagerMu.Lock()
+	defer keyManagerMu.Unlock()
+	keyManager = manager
+}
+
+// GetKeyManager returns the installed keyring, or nil before SetKeyManager was called
+func GetKeyManager() *KeyManager {
+	keyManagerMu.RLock()
+	defer keyManagerMu.RUnlock()
+	return keyManager
+}
+
+// GenerateToken generates a JWT access token for a user's session
+func GenerateToken(userID uint, role, sessionID string) (string, error) {
+	return generateToken(Claims{UserID: userID, Role: role, SessionID: sessionID})
+}
+
+// GenerateClientToken generates a JWT access token for a third-party app acting for a user.
+// familyID is the OAuth refresh token family, empty when no refresh token was issued.
+func GenerateClientToken(userID uint, clientID, scope, familyID string) (string, error) {
+	return generateToken(Claims{UserID: userID, SessionID: familyID, ClientID: clientID, Scope: scope})
+}
+
+func generateToken(claims Claims) (string, error) {
+	manager := GetKeyManager()
+	if manager == nil {
+		return "", ErrKeyManagerNotConfigured
+	}
+
+	jti, err := GenerateOpaqueToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		ExpiresAt: jwt.NewNumericDate(now.Add(manager.Expiry())),
+		IssuedAt:  jwt.NewNumericDate(now),
 	}
 
-	return nil, errors.New("invalid token")
+	return manager.Sign(claims)
+}
+
+// ParseToken parses a JWT token and returns the claims
+func ParseToken(tokenString string) (*Claims, error) {
+	manager := GetKeyManager()
+	if manager == nil {
+		return nil, ErrKeyManagerNotConfigured
+	}
+
+	claims := &Claims{}
+	if err := manager.Parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
 }
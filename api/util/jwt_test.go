@@ -0,0 +1,177 @@
+package util
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 產生 PEM 格式的私鑰與公鑰
+func testKeyPair(t *testing.T, algorithm string) (privatePEM, publicPEM string) {
+	var privateKey, publicKey interface{}
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		privateKey, publicKey = key, &key.PublicKey
+	case JWTAlgorithmEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		privateKey, publicKey = private, public
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+}
+
+func TestKeyManagerSignsAndVerifies(t *testing.T) {
+	rsaPrivate, _ := testKeyPair(t, JWTAlgorithmRS256)
+	edPrivate, _ := testKeyPair(t, JWTAlgorithmEdDSA)
+
+	tests := []struct {
+		name    string
+		key     JWTKeyConfig
+		jwksLen int
+	}{
+		{"HS256", JWTKeyConfig{Algorithm: JWTAlgorithmHS256, Secret: "test-secret"}, 0},
+		{"RS256", JWTKeyConfig{Algorithm: JWTAlgorithmRS256, PEM: rsaPrivate}, 1},
+		{"EdDSA", JWTKeyConfig{Algorithm: JWTAlgorithmEdDSA, PEM: edPrivate}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, err := NewKeyManager(tt.key, nil, time.Hour)
+			require.NoError(t, err)
+
+			token, err := manager.Sign(&Claims{UserID: 7, Role: "user"})
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.name, parsed.Method.Alg())
+			assert.NotEmpty(t, parsed.Header["kid"])
+
+			claims := &Claims{}
+			require.NoError(t, manager.Parse(token, claims))
+			assert.Equal(t, uint(7), claims.UserID)
+
+			// 只公開非對稱金鑰的公鑰
+			jwks := manager.JWKS()
+			require.Len(t, jwks.Keys, tt.jwksLen)
+			if tt.jwksLen > 0 {
+				assert.Equal(t, parsed.Header["kid"], jwks.Keys[0].KeyID)
+			}
+		})
+	}
+}
+
+// 輪替金鑰後，舊金鑰簽發的 token 仍可驗證
+func TestKeyManagerRotation(t *testing.T) {
+	oldPrivate, oldPublic := testKeyPair(t, JWTAlgorithmRS256)
+	newPrivate, _ := testKeyPair(t, JWTAlgorithmEdDSA)
+
+	oldManager, err := NewKeyManager(JWTKeyConfig{Algorithm: JWTAlgorithmRS256, PEM: oldPrivate}, nil, time.Hour)
+	require.NoError(t, err)
+	oldToken, err := oldManager.Sign(&Claims{UserID: 1})
+	require.NoError(t, err)
+
+	publicFile := filepath.Join(t.TempDir(), "previous.pem")
+	require.NoError(t, os.WriteFile(publicFile, []byte(oldPublic), 0o600))
+
+	manager, err := NewKeyManager(JWTKeyConfig{Algorithm: JWTAlgorithmEdDSA, PEM: newPrivate},
+		[]JWTKeyConfig{{PEMFile: publicFile}}, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, manager.Parse(oldToken, &Claims{}))
+	assert.Len(t, manager.JWKS().Keys, 2)
+
+	// 不在金鑰環中的金鑰簽發的 token 會被拒絕
+	otherPrivate, _ := testKeyPair(t, JWTAlgorithmRS256)
+	otherManager, err := NewKeyManager(JWTKeyConfig{Algorithm: JWTAlgorithmRS256, PEM: otherPrivate}, nil, time.Hour)
+	require.NoError(t, err)
+	otherToken, err := otherManager.Sign(&Claims{UserID: 1})
+	require.NoError(t, err)
+	assert.Error(t, manager.Parse(otherToken, &Claims{}))
+}
+
+// 以明確的 kid 輪替金鑰後，舊金鑰須保留原本的 kid，舊 token 才能找到驗證金鑰
+func TestKeyManagerRotationWithKeyIDs(t *testing.T) {
+	oldKey := JWTKeyConfig{Algorithm: JWTAlgorithmHS256, ID: "2025-01", Secret: "old-secret"}
+	oldManager, err := NewKeyManager(oldKey, nil, time.Hour)
+	require.NoError(t, err)
+	oldToken, err := oldManager.Sign(&Claims{UserID: 1})
+	require.NoError(t, err)
+
+	newKey := JWTKeyConfig{Algorithm: JWTAlgorithmHS256, ID: "2025-02", Secret: "new-secret"}
+	manager, err := NewKeyManager(newKey, []JWTKeyConfig{oldKey}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, manager.Parse(oldToken, &Claims{}))
+
+	newToken, err := manager.Sign(&Claims{UserID: 1})
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "2025-02", parsed.Header["kid"])
+
+	// 沒有帶上原本的 kid 時，舊金鑰改用推導出的 kid，舊 token 就無法驗證
+	withoutID, err := NewKeyManager(newKey, []JWTKeyConfig{{Algorithm: JWTAlgorithmHS256, Secret: "old-secret"}}, time.Hour)
+	require.NoError(t, err)
+	assert.Error(t, withoutID.Parse(oldToken, &Claims{}))
+}
+
+// token 不能自行選擇演算法，例如以公鑰當作 HMAC 密鑰
+func TestKeyManagerRejectsAlgorithmMismatch(t *testing.T) {
+	private, public := testKeyPair(t, JWTAlgorithmRS256)
+	manager, err := NewKeyManager(JWTKeyConfig{Algorithm: JWTAlgorithmRS256, PEM: private}, nil, time.Hour)
+	require.NoError(t, err)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1, Role: "admin"})
+	forged.Header["kid"] = manager.current.id
+	token, err := forged.SignedString([]byte(public))
+	require.NoError(t, err)
+
+	assert.Error(t, manager.Parse(token, &Claims{}))
+}
+
+// 第三方應用的 token 帶有 client_id 與 scope，一般登入的 token 則沒有
+func TestClientTokenCarriesScope(t *testing.T) {
+	manager, err := NewKeyManager(JWTKeyConfig{Algorithm: JWTAlgorithmHS256, Secret: "test-secret"}, nil, time.Hour)
+	require.NoError(t, err)
+	SetKeyManager(manager)
+	t.Cleanup(func() { SetKeyManager(nil) })
+
+	token, err := GenerateClientToken(7, "partner-app", "accounts:read transfers:write", "family")
+	require.NoError(t, err)
+
+	claims, err := ParseToken(token)
+	require.NoError(t, err)
+	assert.True(t, claims.IsClientToken())
+	assert.Empty(t, claims.Role)
+	assert.Equal(t, "family", claims.SessionID)
+	assert.True(t, claims.HasScope("accounts:read"))
+	assert.True(t, claims.HasScope("transfers:write"))
+	assert.False(t, claims.HasScope("books:write"))
+	assert.False(t, claims.HasScope("accounts"))
+
+	token, err = GenerateToken(7, "user", "session")
+	require.NoError(t, err)
+	claims, err = ParseToken(token)
+	require.NoError(t, err)
+	assert.False(t, claims.IsClientToken())
+	assert.False(t, claims.HasScope("accounts:read"))
+}
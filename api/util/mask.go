@@ -0,0 +1,20 @@
+package util
+
+import "strings"
+
+// MaskEmail hides most of the local part of an email address, e.g. j***@example.com
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// MaskPhone hides all but the last four digits of a phone number, e.g. ******7890
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
@@ -0,0 +1,187 @@
+package util
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// StorageScale is the number of decimal places kept by decimal(20,8) money columns
+const StorageScale int32 = 8
+
+// DefaultCurrency is used when an account or request does not specify one
+const DefaultCurrency = "USD"
+
+// maxMoneyExponent bounds the decimal exponent of parsed amounts in both directions. Such
+// amounts cannot be stored anyway, and arithmetic on them gets arbitrarily slow.
+const maxMoneyExponent = 38
+
+// currencyScales maps ISO 4217 codes to the number of minor-unit digits they allow
+var currencyScales = map[string]int32{
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"TWD": 2,
+	"CNY": 2,
+	"HKD": 2,
+	"SGD": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"BHD": 3,
+	"KWD": 3,
+}
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// CurrencyScale returns the number of decimal places allowed for a currency
+func CurrencyScale(currency string) (int32, error) {
+	scale, ok := currencyScales[strings.ToUpper(currency)]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+	return scale, nil
+}
+
+// IsSupportedCurrency reports whether the currency code is known
+func IsSupportedCurrency(currency string) bool {
+	_, ok := currencyScales[strings.ToUpper(currency)]
+	return ok
+}
+
+// Money is an exact decimal amount. The zero value is zero.
+// It is stored as a DECIMAL column and encoded in JSON as a string.
+type Money struct {
+	d decimal.Decimal
+}
+
+// NewMoneyFromString parses a decimal string such as "100.50"
+func NewMoneyFromString(value string) (Money, error) {
+	d, err := decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if exp := d.Exponent(); exp > maxMoneyExponent || exp < -maxMoneyExponent {
+		return Money{}, fmt.Errorf("amount %q is out of range", value)
+	}
+	return Money{d: d}, nil
+}
+
+// MustMoney parses value and panics if it is not a valid decimal. Intended for constants and tests.
+func MustMoney(value string) Money {
+	m, err := NewMoneyFromString(value)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// NewMoneyFromMinorUnits builds an amount from an integer number of minor units, e.g. cents
+func NewMoneyFromMinorUnits(units int64, scale int32) Money {
+	return Money{d: decimal.New(units, -scale)}
+}
+
+func (m Money) Add(other Money) Money { return Money{d: m.d.Add(other.d)} }
+func (m Money) Sub(other Money) Money { return Money{d: m.d.Sub(other.d)} }
+func (m Money) Neg() Money            { return Money{d: m.d.Neg()} }
+
+func (m Money) Cmp(other Money) int                 { return m.d.Cmp(other.d) }
+func (m Money) Equal(other Money) bool              { return m.d.Equal(other.d) }
+func (m Money) LessThan(other Money) bool           { return m.d.LessThan(other.d) }
+func (m Money) GreaterThan(other Money) bool        { return m.d.GreaterThan(other.d) }
+func (m Money) GreaterThanOrEqual(other Money) bool { return m.d.GreaterThanOrEqual(other.d) }
+func (m Money) IsZero() bool                        { return m.d.IsZero() }
+func (m Money) IsPositive() bool                    { return m.d.IsPositive() }
+func (m Money) IsNegative() bool                    { return m.d.IsNegative() }
+
+// RoundBank rounds to the given number of decimal places using banker's rounding (half to even)
+func (m Money) RoundBank(places int32) Money {
+	return Money{d: m.d.RoundBank(places)}
+}
+
+// RoundToCurrency rounds to the minor unit of currency using banker's rounding
+func (m Money) RoundToCurrency(currency string) (Money, error) {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return m.RoundBank(scale), nil
+}
+
+// FitsCurrency reports whether the amount can be expressed in currency minor units without rounding
+func (m Money) FitsCurrency(currency string) bool {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return false
+	}
+	return m.d.Truncate(scale).Equal(m.d)
+}
+
+// MinorUnits returns the amount as an integer number of minor units at the given scale
+func (m Money) MinorUnits(scale int32) int64 {
+	return m.d.Shift(scale).RoundBank(0).IntPart()
+}
+
+// String keeps the scale of the amount, so "100.50" stays "100.50"
+func (m Money) String() string {
+	if exp := m.d.Exponent(); exp < 0 {
+		return m.d.StringFixed(-exp)
+	}
+	return m.d.String()
+}
+
+// Format renders the amount with exactly the decimals of currency, e.g. "40.00" for USD
+func (m Money) Format(currency string) string {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return m.String()
+	}
+	return m.d.StringFixedBank(scale)
+}
+
+// MarshalJSON encodes the amount as a JSON string to avoid float precision loss in clients
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts both "100.50" and 100.50; numbers are parsed from their literal text
+func (m *Money) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		*m = Money{}
+		return nil
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		raw = s
+	}
+
+	parsed, err := NewMoneyFromString(raw)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns
+func (m *Money) Scan(value interface{}) error {
+	var d decimal.Decimal
+	if err := d.Scan(value); err != nil {
+		return err
+	}
+	m.d = d
+	return nil
+}
+
+// Value implements driver.Valuer, rounding to the storage scale with banker's rounding
+func (m Money) Value() (driver.Value, error) {
+	return m.d.RoundBank(StorageScale).String(), nil
+}
@@ -0,0 +1,157 @@
+package util
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 銀行家捨入：恰好一半時捨入到偶數
+func TestMoneyRounding(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		currency string
+		want     string
+	}{
+		{"half rounds to even down", "1.005", "USD", "1.00"},
+		{"half rounds to even up", "1.015", "USD", "1.02"},
+		{"above half rounds up", "1.0051", "USD", "1.01"},
+		{"negative half", "-2.345", "EUR", "-2.34"},
+		{"no minor units", "100.5", "JPY", "100"},
+		{"no minor units odd", "101.5", "JPY", "102"},
+		{"three minor units", "1.23450", "BHD", "1.234"},
+		{"lower case code", "9.995", "usd", "10.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rounded, err := MustMoney(tt.value).RoundToCurrency(tt.currency)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rounded.Format(tt.currency))
+		})
+	}
+
+	_, err := MustMoney("1.00").RoundToCurrency("XYZ")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
+
+func TestMoneyFitsCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		currency string
+		fits     bool
+	}{
+		{"cents", "10.25", "USD", true},
+		{"trailing zeros are ignored", "10.2500", "USD", true},
+		{"fraction of a cent", "10.255", "USD", false},
+		{"whole yen", "1500", "JPY", true},
+		{"whole yen with zero decimals", "1500.00", "JPY", true},
+		{"fraction of a yen", "1500.5", "JPY", false},
+		{"fils", "0.125", "BHD", true},
+		{"fraction of a fils", "0.1255", "BHD", false},
+		{"unsupported currency", "1", "XYZ", false},
+		{"smallest allowed exponent", "1e-38", "USD", false},
+		{"largest allowed exponent", "1e38", "JPY", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.fits, MustMoney(tt.value).FitsCurrency(tt.currency))
+		})
+	}
+}
+
+// 指數過大或過小的金額在解析時就被拒絕，不會拖慢後續的精度檢查
+func TestMoneyRejectsExtremeExponents(t *testing.T) {
+	for _, value := range []string{"1e-300000", "1e300000", "1e-39", "1e39", "0." + strings.Repeat("0", 100) + "1"} {
+		t.Run(value[:min(len(value), 12)], func(t *testing.T) {
+			_, err := NewMoneyFromString(value)
+			assert.Error(t, err)
+
+			var m Money
+			assert.Error(t, json.Unmarshal([]byte(value), &m))
+			assert.Error(t, json.Unmarshal([]byte(`"`+value+`"`), &m))
+		})
+	}
+}
+
+// JSON 以字串輸出並保留小數位數；輸入同時接受字串與數字
+func TestMoneyJSON(t *testing.T) {
+	encoded, err := json.Marshal(struct {
+		Amount Money `json:"amount"`
+	}{MustMoney("100.50")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"100.50"}`, string(encoded))
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"string", `"100.50"`, "100.50", false},
+		{"number keeps its literal text", `0.1`, "0.1", false},
+		{"large number stays exact", `12345678901234567.89`, "12345678901234567.89", false},
+		{"padded string", `" 7.25 "`, "7.25", false},
+		{"null is zero", `null`, "0", false},
+		{"not a number", `"ten"`, "", true},
+		{"boolean", `true`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			err := json.Unmarshal([]byte(tt.input), &m)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m.String())
+		})
+	}
+}
+
+// 寫入資料庫時捨入到儲存精度，讀出時支援驅動回傳的各種型別
+func TestMoneyScanValue(t *testing.T) {
+	value, err := MustMoney("1.123456785").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "1.12345678", value)
+
+	value, err = MustMoney("1.123456775").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "1.12345678", value)
+
+	tests := []struct {
+		name  string
+		input interface{}
+		want  string
+	}{
+		{"string", "42.50000000", "42.5"},
+		{"bytes", []byte("0.00000001"), "0.00000001"},
+		{"integer", int64(7), "7"},
+		{"float", 2.5, "2.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m Money
+			require.NoError(t, m.Scan(tt.input))
+			assert.True(t, MustMoney(tt.want).Equal(m), m.String())
+		})
+	}
+
+	var m Money
+	assert.Error(t, m.Scan("abc"))
+}
+
+func TestMoneyMinorUnits(t *testing.T) {
+	assert.Equal(t, int64(1050), MustMoney("10.50").MinorUnits(2))
+	assert.Equal(t, int64(1500), MustMoney("1500").MinorUnits(0))
+	assert.True(t, MustMoney("10.50").Equal(NewMoneyFromMinorUnits(1050, 2)))
+}
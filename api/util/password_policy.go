@@ -0,0 +1,202 @@
+package util
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// bcryptMaxBytes is the length after which bcrypt silently ignores the rest of a password
+const bcryptMaxBytes = 72
+
+// sha1PrefixLength is the length of the hash prefix the breached list is indexed by, as in
+// the k-anonymity range API of Pwned Passwords
+const sha1PrefixLength = 5
+
+//go:embed breached_passwords.txt
+var defaultBreachedPasswords string
+
+// PasswordPolicy describes the passwords users may choose
+type PasswordPolicy struct {
+	MinLength int
+	// MinCharacterClasses is how many of lowercase, uppercase, digits and symbols must appear
+	MinCharacterClasses int
+	RequireLowercase    bool
+	RequireUppercase    bool
+	RequireDigit        bool
+	RequireSymbol       bool
+	// Breached lists passwords known from data breaches; nil disables the check
+	Breached *BreachedPasswordList
+}
+
+// PasswordPolicyError lists every rule a password breaks, so all of them can be shown at once
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password " + strings.Join(e.Violations, ", ")
+}
+
+// Check returns a *PasswordPolicyError when the password breaks the policy. The personal
+// values, such as the user's email and name, must not appear in the password.
+func (p *PasswordPolicy) Check(password string, personal ...string) error {
+	var violations []string
+
+	if length := len([]rune(password)); length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if len(password) > bcryptMaxBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", bcryptMaxBytes))
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLowercase && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireUppercase && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+	if classes := countTrue(lower, upper, digit, symbol); classes < p.MinCharacterClasses {
+		violations = append(violations, fmt.Sprintf("must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinCharacterClasses))
+	}
+
+	if containsPersonalInfo(password, personal) {
+		violations = append(violations, "must not contain your email or name")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, "has appeared in a data breach, choose a different one")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo reports whether the password contains the local part of an email
+// or a name, ignoring case and spaces
+func containsPersonalInfo(password string, personal []string) bool {
+	normalized := strings.ToLower(strings.Join(strings.Fields(password), ""))
+	for _, value := range personal {
+		if at := strings.LastIndex(value, "@"); at > 0 {
+			value = value[:at]
+		}
+		value = strings.ToLower(strings.Join(strings.Fields(value), ""))
+		// Very short values would match by chance
+		if len([]rune(value)) >= 3 && strings.Contains(normalized, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func countTrue(values ...bool) int {
+	count := 0
+	for _, v := range values {
+		if v {
+			count++
+		}
+	}
+	return count
+}
+
+// BreachedPasswordList holds SHA-1 hashes of breached passwords, indexed by their first
+// five hex characters. Lookups only hash the password locally; nothing leaves the process.
+type BreachedPasswordList struct {
+	// suffixes maps a hash prefix to the sorted remaining hex characters of the hashes
+	suffixes map[string][]string
+}
+
+// LoadBreachedPasswords builds the list from the passwords bundled with the application
+// and, when path is set, a file in the Pwned Passwords "ordered by hash" format: one
+// uppercase SHA-1 hash per line, optionally followed by ":count".
+func LoadBreachedPasswords(path string) (*BreachedPasswordList, error) {
+	list := &BreachedPasswordList{suffixes: make(map[string][]string)}
+
+	// The bundled file lists plain passwords, which keeps it reviewable
+	for _, password := range strings.Split(defaultBreachedPasswords, "\n") {
+		if password = strings.TrimSpace(password); password != "" && !strings.HasPrefix(password, "#") {
+			list.add(sha1Hex(password))
+		}
+	}
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if err := list.readHashes(file); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for prefix := range list.suffixes {
+		sort.Strings(list.suffixes[prefix])
+	}
+	return list, nil
+}
+
+// Contains reports whether the password is on the list
+func (l *BreachedPasswordList) Contains(password string) bool {
+	hash := sha1Hex(password)
+	suffixes := l.suffixes[hash[:sha1PrefixLength]]
+	suffix := hash[sha1PrefixLength:]
+
+	i := sort.SearchStrings(suffixes, suffix)
+	return i < len(suffixes) && suffixes[i] == suffix
+}
+
+func (l *BreachedPasswordList) readHashes(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha1.Size*2 {
+			return fmt.Errorf("line %d: not a SHA-1 hash", line)
+		}
+		l.add(strings.ToUpper(hash))
+	}
+	return scanner.Err()
+}
+
+func (l *BreachedPasswordList) add(hash string) {
+	prefix := hash[:sha1PrefixLength]
+	l.suffixes[prefix] = append(l.suffixes[prefix], hash[sha1PrefixLength:])
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	breached, err := LoadBreachedPasswords("")
+	require.NoError(t, err)
+
+	policy := &PasswordPolicy{MinLength: 10, MinCharacterClasses: 3, RequireDigit: true, Breached: breached}
+
+	tests := []struct {
+		name       string
+		password   string
+		violations []string
+	}{
+		{"valid", "Correct-Horse-9", nil},
+		{"too short", "Ab1!", []string{"must be at least 10 characters"}},
+		{"missing classes", "onlylowercase", []string{"must contain a digit", "must contain at least 3 of: lowercase letters, uppercase letters, digits, symbols"}},
+		{"personal info", "Jane.Doe-2024!", []string{"must not contain your email or name"}},
+		{"breached", "Password123!", []string{"has appeared in a data breach, choose a different one"}},
+		{"too long", strings.Repeat("Aa1!", 19), []string{"must be at most 72 bytes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, "jane.doe@example.com", "Jane Doe")
+			if tt.violations == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			require.ErrorAs(t, err, &policyErr)
+			assert.Equal(t, tt.violations, policyErr.Violations)
+		})
+	}
+}
+
+// 外部清單使用 Pwned Passwords 的 HASH:COUNT 格式
+func TestLoadBreachedPasswordsFile(t *testing.T) {
+	sum := sha1.Sum([]byte("Correct-Horse-9"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(path, []byte("0000000A2B3C4D5E6F708192A3B4C5D6E7F8091A:3\n"+hash+":42\n"), 0o600))
+
+	list, err := LoadBreachedPasswords(path)
+	require.NoError(t, err)
+	assert.True(t, list.Contains("Correct-Horse-9"))
+	assert.True(t, list.Contains("qwerty"))
+	assert.False(t, list.Contains("Correct-Horse-10"))
+
+	require.NoError(t, os.WriteFile(path, []byte("not-a-hash\n"), 0o600))
+	_, err = LoadBreachedPasswords(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidPhoneNumber = errors.New("invalid phone number")
+
+	e164Pattern      = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	phoneSeparators  = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+	countryCodeRegex = regexp.MustCompile(`^[1-9][0-9]{0,2}$`)
+)
+
+// NormalizeE164 converts a phone number to E.164 format, e.g. "0912-345-678" with
+// default country code "886" becomes "+886912345678". Numbers that already carry a
+// country code ("+44...", "0044...") keep it.
+func NormalizeE164(phone, defaultCountryCode string) (string, error) {
+	number := phoneSeparators.Replace(strings.TrimSpace(phone))
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = "+" + number[2:]
+	default:
+		if !countryCodeRegex.MatchString(defaultCountryCode) {
+			return "", ErrInvalidPhoneNumber
+		}
+		// Drop the national trunk prefix before adding the country code
+		number = "+" + defaultCountryCode + strings.TrimPrefix(number, "0")
+	}
+
+	if !e164Pattern.MatchString(number) {
+		return "", ErrInvalidPhoneNumber
+	}
+	return number, nil
+}
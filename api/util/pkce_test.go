@@ -0,0 +1,36 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 7636 附錄 B 的範例
+func TestVerifyPKCE(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	assert.Equal(t, challenge, PKCEChallenge(verifier))
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		valid     bool
+	}{
+		{"matching verifier", verifier, challenge, true},
+		{"other verifier", strings.Repeat("a", 43), challenge, false},
+		{"too short", "abc", PKCEChallenge("abc"), false},
+		{"too long", strings.Repeat("a", 129), PKCEChallenge(strings.Repeat("a", 129)), false},
+		{"invalid characters", strings.Repeat("a", 42) + "/", PKCEChallenge(strings.Repeat("a", 42) + "/"), false},
+		{"plain challenge", verifier, verifier, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.valid, VerifyPKCE(tt.verifier, tt.challenge))
+		})
+	}
+}
@@ -3,6 +3,8 @@ package util
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
+	"math/big"
 )
 
 // GenerateRandomString generates a random string of the specified length
@@ -11,3 +13,22 @@ func GenerateRandomString(length int) string {
 	rand.Read(b)
 	return base64.URLEncoding.EncodeToString(b)[:length]
 }
+
+// GenerateNumericCode generates a uniformly distributed numeric code with the given number of digits
+func GenerateNumericCode(digits int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
+
+// GenerateOpaqueToken returns a URL-safe random token with the given number of bytes of entropy
+func GenerateOpaqueToken(bytes int) (string, error) {
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
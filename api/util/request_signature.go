@@ -0,0 +1,54 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SignedRequest is the part of an HTTP request covered by an API key signature
+type SignedRequest struct {
+	Method string
+	// Path includes the query string, so query parameters cannot be changed either
+	Path string
+	// Timestamp is the Unix time in seconds at which the client signed the request
+	Timestamp string
+	// Nonce is unique per request and makes a captured request impossible to replay
+	Nonce string
+	Body  []byte
+}
+
+// StringToSign joins the method, path, timestamp, nonce and the hex SHA-256 of the body
+// with newlines
+func (r SignedRequest) StringToSign() string {
+	bodyHash := sha256.Sum256(r.Body)
+	return strings.Join([]string{
+		strings.ToUpper(r.Method),
+		r.Path,
+		r.Timestamp,
+		r.Nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignRequest returns the hex HMAC-SHA256 of the string to sign of the request
+func SignRequest(secret string, r SignedRequest) string {
+	return hex.EncodeToString(requestMAC(secret, r))
+}
+
+// VerifyRequestSignature reports whether signature was made with secret over the request,
+// in constant time
+func VerifyRequestSignature(secret string, r SignedRequest, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(requestMAC(secret, r), expected)
+}
+
+func requestMAC(secret string, r SignedRequest) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(r.StringToSign()))
+	return mac.Sum(nil)
+}
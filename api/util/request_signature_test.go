@@ -0,0 +1,52 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyRequestSignature(t *testing.T) {
+	const secret = "test-signing-secret"
+	request := SignedRequest{
+		Method:    "POST",
+		Path:      "/accounts/1/deposit?currency=USD",
+		Timestamp: "1760745600",
+		Nonce:     "3f9c1a7e5b2d4c60",
+		Body:      []byte(`{"amount":"100.00"}`),
+	}
+	signature := SignRequest(secret, request)
+
+	// 任何一個被簽署的欄位改變都會讓簽章失效
+	tests := []struct {
+		name      string
+		secret    string
+		modify    func(r *SignedRequest)
+		signature string
+		valid     bool
+	}{
+		{"unchanged request", secret, func(r *SignedRequest) {}, signature, true},
+		{"lower case method", secret, func(r *SignedRequest) { r.Method = "post" }, signature, true},
+		{"other secret", "other-secret", func(r *SignedRequest) {}, signature, false},
+		{"other method", secret, func(r *SignedRequest) { r.Method = "PUT" }, signature, false},
+		{"other query", secret, func(r *SignedRequest) { r.Path = "/accounts/1/deposit?currency=EUR" }, signature, false},
+		{"other timestamp", secret, func(r *SignedRequest) { r.Timestamp = "1760745601" }, signature, false},
+		{"other nonce", secret, func(r *SignedRequest) { r.Nonce = "3f9c1a7e5b2d4c61" }, signature, false},
+		{"other body", secret, func(r *SignedRequest) { r.Body = []byte(`{"amount":"900.00"}`) }, signature, false},
+		{"not hex", secret, func(r *SignedRequest) {}, "not-a-signature", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := request
+			tt.modify(&r)
+			assert.Equal(t, tt.valid, VerifyRequestSignature(tt.secret, r, tt.signature))
+		})
+	}
+}
+
+// 沒有 body 的請求簽署空字串的雜湊
+func TestStringToSignWithoutBody(t *testing.T) {
+	request := SignedRequest{Method: "get", Path: "/accounts", Timestamp: "1760745600", Nonce: "n1"}
+	assert.Equal(t, "GET\n/accounts\n1760745600\nn1\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", request.StringToSign())
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrInvalidCiphertext is returned when a sealed value was tampered with or sealed with another key
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// SealSecret encrypts a secret that must be stored but read back later, such as a TOTP
+// secret, with AES-256-GCM. The key is derived from the configured passphrase.
+func SealSecret(passphrase, plaintext string) (string, error) {
+	aead, err := secretAEAD(passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSecret decrypts a value sealed by SealSecret
+func OpenSecret(passphrase, ciphertext string) (string, error) {
+	aead, err := secretAEAD(passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, data := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
+
+func secretAEAD(passphrase string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(passphrase))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
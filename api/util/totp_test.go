@@ -0,0 +1,80 @@
+package util
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 附錄 B 的 SHA1 測試向量，取後 6 位
+func TestTOTPCodeMatchesRFCVectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.code, code, "time %d", tt.unix)
+	}
+}
+
+// 容許前後一個時間區間的時鐘誤差
+func TestValidateTOTPWindow(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	previous, err := TOTPCode(secret, TOTPStep(now)-1)
+	require.NoError(t, err)
+
+	step, ok := ValidateTOTP(secret, previous, now)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now)-1, step)
+
+	stale, err := TOTPCode(secret, TOTPStep(now)-3)
+	require.NoError(t, err)
+	_, ok = ValidateTOTP(secret, stale, now)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Go Bank", "user@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Go Bank:user@example.com", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "Go Bank", uri.Query().Get("issuer"))
+}
+
+func TestSealSecretRoundTrip(t *testing.T) {
+	sealed, err := SealSecret("passphrase", "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	opened, err := OpenSecret("passphrase", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", opened)
+
+	_, err = OpenSecret("other", sealed)
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
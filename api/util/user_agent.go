@@ -0,0 +1,50 @@
+package util
+
+import "strings"
+
+// DeviceLabel describes the browser and platform of a User-Agent header for display,
+// e.g. "Chrome on Windows". It is a best-effort guess, not a security signal.
+func DeviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := firstMatch(userAgent, [][2]string{
+		// Order matters: Edge and Opera also claim to be Chrome, Chrome claims to be Safari
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"okhttp", "Android app"},
+	})
+	platform := firstMatch(userAgent, [][2]string{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
+
+func firstMatch(userAgent string, candidates [][2]string) string {
+	for _, candidate := range candidates {
+		if strings.Contains(userAgent, candidate[0]) {
+			return candidate[1]
+		}
+	}
+	return ""
+}
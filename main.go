@@ -4,6 +4,7 @@ import (
 	"context"
 	"go-gin-template/api"
 	"go-gin-template/api/config"
+	"go-gin-template/api/repository"
 	"go-gin-template/api/service"
 	"go-gin-template/docs"
 	"log"
@@ -34,17 +35,26 @@ func main() {
 	// Initialize Swagger docs
 	docs.SwaggerInfo.BasePath = "/"
 
+	// Initialize Redis connection; first, since seeding the roles drops their cached permissions
+	config.InitRedis()
+
 	// Initialize database connection
 	config.InitDB()
 
-	// Initialize Redis connection
-	config.InitRedis()
+	// 初始化通知服务，并启动发送 outbox 消息的 worker
+	notificationService = service.NewNotificationService(repository.NewNotificationRepository(config.DB))
+	notificationService.Start()
 
-	// 初始化通知服务
-	notificationService = service.NewNotificationService()
+	// 定期删除未在期限内验证邮箱的注册，关闭时通过 context 停止
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		service.NewUnverifiedUserPurger(repository.NewUserRepository(config.DB)).Run(purgeCtx)
+	}()
 
 	// Initialize router
-	r := api.InitRouter()
+	r := api.InitRouter(notificationService)
 
 	// Swagger documentation endpoint
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -78,10 +88,14 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 等待所有邮件发送完成
-	log.Println("等待所有邮件发送完成...")
+	// 停止通知 worker，等待正在发送的消息完成；未发送的消息保留在 outbox 中
+	log.Println("等待正在发送的通知完成...")
 	notificationService.WaitForCompletion()
-	log.Println("所有邮件已发送完成")
+	log.Println("通知 worker 已停止")
+
+	// 停止清理未验证用户的任务
+	stopPurge()
+	<-purgeDone
 
 	// 关闭 HTTP 服务器
 	if err := srv.Shutdown(ctx); err != nil {
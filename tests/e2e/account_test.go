@@ -0,0 +1,475 @@
+package e2e
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/suite"
+
+	"go-gin-template/api"
+	"go-gin-template/api/config"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/service"
+	"go-gin-template/api/util"
+)
+
+type AccountTestSuite struct {
+	suite.Suite
+	router http.Handler
+	token  string
+}
+
+func TestAccountSuite(t *testing.T) {
+	suite.Run(t, new(AccountTestSuite))
+}
+
+func (s *AccountTestSuite) SetupSuite() {
+	// 載入測試環境配置
+	err := godotenv.Load("../../tests/e2e/.env.test")
+	if err != nil {
+		s.T().Logf("Warning: .env.test file not found: %v", err)
+	}
+
+	config.InitRedis()
+	config.InitDB()
+	s.router = api.InitRouter(service.NewNotificationService(repository.NewNotificationRepository(config.DB)))
+}
+
+func (s *AccountTestSuite) SetupTest() {
+	cleanTestData()
+	s.token = registerAndLogin(s.router, "test-account@example.com", "Test123!@#")
+	s.Require().NotEmpty(s.token)
+}
+
+func (s *AccountTestSuite) TearDownTest() {
+	cleanTestData()
+}
+
+func (s *AccountTestSuite) TearDownSuite() {
+	cleanTestData()
+	if sqlDB, err := config.DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+	config.Redis.Close()
+}
+
+// createAccount 建立帳戶並回傳帳戶 ID
+func (s *AccountTestSuite) createAccount(name string) uint {
+	w := testRequestWithToken(s.router, "POST", "/accounts", s.token, map[string]interface{}{"name": name})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+
+	var account struct {
+		ID uint `json:"id"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &account))
+	return account.ID
+}
+
+// balanceOf 從資料庫讀取帳戶餘額
+func (s *AccountTestSuite) balanceOf(accountID uint) util.Money {
+	account, err := repository.NewAccountRepository(config.DB).FindByID(accountID)
+	s.Require().NoError(err)
+	return account.Balance
+}
+
+func (s *AccountTestSuite) assertLedgerConsistent(accountID uint) {
+	ledgerService := service.NewLedgerService(repository.NewLedgerRepository(config.DB), repository.NewAccountRepository(config.DB))
+	result, err := ledgerService.Reconcile(accountID)
+	s.Require().NoError(err)
+	s.True(result.Consistent, "cached %s, ledger %s, running %s", result.CachedBalance, result.LedgerBalance, result.RunningBalance)
+}
+
+// 並發存款不應遺失任何更新
+func (s *AccountTestSuite) TestConcurrentDepositsAreNotLost() {
+	accountID := s.createAccount("Concurrent Deposits")
+	const workers = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", accountID), s.token,
+				map[string]interface{}{"amount": "1.10"})
+			s.Equal(http.StatusOK, w.Code, w.Body.String())
+		}()
+	}
+	wg.Wait()
+
+	s.True(util.MustMoney("55.00").Equal(s.balanceOf(accountID)))
+	s.assertLedgerConsistent(accountID)
+}
+
+// 並發提款只能在餘額足夠時成功，餘額永遠不會變成負數
+func (s *AccountTestSuite) TestConcurrentWithdrawalsNeverOverdraw() {
+	accountID := s.createAccount("Concurrent Withdrawals")
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", accountID), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	const workers = 40
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/withdraw", accountID), s.token,
+				map[string]interface{}{"amount": "3.00"})
+			if w.Code == http.StatusOK {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 100 / 3 = 33 次成功，剩餘 1.00
+	s.Equal(33, succeeded)
+	s.True(util.MustMoney("1.00").Equal(s.balanceOf(accountID)))
+	s.assertLedgerConsistent(accountID)
+}
+
+// 雙向並發轉帳按帳戶 ID 順序加鎖，不會死鎖也不會遺失金額
+func (s *AccountTestSuite) TestConcurrentTransfersInBothDirections() {
+	first := s.createAccount("First")
+	second := s.createAccount("Second")
+	for _, id := range []uint{first, second} {
+		w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", id), s.token,
+			map[string]interface{}{"amount": "500.00"})
+		s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	}
+
+	const workers = 30
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		from, to := first, second
+		if i%2 == 1 {
+			from, to = second, first
+		}
+		wg.Add(1)
+		go func(from, to uint) {
+			defer wg.Done()
+			w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/transfer", from), s.token,
+				map[string]interface{}{"amount": "2.50", "target_account_id": to})
+			s.Equal(http.StatusOK, w.Code, w.Body.String())
+		}(from, to)
+	}
+	wg.Wait()
+
+	total := s.balanceOf(first).Add(s.balanceOf(second))
+	s.True(util.MustMoney("1000.00").Equal(total))
+	s.assertLedgerConsistent(first)
+	s.assertLedgerConsistent(second)
+}
+
+// 其他使用者無法讀取或操作不屬於自己的個人資料、帳戶與交易
+func (s *AccountTestSuite) TestOtherUsersResourcesAreForbidden() {
+	source := s.createAccount("Source")
+	target := s.createAccount("Target")
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", source), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/transfer/init", source), s.token,
+		map[string]interface{}{"amount": "10.00", "target_account_id": target})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var initiated struct {
+		TransactionID uint `json:"transaction_id"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &initiated))
+
+	var owner model.User
+	s.Require().NoError(config.DB.Where("email = ?", "test-account@example.com").First(&owner).Error)
+	otherToken := registerAndLogin(s.router, "test-account-other@example.com", "Test123!@#")
+	s.Require().NotEmpty(otherToken)
+
+	w = testRequestWithToken(s.router, "GET", fmt.Sprintf("/users/%d", owner.ID), otherToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+	w = testRequestWithToken(s.router, "PUT", fmt.Sprintf("/users/%d", owner.ID), otherToken, map[string]interface{}{"name": "Mallory"})
+	s.Equal(http.StatusForbidden, w.Code)
+	w = testRequestWithToken(s.router, "GET", fmt.Sprintf("/accounts/%d/transactions", source), otherToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/withdraw", source), otherToken,
+		map[string]interface{}{"amount": "1.00"})
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 他人的交易視同不存在
+	w = testRequestWithToken(s.router, "POST", "/verifications", otherToken,
+		map[string]interface{}{"transaction_id": initiated.TransactionID, "type": "email"})
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.Contains(w.Body.String(), "transaction not found")
+
+	// 本人仍可讀取自己的資料
+	w = testRequestWithToken(s.router, "GET", fmt.Sprintf("/users/%d", owner.ID), s.token, nil)
+	s.Equal(http.StatusOK, w.Code)
+	s.True(util.MustMoney("100.00").Equal(s.balanceOf(source)))
+}
+
+func (s *AccountTestSuite) TestOpenBankingAccountAccessConsent() {
+	shared := s.createAccount("Shared")
+	private := s.createAccount("Private")
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", shared), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	appToken := thirdPartyToken(s.router, s.token, model.ScopeAccountsRead)
+	s.Require().NotEmpty(appToken)
+
+	// 第三方應用建立同意書，使用者授權前無法讀取
+	w = testRequestWithToken(s.router, "POST", "/open-banking/account-access-consents", appToken,
+		map[string]interface{}{"permissions": []string{model.ConsentPermissionReadBalances}})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var consent struct {
+		ID     uint   `json:"id"`
+		Status string `json:"status"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &consent))
+	s.Equal(string(model.ConsentStatusAwaitingAuthorisation), consent.Status)
+
+	balancePath := func(accountID uint) string {
+		return fmt.Sprintf("/open-banking/account-access-consents/%d/accounts/%d/balance", consent.ID, accountID)
+	}
+	w = testRequestWithToken(s.router, "GET", balancePath(shared), appToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 使用者的 token 不能呼叫第三方端點，第三方的 token 也不能替使用者授權
+	w = testRequestWithToken(s.router, "GET", balancePath(shared), s.token, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+	authorisePath := fmt.Sprintf("/open-banking/consents/%d/authorise", consent.ID)
+	w = testRequestWithToken(s.router, "POST", authorisePath, appToken, map[string]interface{}{"account_ids": []uint{shared}})
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 使用者只分享其中一個帳戶
+	w = testRequestWithToken(s.router, "POST", authorisePath, s.token, map[string]interface{}{"account_ids": []uint{shared}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = testRequestWithToken(s.router, "GET", balancePath(shared), appToken, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var balance struct {
+		Balance util.Money `json:"balance"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &balance))
+	s.True(util.MustMoney("100.00").Equal(balance.Balance))
+	w = testRequestWithToken(s.router, "GET", balancePath(private), appToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 同意書未授予 read_transactions
+	w = testRequestWithToken(s.router, "GET",
+		fmt.Sprintf("/open-banking/account-access-consents/%d/accounts/%d/transactions", consent.ID, shared), appToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 使用者撤銷後立即失效
+	w = testRequestWithToken(s.router, "DELETE", fmt.Sprintf("/open-banking/consents/%d", consent.ID), s.token, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	w = testRequestWithToken(s.router, "GET", balancePath(shared), appToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+}
+
+// 第三方應用只能透過付款同意書發起轉帳，也不能替使用者完成驗證
+func (s *AccountTestSuite) TestThirdPartyTransfersNeedPaymentConsent() {
+	source := s.createAccount("Source")
+	target := s.createAccount("Target")
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", source), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	appToken := thirdPartyToken(s.router, s.token, model.ScopeTransfersWrite)
+	s.Require().NotEmpty(appToken)
+
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/transfer/init", source), appToken,
+		map[string]interface{}{"amount": "10.00", "target_account_id": target})
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 使用者自己發起的轉帳，應用程式也無法產生或提交驗證碼
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/transfer/init", source), s.token,
+		map[string]interface{}{"amount": "10.00", "target_account_id": target})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var initiated struct {
+		TransactionID uint `json:"transaction_id"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &initiated))
+	w = testRequestWithToken(s.router, "POST", "/verifications", appToken,
+		map[string]interface{}{"transaction_id": initiated.TransactionID, "type": "email"})
+	s.Equal(http.StatusForbidden, w.Code)
+	s.True(util.MustMoney("100.00").Equal(s.balanceOf(source)))
+}
+
+// 以相同 Idempotency-Key 重送的請求只執行一次，之後重播第一次的回應
+func (s *AccountTestSuite) TestIdempotentDepositIsReplayed() {
+	accountID := s.createAccount("Idempotent")
+	path := fmt.Sprintf("/accounts/%d/deposit", accountID)
+
+	first := testRequestWithIdempotencyKey(s.router, "POST", path, s.token, "deposit-1", map[string]interface{}{"amount": "25.00"})
+	s.Require().Equal(http.StatusOK, first.Code, first.Body.String())
+	s.Empty(first.Header().Get(middleware.IdempotencyReplayedHeader))
+
+	replay := testRequestWithIdempotencyKey(s.router, "POST", path, s.token, "deposit-1", map[string]interface{}{"amount": "25.00"})
+	s.Require().Equal(http.StatusOK, replay.Code, replay.Body.String())
+	s.Equal("true", replay.Header().Get(middleware.IdempotencyReplayedHeader))
+	s.JSONEq(first.Body.String(), replay.Body.String())
+
+	// 同一個 key 搭配不同的內容會被拒絕
+	w := testRequestWithIdempotencyKey(s.router, "POST", path, s.token, "deposit-1", map[string]interface{}{"amount": "30.00"})
+	s.Equal(http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	s.True(util.MustMoney("25.00").Equal(s.balanceOf(accountID)), s.balanceOf(accountID).String())
+	s.assertLedgerConsistent(accountID)
+}
+
+// 第一個請求仍在處理時，以相同 Idempotency-Key 重送會得到 409
+func (s *AccountTestSuite) TestIdempotencyKeyInFlightConflicts() {
+	accountID := s.createAccount("In Flight")
+	path := fmt.Sprintf("/accounts/%d/deposit", accountID)
+	body := map[string]interface{}{"amount": "10.00"}
+
+	w := testRequestWithIdempotencyKey(s.router, "POST", path, s.token, "deposit-2", body)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// 將紀錄改回由另一個請求處理中的狀態，模擬第一個請求尚未完成
+	err := config.DB.Model(&model.IdempotencyRecord{}).
+		Where("idempotency_key = ?", "deposit-2").
+		Updates(map[string]interface{}{
+			"status":       model.IdempotencyStatusProcessing,
+			"lock_owner":   "first-request",
+			"locked_until": time.Now().Add(time.Minute),
+		}).Error
+	s.Require().NoError(err)
+	// 清除快取，讓請求讀到資料庫中的紀錄
+	if keys, err := config.Redis.Keys(context.Background(), "idempotency:*").Result(); err == nil && len(keys) > 0 {
+		config.Redis.Del(context.Background(), keys...)
+	}
+
+	w = testRequestWithIdempotencyKey(s.router, "POST", path, s.token, "deposit-2", body)
+	s.Equal(http.StatusConflict, w.Code, w.Body.String())
+	s.NotEmpty(w.Header().Get("Retry-After"))
+	s.True(util.MustMoney("10.00").Equal(s.balanceOf(accountID)), s.balanceOf(accountID).String())
+}
+
+// listTransactions 查詢帳戶交易紀錄
+func (s *AccountTestSuite) listTransactions(accountID uint, query string) dto.TransactionListResponse {
+	w := testRequestWithToken(s.router, "GET", fmt.Sprintf("/accounts/%d/transactions?%s", accountID, query), s.token, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var page dto.TransactionListResponse
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+// 以游標逐頁讀取交易紀錄，由新到舊且不重複、不遺漏
+func (s *AccountTestSuite) TestTransactionHistoryCursorPagination() {
+	accountID := s.createAccount("History")
+	for i := 1; i <= 5; i++ {
+		w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", accountID), s.token,
+			map[string]interface{}{"amount": fmt.Sprintf("%d.00", i)})
+		s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var amounts []string
+	var lastID uint
+	cursor := ""
+	for pages := 0; ; pages++ {
+		s.Require().Less(pages, 3, "pagination did not end")
+		page := s.listTransactions(accountID, "limit=2&cursor="+cursor)
+		for _, transaction := range page.Transactions {
+			if lastID != 0 {
+				s.Less(transaction.ID, lastID)
+			}
+			lastID = transaction.ID
+			amounts = append(amounts, transaction.Amount.String())
+		}
+		if page.NextCursor == "" {
+			s.Len(page.Transactions, 1)
+			break
+		}
+		s.Len(page.Transactions, 2)
+		cursor = page.NextCursor
+	}
+	s.Equal([]string{"5.00", "4.00", "3.00", "2.00", "1.00"}, amounts)
+}
+
+// 依類型、狀態與時間區間篩選交易紀錄
+func (s *AccountTestSuite) TestTransactionHistoryFilters() {
+	source := s.createAccount("Source")
+	target := s.createAccount("Target")
+
+	for _, step := range []struct {
+		path string
+		body map[string]interface{}
+	}{
+		{"deposit", map[string]interface{}{"amount": "100.00"}},
+		{"deposit", map[string]interface{}{"amount": "20.00"}},
+		{"withdraw", map[string]interface{}{"amount": "10.00"}},
+		{"transfer", map[string]interface{}{"amount": "5.00", "target_account_id": target}},
+		{"transfer/init", map[string]interface{}{"amount": "7.00", "target_account_id": target}},
+	} {
+		w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/%s", source, step.path), s.token, step.body)
+		s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// 將第一筆存款移到過去，供時間區間篩選
+	var first model.Transaction
+	s.Require().NoError(config.DB.Where("to_account_id = ? AND type = ?", source, model.TransactionTypeDeposit).Order("id").First(&first).Error)
+	s.Require().NoError(config.DB.Model(&first).Update("created_at", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)).Error)
+
+	tests := []struct {
+		name    string
+		query   string
+		amounts []string
+	}{
+		{"all", "", []string{"7.00", "5.00", "10.00", "20.00", "100.00"}},
+		{"deposits", "type=deposit", []string{"20.00", "100.00"}},
+		{"withdrawals", "type=withdraw", []string{"10.00"}},
+		{"pending", "status=pending", []string{"7.00"}},
+		{"completed transfers", "type=transfer&status=completed", []string{"5.00"}},
+		{"inside date range", "from=2025-01-01T00:00:00Z&to=2025-01-02T00:00:00Z", []string{"100.00"}},
+		{"after date", "type=deposit&from=2025-06-01T00:00:00Z", []string{"20.00"}},
+		{"empty date range", "to=2024-12-31T23:59:59Z", nil},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			var amounts []string
+			for _, transaction := range s.listTransactions(source, tt.query).Transactions {
+				amounts = append(amounts, transaction.Amount.String())
+			}
+			s.Equal(tt.amounts, amounts)
+		})
+	}
+
+	// 目標帳戶只看到入帳的轉帳
+	page := s.listTransactions(target, "type=transfer")
+	s.Require().Len(page.Transactions, 2)
+	s.Equal("incoming", page.Transactions[0].Direction)
+}
+
+// 無效的游標與篩選條件回傳 400
+func (s *AccountTestSuite) TestTransactionHistoryRejectsBadQuery() {
+	accountID := s.createAccount("Bad Query")
+
+	for _, query := range []string{
+		"cursor=not-a-cursor!",
+		"cursor=" + base64.RawURLEncoding.EncodeToString([]byte("abc")),
+		"type=refund",
+		"status=lost",
+		"from=yesterday",
+		"limit=101",
+		"min_amount=ten",
+	} {
+		w := testRequestWithToken(s.router, "GET", fmt.Sprintf("/accounts/%d/transactions?%s", accountID, query), s.token, nil)
+		s.Equal(http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -2,16 +2,26 @@ package e2e
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/suite"
 
 	"go-gin-template/api"
 	"go-gin-template/api/config"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/service"
+	"go-gin-template/api/util"
 )
 
 type AuthTestSuite struct {
@@ -30,12 +40,12 @@ func (s *AuthTestSuite) SetupSuite() {
 		s.T().Logf("Warning: .env.test file not found: %v", err)
 	}
 
-	// 初始化資料庫連接
-	config.InitDB()
+	// 初始化 Redis 與資料庫連接；植入角色時會清除權限快取，所以先連 Redis
 	config.InitRedis()
+	config.InitDB()
 	
 	// 初始化路由
-	router := api.InitRouter()
+	router := api.InitRouter(service.NewNotificationService(repository.NewNotificationRepository(config.DB)))
 	s.router = router
 }
 
@@ -67,11 +77,7 @@ func (s *AuthTestSuite) TearDownSuite() {
 }
 
 func (s *AuthTestSuite) cleanTestData() {
-	db := config.DB
-	// 按順序刪除相關的外鍵數據
-	db.Exec("DELETE FROM accounts WHERE user_id IN (SELECT id FROM users WHERE email LIKE 'test%@example.com')")
-	db.Exec("DELETE FROM user_passwords WHERE user_id IN (SELECT id FROM users WHERE email LIKE 'test%@example.com')")
-	db.Exec("DELETE FROM users WHERE email LIKE 'test%@example.com'")
+	cleanTestData()
 }
 
 func (s *AuthTestSuite) TestRegisterAndLogin() {
@@ -132,3 +138,674 @@ func (s *AuthTestSuite) TestInvalidLogin() {
 
 	s.Equal(http.StatusUnauthorized, w.Code)
 }
+
+// 重複使用已輪替的 refresh token 會撤銷整個登入
+func (s *AuthTestSuite) TestRefreshTokenRotationAndReuse() {
+	registerAndLogin(s.router, "test-refresh@example.com", "Test123!@#")
+	w := testRequest(s.router, "POST", "/users/login", map[string]interface{}{
+		"email":    "test-refresh@example.com",
+		"password": "Test123!@#",
+	})
+	s.Require().Equal(http.StatusOK, w.Code)
+
+	var login map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &login))
+	refreshToken := login["refresh_token"]
+	s.Require().NotEmpty(refreshToken)
+
+	// 第一次使用會換發新的 token
+	w = testRequest(s.router, "POST", "/auth/refresh", map[string]interface{}{"refresh_token": refreshToken})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var rotated map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rotated))
+	s.NotEqual(refreshToken, rotated["refresh_token"])
+
+	// 再次使用舊的 token 會被拒絕，新的 token 也一併失效
+	w = testRequest(s.router, "POST", "/auth/refresh", map[string]interface{}{"refresh_token": refreshToken})
+	s.Equal(http.StatusUnauthorized, w.Code)
+	w = testRequest(s.router, "POST", "/auth/refresh", map[string]interface{}{"refresh_token": rotated["refresh_token"]})
+	s.Equal(http.StatusUnauthorized, w.Code)
+	w = testRequestWithToken(s.router, "GET", "/accounts", rotated["token"].(string), nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+// 登出後 access token 立即失效
+func (s *AuthTestSuite) TestLogoutRevokesAccessToken() {
+	token := registerAndLogin(s.router, "test-logout@example.com", "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	w := testRequestWithToken(s.router, "POST", "/auth/logout", token, nil)
+	s.Require().Equal(http.StatusNoContent, w.Code)
+
+	w = testRequestWithToken(s.router, "GET", "/accounts", token, nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+// 撤銷其他裝置的 session 後，該裝置的 token 立即失效
+func (s *AuthTestSuite) TestRevokeOtherSession() {
+	current := registerAndLogin(s.router, "test-session@example.com", "Test123!@#")
+	other := getAuthToken(s.router, "test-session@example.com", "Test123!@#")
+	s.Require().NotEmpty(current)
+	s.Require().NotEmpty(other)
+
+	w := testRequestWithToken(s.router, "GET", "/users/me/sessions", current, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var list struct {
+		Sessions []struct {
+			ID      string `json:"id"`
+			Current bool   `json:"current"`
+		} `json:"sessions"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &list))
+	s.Require().Len(list.Sessions, 2)
+
+	for _, session := range list.Sessions {
+		if !session.Current {
+			w = testRequestWithToken(s.router, "DELETE", "/users/me/sessions/"+session.ID, current, nil)
+			s.Equal(http.StatusNoContent, w.Code)
+		}
+	}
+
+	w = testRequestWithToken(s.router, "GET", "/accounts", other, nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+	w = testRequestWithToken(s.router, "GET", "/accounts", current, nil)
+	s.Equal(http.StatusOK, w.Code)
+}
+
+// 啟用兩步驟驗證後，登入需要以挑戰 token 加上驗證器產生的代碼完成
+func (s *AuthTestSuite) TestTwoFactorLogin() {
+	token := registerAndLogin(s.router, "test-2fa@example.com", "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	w := testRequestWithToken(s.router, "POST", "/users/me/2fa/totp", token, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &enrollment))
+
+	step := util.TOTPStep(time.Now())
+	code, err := util.TOTPCode(enrollment.Secret, step)
+	s.Require().NoError(err)
+	w = testRequestWithToken(s.router, "POST", "/users/me/2fa/totp/confirm", token, map[string]interface{}{"code": code})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var recovery struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &recovery))
+	s.Len(recovery.RecoveryCodes, 10)
+
+	// 密碼正確只會拿到挑戰 token
+	w = testRequest(s.router, "POST", "/users/login", map[string]interface{}{
+		"email":    "test-2fa@example.com",
+		"password": "Test123!@#",
+	})
+	s.Require().Equal(http.StatusOK, w.Code)
+	var challenge map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &challenge))
+	s.Equal(true, challenge["mfa_required"])
+	s.Nil(challenge["token"])
+	challengeToken := challenge["challenge_token"].(string)
+
+	// 已使用過的代碼不能重複使用
+	w = testRequest(s.router, "POST", "/users/login/2fa", map[string]interface{}{
+		"challenge_token": challengeToken,
+		"code":            code,
+	})
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	next, err := util.TOTPCode(enrollment.Secret, step+1)
+	s.Require().NoError(err)
+	w = testRequest(s.router, "POST", "/users/login/2fa", map[string]interface{}{
+		"challenge_token": challengeToken,
+		"code":            next,
+	})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var login map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &login))
+	s.NotEmpty(login["token"])
+
+	// 挑戰 token 只能使用一次
+	w = testRequest(s.router, "POST", "/users/login/2fa", map[string]interface{}{
+		"challenge_token": challengeToken,
+		"code":            recovery.RecoveryCodes[0],
+	})
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+// 錯誤的兩步驟驗證碼與錯誤密碼一樣計入失敗次數，達到門檻時鎖定帳號
+func (s *AuthTestSuite) TestTwoFactorFailuresLockAccount() {
+	const email = "test-2fa-lockout@example.com"
+	token := registerAndLogin(s.router, email, "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	w := testRequestWithToken(s.router, "POST", "/users/me/2fa/totp", token, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &enrollment))
+	code, err := util.TOTPCode(enrollment.Secret, util.TOTPStep(time.Now()))
+	s.Require().NoError(err)
+	w = testRequestWithToken(s.router, "POST", "/users/me/2fa/totp/confirm", token, map[string]interface{}{"code": code})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = testRequest(s.router, "POST", "/users/login", map[string]interface{}{
+		"email":    email,
+		"password": "Test123!@#",
+	})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var challenge map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &challenge))
+	challengeToken := challenge["challenge_token"].(string)
+
+	// 密碼正確不會清除失敗次數，直到兩步驟驗證完成
+	for i := 0; i < 5; i++ {
+		w = testRequest(s.router, "POST", "/users/login/2fa", map[string]interface{}{
+			"challenge_token": challengeToken,
+			"code":            "000000",
+		})
+		s.Equal(http.StatusUnauthorized, w.Code)
+	}
+
+	// 鎖定後即使密碼正確也無法再取得挑戰 token
+	s.Eventually(func() bool {
+		w := testRequest(s.router, "POST", "/users/login", map[string]interface{}{
+			"email":    email,
+			"password": "Test123!@#",
+		})
+		if w.Code == http.StatusTooManyRequests {
+			return false
+		}
+		s.Equal(http.StatusUnauthorized, w.Code, w.Body.String())
+		return true
+	}, 5*time.Second, 200*time.Millisecond)
+}
+
+// 變更密碼後舊的 token 失效，且不能改回最近使用過的密碼
+func (s *AuthTestSuite) TestChangePassword() {
+	token := registerAndLogin(s.router, "test-password@example.com", "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	w := testRequestWithToken(s.router, "PUT", "/users/me/password", token, map[string]interface{}{
+		"current_password": "wrong-password",
+		"new_password":     "NewPass456!",
+	})
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = testRequestWithToken(s.router, "PUT", "/users/me/password", token, map[string]interface{}{
+		"current_password": "Test123!@#",
+		"new_password":     "NewPass456!",
+	})
+	s.Require().Equal(http.StatusNoContent, w.Code, w.Body.String())
+
+	w = testRequestWithToken(s.router, "GET", "/accounts", token, nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+	s.Empty(getAuthToken(s.router, "test-password@example.com", "Test123!@#"))
+
+	token = getAuthToken(s.router, "test-password@example.com", "NewPass456!")
+	s.Require().NotEmpty(token)
+	w = testRequestWithToken(s.router, "PUT", "/users/me/password", token, map[string]interface{}{
+		"current_password": "NewPass456!",
+		"new_password":     "Test123!@#",
+	})
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+// 不符合密碼政策的密碼會以欄位錯誤回報
+func (s *AuthTestSuite) TestRegisterRejectsWeakPassword() {
+	w := testRequest(s.router, "POST", "/users/register", map[string]interface{}{
+		"email":    "test-weak@example.com",
+		"password": "password123",
+		"name":     "Test User",
+	})
+	s.Require().Equal(http.StatusBadRequest, w.Code)
+
+	var response struct {
+		Fields []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"fields"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	s.Require().NotEmpty(response.Fields)
+	for _, fieldErr := range response.Fields {
+		s.Equal("password", fieldErr.Field)
+	}
+	s.Empty(getAuthToken(s.router, "test-weak@example.com", "password123"))
+}
+
+// 連續登入失敗會延遲並鎖定帳號，鎖定時的回應與帳號不存在時相同，管理員可以解除鎖定
+func (s *AuthTestSuite) TestLoginLockoutAndAdminUnlock() {
+	const email = "test-lockout@example.com"
+	s.Require().NotEmpty(registerAndLogin(s.router, email, "Test123!@#"))
+
+	login := func(email, password string) *httptest.ResponseRecorder {
+		return testRequest(s.router, "POST", "/users/login", map[string]interface{}{
+			"email":    email,
+			"password": password,
+		})
+	}
+
+	// 不存在的帳號與錯誤密碼的回應完全相同
+	unknown := login("test-nobody@example.com", "Wrong123!@#")
+	s.Require().Equal(http.StatusUnauthorized, unknown.Code)
+
+	// 失敗次數超過免延遲次數後會回傳 429，等待後才能再試；達到門檻時鎖定
+	failures := 0
+	throttled := false
+	for failures < 5 {
+		w := login(email, "Wrong123!@#")
+		if w.Code == http.StatusTooManyRequests {
+			throttled = true
+			s.NotEmpty(w.Header().Get("Retry-After"))
+			time.Sleep(200 * time.Millisecond)
+			continue
+		}
+		s.Require().Equal(http.StatusUnauthorized, w.Code)
+		s.Equal(unknown.Body.String(), w.Body.String())
+		failures++
+	}
+	s.True(throttled)
+
+	// 鎖定後即使密碼正確也會被拒絕，且回應與錯誤密碼相同
+	s.Eventually(func() bool {
+		w := login(email, "Test123!@#")
+		if w.Code == http.StatusTooManyRequests {
+			return false
+		}
+		s.Equal(http.StatusUnauthorized, w.Code)
+		s.Equal(unknown.Body.String(), w.Body.String())
+		return true
+	}, 5*time.Second, 200*time.Millisecond)
+
+	// 使用者會收到帳號鎖定通知；此 suite 未啟動通知 worker，因此檢查 outbox
+	var lockedNotifications int64
+	s.Require().NoError(config.DB.Model(&model.NotificationMessage{}).
+		Where("recipient = ? AND payload LIKE ?", email, "%\"template_id\":\""+service.TemplateAccountLocked+"\"%").
+		Count(&lockedNotifications).Error)
+	s.Equal(int64(1), lockedNotifications)
+
+	// 一般使用者無法解除鎖定
+	var user model.User
+	s.Require().NoError(config.DB.Where("email = ?", email).First(&user).Error)
+	userToken := registerAndLogin(s.router, "test-lockout-user@example.com", "Test123!@#")
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/admin/users/%d/unlock", user.ID), userToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 管理員解除鎖定後即可用正確密碼登入
+	adminRole := model.Role{Name: "admin"}
+	s.Require().NoError(config.DB.Where(&adminRole).FirstOrCreate(&adminRole).Error)
+	s.Require().NotEmpty(registerAndLogin(s.router, "test-lockout-admin@example.com", "Test123!@#"))
+	s.Require().NoError(config.DB.Model(&model.User{}).Where("email = ?", "test-lockout-admin@example.com").Update("role_id", adminRole.ID).Error)
+	adminToken := getAuthToken(s.router, "test-lockout-admin@example.com", "Test123!@#")
+	s.Require().NotEmpty(adminToken)
+
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/admin/users/%d/unlock", user.ID), adminToken, nil)
+	s.Require().Equal(http.StatusNoContent, w.Code, w.Body.String())
+
+	s.Equal(http.StatusOK, login(email, "Test123!@#").Code)
+}
+
+func (s *AuthTestSuite) TestBookWriteRequiresPermission() {
+	book := map[string]interface{}{
+		"title":  "Test Book",
+		"author": "Test Author",
+		"isbn":   "test-rbac-1",
+	}
+
+	// 一般使用者沒有 books:write 權限
+	userToken := registerAndLogin(s.router, "test-rbac-user@example.com", "Test123!@#")
+	s.Require().NotEmpty(userToken)
+	w := testRequestWithToken(s.router, "POST", "/books", userToken, book)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 一般使用者也無法管理角色
+	w = testRequestWithToken(s.router, "GET", "/admin/roles", userToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 預設的 admin 角色擁有所有權限
+	s.Require().NotEmpty(registerAndLogin(s.router, "test-rbac-admin@example.com", "Test123!@#"))
+	var adminRole model.Role
+	s.Require().NoError(config.DB.Where("name = ?", model.RoleAdmin).First(&adminRole).Error)
+	s.Require().NoError(config.DB.Model(&model.User{}).Where("email = ?", "test-rbac-admin@example.com").Update("role_id", adminRole.ID).Error)
+	adminToken := getAuthToken(s.router, "test-rbac-admin@example.com", "Test123!@#")
+	s.Require().NotEmpty(adminToken)
+
+	// 建立擁有 books:write 的角色並指派給使用者
+	w = testRequestWithToken(s.router, "POST", "/admin/roles", adminToken, map[string]interface{}{
+		"name":        "test-librarian",
+		"permissions": []string{model.PermissionBooksWrite},
+	})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var role map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &role))
+
+	var user model.User
+	s.Require().NoError(config.DB.Where("email = ?", "test-rbac-user@example.com").First(&user).Error)
+	w = testRequestWithToken(s.router, "PUT", fmt.Sprintf("/admin/users/%d/role", user.ID), adminToken, map[string]interface{}{
+		"role": "test-librarian",
+	})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// 變更角色會登出所有裝置，舊 token 失效
+	w = testRequestWithToken(s.router, "POST", "/books", userToken, book)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	userToken = getAuthToken(s.router, "test-rbac-user@example.com", "Test123!@#")
+	w = testRequestWithToken(s.router, "POST", "/books", userToken, book)
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+
+	// 移除角色的權限後立即生效
+	w = testRequestWithToken(s.router, "PUT", fmt.Sprintf("/admin/roles/%.0f", role["id"]), adminToken, map[string]interface{}{
+		"name":        "test-librarian",
+		"permissions": []string{},
+	})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	book["isbn"] = "test-rbac-2"
+	w = testRequestWithToken(s.router, "POST", "/books", userToken, book)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 仍有使用者的角色不能刪除
+	w = testRequestWithToken(s.router, "DELETE", fmt.Sprintf("/admin/roles/%.0f", role["id"]), adminToken, nil)
+	s.Equal(http.StatusConflict, w.Code)
+}
+
+// 植入角色後會清除權限快取，新加入 admin 的權限立即生效
+func (s *AuthTestSuite) TestSeedRolesRefreshesCachedPermissions() {
+	s.Require().NotEmpty(registerAndLogin(s.router, "test-seed-admin@example.com", "Test123!@#"))
+	var adminRole model.Role
+	s.Require().NoError(config.DB.Where("name = ?", model.RoleAdmin).First(&adminRole).Error)
+	s.Require().NoError(config.DB.Model(&model.User{}).Where("email = ?", "test-seed-admin@example.com").Update("role_id", adminRole.ID).Error)
+	adminToken := getAuthToken(s.router, "test-seed-admin@example.com", "Test123!@#")
+	s.Require().NotEmpty(adminToken)
+
+	// 模擬部署前快取的權限，還沒有新加入的權限
+	key := model.RolePermissionsCacheKey(model.RoleAdmin)
+	s.Require().NoError(config.Redis.Set(context.Background(), key, "[]", time.Minute).Err())
+	w := testRequestWithToken(s.router, "GET", "/admin/roles", adminToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	s.Require().NoError(config.SeedRoles(config.DB))
+	w = testRequestWithToken(s.router, "GET", "/admin/roles", adminToken, nil)
+	s.Equal(http.StatusOK, w.Code, w.Body.String())
+}
+
+// 第三方應用程式透過授權碼 + PKCE 取得 token，只能存取同意的 scope
+func (s *AuthTestSuite) TestOAuthAuthorizationCodeFlow() {
+	token := registerAndLogin(s.router, "test-oauth@example.com", "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	// 註冊機密客戶端，secret 只在註冊時回傳一次
+	w := testRequestWithToken(s.router, "POST", "/oauth/clients", token, map[string]interface{}{
+		"name":          "Test Budget App",
+		"type":          "confidential",
+		"redirect_uris": []string{"https://app.example.com/callback"},
+		"scopes":        []string{model.ScopeAccountsRead, model.ScopeTransfersWrite},
+	})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var client map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &client))
+	clientID := client["client_id"].(string)
+	clientSecret := client["client_secret"].(string)
+	s.Require().NotEmpty(clientSecret)
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	authorize := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"scope":                 {model.ScopeAccountsRead},
+		"state":                 {"test-state"},
+		"code_challenge":        {util.PKCEChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	// 同意畫面列出要求的 scope
+	w = testRequestWithToken(s.router, "GET", "/oauth/authorize?"+authorize.Encode(), token, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var consent map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &consent))
+	s.Equal(true, consent["consent_required"])
+
+	// 使用者同意後回傳帶有授權碼的 redirect URI
+	w = testRequestWithToken(s.router, "POST", "/oauth/authorize", token, map[string]interface{}{
+		"response_type":         "code",
+		"client_id":             clientID,
+		"scope":                 model.ScopeAccountsRead,
+		"state":                 "test-state",
+		"code_challenge":        util.PKCEChallenge(verifier),
+		"code_challenge_method": "S256",
+		"approve":               true,
+	})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var decision map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &decision))
+	redirect, err := url.Parse(decision["redirect_uri"].(string))
+	s.Require().NoError(err)
+	s.Equal("test-state", redirect.Query().Get("state"))
+	code := redirect.Query().Get("code")
+	s.Require().NotEmpty(code)
+
+	// code_verifier 錯誤時拒絕交換
+	exchange := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "code_verifier": {verifier + "x"}}
+	w = testFormRequest(s.router, "/oauth/token", clientID, clientSecret, exchange)
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	exchange.Set("code_verifier", verifier)
+	w = testFormRequest(s.router, "/oauth/token", clientID, clientSecret, exchange)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var tokens map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &tokens))
+	s.Equal(model.ScopeAccountsRead, tokens["scope"])
+	accessToken := tokens["access_token"].(string)
+
+	// access token 只能使用同意的 scope，且不能呼叫非開放給第三方的端點
+	w = testRequestWithToken(s.router, "GET", "/accounts", accessToken, nil)
+	s.Equal(http.StatusOK, w.Code)
+	w = testRequestWithToken(s.router, "POST", "/accounts/1/transfer/init", accessToken, map[string]interface{}{"to_account_id": 2, "amount": "1.00"})
+	s.Equal(http.StatusForbidden, w.Code)
+	w = testRequestWithToken(s.router, "GET", "/users/me/sessions", accessToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// introspection 回報 token 的 scope 與客戶端
+	w = testFormRequest(s.router, "/oauth/introspect", clientID, clientSecret, url.Values{"token": {accessToken}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var introspection map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &introspection))
+	s.Equal(true, introspection["active"])
+	s.Equal(clientID, introspection["client_id"])
+
+	// refresh token 每次使用都會輪替
+	w = testFormRequest(s.router, "/oauth/token", clientID, clientSecret, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens["refresh_token"].(string)}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var refreshed map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &refreshed))
+	s.NotEqual(tokens["refresh_token"], refreshed["refresh_token"])
+
+	// 授權碼重複使用會撤銷它換發的整組 token
+	w = testFormRequest(s.router, "/oauth/token", clientID, clientSecret, exchange)
+	s.Equal(http.StatusBadRequest, w.Code)
+	w = testRequestWithToken(s.router, "GET", "/accounts", refreshed["access_token"].(string), nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	// 撤銷後 client credentials 的 token 立即失效
+	w = testFormRequest(s.router, "/oauth/token", clientID, clientSecret, url.Values{"grant_type": {"client_credentials"}})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var clientTokens map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &clientTokens))
+	w = testFormRequest(s.router, "/oauth/revoke", clientID, clientSecret, url.Values{"token": {clientTokens["access_token"].(string)}})
+	s.Equal(http.StatusOK, w.Code)
+	w = testRequestWithToken(s.router, "GET", "/accounts", clientTokens["access_token"].(string), nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func (s *AuthTestSuite) TestAPIKeySignedRequests() {
+	token := registerAndLogin(s.router, "test-apikey@example.com", "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	// 發行 API key，secret 只在發行時回傳一次
+	w := testRequestWithToken(s.router, "POST", "/users/me/api-keys", token, map[string]interface{}{"name": "Test Service"})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var key struct {
+		ID     uint   `json:"id"`
+		KeyID  string `json:"key_id"`
+		Secret string `json:"secret"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &key))
+	s.Require().NotEmpty(key.Secret)
+
+	// 簽章的請求與 Bearer token 一樣代表使用者
+	w = testSignedRequest(s.router, "GET", "/accounts", key.KeyID, key.Secret, "test-nonce-1", nil)
+	s.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// 相同的 nonce 不能重複使用
+	w = testSignedRequest(s.router, "GET", "/accounts", key.KeyID, key.Secret, "test-nonce-1", nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	// 錯誤的 secret 簽出的請求被拒絕
+	w = testSignedRequest(s.router, "GET", "/accounts", key.KeyID, "wrong-secret", "test-nonce-2", nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+
+	// 超過上限的請求內容不會整個讀進記憶體
+	w = testSignedRequest(s.router, "POST", "/accounts", key.KeyID, key.Secret, "test-nonce-large",
+		map[string]interface{}{"name": strings.Repeat("x", int(config.GetAPIKeyConfig().MaxBodyBytes))})
+	s.Equal(http.StatusRequestEntityTooLarge, w.Code)
+
+	// API key 不能管理 API key
+	w = testSignedRequest(s.router, "POST", "/users/me/api-keys", key.KeyID, key.Secret, "test-nonce-3", map[string]interface{}{"name": "Another"})
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 輪替後舊 key 在寬限期內仍可使用
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/users/me/api-keys/%d/rotate", key.ID), token, nil)
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var rotated struct {
+		ID     uint   `json:"id"`
+		KeyID  string `json:"key_id"`
+		Secret string `json:"secret"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rotated))
+	w = testSignedRequest(s.router, "GET", "/accounts", key.KeyID, key.Secret, "test-nonce-4", nil)
+	s.Equal(http.StatusOK, w.Code)
+	w = testSignedRequest(s.router, "GET", "/accounts", rotated.KeyID, rotated.Secret, "test-nonce-5", nil)
+	s.Equal(http.StatusOK, w.Code)
+
+	// 撤銷後立即失效
+	w = testRequestWithToken(s.router, "DELETE", fmt.Sprintf("/users/me/api-keys/%d", key.ID), token, nil)
+	s.Require().Equal(http.StatusNoContent, w.Code, w.Body.String())
+	w = testSignedRequest(s.router, "GET", "/accounts", key.KeyID, key.Secret, "test-nonce-6", nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+}
+
+// 同時發行多把 API key 時，有效的 key 也不會超過上限
+func (s *AuthTestSuite) TestAPIKeyLimitHoldsUnderConcurrentRequests() {
+	token := registerAndLogin(s.router, "test-apikey-limit@example.com", "Test123!@#")
+	s.Require().NotEmpty(token)
+	limit := config.GetAPIKeyConfig().MaxKeysPerUser
+
+	var wg sync.WaitGroup
+	codes := make(chan int, limit*2)
+	for i := 0; i < limit*2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := testRequestWithToken(s.router, "POST", "/users/me/api-keys", token, map[string]interface{}{"name": "Test Service"})
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else {
+			s.Equal(http.StatusConflict, code)
+		}
+	}
+	s.Equal(limit, created)
+
+	w := testRequestWithToken(s.router, "GET", "/users/me/api-keys", token, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var keys []map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &keys))
+	s.Len(keys, limit)
+}
+
+// 未驗證電子郵件的使用者不能發行 API key 或授權第三方應用，清除未驗證帳號時一併刪除這些資料
+func (s *AuthTestSuite) TestUnverifiedUserCannotIssueKeysOrAuthoriseApps() {
+	const email = "test-unverified-keys@example.com"
+	w := testRequest(s.router, "POST", "/users/register", map[string]interface{}{
+		"email":    email,
+		"password": "Test123!@#",
+		"name":     "Test User",
+	})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	token := getAuthToken(s.router, email, "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	w = testRequestWithToken(s.router, "POST", "/users/me/api-keys", token, map[string]interface{}{"name": "Test Service"})
+	s.Equal(http.StatusForbidden, w.Code)
+
+	w = testRequestWithToken(s.router, "POST", "/oauth/authorize", token, map[string]interface{}{
+		"response_type":         "code",
+		"client_id":             "any-client",
+		"scope":                 model.ScopeAccountsRead,
+		"code_challenge":        util.PKCEChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"),
+		"code_challenge_method": "S256",
+		"redirect_uri":          "https://app.example.com/callback",
+	})
+	s.Equal(http.StatusForbidden, w.Code)
+
+	// 在檢查加入前發行的 key 不會讓清除失敗
+	var user model.User
+	s.Require().NoError(config.DB.Where("email = ?", email).First(&user).Error)
+	s.Require().NoError(config.DB.Create(&model.APIKey{
+		UserID:           user.ID,
+		KeyID:            "test-unverified-key",
+		Name:             "Old Service",
+		SecretCiphertext: "sealed",
+	}).Error)
+
+	purged, err := repository.NewUserRepository(config.DB).PurgeUnverified(time.Now().Add(time.Minute))
+	s.Require().NoError(err)
+	s.GreaterOrEqual(purged, int64(1))
+
+	var remaining int64
+	s.Require().NoError(config.DB.Model(&model.User{}).Where("id = ?", user.ID).Count(&remaining).Error)
+	s.Zero(remaining)
+	s.Require().NoError(config.DB.Model(&model.APIKey{}).Where("user_id = ?", user.ID).Count(&remaining).Error)
+	s.Zero(remaining)
+}
+
+// 第三方應用只能以使用者本人的身分存取，不會取得使用者角色的權限
+func (s *AuthTestSuite) TestThirdPartyAppGetsNoStaffPermissions() {
+	customerToken := registerAndLogin(s.router, "test-staff-customer@example.com", "Test123!@#")
+	s.Require().NotEmpty(customerToken)
+	w := testRequestWithToken(s.router, "POST", "/accounts", customerToken, map[string]interface{}{"name": "Customer"})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var customerAccount struct {
+		ID uint `json:"id"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &customerAccount))
+
+	// 擁有 accounts:read 的職員可以讀取任何客戶的交易
+	s.Require().NotEmpty(registerAndLogin(s.router, "test-staff-teller@example.com", "Test123!@#"))
+	permission := model.Permission{Name: model.PermissionAccountsRead}
+	s.Require().NoError(config.DB.Where("name = ?", permission.Name).FirstOrCreate(&permission).Error)
+	role := model.Role{Name: "test-teller", Permissions: []model.Permission{permission}}
+	s.Require().NoError(config.DB.Create(&role).Error)
+	s.Require().NoError(config.DB.Model(&model.User{}).Where("email = ?", "test-staff-teller@example.com").Update("role_id", role.ID).Error)
+	tellerToken := getAuthToken(s.router, "test-staff-teller@example.com", "Test123!@#")
+	s.Require().NotEmpty(tellerToken)
+
+	transactionsPath := fmt.Sprintf("/accounts/%d/transactions", customerAccount.ID)
+	w = testRequestWithToken(s.router, "GET", transactionsPath, tellerToken, nil)
+	s.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// 職員授權的應用程式即使有 accounts:read scope 也不能讀取客戶的帳戶
+	appToken := thirdPartyToken(s.router, tellerToken, model.ScopeAccountsRead)
+	s.Require().NotEmpty(appToken)
+	w = testRequestWithToken(s.router, "GET", transactionsPath, appToken, nil)
+	s.Equal(http.StatusForbidden, w.Code)
+}
@@ -2,9 +2,19 @@ package e2e
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/middleware"
+	"go-gin-template/api/model"
+	"go-gin-template/api/util"
 )
 
 // testRequest 是一個輔助函數，用於發送測試請求
@@ -31,7 +41,7 @@ func getAuthToken(router http.Handler, email, password string) string {
 		"password": password,
 	}
 	
-	w := testRequest(router, "POST", "/api/v1/auth/login", loginBody)
+	w := testRequest(router, "POST", "/users/login", loginBody)
 	if w.Code != http.StatusOK {
 		return ""
 	}
@@ -44,3 +54,167 @@ func getAuthToken(router http.Handler, email, password string) string {
 	}
 	return token
 }
+
+// testRequestWithToken 發送帶有 Bearer token 的測試請求
+func testRequestWithToken(router http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var reqBody *bytes.Buffer
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	} else {
+		reqBody = bytes.NewBuffer(nil)
+	}
+
+	req, _ := http.NewRequest(method, path, reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// testRequestWithIdempotencyKey 發送帶有 Bearer token 與 Idempotency-Key 的測試請求
+func testRequestWithIdempotencyKey(router http.Handler, method, path, token, key string, body interface{}) *httptest.ResponseRecorder {
+	jsonBody, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(middleware.IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// testFormRequest 以表單格式發送 OAuth 端點的請求，並以 HTTP Basic 驗證客戶端
+func testFormRequest(router http.Handler, path, clientID, clientSecret string, values url.Values) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// testSignedRequest 發送以 API key 簽章的測試請求，時間戳記為目前時間
+func testSignedRequest(router http.Handler, method, path, keyID, secret, nonce string, body interface{}) *httptest.ResponseRecorder {
+	var jsonBody []byte
+	if body != nil {
+		jsonBody, _ = json.Marshal(body)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := util.SignRequest(secret, util.SignedRequest{
+		Method:    method,
+		Path:      path,
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		Body:      jsonBody,
+	})
+
+	req, _ := http.NewRequest(method, path, bytes.NewReader(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "HMAC-SHA256 "+keyID+":"+signature)
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Nonce", nonce)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// registerAndLogin 註冊測試用戶並回傳 token；電子郵件直接標記為已驗證，
+// 完整的驗證流程由 TestEmailVerificationWithLinkFromInbox 涵蓋
+func registerAndLogin(router http.Handler, email, password string) string {
+	registerBody := map[string]interface{}{
+		"email":    email,
+		"password": password,
+		"name":     "Test User",
+	}
+	testRequest(router, "POST", "/users/register", registerBody)
+	config.DB.Model(&model.User{}).Where("email = ?", email).Update("status", model.UserStatusActive)
+	return getAuthToken(router, email, password)
+}
+
+// thirdPartyToken 註冊一個第三方應用，並以使用者的同意透過授權碼流程取得帶有指定 scope 的 access token
+func thirdPartyToken(router http.Handler, userToken, scope string) string {
+	w := testRequestWithToken(router, "POST", "/oauth/clients", userToken, map[string]interface{}{
+		"name":          "Test Third-Party App",
+		"type":          "confidential",
+		"redirect_uris": []string{"https://app.example.com/callback"},
+		"scopes":        []string{scope},
+	})
+	var client map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &client)
+	clientID, _ := client["client_id"].(string)
+	clientSecret, _ := client["client_secret"].(string)
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	w = testRequestWithToken(router, "POST", "/oauth/authorize", userToken, map[string]interface{}{
+		"response_type":         "code",
+		"client_id":             clientID,
+		"scope":                 scope,
+		"code_challenge":        util.PKCEChallenge(verifier),
+		"code_challenge_method": "S256",
+		"approve":               true,
+	})
+	var decision map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &decision)
+	redirectURI, _ := decision["redirect_uri"].(string)
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return ""
+	}
+
+	w = testFormRequest(router, "/oauth/token", clientID, clientSecret, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {redirect.Query().Get("code")},
+		"code_verifier": {verifier},
+	})
+	var tokens map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &tokens)
+	accessToken, _ := tokens["access_token"].(string)
+	return accessToken
+}
+
+// cleanTestData 按順序刪除測試用戶及其相關的外鍵數據
+func cleanTestData() {
+	db := config.DB
+	testUsers := "SELECT id FROM users WHERE email LIKE 'test%@example.com'"
+	testAccounts := "SELECT id FROM accounts WHERE user_id IN (" + testUsers + ")"
+	testTransactions := "SELECT id FROM transactions WHERE from_account_id IN (" + testAccounts + ") OR to_account_id IN (" + testAccounts + ")"
+	db.Exec("DELETE FROM open_banking_consent_accounts WHERE account_id IN (" + testAccounts + ")")
+	db.Exec("DELETE FROM open_banking_consents WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM transaction_verifications WHERE transaction_id IN (" + testTransactions + ")")
+	db.Exec("DELETE FROM postings WHERE journal_entry_id IN (SELECT id FROM journal_entries WHERE transaction_id IN (" + testTransactions + "))")
+	db.Exec("DELETE FROM journal_entries WHERE transaction_id IN (" + testTransactions + ")")
+	db.Exec("DELETE FROM transactions WHERE id IN (" + testTransactions + ")")
+	db.Exec("DELETE FROM idempotency_records WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM accounts WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM user_passwords WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM user_contacts WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM refresh_tokens WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM sessions WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM password_reset_tokens WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM recovery_codes WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM user_totps WHERE user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM api_keys WHERE user_id IN (" + testUsers + ")")
+	testClients := "SELECT id FROM o_auth_clients WHERE owner_id IN (" + testUsers + ")"
+	db.Exec("DELETE FROM o_auth_authorization_codes WHERE client_id IN (" + testClients + ") OR user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM o_auth_refresh_tokens WHERE client_id IN (" + testClients + ") OR user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM o_auth_consents WHERE client_id IN (" + testClients + ") OR user_id IN (" + testUsers + ")")
+	db.Exec("DELETE FROM o_auth_clients WHERE id IN (" + testClients + ")")
+	db.Exec("DELETE FROM notification_messages WHERE recipient LIKE 'test%@example.com'")
+	db.Exec("DELETE FROM users WHERE email LIKE 'test%@example.com'")
+	db.Exec("DELETE FROM books WHERE isbn LIKE 'test-%'")
+	db.Exec("DELETE FROM role_permissions WHERE role_id IN (SELECT id FROM roles WHERE name LIKE 'test-%')")
+	db.Exec("DELETE FROM roles WHERE name LIKE 'test-%'")
+
+	// 清除登入失敗計數，避免同一個測試 IP 的失敗次數累積到下一個測試；
+	// 同時清除測試角色的權限快取、快取的冪等回應與待確認的聯絡方式驗證碼
+	if config.Redis != nil {
+		ctx := context.Background()
+		for _, pattern := range []string{"login:*", "rbac:role:test-*", "idempotency:*", "contact:*"} {
+			if keys, err := config.Redis.Keys(ctx, pattern).Result(); err == nil && len(keys) > 0 {
+				config.Redis.Del(ctx, keys...)
+			}
+		}
+	}
+}
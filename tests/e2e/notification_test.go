@@ -0,0 +1,148 @@
+package e2e
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/suite"
+
+	"go-gin-template/api/config"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+)
+
+const notificationTestRecipient = "test-notify@example.com"
+
+type NotificationTestSuite struct {
+	suite.Suite
+	repo repository.NotificationRepository
+}
+
+func TestNotificationSuite(t *testing.T) {
+	suite.Run(t, new(NotificationTestSuite))
+}
+
+func (s *NotificationTestSuite) SetupSuite() {
+	// 載入測試環境配置
+	err := godotenv.Load("../../tests/e2e/.env.test")
+	if err != nil {
+		s.T().Logf("Warning: .env.test file not found: %v", err)
+	}
+
+	config.InitDB()
+	s.repo = repository.NewNotificationRepository(config.DB)
+}
+
+func (s *NotificationTestSuite) SetupTest() {
+	cleanTestData()
+}
+
+func (s *NotificationTestSuite) TearDownTest() {
+	cleanTestData()
+}
+
+func (s *NotificationTestSuite) TearDownSuite() {
+	cleanTestData()
+	if sqlDB, err := config.DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// claim 建立一則訊息並由 worker 領取
+func (s *NotificationTestSuite) claim(payload string, replayable bool) *model.NotificationMessage {
+	message := &model.NotificationMessage{
+		Channel:     "email",
+		Recipient:   notificationTestRecipient,
+		Payload:     payload,
+		Replayable:  replayable,
+		MaxAttempts: 8,
+	}
+	s.Require().NoError(s.repo.Create(message))
+
+	claimed, err := s.repo.ClaimDue(100, time.Minute)
+	s.Require().NoError(err)
+	for _, candidate := range claimed {
+		if candidate.ID == message.ID {
+			s.Require().NotEmpty(candidate.LeaseOwner)
+			return candidate
+		}
+	}
+	s.FailNow("message was not claimed")
+	return nil
+}
+
+// 租約過期後被其他 worker 領取，原本的 worker 無法再記錄結果
+func (s *NotificationTestSuite) TestOnlyLeaseOwnerRecordsOutcome() {
+	first := s.claim(`{"template_id":"login_alert"}`, true)
+
+	// 讓租約過期，由另一個 worker 重新領取
+	s.Require().NoError(config.DB.Model(&model.NotificationMessage{}).Where("id = ?", first.ID).
+		Update("locked_until", time.Now().Add(-time.Second)).Error)
+	reclaimed, err := s.repo.ClaimDue(100, time.Minute)
+	s.Require().NoError(err)
+	var second *model.NotificationMessage
+	for _, candidate := range reclaimed {
+		if candidate.ID == first.ID {
+			second = candidate
+		}
+	}
+	s.Require().NotNil(second)
+	s.NotEqual(first.LeaseOwner, second.LeaseOwner)
+	s.Equal(2, second.Attempts)
+
+	s.ErrorIs(s.repo.MarkFailed(first.ID, first.LeaseOwner, "timeout", time.Now()), repository.ErrNotificationLeaseLost)
+	s.ErrorIs(s.repo.MarkDead(first.ID, first.LeaseOwner, "timeout"), repository.ErrNotificationLeaseLost)
+	s.Require().NoError(s.repo.MarkSent(second.ID, second.LeaseOwner))
+
+	message, err := s.repo.FindByID(first.ID)
+	s.Require().NoError(err)
+	s.Equal(model.NotificationStatusSent, message.Status)
+	s.Empty(message.Payload)
+}
+
+// 失敗的訊息依排程重試，重試前不會再被領取
+func (s *NotificationTestSuite) TestFailedMessageIsRetriedWhenDue() {
+	message := s.claim(`{"template_id":"login_alert"}`, true)
+	s.Require().NoError(s.repo.MarkFailed(message.ID, message.LeaseOwner, "timeout", time.Now().Add(time.Hour)))
+
+	claimed, err := s.repo.ClaimDue(100, time.Minute)
+	s.Require().NoError(err)
+	for _, candidate := range claimed {
+		s.NotEqual(message.ID, candidate.ID)
+	}
+
+	stored, err := s.repo.FindByID(message.ID)
+	s.Require().NoError(err)
+	s.Equal(model.NotificationStatusPending, stored.Status)
+	s.Equal("timeout", stored.LastError)
+	s.Equal(1, stored.Attempts)
+}
+
+// 含一次性驗證碼的訊息放棄後清除內容，且不能重送
+func (s *NotificationTestSuite) TestDeadOneTimeMessageIsNotReplayed() {
+	message := s.claim(`{"template_id":"verification_code","variables":{"Code":"123456"}}`, false)
+	s.Require().NoError(s.repo.MarkDead(message.ID, message.LeaseOwner, "mailbox unavailable"))
+
+	stored, err := s.repo.FindByID(message.ID)
+	s.Require().NoError(err)
+	s.Equal(model.NotificationStatusDead, stored.Status)
+	s.Empty(stored.Payload)
+
+	s.ErrorIs(s.repo.Replay(message.ID), repository.ErrNotificationExpired)
+}
+
+// 一般訊息放棄後保留內容，重送時重新計算嘗試次數
+func (s *NotificationTestSuite) TestDeadMessageIsReplayed() {
+	message := s.claim(`{"template_id":"transfer_receipt"}`, true)
+	s.Require().NoError(s.repo.MarkDead(message.ID, message.LeaseOwner, "mailbox unavailable"))
+
+	s.Require().NoError(s.repo.Replay(message.ID))
+	stored, err := s.repo.FindByID(message.ID)
+	s.Require().NoError(err)
+	s.Equal(model.NotificationStatusPending, stored.Status)
+	s.Equal(0, stored.Attempts)
+	s.NotEmpty(stored.Payload)
+
+	s.ErrorIs(s.repo.Replay(message.ID), repository.ErrNotificationNotDead)
+}
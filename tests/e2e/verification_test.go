@@ -0,0 +1,455 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/stretchr/testify/suite"
+
+	"go-gin-template/api"
+	"go-gin-template/api/config"
+	"go-gin-template/api/dto"
+	"go-gin-template/api/model"
+	"go-gin-template/api/repository"
+	"go-gin-template/api/service"
+	"go-gin-template/api/util"
+)
+
+const verificationTestEmail = "test-verify@example.com"
+
+type VerificationTestSuite struct {
+	suite.Suite
+	router              http.Handler
+	notificationService service.NotificationService
+	token               string
+}
+
+func TestVerificationSuite(t *testing.T) {
+	suite.Run(t, new(VerificationTestSuite))
+}
+
+func (s *VerificationTestSuite) SetupSuite() {
+	// 載入測試環境配置，通知會被擷取到 /dev/inbox
+	err := godotenv.Load("../../tests/e2e/.env.test")
+	if err != nil {
+		s.T().Logf("Warning: .env.test file not found: %v", err)
+	}
+
+	config.InitRedis()
+	config.InitDB()
+
+	// 啟動通知 worker，驗證碼才會送達收件匣
+	s.notificationService = service.NewNotificationService(repository.NewNotificationRepository(config.DB))
+	s.notificationService.Start()
+	s.router = api.InitRouter(s.notificationService)
+}
+
+func (s *VerificationTestSuite) SetupTest() {
+	cleanTestData()
+	s.token = registerAndLogin(s.router, verificationTestEmail, "Test123!@#")
+	s.Require().NotEmpty(s.token)
+}
+
+func (s *VerificationTestSuite) TearDownTest() {
+	cleanTestData()
+}
+
+func (s *VerificationTestSuite) TearDownSuite() {
+	s.notificationService.WaitForCompletion()
+	cleanTestData()
+	if sqlDB, err := config.DB.DB(); err == nil {
+		sqlDB.Close()
+	}
+	config.Redis.Close()
+}
+
+// readCode 輪詢收件匣，直到收到指定驗證對應的驗證碼
+func (s *VerificationTestSuite) readCode(notificationID uint) string {
+	reference := fmt.Sprintf("%d", notificationID)
+	message := s.waitForMessage(verificationTestEmail, func(message service.CapturedMessage) bool {
+		return message.Reference == reference && message.TemplateID == service.TemplateVerificationCode
+	})
+	return message.Variables["Code"]
+}
+
+// waitForMessage 輪詢收件匣，直到出現符合條件的訊息
+func (s *VerificationTestSuite) waitForMessage(to string, match func(service.CapturedMessage) bool) service.CapturedMessage {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		w := testRequest(s.router, "GET", "/dev/inbox?to="+url.QueryEscape(to), nil)
+		s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+		var inbox struct {
+			Messages []service.CapturedMessage `json:"messages"`
+		}
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &inbox))
+		for _, message := range inbox.Messages {
+			if match(message) {
+				return message
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	s.FailNow("message was not delivered to the inbox")
+	return service.CapturedMessage{}
+}
+
+// openAccounts 開立指定名稱的帳戶並回傳帳戶 ID
+func (s *VerificationTestSuite) openAccounts(names ...string) []uint {
+	var accountIDs []uint
+	for _, name := range names {
+		w := testRequestWithToken(s.router, "POST", "/accounts", s.token, map[string]interface{}{"name": name})
+		s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+		var account struct {
+			ID uint `json:"id"`
+		}
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &account))
+		accountIDs = append(accountIDs, account.ID)
+	}
+	return accountIDs
+}
+
+// transferChallenge 是發起轉帳並寄出驗證碼後的識別資訊
+type transferChallenge struct {
+	TransactionID  uint
+	VerificationID uint `json:"verification_id"`
+	NotificationID uint `json:"notification_id"`
+}
+
+// startTransfer 發起需要驗證的轉帳，並以電子郵件寄出驗證碼
+func (s *VerificationTestSuite) startTransfer(source, target uint, amount string) transferChallenge {
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/transfer/init", source), s.token,
+		map[string]interface{}{"amount": amount, "target_account_id": target})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var initiated struct {
+		TransactionID uint `json:"transaction_id"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &initiated))
+
+	w = testRequestWithToken(s.router, "POST", "/verifications", s.token,
+		map[string]interface{}{"transaction_id": initiated.TransactionID, "type": "email"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var challenge transferChallenge
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &challenge))
+	challenge.TransactionID = initiated.TransactionID
+	return challenge
+}
+
+// 從收件匣讀取驗證碼完成兩步驟轉帳
+func (s *VerificationTestSuite) TestTransferVerifiedWithCodeFromInbox() {
+	accountIDs := s.openAccounts("Source", "Target")
+	source, target := accountIDs[0], accountIDs[1]
+
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", source), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// 發起需要驗證的轉帳
+	challenge := s.startTransfer(source, target, "40.00")
+
+	code := s.readCode(challenge.NotificationID)
+	s.Require().Len(code, 6)
+
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/verifications/%d/verify", challenge.VerificationID), s.token,
+		map[string]interface{}{"code": code})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	accountRepo := repository.NewAccountRepository(config.DB)
+	sourceAccount, err := accountRepo.FindByID(source)
+	s.Require().NoError(err)
+	targetAccount, err := accountRepo.FindByID(target)
+	s.Require().NoError(err)
+	s.True(util.MustMoney("60.00").Equal(sourceAccount.Balance), sourceAccount.Balance.String())
+	s.True(util.MustMoney("40.00").Equal(targetAccount.Balance), targetAccount.Balance.String())
+}
+
+// 驗證時餘額已不足，轉帳與驗證一併記錄為失敗，不會停在已驗證狀態
+func (s *VerificationTestSuite) TestTransferFailsWhenBalanceDropsBeforeVerification() {
+	accountIDs := s.openAccounts("Source", "Target")
+	source, target := accountIDs[0], accountIDs[1]
+
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", source), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	challenge := s.startTransfer(source, target, "80.00")
+	code := s.readCode(challenge.NotificationID)
+
+	// 發起轉帳後餘額減少
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/withdraw", source), s.token,
+		map[string]interface{}{"amount": "50.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/verifications/%d/verify", challenge.VerificationID), s.token,
+		map[string]interface{}{"code": code})
+	s.Equal(http.StatusBadRequest, w.Code, w.Body.String())
+
+	transaction, err := repository.NewTransactionRepository(config.DB).FindByID(challenge.TransactionID)
+	s.Require().NoError(err)
+	s.Equal(model.TransactionStatusFailed, transaction.Status)
+
+	// 同一驗證碼無法再次執行轉帳
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/verifications/%d/verify", challenge.VerificationID), s.token,
+		map[string]interface{}{"code": code})
+	s.Equal(http.StatusBadRequest, w.Code, w.Body.String())
+
+	sourceAccount, err := repository.NewAccountRepository(config.DB).FindByID(source)
+	s.Require().NoError(err)
+	s.True(util.MustMoney("50.00").Equal(sourceAccount.Balance), sourceAccount.Balance.String())
+}
+
+// wrongCode 回傳一個與正確驗證碼不同的六位數
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+// 連續輸入錯誤驗證碼達上限後，驗證與轉帳都被取消
+func (s *VerificationTestSuite) TestTooManyWrongCodesCancelTransfer() {
+	accountIDs := s.openAccounts("Source", "Target")
+	source, target := accountIDs[0], accountIDs[1]
+
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", source), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	challenge := s.startTransfer(source, target, "40.00")
+	code := s.readCode(challenge.NotificationID)
+	verifyPath := fmt.Sprintf("/verifications/%d/verify", challenge.VerificationID)
+
+	for remaining := 2; remaining > 0; remaining-- {
+		w = testRequestWithToken(s.router, "POST", verifyPath, s.token, map[string]interface{}{"code": wrongCode(code)})
+		s.Require().Equal(http.StatusBadRequest, w.Code, w.Body.String())
+		s.Contains(w.Body.String(), fmt.Sprintf("attempts remaining: %d", remaining))
+	}
+
+	w = testRequestWithToken(s.router, "POST", verifyPath, s.token, map[string]interface{}{"code": wrongCode(code)})
+	s.Require().Equal(http.StatusBadRequest, w.Code, w.Body.String())
+	s.Contains(w.Body.String(), "transfer has been canceled")
+
+	transaction, err := repository.NewTransactionRepository(config.DB).FindByID(challenge.TransactionID)
+	s.Require().NoError(err)
+	s.Equal(model.TransactionStatusCanceled, transaction.Status)
+
+	// 取消後即使輸入正確的驗證碼也不會轉帳
+	w = testRequestWithToken(s.router, "POST", verifyPath, s.token, map[string]interface{}{"code": code})
+	s.Equal(http.StatusBadRequest, w.Code, w.Body.String())
+
+	sourceAccount, err := repository.NewAccountRepository(config.DB).FindByID(source)
+	s.Require().NoError(err)
+	s.True(util.MustMoney("100.00").Equal(sourceAccount.Balance), sourceAccount.Balance.String())
+}
+
+// 重送驗證碼須等待冷卻時間，重送後舊的驗證碼失效
+func (s *VerificationTestSuite) TestResendCodeCooldown() {
+	accountIDs := s.openAccounts("Source", "Target")
+	source, target := accountIDs[0], accountIDs[1]
+
+	w := testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/deposit", source), s.token,
+		map[string]interface{}{"amount": "100.00"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	challenge := s.startTransfer(source, target, "40.00")
+	oldCode := s.readCode(challenge.NotificationID)
+	resendPath := fmt.Sprintf("/verifications/%d/resend", challenge.VerificationID)
+
+	w = testRequestWithToken(s.router, "POST", resendPath, s.token, nil)
+	s.Require().Equal(http.StatusTooManyRequests, w.Code, w.Body.String())
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	s.Require().NoError(err)
+	s.Greater(retryAfter, 0)
+	s.LessOrEqual(retryAfter, int(service.VerificationResendCooldown.Seconds()))
+
+	// 冷卻時間過後即可重送
+	s.Require().NoError(config.DB.Model(&model.TransactionVerification{}).
+		Where("id = ?", challenge.VerificationID).
+		Update("last_sent_at", time.Now().Add(-service.VerificationResendCooldown)).Error)
+
+	w = testRequestWithToken(s.router, "POST", resendPath, s.token, nil)
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var resent transferChallenge
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resent))
+	s.NotEqual(challenge.NotificationID, resent.NotificationID)
+	newCode := s.readCode(resent.NotificationID)
+
+	// 剛重送過，又要重新等待
+	w = testRequestWithToken(s.router, "POST", resendPath, s.token, nil)
+	s.Equal(http.StatusTooManyRequests, w.Code, w.Body.String())
+
+	verifyPath := fmt.Sprintf("/verifications/%d/verify", challenge.VerificationID)
+	if oldCode != newCode {
+		w = testRequestWithToken(s.router, "POST", verifyPath, s.token, map[string]interface{}{"code": oldCode})
+		s.Equal(http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	w = testRequestWithToken(s.router, "POST", verifyPath, s.token, map[string]interface{}{"code": newCode})
+	s.Equal(http.StatusOK, w.Code, w.Body.String())
+}
+
+// 以收件匣中的重設連結設定新密碼，連結只能使用一次
+func (s *VerificationTestSuite) TestPasswordResetWithLinkFromInbox() {
+	w := testRequest(s.router, "POST", "/users/password/forgot", map[string]interface{}{"email": verificationTestEmail})
+	s.Require().Equal(http.StatusAccepted, w.Code, w.Body.String())
+
+	message := s.waitForMessage(verificationTestEmail, func(message service.CapturedMessage) bool {
+		return message.TemplateID == service.TemplatePasswordReset
+	})
+	resetURL, err := url.Parse(message.Variables["ResetURL"])
+	s.Require().NoError(err)
+	resetToken := resetURL.Query().Get("token")
+	s.Require().NotEmpty(resetToken)
+
+	w = testRequest(s.router, "POST", "/users/password/reset", map[string]interface{}{
+		"token":        resetToken,
+		"new_password": "Reset789!@#",
+	})
+	s.Require().Equal(http.StatusNoContent, w.Code, w.Body.String())
+
+	// 重設後原本的 token 失效
+	w = testRequestWithToken(s.router, "GET", "/accounts", s.token, nil)
+	s.Equal(http.StatusUnauthorized, w.Code)
+	s.NotEmpty(getAuthToken(s.router, verificationTestEmail, "Reset789!@#"))
+
+	w = testRequest(s.router, "POST", "/users/password/reset", map[string]interface{}{
+		"token":        resetToken,
+		"new_password": "Another789!@#",
+	})
+	s.Equal(http.StatusBadRequest, w.Code)
+}
+
+// 註冊後須以收件匣中的連結驗證電子郵件，驗證前無法開立帳戶
+func (s *VerificationTestSuite) TestEmailVerificationWithLinkFromInbox() {
+	const email = "test-signup@example.com"
+	w := testRequest(s.router, "POST", "/users/register", map[string]interface{}{
+		"email":    email,
+		"password": "Test123!@#",
+		"name":     "Test User",
+	})
+	s.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	s.Contains(w.Body.String(), `"status":"pending_verification"`)
+
+	token := getAuthToken(s.router, email, "Test123!@#")
+	s.Require().NotEmpty(token)
+
+	w = testRequestWithToken(s.router, "POST", "/accounts", token, map[string]interface{}{"name": "Savings"})
+	s.Equal(http.StatusForbidden, w.Code)
+
+	message := s.waitForMessage(email, func(message service.CapturedMessage) bool {
+		return message.TemplateID == service.TemplateEmailVerification
+	})
+	verifyURL, err := url.Parse(message.Variables["VerifyURL"])
+	s.Require().NoError(err)
+	verifyToken := verifyURL.Query().Get("token")
+	s.Require().NotEmpty(verifyToken)
+
+	// 竄改過的連結無效
+	w = testRequest(s.router, "POST", "/users/email/verify", map[string]interface{}{"token": verifyToken + "0"})
+	s.Equal(http.StatusBadRequest, w.Code)
+
+	w = testRequest(s.router, "POST", "/users/email/verify", map[string]interface{}{"token": verifyToken})
+	s.Require().Equal(http.StatusNoContent, w.Code, w.Body.String())
+
+	// 驗證後即可開立帳戶，預設帳戶也已建立
+	w = testRequestWithToken(s.router, "POST", "/accounts", token, map[string]interface{}{"name": "Savings"})
+	s.Equal(http.StatusCreated, w.Code, w.Body.String())
+
+	w = testRequestWithToken(s.router, "GET", "/accounts", token, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var accounts []map[string]interface{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &accounts))
+	s.Len(accounts, 2)
+
+	// 電子郵件聯絡方式也已標記為已驗證
+	w = testRequestWithToken(s.router, "GET", "/users/me/contacts", token, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	var contacts dto.ContactListResponse
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &contacts))
+	s.Require().Len(contacts.Contacts, 1)
+	s.Equal("email", contacts.Contacts[0].Channel)
+	s.True(contacts.Contacts[0].Verified)
+
+	// 重複使用連結不會再開立預設帳戶
+	w = testRequest(s.router, "POST", "/users/email/verify", map[string]interface{}{"token": verifyToken})
+	s.Equal(http.StatusNoContent, w.Code, w.Body.String())
+	w = testRequestWithToken(s.router, "GET", "/accounts", token, nil)
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &accounts))
+	s.Len(accounts, 2)
+
+	w = testRequestWithToken(s.router, "POST", "/users/me/email/verify/resend", token, nil)
+	s.Equal(http.StatusConflict, w.Code)
+}
+
+// 手機號碼須以簡訊驗證碼確認後才會收到簡訊，變更號碼後須重新確認
+func (s *VerificationTestSuite) TestPhoneConfirmedWithCodeFromInbox() {
+	const phone = "+886900000001"
+	var user model.User
+	s.Require().NoError(config.DB.Where("email = ?", verificationTestEmail).First(&user).Error)
+	profilePath := fmt.Sprintf("/users/%d", user.ID)
+
+	w := testRequestWithToken(s.router, "PUT", profilePath, s.token, map[string]interface{}{"phone": phone})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// 確認前不能把簡訊設為偏好管道，也不會寄出簡訊驗證碼
+	w = testRequestWithToken(s.router, "PUT", "/users/me/contacts/preferred", s.token, map[string]interface{}{"channel": "sms"})
+	s.Equal(http.StatusBadRequest, w.Code, w.Body.String())
+
+	accountIDs := s.openAccounts("Source", "Target")
+	w = testRequestWithToken(s.router, "POST", fmt.Sprintf("/accounts/%d/transfer/init", accountIDs[0]), s.token,
+		map[string]interface{}{"amount": "1.00", "target_account_id": accountIDs[1]})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var initiated struct {
+		TransactionID uint `json:"transaction_id"`
+	}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &initiated))
+	w = testRequestWithToken(s.router, "POST", "/verifications", s.token,
+		map[string]interface{}{"transaction_id": initiated.TransactionID, "type": "sms"})
+	s.Equal(http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = testRequestWithToken(s.router, "POST", "/users/me/contacts/sms/verify", s.token, nil)
+	s.Require().Equal(http.StatusAccepted, w.Code, w.Body.String())
+	message := s.waitForMessage(phone, func(message service.CapturedMessage) bool {
+		return message.TemplateID == service.TemplateContactVerification
+	})
+	code := message.Variables["Code"]
+	s.Require().NotEmpty(code)
+
+	w = testRequestWithToken(s.router, "POST", "/users/me/contacts/sms/verify", s.token, nil)
+	s.Equal(http.StatusTooManyRequests, w.Code, w.Body.String())
+
+	w = testRequestWithToken(s.router, "POST", "/users/me/contacts/sms/confirm", s.token, map[string]interface{}{"code": code})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var contacts dto.ContactListResponse
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &contacts))
+	for _, contact := range contacts.Contacts {
+		s.True(contact.Verified, contact.Channel)
+	}
+
+	// 確認後即可使用簡訊驗證
+	w = testRequestWithToken(s.router, "PUT", "/users/me/contacts/preferred", s.token, map[string]interface{}{"channel": "sms"})
+	s.Equal(http.StatusOK, w.Code, w.Body.String())
+	w = testRequestWithToken(s.router, "POST", "/verifications", s.token,
+		map[string]interface{}{"transaction_id": initiated.TransactionID, "type": "sms"})
+	s.Equal(http.StatusOK, w.Code, w.Body.String())
+
+	// 變更號碼後須重新確認，驗證碼改寄到電子郵件
+	w = testRequestWithToken(s.router, "PUT", profilePath, s.token, map[string]interface{}{"phone": "+886900000002"})
+	s.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	w = testRequestWithToken(s.router, "GET", "/users/me/contacts", s.token, nil)
+	s.Require().Equal(http.StatusOK, w.Code)
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &contacts))
+	for _, contact := range contacts.Contacts {
+		s.Equal(contact.Channel == "email", contact.Verified, contact.Channel)
+	}
+
+	challenge := s.startTransfer(accountIDs[0], accountIDs[1], "1.00")
+	s.NotEmpty(s.readCode(challenge.NotificationID))
+}